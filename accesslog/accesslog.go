@@ -0,0 +1,215 @@
+// Package accesslog writes HTTP access logs in the Common Log Format
+// and the Combined Log Format used by Apache and nginx.
+//
+// [Middleware] logs a record for each request with the attributes
+// named by the Key constants. The formatters returned by
+// [NewCommonFormatter] and [NewCombinedFormatter] render those records
+// for a [general.Handler]:
+//
+//	h := general.New(w, accesslog.NewCombinedFormatter)
+//	http.ListenAndServe(addr, accesslog.Middleware(slog.New(h), mux))
+package accesslog
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/internal/response"
+)
+
+// Keys for the attributes of an access log record.
+const (
+	RemoteAddrKey = "remote_addr"
+	UserKey       = "user"
+	MethodKey     = "method"
+	URIKey        = "uri"
+	ProtoKey      = "proto"
+	StatusKey     = "status"
+	BytesKey      = "bytes"
+	RefererKey    = "referer"
+	UserAgentKey  = "user_agent"
+)
+
+// RequestAttrs returns the access log attributes for r,
+// which was answered with the given status and number of body bytes.
+func RequestAttrs(r *http.Request, status int, bytes int64) []slog.Attr {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	var user string
+	if r.URL.User != nil {
+		user = r.URL.User.Username()
+	} else if u, _, ok := r.BasicAuth(); ok {
+		user = u
+	}
+	return []slog.Attr{
+		slog.String(RemoteAddrKey, host),
+		slog.String(UserKey, user),
+		slog.String(MethodKey, r.Method),
+		slog.String(URIKey, r.RequestURI),
+		slog.String(ProtoKey, r.Proto),
+		slog.Int(StatusKey, status),
+		slog.Int64(BytesKey, bytes),
+		slog.String(RefererKey, r.Referer()),
+		slog.String(UserAgentKey, r.UserAgent()),
+	}
+}
+
+// Middleware returns an http.Handler that calls next, then logs
+// the request to l at [slog.LevelInfo] with the attributes from
+// [RequestAttrs]. The record's time is the time the request started.
+func Middleware(l *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := response.NewWriter(w)
+		next.ServeHTTP(rw, r)
+		ctx := r.Context()
+		if !l.Enabled(ctx, slog.LevelInfo) {
+			return
+		}
+		rec := slog.NewRecord(start, slog.LevelInfo, "", 0)
+		rec.AddAttrs(RequestAttrs(r, rw.Status(), rw.Bytes())...)
+		_ = l.Handler().Handle(ctx, rec)
+	})
+}
+
+////////////////////////////////////////////////////////////////
+
+// NewCommonFormatter returns a Formatter that writes each record
+// in the Common Log Format:
+//
+//	host ident authuser [02/Jan/2006:15:04:05 -0700] "request line" status bytes
+//
+// Values come from the top-level attributes named by the Key constants,
+// and the time from the record. Missing values are written as "-".
+// Other attributes, including those added with WithAttrs, are ignored.
+// Each line ends in a newline.
+func NewCommonFormatter() general.Formatter {
+	return &formatter{}
+}
+
+// NewCombinedFormatter returns a Formatter like the one returned by
+// [NewCommonFormatter] that also writes the referer and user agent,
+// in the Combined Log Format.
+func NewCombinedFormatter() general.Formatter {
+	return &formatter{combined: true}
+}
+
+type formatter struct {
+	combined bool
+	depth    int // group nesting
+	time     time.Time
+	fields   map[string]slog.Value
+}
+
+func (f *formatter) AppendBegin(buf []byte) []byte {
+	*f = formatter{combined: f.combined, fields: map[string]slog.Value{}}
+	return buf
+}
+
+func (f *formatter) AppendEnd(buf []byte) []byte {
+	buf = appendField(buf, f.fields[RemoteAddrKey])
+	buf = append(buf, " - "...)
+	buf = appendField(buf, f.fields[UserKey])
+	buf = append(buf, " ["...)
+	if f.time.IsZero() {
+		buf = append(buf, '-')
+	} else {
+		buf = f.time.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	}
+	buf = append(buf, "] \""...)
+	if m, ok := f.fields[MethodKey]; ok {
+		buf = appendEscaped(buf, m.String())
+		buf = append(buf, ' ')
+		buf = appendEscaped(buf, f.fields[URIKey].String())
+		if p, ok := f.fields[ProtoKey]; ok {
+			buf = append(buf, ' ')
+			buf = appendEscaped(buf, p.String())
+		}
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, "\" "...)
+	buf = appendField(buf, f.fields[StatusKey])
+	buf = append(buf, ' ')
+	if b, ok := f.fields[BytesKey]; ok && b.Kind() == slog.KindInt64 && b.Int64() == 0 {
+		buf = append(buf, '-')
+	} else {
+		buf = appendField(buf, b)
+	}
+	if f.combined {
+		buf = appendQuotedField(buf, f.fields[RefererKey])
+		buf = appendQuotedField(buf, f.fields[UserAgentKey])
+	}
+	return append(buf, '\n')
+}
+
+func (f *formatter) AppendOpenGroup(buf []byte, name string) []byte {
+	f.depth++
+	return buf
+}
+
+func (f *formatter) AppendCloseGroup(buf []byte, name string) []byte {
+	f.depth--
+	return buf
+}
+
+func (f *formatter) AppendSeparatorIfNeeded(buf []byte) []byte { return buf }
+
+func (f *formatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	if f.fields == nil || f.depth > 0 || len(openGroups) > 0 {
+		return buf
+	}
+	v := a.Value.Resolve()
+	if a.Key == slog.TimeKey && v.Kind() == slog.KindTime {
+		f.time = v.Time()
+	} else {
+		f.fields[a.Key] = v
+	}
+	return buf
+}
+
+// appendField appends v, or "-" if it is missing or empty.
+func appendField(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindAny:
+		if v.Any() == nil {
+			return append(buf, '-')
+		}
+	}
+	s := v.String()
+	if s == "" {
+		return append(buf, '-')
+	}
+	return appendEscaped(buf, s)
+}
+
+func appendQuotedField(buf []byte, v slog.Value) []byte {
+	buf = append(buf, " \""...)
+	buf = appendField(buf, v)
+	return append(buf, '"')
+}
+
+// appendEscaped appends s, escaping quotes, backslashes and
+// non-printable bytes as Apache does.
+func appendEscaped(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b == '"' || b == '\\':
+			buf = append(buf, '\\', b)
+		case b < 0x20 || b >= 0x7f:
+			buf = append(buf, '\\', 'x', hex[b>>4], hex[b&0xf])
+		default:
+			buf = append(buf, b)
+		}
+	}
+	return buf
+}
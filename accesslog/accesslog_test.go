@@ -0,0 +1,87 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers/general"
+)
+
+func TestFormatters(t *testing.T) {
+	tm := time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	r := slog.NewRecord(tm, slog.LevelInfo, "", 0)
+	r.AddAttrs(
+		slog.String(RemoteAddrKey, "127.0.0.1"),
+		slog.String(UserKey, "frank"),
+		slog.String(MethodKey, "GET"),
+		slog.String(URIKey, `/a "b"`),
+		slog.String(ProtoKey, "HTTP/1.0"),
+		slog.Int(StatusKey, 200),
+		slog.Int(BytesKey, 2326),
+		slog.String(RefererKey, "http://example.com/"),
+		slog.String(UserAgentKey, "Mozilla/4.08"),
+		slog.Group("g", slog.String(StatusKey, "ignored")),
+	)
+	for _, test := range []struct {
+		newFormatter func() general.Formatter
+		want         string
+	}{
+		{
+			NewCommonFormatter,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a \"b\" HTTP/1.0" 200 2326` + "\n",
+		},
+		{
+			NewCombinedFormatter,
+			`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a \"b\" HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"` + "\n",
+		},
+	} {
+		var buf bytes.Buffer
+		h := general.New(&buf, test.newFormatter)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("\ngot  %s\nwant %s", got, test.want)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	var buf bytes.Buffer
+	h := general.New(&buf, NewCombinedFormatter)
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+	r.AddAttrs(slog.Int(BytesKey, 0))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `- - - [-] "-" - - "-" "-"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(general.New(&buf, NewCombinedFormatter))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	})
+	req := httptest.NewRequest("POST", "/tea?x=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.SetBasicAuth("pat", "secret")
+	req.Header.Set("User-Agent", "test")
+	Middleware(l, next).ServeHTTP(httptest.NewRecorder(), req)
+
+	want := regexp.MustCompile(`^10\.0\.0\.1 - pat \[\d\d/\w\w\w/\d{4}:\d\d:\d\d:\d\d [-+]\d{4}\] "POST /tea\?x=1 HTTP/1\.1" 418 15 "-" "test"\n$`)
+	if got := buf.String(); !want.MatchString(got) {
+		t.Errorf("got %q, want match for %s", got, want)
+	}
+}
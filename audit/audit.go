@@ -0,0 +1,182 @@
+// Package audit writes tamper-evident logs.
+//
+// A [Writer] adds a sequence number and a hash chain to each JSON object
+// written to it. Each record ends with the fields
+//
+//	"seq":N,"prev":"P","hash":"H"
+//
+// where N counts records from 1, P is the hash of the previous record
+// (empty for the first), and H is the hex-encoded SHA-256 hash, or
+// HMAC-SHA256 if a key is given, of the record's line up to but not
+// including the hash field. Because each hash covers the previous one,
+// changing, removing or reordering a record breaks the chain from that
+// point on, and [Options.Verify] reports it. With a secret key, the chain
+// can't be recomputed by someone who alters the log.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// Keys of the fields added to each record.
+const (
+	SeqKey  = "seq"
+	PrevKey = "prev"
+	HashKey = "hash"
+)
+
+// Options describe a hash chain.
+type Options struct {
+	// Key, if non-empty, is the key for HMAC-SHA256.
+	// Otherwise, plain SHA-256 is used.
+	Key []byte
+
+	// Seq and Prev are the sequence number and hash of the last record
+	// before the first one to be written or verified. They are zero for
+	// a new log. To continue a log, use the Options returned by Verify.
+	Seq  uint64
+	Prev string
+}
+
+// A Writer adds a sequence number and a hash chain to each record written
+// to it. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	h    hash.Hash
+	seq  uint64
+	prev string
+	buf  []byte
+}
+
+// NewWriter returns a Writer that writes to w.
+func (opts Options) NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, h: opts.newHash(), seq: opts.Seq, prev: opts.Prev}
+}
+
+// NewHandler returns a slog.JSONHandler that writes to a Writer on w.
+func (opts Options) NewHandler(w io.Writer, hopts *slog.HandlerOptions) slog.Handler {
+	return slog.NewJSONHandler(opts.NewWriter(w), hopts)
+}
+
+func (opts Options) newHash() hash.Hash {
+	if len(opts.Key) > 0 {
+		return hmac.New(sha256.New, opts.Key)
+	}
+	return sha256.New()
+}
+
+// Write writes p, which must be a single JSON object, optionally followed
+// by a newline, with the chain fields added. It writes a line ending in
+// a newline.
+func (w *Writer) Write(p []byte) (int, error) {
+	obj := bytes.TrimSuffix(p, []byte{'\n'})
+	if len(obj) < 2 || obj[0] != '{' || obj[len(obj)-1] != '}' {
+		return 0, errors.New("audit: record is not a JSON object")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seq := w.seq + 1
+	buf := append(w.buf[:0], obj[:len(obj)-1]...)
+	if len(obj) > 2 {
+		buf = append(buf, ',')
+	}
+	buf = fmt.Appendf(buf, `"%s":%d,"%s":%q`, SeqKey, seq, PrevKey, w.prev)
+	sum := w.sum(buf)
+	buf = fmt.Appendf(buf, `,"%s":%q}`+"\n", HashKey, sum)
+	w.buf = buf
+	// Even if the write fails, the next record continues the chain from
+	// this one, so a verifier sees a gap rather than a forgery.
+	w.seq, w.prev = seq, sum
+	if _, err := w.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *Writer) sum(b []byte) string {
+	w.h.Reset()
+	w.h.Write(b)
+	return hex.EncodeToString(w.h.Sum(nil))
+}
+
+// A VerifyError describes a broken chain.
+type VerifyError struct {
+	Line   int // 1-based line number in the input
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit: line %d: %s", e.Line, e.Reason)
+}
+
+// Verify reads a log from r and checks that its records form a chain
+// that starts after opts.Seq and opts.Prev: that each has the next
+// sequence number, refers to the hash of the one before and has the
+// correct hash. It returns the Options to continue the chain with, and
+// a *VerifyError for the first record that fails.
+//
+// Records removed from the end of a log leave no trace in it. To detect
+// that, compare the returned sequence number with one kept elsewhere.
+func (opts Options) Verify(r io.Reader) (Options, error) {
+	w := opts.NewWriter(io.Discard)
+	hashField := []byte(`,"` + HashKey + `":"`)
+	seqField := []byte(`"` + SeqKey + `":`)
+	prevField := []byte(`,"` + PrevKey + `":`)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<30)
+	line := 0
+	for s.Scan() {
+		line++
+		fail := func(format string, args ...any) (Options, error) {
+			return Options{Key: opts.Key, Seq: w.seq, Prev: w.prev}, &VerifyError{line, fmt.Sprintf(format, args...)}
+		}
+		b := s.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		i := bytes.LastIndex(b, hashField)
+		if i < 0 || !bytes.HasSuffix(b, []byte(`"}`)) {
+			return fail("missing %s", HashKey)
+		}
+		content, gotHash := b[:i], string(b[i+len(hashField):len(b)-2])
+		j := bytes.LastIndex(content, seqField)
+		k := bytes.LastIndex(content, prevField)
+		if j < 0 || k < j {
+			return fail("missing %s or %s", SeqKey, PrevKey)
+		}
+		seq, err := strconv.ParseUint(string(content[j+len(seqField):k]), 10, 64)
+		if err != nil {
+			return fail("bad %s: %v", SeqKey, err)
+		}
+		prev, err := strconv.Unquote(string(content[k+len(prevField):]))
+		if err != nil {
+			return fail("bad %s: %v", PrevKey, err)
+		}
+		if seq != w.seq+1 {
+			return fail("got %s %d, want %d", SeqKey, seq, w.seq+1)
+		}
+		if prev != w.prev {
+			return fail("%s does not match the hash of the previous record", PrevKey)
+		}
+		if sum := w.sum(content); !hmac.Equal([]byte(sum), []byte(gotHash)) {
+			return fail("%s does not match the record", HashKey)
+		}
+		w.seq, w.prev = seq, gotHash
+	}
+	if err := s.Err(); err != nil {
+		return Options{Key: opts.Key, Seq: w.seq, Prev: w.prev}, err
+	}
+	return Options{Key: opts.Key, Seq: w.seq, Prev: w.prev}, nil
+}
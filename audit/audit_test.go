@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func writeLog(t *testing.T, opts Options, msgs ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	l := slog.New(opts.NewHandler(&buf, nil))
+	for _, m := range msgs {
+		l.Info(m, "a", 1)
+	}
+	return buf.String()
+}
+
+func TestChain(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("secret")} {
+		opts := Options{Key: key}
+		log := writeLog(t, opts, "one", "two", "three")
+		lines := strings.Split(strings.TrimSuffix(log, "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("got %d lines, want 3", len(lines))
+		}
+		var prev string
+		for i, line := range lines {
+			var m map[string]any
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m[SeqKey], float64(i+1); got != want {
+				t.Errorf("seq: got %v, want %v", got, want)
+			}
+			if got := m[PrevKey]; got != prev {
+				t.Errorf("prev: got %v, want %v", got, prev)
+			}
+			prev = m[HashKey].(string)
+		}
+
+		next, err := opts.Verify(strings.NewReader(log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.Seq != 3 || next.Prev != prev {
+			t.Errorf("got %+v, want seq 3 and prev %s", next, prev)
+		}
+
+		// Continue the log.
+		log2 := writeLog(t, next, "four")
+		if _, err := opts.Verify(strings.NewReader(log + log2)); err != nil {
+			t.Errorf("continued log: %v", err)
+		}
+		if _, err := next.Verify(strings.NewReader(log2)); err != nil {
+			t.Errorf("second part: %v", err)
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	opts := Options{Key: []byte("k")}
+	log := writeLog(t, opts, "one", "two", "three")
+	lines := strings.SplitAfter(log, "\n")[:3]
+	for _, test := range []struct {
+		name string
+		log  string
+		line int
+		want string
+	}{
+		{"altered", lines[0] + strings.Replace(lines[1], "two", "TWO", 1) + lines[2], 2, "hash does not match"},
+		{"removed", lines[0] + lines[2], 2, "got seq 3, want 2"},
+		{"reordered", lines[1] + lines[0], 1, "got seq 2, want 1"},
+		{"wrong key", log, 1, "hash does not match"},
+		{"truncated", lines[0] + lines[1][:20] + "\n", 2, "missing hash"},
+	} {
+		vopts := opts
+		if test.name == "wrong key" {
+			vopts.Key = []byte("other")
+		}
+		next, err := vopts.Verify(strings.NewReader(test.log))
+		var ve *VerifyError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: got %v, want a VerifyError", test.name, err)
+			continue
+		}
+		if ve.Line != test.line || !strings.Contains(ve.Reason, test.want) {
+			t.Errorf("%s: got %v, want line %d containing %q", test.name, err, test.line, test.want)
+		}
+		if next.Seq != uint64(test.line-1) {
+			t.Errorf("%s: got seq %d, want %d", test.name, next.Seq, test.line-1)
+		}
+	}
+}
+
+func TestWriteNonObject(t *testing.T) {
+	w := Options{}.NewWriter(&bytes.Buffer{})
+	if _, err := w.Write([]byte("x=1\n")); err == nil {
+		t.Error("got nil, want error")
+	}
+	var buf bytes.Buffer
+	w = Options{}.NewWriter(&buf)
+	if _, err := w.Write([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"seq":1,"prev":"","hash":"`) {
+		t.Errorf("got %q", buf.String())
+	}
+}
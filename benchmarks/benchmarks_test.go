@@ -0,0 +1,139 @@
+package benchmarks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers"
+	"github.com/jba/slog/handlers/discard"
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/handlers/loghandler"
+	"github.com/jba/slog/handlers/simple"
+)
+
+// handlerCases are the handlers under test. Each writes to io.Discard,
+// except the discard handler, which measures the overhead of the caller.
+var handlerCases = []struct {
+	name string
+	new  func() slog.Handler
+}{
+	{"discard", func() slog.Handler { return discard.New(nil) }},
+	{"slog.Text", func() slog.Handler { return slog.NewTextHandler(io.Discard, nil) }},
+	{"slog.JSON", func() slog.Handler { return slog.NewJSONHandler(io.Discard, nil) }},
+	{"loghandler", func() slog.Handler { return loghandler.New(io.Discard, nil) }},
+	{"general.Text", func() slog.Handler { return general.New(io.Discard, general.NewTextFormatter) }},
+	{"general.JSON", func() slog.Handler { return general.New(io.Discard, general.NewJSONFormatter) }},
+	{"simple", func() slog.Handler { return simple.Handler(discardRecord, slog.HandlerOptions{}) }},
+	{"binary", func() slog.Handler { return handlers.NewBinaryHandler(io.Discard, nil) }},
+}
+
+// discardRecord visits every attribute of r, as a real handle function
+// would, and then discards it.
+func discardRecord(r slog.Record) error {
+	r.Attrs(func(slog.Attr) bool { return true })
+	return nil
+}
+
+const testMessage = "Test logging, but use a somewhat realistic message length."
+
+var (
+	testTime     = time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	testString   = "7e3b3b2aaeff56a7108fe11e154200dd/7819479873059528190"
+	testInt      = 32768
+	testDuration = 23 * time.Second
+	testError    = io.ErrUnexpectedEOF
+)
+
+func fiveAttrs() []slog.Attr {
+	return []slog.Attr{
+		slog.String("string", testString),
+		slog.Int("status", testInt),
+		slog.Duration("duration", testDuration),
+		slog.Time("time", testTime),
+		slog.Any("error", testError),
+	}
+}
+
+// run calls f with a logger for each handler in handlerCases.
+// If with is non-nil, it is applied to the logger first.
+func run(b *testing.B, with func(*slog.Logger) *slog.Logger, f func(*slog.Logger)) {
+	for _, hc := range handlerCases {
+		b.Run(hc.name, func(b *testing.B) {
+			logger := slog.New(hc.new())
+			if with != nil {
+				logger = with(logger)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					f(logger)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkFiveAttrs(b *testing.B) {
+	attrs := fiveAttrs()
+	run(b, nil, func(l *slog.Logger) {
+		l.LogAttrs(context.Background(), slog.LevelInfo, testMessage, attrs...)
+	})
+}
+
+func BenchmarkGroups(b *testing.B) {
+	attrs := fiveAttrs()
+	run(b, nil, func(l *slog.Logger) {
+		l.LogAttrs(context.Background(), slog.LevelInfo, testMessage,
+			slog.Group("request", slog.String("method", "GET"), slog.Int("size", testInt)),
+			slog.Group("attrs", slog.Any("", attrs[:3]), slog.Group("inner", attrs[3], attrs[4])))
+	})
+}
+
+func BenchmarkWithChain(b *testing.B) {
+	with := func(l *slog.Logger) *slog.Logger {
+		return l.With("a", 1, "b", testString).
+			WithGroup("g").
+			With(slog.Duration("c", testDuration)).
+			WithGroup("h")
+	}
+	run(b, with, func(l *slog.Logger) {
+		l.LogAttrs(context.Background(), slog.LevelInfo, testMessage,
+			slog.Int("status", testInt), slog.Any("error", testError))
+	})
+}
+
+// deepWith applies With and WithGroup ten times each, as loggers passed
+// down through the layers of a request handler do.
+func deepWith(l *slog.Logger) *slog.Logger {
+	for i := 0; i < 10; i++ {
+		l = l.With("request_id", testString, "depth", i).WithGroup("layer")
+	}
+	return l
+}
+
+func BenchmarkDeepWithChain(b *testing.B) {
+	run(b, deepWith, func(l *slog.Logger) {
+		l.LogAttrs(context.Background(), slog.LevelInfo, testMessage,
+			slog.Int("status", testInt), slog.Any("error", testError))
+	})
+}
+
+// BenchmarkDeepWith measures building a deep chain for each record,
+// as a request-scoped logger is.
+func BenchmarkDeepWith(b *testing.B) {
+	run(b, nil, func(l *slog.Logger) {
+		deepWith(l).LogAttrs(context.Background(), slog.LevelInfo, testMessage,
+			slog.Int("status", testInt))
+	})
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	attrs := fiveAttrs()
+	run(b, nil, func(l *slog.Logger) {
+		l.LogAttrs(context.Background(), slog.LevelDebug, testMessage, attrs...)
+	})
+}
@@ -0,0 +1,9 @@
+// Package benchmarks compares the performance of the handlers in this
+// module with each other and with the handlers in log/slog.
+//
+// Run the benchmarks with
+//
+//	go test -bench . -benchmem ./benchmarks
+//
+// Benchmark names have the form Benchmark<Workload>/<handler>.
+package benchmarks
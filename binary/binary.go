@@ -2,19 +2,23 @@
 package binary
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log/slog"
 	"math"
+	"runtime"
+	"slices"
 	"sync"
 	"time"
 )
 
 type Encoder struct {
-	buf  []byte
+	buf  []byte // begins with space for the frame header
 	abuf [1024]byte
 	err  error
 }
@@ -24,12 +28,21 @@ var pool = sync.Pool{New: func() any { return new(Encoder) }}
 func GetEncoder() *Encoder {
 	e := pool.Get().(*Encoder)
 	e.err = nil
-	e.buf = e.abuf[:0]
+	e.buf = e.abuf[:headerSize]
 	return e
 }
 
 func PutEncoder(e *Encoder) { pool.Put(e) }
 
+// Grow makes room for at least n more bytes in e, so that encoding
+// that many bytes doesn't allocate. Use it with an estimate of the
+// size of a record, like the one from [slogsize.EstimateSize].
+//
+// [slogsize.EstimateSize]: https://pkg.go.dev/github.com/jba/slog/slogsize#EstimateSize
+func (e *Encoder) Grow(n int) {
+	e.buf = slices.Grow(e.buf, n)
+}
+
 func (e *Encoder) EncodeKey(key string) {
 	e.encodeString(key)
 }
@@ -70,22 +83,104 @@ func (e *Encoder) EncodeValue(v slog.Value) {
 	}
 }
 
-const magic uint32 = 0xBAFEDC01
+// EncodeRecord encodes the built-in fields of r followed by its attributes.
+//
+// The built-in fields are encoded as key-value pairs, in this order:
+//
+//   - the time, with key [slog.TimeKey], omitted if r.Time is zero
+//   - the level as an integer, with key [slog.LevelKey]
+//   - the message, with key [slog.MessageKey]
+//   - the source location, omitted if r.PC is zero
+//
+// The source location is a group of the fields of [slog.Source]. In
+// place of a key, it has a marker that distinguishes it from an Attr
+// with the same key; [Decode] reports it with the key [slog.SourceKey].
+// An Attr of r whose key is slog.SourceKey and whose value is a
+// *slog.Source, like the one made by [Query], is encoded the same way.
+func (e *Encoder) EncodeRecord(r slog.Record) {
+	if !r.Time.IsZero() {
+		e.EncodeKey(slog.TimeKey)
+		e.encodeTime(r.Time)
+	}
+	e.EncodeKey(slog.LevelKey)
+	e.encodeInt(int64(r.Level))
+	e.EncodeKey(slog.MessageKey)
+	e.encodeString(r.Message)
+	if r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		e.encodeSource(&slog.Source{Function: f.Function, File: f.File, Line: f.Line})
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == slog.SourceKey && a.Value.Kind() == slog.KindAny {
+			if src, ok := a.Value.Any().(*slog.Source); ok {
+				e.encodeSource(src)
+				return true
+			}
+		}
+		e.EncodeKey(a.Key)
+		e.EncodeValue(a.Value)
+		return true
+	})
+}
+
+// encodeSource encodes src as a record's source location.
+func (e *Encoder) encodeSource(src *slog.Source) {
+	e.encodeOp(opSource)
+	e.EncodeValue(slog.GroupValue(
+		slog.String("function", src.Function),
+		slog.String("file", src.File),
+		slog.Int("line", src.Line)))
+}
 
-func (e *Encoder) WriteTo(w io.Writer) (int, error) {
+// Each encoded record is written as a frame consisting of a header
+// followed by the encoded bytes. The header holds, in order:
+//
+//   - the magic number, as a little-endian uint32
+//   - the format version, a single byte
+//   - the length of the encoded bytes, as a little-endian uint32
+//   - the CRC-32 (IEEE) checksum of the encoded bytes, as a little-endian uint32
+const (
+	magic      uint32 = 0xBAFEDC01
+	version    byte   = 1
+	headerSize        = 13
+)
+
+// WriteTo writes the encoded bytes to w as a single frame.
+func (e *Encoder) WriteTo(w io.Writer) (int64, error) {
 	if e.err != nil {
 		return 0, e.err
 	}
-	if len(e.buf) > math.MaxUint32 {
+	if len(e.buf)-headerSize > math.MaxUint32 {
 		return 0, errors.New("buffer too big")
 	}
-	var header [8]byte
+	// Write the frame with a single call, so that writers that
+	// treat each Write as a unit, like compress.Writer, see
+	// the whole frame.
+	putHeader(e.buf[:headerSize], e.buf[headerSize:])
+	n, err := w.Write(e.buf)
+	return int64(n), err
+}
+
+// AppendFrame appends data to buf as a frame, with the same header as
+// the frames of [Encoder.WriteTo], and returns the extended buffer.
+// Use it to store other data, like records formatted as text, in the
+// frame format, so that a [StreamDecoder] can read it back with
+// [StreamDecoder.NextFrame], skipping any corrupt parts.
+func AppendFrame(buf, data []byte) []byte {
+	n := len(buf)
+	var header [headerSize]byte
+	buf = append(buf, header[:]...)
+	buf = append(buf, data...)
+	putHeader(buf[n:n+headerSize], buf[n+headerSize:])
+	return buf
+}
+
+func putHeader(header, data []byte) {
 	binary.LittleEndian.PutUint32(header[0:4], magic)
-	binary.LittleEndian.PutUint32(header[4:], uint32(len(e.buf)))
-	if n, err := w.Write(header[:]); err != nil {
-		return n, err
-	}
-	return w.Write(e.buf)
+	header[4] = version
+	binary.LittleEndian.PutUint32(header[5:9], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[9:13], crc32.ChecksumIEEE(data))
 }
 
 const smallIntEnd = 200
@@ -103,6 +198,7 @@ const (
 	opDuration
 	opTime
 	opList
+	opSource // in place of a key, marks the source location of a record
 )
 
 func (e *Encoder) encodeOp(o op) {
@@ -186,75 +282,245 @@ type DecodeVisitor interface {
 	Group(n int)
 }
 
+// Decode reads a frame from r and calls the methods of v for each
+// key-value pair in it. A group is reported by a call to v.Group with
+// the number of pairs in the group, followed by the pairs.
+//
+// Decode skips frames whose checksum does not match their contents.
+// If the last frame in r is incomplete, Decode returns [io.ErrUnexpectedEOF].
 func Decode(r io.Reader, v DecodeVisitor) error {
-	buf, err := readHeader(r)
-	if err != nil {
-		return err
+	var buf []byte
+	for {
+		var err error
+		buf, err = readFrame(r)
+		if err == nil {
+			break
+		}
+		if err != errChecksum {
+			return err
+		}
 	}
+	return decodeFrame(buf, v)
+}
+
+// decodeFrame calls the methods of v for each key-value pair in buf,
+// the contents of a frame.
+func decodeFrame(buf []byte, v DecodeVisitor) error {
 	for len(buf) > 0 {
-		// Decode key.
-		if buf[0] != byte(opString) {
-			return errors.New("key is not a string")
+		var err error
+		buf, err = decodePair(buf, v, 0)
+		if err != nil {
+			return err
 		}
-		key, buf := decodeString(buf[1:])
-		// Decode value.
-		b, buf := buf[0], buf[1:]
-		if b < smallIntEnd {
-			v.Int(key, int64(b))
+	}
+	return nil
+}
+
+var errTruncated = errors.New("binary: truncated value")
+
+// maxGroupDepth is the deepest nesting of groups that the decoders
+// accept. Without a limit, a small input could nest deeply enough to
+// overflow the stack.
+const maxGroupDepth = 100
+
+var errTooDeep = fmt.Errorf("binary: groups nested more than %d deep", maxGroupDepth)
+
+// A groupVisitor is a DecodeVisitor that is also told what
+// the methods of DecodeVisitor don't say.
+type groupVisitor interface {
+	// groupKey is called with the key of a group before Group.
+	groupKey(key []byte)
+	// source is called before the value of an encoded source location,
+	// whose key is sourceKey.
+	source()
+}
+
+// decodePair decodes a key-value pair from the start of buf,
+// and returns the remainder of buf. The pair is in depth groups.
+func decodePair(buf []byte, v DecodeVisitor, depth int) ([]byte, error) {
+	if len(buf) > 0 && buf[0] == byte(opSource) {
+		if gv, ok := v.(groupVisitor); ok {
+			gv.source()
+		}
+		return decodeValue(sourceKey, buf[1:], v, depth)
+	}
+	if len(buf) == 0 || buf[0] != byte(opString) {
+		return nil, errors.New("binary: key is not a string")
+	}
+	key, buf, err := decodeString(buf[1:])
+	if err != nil {
+		return nil, err
+	}
+	return decodeValue(key, buf, v, depth)
+}
+
+var sourceKey = []byte(slog.SourceKey)
+
+// decodeValue decodes a value from the start of buf, and returns
+// the remainder of buf. The value is in depth groups.
+func decodeValue(key, buf []byte, v DecodeVisitor, depth int) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errTruncated
+	}
+	if buf[0] < smallIntEnd {
+		v.Int(key, int64(buf[0]))
+		return buf[1:], nil
+	}
+	o, buf := op(buf[0]), buf[1:]
+	switch o {
+	case opInt:
+		i, n := binary.Varint(buf)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		v.Int(key, i)
+		return buf[n:], nil
+	case opUint:
+		u, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		v.Uint(key, u)
+		return buf[n:], nil
+	case opFloat:
+		if len(buf) < 8 {
+			return nil, errTruncated
+		}
+		v.Float(key, math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+		return buf[8:], nil
+	case opTrue:
+		v.Bool(key, true)
+		return buf, nil
+	case opFalse:
+		v.Bool(key, false)
+		return buf, nil
+	case opString, opBytes:
+		s, buf, err := decodeString(buf)
+		if err != nil {
+			return nil, err
+		}
+		if o == opString {
+			v.String(key, s)
 		} else {
-			switch op(b) {
-			case opInt:
-				i, n := binary.Varint(buf)
-				v.Int(key, i)
-				buf = buf[n:]
-			case opUint:
-				u, n := binary.Uvarint(buf)
-				v.Uint(key, u)
-				buf = buf[n:]
-			case opFloat:
-				u := binary.LittleEndian.Uint64(buf)
-				v.Float(key, math.Float64frombits(u))
-				buf = buf[8:]
-			case opTrue:
-				v.Bool(key, true)
-			case opFalse:
-				v.Bool(key, false)
-			case opString:
-				l, n := binary.Varint(buf)
-				buf = buf[n:]
-				v.String(key, buf[:l])
-				buf = buf[l:]
-			default:
-				panic(fmt.Sprintf("unknown op %v", op(b)))
+			v.Bytes(key, s)
+		}
+		return buf, nil
+	case opDuration:
+		d, buf, err := decodeInt(buf)
+		if err != nil {
+			return nil, err
+		}
+		v.Duration(key, time.Duration(d))
+		return buf, nil
+	case opTime:
+		// See time.Time.MarshalBinary.
+		if len(buf) == 0 {
+			return nil, errTruncated
+		}
+		n := 15
+		if buf[0] == 2 {
+			n = 16
+		}
+		if len(buf) < n {
+			return nil, errTruncated
+		}
+		var t time.Time
+		if err := t.UnmarshalBinary(buf[:n]); err != nil {
+			return nil, err
+		}
+		v.Time(key, t)
+		return buf[n:], nil
+	case opList:
+		n, buf, err := decodeInt(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Each pair takes at least two bytes, so a larger count
+		// than that cannot be right.
+		if n < 0 || n%2 != 0 || n > int64(len(buf)) {
+			return nil, fmt.Errorf("binary: bad list length %d", n)
+		}
+		if depth >= maxGroupDepth {
+			return nil, errTooDeep
+		}
+		if gv, ok := v.(groupVisitor); ok {
+			gv.groupKey(key)
+		}
+		v.Group(int(n / 2))
+		for i := int64(0); i < n/2; i++ {
+			buf, err = decodePair(buf, v, depth+1)
+			if err != nil {
+				return nil, err
 			}
 		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("binary: unknown op %d", o)
 	}
-	return nil
 }
 
-// opBytes
-// opDuration
-// opTime
-// opList
+// decodeInt decodes an integer written by Encoder.encodeInt.
+func decodeInt(buf []byte) (int64, []byte, error) {
+	if len(buf) == 0 {
+		return 0, nil, errTruncated
+	}
+	if buf[0] < smallIntEnd {
+		return int64(buf[0]), buf[1:], nil
+	}
+	if op(buf[0]) != opInt {
+		return 0, nil, errors.New("binary: expected int")
+	}
+	i, n := binary.Varint(buf[1:])
+	if n <= 0 {
+		return 0, nil, errTruncated
+	}
+	return i, buf[1+n:], nil
+}
 
-func decodeString(buf []byte) (str, newbuf []byte) {
-	l, n := binary.Varint(buf)
-	len := int(l)
-	return buf[n : n+len], buf[n+len:]
+func decodeString(buf []byte) (str, newbuf []byte, err error) {
+	l, buf, err := decodeInt(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l < 0 || l > int64(len(buf)) {
+		return nil, nil, errTruncated
+	}
+	return buf[:l], buf[l:], nil
 }
 
-func readHeader(r io.Reader) ([]byte, error) {
-	var header [8]byte
+var errChecksum = errors.New("binary: checksum mismatch")
+
+// readFrame reads a frame from r and returns its contents.
+// If the frame's checksum is wrong, it returns errChecksum
+// after consuming the frame.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [headerSize]byte
 	if _, err := io.ReadFull(r, header[:]); err != nil {
 		return nil, err
 	}
 	if m := binary.LittleEndian.Uint32(header[0:4]); m != magic {
-		return nil, fmt.Errorf("got magic %x, want %x", m, magic)
+		return nil, fmt.Errorf("binary: got magic %x, want %x", m, magic)
 	}
-	length := binary.LittleEndian.Uint32(header[4:])
-	buf := make([]byte, length) // TODO: pool
-	if _, err := io.ReadFull(r, buf); err != nil {
+	if header[4] != version {
+		return nil, fmt.Errorf("binary: unsupported version %d", header[4])
+	}
+	length := binary.LittleEndian.Uint32(header[5:9])
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("binary: frame length %d is too large", length)
+	}
+	// Don't trust the length for the initial allocation, in case
+	// the stream is garbage or truncated.
+	var b bytes.Buffer
+	b.Grow(int(min(length, 64<<10)))
+	if _, err := io.CopyN(&b, r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
+	buf := b.Bytes()
+	if crc32.ChecksumIEEE(buf) != binary.LittleEndian.Uint32(header[9:13]) {
+		return nil, errChecksum
+	}
 	return buf, nil
 }
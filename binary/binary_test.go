@@ -0,0 +1,204 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 4, time.UTC)
+	var buf bytes.Buffer
+	writeFrame(t, &buf,
+		slog.Int("a", 1),
+		slog.Int("big", -300),
+		slog.Uint64("u", 7),
+		slog.Float64("f", 2.5),
+		slog.Bool("t", true),
+		slog.Bool("f", false),
+		slog.String("s", "str"),
+		slog.Duration("d", time.Second),
+		slog.Time("tm", tm),
+		slog.Any("l", slog.LevelWarn),
+		slog.Group("g", slog.Int("x", 1), slog.String("y", "z")),
+	)
+	got := decode(t, &buf)
+	want := "a=1 big=-300 u=7 f=2.5 t=true f=false s=str d=1s tm=2023-04-03T01:02:03.000000004Z l=bytes:WARN group(2) x=1 y=z"
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestEncodeRecord(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+	r := slog.NewRecord(tm, slog.LevelDebug, "hello", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	e := GetEncoder()
+	defer PutEncoder(e)
+	e.EncodeRecord(r)
+	var buf bytes.Buffer
+	if _, err := e.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := decode(t, &buf)
+	want := "time=2023-04-03T01:02:03Z level=-4 msg=hello a=1"
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	long := strings.Repeat("x", 4000)
+	e := GetEncoder()
+	defer PutEncoder(e)
+	e.Grow(len(long) + 100)
+	got := testing.AllocsPerRun(1, func() {
+		e.buf = e.buf[:headerSize]
+		e.EncodeKey("k")
+		e.EncodeValue(slog.StringValue(long))
+	})
+	if got != 0 {
+		t.Errorf("got %.1f allocs, want 0", got)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(t, &buf, slog.Int("a", 1))
+	writeFrame(t, &buf, slog.Int("b", 2))
+	// Corrupt the contents of the first frame.
+	buf.Bytes()[headerSize+2] ^= 0xff
+	if got, want := decode(t, &buf), "b=2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTornFrame(t *testing.T) {
+	var buf bytes.Buffer
+	writeFrame(t, &buf, slog.String("a", "abc"))
+	r := bytes.NewReader(buf.Bytes()[:buf.Len()-1])
+	if err := Decode(r, &recordingVisitor{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func writeFrame(t *testing.T, w io.Writer, attrs ...slog.Attr) {
+	t.Helper()
+	e := GetEncoder()
+	defer PutEncoder(e)
+	for _, a := range attrs {
+		e.EncodeKey(a.Key)
+		e.EncodeValue(a.Value)
+	}
+	if _, err := e.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decode(t *testing.T, r io.Reader) string {
+	t.Helper()
+	v := &recordingVisitor{}
+	if err := Decode(r, v); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(v.out, " ")
+}
+
+type recordingVisitor struct {
+	out []string
+}
+
+func (v *recordingVisitor) add(key []byte, val any) {
+	v.out = append(v.out, fmt.Sprintf("%s=%v", key, val))
+}
+
+func (v *recordingVisitor) Int(key []byte, val int64)     { v.add(key, val) }
+func (v *recordingVisitor) Uint(key []byte, val uint64)   { v.add(key, val) }
+func (v *recordingVisitor) String(key, val []byte)        { v.add(key, string(val)) }
+func (v *recordingVisitor) Bytes(key, val []byte)         { v.add(key, "bytes:"+string(val)) }
+func (v *recordingVisitor) Bool(key []byte, val bool)     { v.add(key, val) }
+func (v *recordingVisitor) Float(key []byte, val float64) { v.add(key, val) }
+func (v *recordingVisitor) Duration(key []byte, val time.Duration) {
+	v.add(key, val)
+}
+func (v *recordingVisitor) Time(key []byte, val time.Time) {
+	v.add(key, val.Format(time.RFC3339Nano))
+}
+func (v *recordingVisitor) Group(n int) {
+	v.out = append(v.out, fmt.Sprintf("group(%d)", n))
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, a := range []slog.Attr{
+		slog.Int("a", 1),
+		slog.Int("big", -300),
+		slog.Uint64("u", 7),
+		slog.Float64("f", 2.5),
+		slog.Bool("t", true),
+		slog.String("s", "str"),
+		slog.Duration("d", time.Second),
+		slog.Time("tm", time.Date(2023, time.April, 3, 1, 2, 3, 4, time.FixedZone("", 3600))),
+		slog.Any("l", slog.LevelWarn),
+		slog.Group("g", slog.Int("x", 1), slog.Group("h", slog.String("y", "z"))),
+	} {
+		e := GetEncoder()
+		e.EncodeKey(a.Key)
+		e.EncodeValue(a.Value)
+		f.Add(slices.Clone(e.buf[headerSize:]))
+		PutEncoder(e)
+	}
+	f.Add([]byte{byte(opString), 1, 'k', byte(opList), byte(opInt), 0x81, 0x80, 0x80, 0x80, 0x10})
+	f.Add([]byte{byte(opString), 1, 'k', 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// As a stream, the data is almost always rejected by the header
+		// checks. As the contents of a frame, it reaches the decoder.
+		for _, in := range [][]byte{data, AppendFrame(nil, data)} {
+			v := &checkingVisitor{t: t, max: len(data)}
+			err := Decode(bytes.NewReader(in), v)
+			if err == nil && v.pairs == 0 && len(data) > 0 && bytes.Equal(in[headerSize:], data) {
+				t.Errorf("no error and no pairs from %x", data)
+			}
+		}
+	})
+}
+
+// checkingVisitor checks that the values it is given could have come
+// from an input of max bytes.
+type checkingVisitor struct {
+	t     *testing.T
+	max   int
+	pairs int // including groups
+}
+
+func (v *checkingVisitor) check(key []byte, n int) {
+	v.pairs++
+	klen := len(key)
+	if bytes.Equal(key, sourceKey) {
+		// The key of a source location comes from a one-byte marker.
+		klen = 1
+	}
+	if klen+n > v.max {
+		v.t.Errorf("key and value of %d bytes from %d bytes of input", klen+n, v.max)
+	}
+}
+
+func (v *checkingVisitor) Int(key []byte, val int64)              { v.check(key, 1) }
+func (v *checkingVisitor) Uint(key []byte, val uint64)            { v.check(key, 1) }
+func (v *checkingVisitor) String(key, val []byte)                 { v.check(key, len(val)) }
+func (v *checkingVisitor) Bytes(key, val []byte)                  { v.check(key, len(val)) }
+func (v *checkingVisitor) Bool(key []byte, val bool)              { v.check(key, 0) }
+func (v *checkingVisitor) Float(key []byte, val float64)          { v.check(key, 8) }
+func (v *checkingVisitor) Duration(key []byte, val time.Duration) { v.check(key, 1) }
+func (v *checkingVisitor) Time(key []byte, val time.Time)         { v.check(key, 15) }
+func (v *checkingVisitor) Group(n int) {
+	v.pairs++
+	if n < 0 || n > v.max {
+		v.t.Errorf("group of %d pairs from %d bytes of input", n, v.max)
+	}
+}
@@ -0,0 +1,454 @@
+package binary
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+)
+
+// An index is a sidecar file for a log file of frames. It lets [Query]
+// read only the parts of the log file that may hold the records it wants.
+// The index of the log file "app.log" is "app.log.idx".
+//
+// An index consists of entries, each describing a block of consecutive
+// frames in the log file. An entry holds, in order:
+//
+//   - the magic number indexMagic, as a little-endian uint32
+//   - the offset of the block's first frame, as a little-endian uint64
+//   - the offset just past the block's last frame, as a little-endian uint64
+//   - the number of frames in the block, as a little-endian uint32
+//   - the earliest record time in the block, in Unix nanoseconds,
+//     as a little-endian int64
+//   - the latest record time in the block, likewise
+//   - the highest record level in the block, as a little-endian int32
+//   - the CRC-32 (IEEE) checksum of the preceding bytes of the entry,
+//     as a little-endian uint32
+//
+// A block without times has an earliest time of MaxInt64 and a latest
+// time of MinInt64.
+const (
+	indexMagic     uint32 = 0xBAFEDC1D
+	indexEntrySize        = 48
+
+	// indexBlockFrames is the number of frames in a block.
+	indexBlockFrames = 256
+)
+
+// IndexSuffix is appended to the name of a log file to get the name of its index.
+const IndexSuffix = ".idx"
+
+// An indexEntry describes a block of frames.
+type indexEntry struct {
+	start, end       int64
+	count            uint32
+	minTime, maxTime int64
+	maxLevel         slog.Level
+}
+
+func newIndexEntry(start int64) indexEntry {
+	return indexEntry{
+		start:    start,
+		end:      start,
+		minTime:  math.MaxInt64,
+		maxTime:  math.MinInt64,
+		maxLevel: math.MinInt32,
+	}
+}
+
+func (e *indexEntry) add(size int64, t time.Time, level slog.Level) {
+	e.end += size
+	e.count++
+	if !t.IsZero() {
+		n := t.UnixNano()
+		e.minTime = min(e.minTime, n)
+		e.maxTime = max(e.maxTime, n)
+	}
+	e.maxLevel = max(e.maxLevel, level)
+}
+
+func (e indexEntry) append(buf []byte) []byte {
+	start := len(buf)
+	buf = binary.LittleEndian.AppendUint32(buf, indexMagic)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.start))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.end))
+	buf = binary.LittleEndian.AppendUint32(buf, e.count)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.minTime))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(e.maxTime))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(e.maxLevel)))
+	return binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[start:]))
+}
+
+// parseIndexEntry parses an entry from b, which has length indexEntrySize.
+// It reports false if b is not a valid entry.
+func parseIndexEntry(b []byte) (indexEntry, bool) {
+	if binary.LittleEndian.Uint32(b[0:4]) != indexMagic ||
+		crc32.ChecksumIEEE(b[:44]) != binary.LittleEndian.Uint32(b[44:48]) {
+		return indexEntry{}, false
+	}
+	return indexEntry{
+		start:    int64(binary.LittleEndian.Uint64(b[4:12])),
+		end:      int64(binary.LittleEndian.Uint64(b[12:20])),
+		count:    binary.LittleEndian.Uint32(b[20:24]),
+		minTime:  int64(binary.LittleEndian.Uint64(b[24:32])),
+		maxTime:  int64(binary.LittleEndian.Uint64(b[32:40])),
+		maxLevel: slog.Level(int32(binary.LittleEndian.Uint32(b[40:44]))),
+	}, true
+}
+
+// An IndexWriter writes frames to a log file and entries describing them
+// to an index.
+//
+// Each call to Write must pass one or more complete frames, as
+// [Encoder.WriteTo] does. An IndexWriter is not safe for concurrent use.
+type IndexWriter struct {
+	w, index io.Writer
+	block    indexEntry
+	buf      []byte
+}
+
+// NewIndexWriter returns an IndexWriter that writes frames to w and
+// entries to index. The offset is the size of the log file that w writes
+// to, so that frames can be appended to an existing file.
+func NewIndexWriter(w, index io.Writer, offset int64) *IndexWriter {
+	return &IndexWriter{w: w, index: index, block: newIndexEntry(offset)}
+}
+
+// Write writes the frames in p to the log file, and an entry to the index
+// each time a block is complete.
+func (iw *IndexWriter) Write(p []byte) (int, error) {
+	type frameInfo struct {
+		size  int64
+		time  time.Time
+		level slog.Level
+	}
+	var frames []frameInfo
+	for rest := p; len(rest) > 0; {
+		if len(rest) < headerSize || binary.LittleEndian.Uint32(rest[0:4]) != magic {
+			return 0, errors.New("binary: IndexWriter.Write: not a frame")
+		}
+		size := headerSize + int(binary.LittleEndian.Uint32(rest[5:9]))
+		if size > len(rest) {
+			return 0, errors.New("binary: IndexWriter.Write: incomplete frame")
+		}
+		t, level := decodeTimeAndLevel(rest[headerSize:size])
+		frames = append(frames, frameInfo{int64(size), t, level})
+		rest = rest[size:]
+	}
+	n, err := iw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	for _, f := range frames {
+		iw.block.add(f.size, f.time, f.level)
+		if iw.block.count >= indexBlockFrames {
+			if err := iw.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush writes an entry for the frames written since the last entry,
+// if there are any.
+func (iw *IndexWriter) Flush() error {
+	if iw.block.count == 0 {
+		return nil
+	}
+	iw.buf = iw.block.append(iw.buf[:0])
+	iw.block = newIndexEntry(iw.block.end)
+	_, err := iw.index.Write(iw.buf)
+	return err
+}
+
+// decodeTimeAndLevel returns the time and level at the start of a frame
+// written by [Encoder.EncodeRecord]. If there is no time, it returns the
+// zero time. If there is no level, it returns [slog.LevelInfo].
+func decodeTimeAndLevel(frame []byte) (time.Time, slog.Level) {
+	var t time.Time
+	level := slog.LevelInfo
+	for i := 0; i < 2 && len(frame) > 0; i++ {
+		var v attrVisitor
+		rest, err := decodePair(frame, &v, 0)
+		if err != nil || len(v.attrs) != 1 {
+			break
+		}
+		frame = rest
+		switch a := v.attrs[0]; {
+		case a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime:
+			t = a.Value.Time()
+		case a.Key == slog.LevelKey && a.Value.Kind() == slog.KindInt64:
+			level = slog.Level(a.Value.Int64())
+		}
+	}
+	return t, level
+}
+
+// A Filter selects records for [Query].
+type Filter struct {
+	// Start and End select records whose times are in [Start, End).
+	// A zero Start or End leaves that side of the range open.
+	// If either is non-zero, records without a time are not selected.
+	Start, End time.Time
+
+	// Level, if non-nil, selects records whose level is at least Level.Level().
+	Level slog.Leveler
+}
+
+func (f Filter) matchRecord(r slog.Record) bool {
+	if !f.Start.IsZero() || !f.End.IsZero() {
+		if r.Time.IsZero() ||
+			(!f.Start.IsZero() && r.Time.Before(f.Start)) ||
+			(!f.End.IsZero() && !r.Time.Before(f.End)) {
+			return false
+		}
+	}
+	return f.Level == nil || r.Level >= f.Level.Level()
+}
+
+// matchBlock reports whether the block described by e may hold records
+// selected by f.
+func (f Filter) matchBlock(e indexEntry) bool {
+	if !f.Start.IsZero() && e.maxTime < f.Start.UnixNano() {
+		return false
+	}
+	if !f.End.IsZero() && e.minTime >= f.End.UnixNano() {
+		return false
+	}
+	return f.Level == nil || e.maxLevel >= f.Level.Level()
+}
+
+// Query returns the records in the log file that are selected by filter,
+// in the order they appear in the file. The records must have been
+// written by [Encoder.EncodeRecord].
+//
+// If file has an index, only the blocks whose entries show that they may
+// hold selected records are read, along with the frames after the last
+// entry. Otherwise the whole file is read. Frames with bad checksums are
+// skipped, as is an incomplete frame at the end of the file.
+//
+// A record's source location, if it was encoded, is an Attr whose value
+// is a *slog.Source; use [RecordSource] to retrieve it.
+func Query(file string, filter Filter) ([]slog.Record, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := readIndex(file + IndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		recs []slog.Record
+		end  int64 // offset just past the last indexed frame
+	)
+	for _, e := range entries {
+		if filter.matchBlock(e) {
+			recs, err = queryFrames(recs, io.NewSectionReader(f, e.start, e.end-e.start), filter)
+			if err != nil {
+				return nil, fmt.Errorf("binary: %s at offset %d: %w", file, e.start, err)
+			}
+		}
+		end = e.end
+	}
+	recs, err = queryFrames(recs, io.NewSectionReader(f, end, math.MaxInt64-end), filter)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("binary: %s after offset %d: %w", file, end, err)
+	}
+	return recs, nil
+}
+
+// readIndex reads the entries of the index file. It stops at the first
+// invalid entry, which may have been partially written. It returns
+// no entries and no error if the file doesn't exist.
+func readIndex(file string) ([]indexEntry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []indexEntry
+	var end int64
+	for len(data) >= indexEntrySize {
+		e, ok := parseIndexEntry(data[:indexEntrySize])
+		// Entries must describe consecutive blocks.
+		if !ok || (len(entries) > 0 && e.start != end) || e.end < e.start {
+			break
+		}
+		entries = append(entries, e)
+		end = e.end
+		data = data[indexEntrySize:]
+	}
+	return entries, nil
+}
+
+// queryFrames appends the records of the frames in r that are selected by filter.
+func queryFrames(recs []slog.Record, r io.Reader, filter Filter) ([]slog.Record, error) {
+	br := bufio.NewReader(r)
+	for {
+		buf, err := readFrame(br)
+		if err == errChecksum {
+			continue
+		}
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return recs, err
+		}
+		rec, err := decodeRecord(buf)
+		if err != nil {
+			return recs, err
+		}
+		if filter.matchRecord(rec) {
+			recs = append(recs, rec)
+		}
+	}
+}
+
+// decodeRecord decodes a frame written by [Encoder.EncodeRecord].
+// The source location, if present, is an Attr of the record.
+func decodeRecord(frame []byte) (slog.Record, error) {
+	var v attrVisitor
+	if err := decodeFrame(frame, &v); err != nil {
+		return slog.Record{}, err
+	}
+	if v.err != nil {
+		return slog.Record{}, v.err
+	}
+	attrs := v.attrs
+	var r slog.Record
+	if len(attrs) > 0 && attrs[0].Key == slog.TimeKey && attrs[0].Value.Kind() == slog.KindTime {
+		r.Time = attrs[0].Value.Time()
+		attrs = attrs[1:]
+	}
+	if len(attrs) > 0 && attrs[0].Key == slog.LevelKey && attrs[0].Value.Kind() == slog.KindInt64 {
+		r.Level = slog.Level(attrs[0].Value.Int64())
+		attrs = attrs[1:]
+	}
+	if len(attrs) > 0 && attrs[0].Key == slog.MessageKey && attrs[0].Value.Kind() == slog.KindString {
+		r.Message = attrs[0].Value.String()
+		attrs = attrs[1:]
+	}
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, 0)
+	r2.AddAttrs(attrs...)
+	return r2, nil
+}
+
+// RecordSource returns the source location of a record returned by
+// [Query], or nil if none was encoded.
+func RecordSource(r slog.Record) *slog.Source {
+	var src *slog.Source
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == slog.SourceKey && a.Value.Kind() == slog.KindAny {
+			src, _ = a.Value.Any().(*slog.Source)
+		}
+		return src == nil
+	})
+	return src
+}
+
+// An attrVisitor builds Attrs from the pairs it visits.
+// The source location becomes an Attr whose value is a *slog.Source.
+// No other encoded value decodes to a *slog.Source.
+type attrVisitor struct {
+	attrs  []slog.Attr // of the innermost open group
+	groups []openGroup
+	key    string // of the next group
+	src    bool   // the next value is the source location
+	err    error
+}
+
+type openGroup struct {
+	key   string
+	src   bool
+	n     int         // pairs left
+	attrs []slog.Attr // of the enclosing group
+}
+
+func (v *attrVisitor) groupKey(key []byte) { v.key = string(key) }
+func (v *attrVisitor) source()             { v.src = true }
+
+func (v *attrVisitor) Int(k []byte, x int64)              { v.value(k, slog.Int64Value(x)) }
+func (v *attrVisitor) Uint(k []byte, x uint64)            { v.value(k, slog.Uint64Value(x)) }
+func (v *attrVisitor) String(k, x []byte)                 { v.value(k, slog.StringValue(string(x))) }
+func (v *attrVisitor) Bytes(k, x []byte)                  { v.value(k, slog.AnyValue(bytes.Clone(x))) }
+func (v *attrVisitor) Bool(k []byte, x bool)              { v.value(k, slog.BoolValue(x)) }
+func (v *attrVisitor) Float(k []byte, x float64)          { v.value(k, slog.Float64Value(x)) }
+func (v *attrVisitor) Duration(k []byte, x time.Duration) { v.value(k, slog.DurationValue(x)) }
+func (v *attrVisitor) Time(k []byte, x time.Time)         { v.value(k, slog.TimeValue(x)) }
+
+func (v *attrVisitor) value(key []byte, val slog.Value) {
+	if v.src {
+		v.src = false
+		v.setErr(errors.New("binary: source location is not a group"))
+	}
+	v.add(slog.Attr{Key: string(key), Value: val})
+}
+
+func (v *attrVisitor) Group(n int) {
+	v.groups = append(v.groups, openGroup{key: v.key, src: v.src, n: n, attrs: v.attrs})
+	v.attrs = nil
+	v.src = false
+	if n == 0 {
+		v.endGroup()
+	}
+}
+
+// add adds a to the innermost open group, ending the group if it is full.
+func (v *attrVisitor) add(a slog.Attr) {
+	v.attrs = append(v.attrs, a)
+	if n := len(v.groups); n > 0 {
+		g := &v.groups[n-1]
+		g.n--
+		if g.n == 0 {
+			v.endGroup()
+		}
+	}
+}
+
+// endGroup adds the innermost open group to the one enclosing it.
+func (v *attrVisitor) endGroup() {
+	g := v.groups[len(v.groups)-1]
+	v.groups = v.groups[:len(v.groups)-1]
+	a := slog.Attr{Key: g.key, Value: slog.GroupValue(v.attrs...)}
+	if g.src {
+		a = sourceAttr(a.Value.Group())
+	}
+	v.attrs = g.attrs
+	v.add(a)
+}
+
+func (v *attrVisitor) setErr(err error) {
+	if v.err == nil {
+		v.err = err
+	}
+}
+
+// sourceAttr returns the source location with the members written by
+// Encoder.encodeSource as an Attr.
+func sourceAttr(as []slog.Attr) slog.Attr {
+	src := &slog.Source{}
+	for _, f := range as {
+		switch {
+		case f.Key == "function":
+			src.Function = f.Value.String()
+		case f.Key == "file":
+			src.File = f.Value.String()
+		case f.Key == "line" && f.Value.Kind() == slog.KindInt64:
+			src.Line = int(f.Value.Int64())
+		}
+	}
+	return slog.Any(slog.SourceKey, src)
+}
@@ -0,0 +1,302 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	start := time.Date(2023, time.April, 3, 0, 0, 0, 0, time.UTC)
+	// 1000 records, one per minute, with every tenth one at level Error.
+	const n = 1000
+	var logBuf, indexBuf bytes.Buffer
+	iw := NewIndexWriter(&logBuf, &indexBuf, 0)
+	for i := 0; i < n; i++ {
+		level := slog.LevelInfo
+		if i%10 == 0 {
+			level = slog.LevelError
+		}
+		r := slog.NewRecord(start.Add(time.Duration(i)*time.Minute), level, fmt.Sprint(i), 0)
+		r.AddAttrs(slog.Int("i", i), slog.Group("g", slog.String("s", "x")))
+		writeRecord(t, iw, r)
+	}
+	if err := iw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexBuf.Len(), (n+indexBlockFrames-1)/indexBlockFrames*indexEntrySize; got != want {
+		t.Fatalf("index size: got %d, want %d", got, want)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "log")
+	if err := os.WriteFile(file, logBuf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	unindexed := filepath.Join(dir, "unindexed")
+	if err := os.WriteFile(unindexed, logBuf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file+IndexSuffix, indexBuf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"all", Filter{}, fmt.Sprintf("0..%d", n-1)},
+		{"range", Filter{Start: start.Add(300 * time.Minute), End: start.Add(310 * time.Minute)}, "300..309"},
+		{"start", Filter{Start: start.Add(995 * time.Minute)}, "995..999"},
+		{"end", Filter{End: start.Add(3 * time.Minute)}, "0..2"},
+		{"level", Filter{Start: start.Add(500 * time.Minute), End: start.Add(540 * time.Minute), Level: slog.LevelError}, "500 510 520 530"},
+		{"none", Filter{Start: start.Add(-time.Hour), End: start}, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, f := range []string{file, unindexed} {
+				recs, err := Query(f, test.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := summarize(recs); got != test.want {
+					t.Errorf("%s: got %q, want %q", filepath.Base(f), got, test.want)
+				}
+			}
+		})
+	}
+
+	// Check the contents of a record.
+	recs, err := Query(file, Filter{Start: start.Add(7 * time.Minute), End: start.Add(8 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("got %d records, want 1", len(recs))
+	}
+	r := recs[0]
+	var attrs []string
+	r.Attrs(func(a slog.Attr) bool { attrs = append(attrs, a.String()); return true })
+	if got, want := fmt.Sprintf("%s %s %s %s", r.Time.Format(time.RFC3339), r.Level, r.Message, attrs),
+		"2023-04-03T00:07:00Z INFO 7 [i=7 g=[s=x]]"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestQueryUnindexedTail(t *testing.T) {
+	// Records written after the last index entry, including a partial
+	// one at the end, are still queried.
+	start := time.Date(2023, time.April, 3, 0, 0, 0, 0, time.UTC)
+	var logBuf, indexBuf bytes.Buffer
+	iw := NewIndexWriter(&logBuf, &indexBuf, 0)
+	for i := 0; i < 5; i++ {
+		writeRecord(t, iw, slog.NewRecord(start.Add(time.Duration(i)*time.Second), slog.LevelInfo, fmt.Sprint(i), 0))
+		if i == 2 {
+			if err := iw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// A torn index entry is ignored.
+	indexBuf.Write(make([]byte, indexEntrySize/2))
+	data := logBuf.Bytes()
+	data = data[:len(data)-3]
+
+	file := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file+IndexSuffix, indexBuf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := Query(file, Filter{Start: start.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summarize(recs), "1..3"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestIndexWriterOffset(t *testing.T) {
+	var logBuf, indexBuf bytes.Buffer
+	iw := NewIndexWriter(&logBuf, &indexBuf, 100)
+	writeRecord(t, iw, slog.NewRecord(time.Time{}, slog.LevelWarn, "m", 0))
+	if err := iw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := parseIndexEntry(indexBuf.Bytes())
+	if !ok {
+		t.Fatal("bad entry")
+	}
+	want := indexEntry{start: 100, end: 100 + int64(logBuf.Len()), count: 1, minTime: newIndexEntry(0).minTime, maxTime: newIndexEntry(0).maxTime, maxLevel: slog.LevelWarn}
+	if e != want {
+		t.Errorf("got %+v, want %+v", e, want)
+	}
+	if _, err := iw.Write([]byte("not a frame")); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func writeRecord(t *testing.T, iw *IndexWriter, r slog.Record) {
+	t.Helper()
+	e := GetEncoder()
+	defer PutEncoder(e)
+	e.EncodeRecord(r)
+	if _, err := e.WriteTo(iw); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// summarize returns the messages of recs, with runs of consecutive
+// numbers written as "a..b".
+func summarize(recs []slog.Record) string {
+	var parts []string
+	for i := 0; i < len(recs); {
+		j := i + 1
+		for j < len(recs) && recs[j].Message == fmt.Sprint(atoi(recs[j-1].Message)+1) {
+			j++
+		}
+		if j-i > 2 {
+			parts = append(parts, recs[i].Message+".."+recs[j-1].Message)
+		} else {
+			for _, r := range recs[i:j] {
+				parts = append(parts, r.Message)
+			}
+		}
+		i = j
+	}
+	return strings.Join(parts, " ")
+}
+
+func atoi(s string) int {
+	var n int
+	fmt.Sscan(s, &n)
+	return n
+}
+
+func TestDecodeRecordBadListLength(t *testing.T) {
+	for _, n := range []int64{-2, 3, 1 << 40} {
+		var e Encoder
+		e.EncodeKey("g")
+		e.encodeOp(opList)
+		e.encodeInt(n)
+		if _, err := decodeRecord(e.buf); err == nil || !strings.Contains(err.Error(), "bad list length") {
+			t.Errorf("%d: got %v, want bad list length", n, err)
+		}
+	}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		a := slog.Int("x", 1)
+		for i := 0; i < n; i++ {
+			a = slog.Group("g", a)
+		}
+		var e Encoder
+		e.EncodeKey(a.Key)
+		e.EncodeValue(a.Value)
+		return e.buf
+	}
+	ok, deep := nested(maxGroupDepth), nested(maxGroupDepth+1)
+	if _, err := decodeRecord(ok); err != nil {
+		t.Error(err)
+	}
+	if _, err := decodeRecord(deep); err != errTooDeep {
+		t.Errorf("decodeRecord: got %v, want errTooDeep", err)
+	}
+	if err := Decode(bytes.NewReader(AppendFrame(nil, deep)), &recordingVisitor{}); err != errTooDeep {
+		t.Errorf("Decode: got %v, want errTooDeep", err)
+	}
+}
+
+func TestRecordSource(t *testing.T) {
+	decode := func(r slog.Record) slog.Record {
+		t.Helper()
+		var e Encoder
+		e.EncodeRecord(r)
+		r2, err := decodeRecord(e.buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r2
+	}
+
+	// An Attr that looks like a source location is not one.
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Group(slog.SourceKey, slog.String("function", "f"), slog.String("file", "f.go"), slog.Int("line", 1)))
+	if src := RecordSource(decode(r)); src != nil {
+		t.Errorf("got %+v, want nil", src)
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	r = slog.NewRecord(time.Time{}, slog.LevelInfo, "m", pcs[0])
+	r.AddAttrs(slog.String(slog.SourceKey, "user"))
+	r2 := decode(r)
+	src := RecordSource(r2)
+	if src == nil || filepath.Base(src.File) != "index_test.go" || src.Line == 0 {
+		t.Fatalf("got %+v, want a source in index_test.go", src)
+	}
+	// A decoded record encodes its source location as one.
+	if got := RecordSource(decode(r2)); got == nil || *got != *src {
+		t.Errorf("after re-encoding: got %+v, want %+v", got, src)
+	}
+}
+
+func FuzzDecodeRecord(f *testing.F) {
+	for _, r := range []slog.Record{
+		slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0),
+		slog.NewRecord(time.Date(2023, time.April, 3, 1, 2, 3, 4, time.UTC), slog.LevelWarn, "msg", 0),
+	} {
+		r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "x"), slog.Duration("d", time.Second)))
+		var e Encoder
+		e.EncodeRecord(r)
+		f.Add(e.buf)
+	}
+	f.Add([]byte{byte(opString), 1, 'g', byte(opList), 0x10})
+	var e Encoder
+	e.encodeSource(&slog.Source{Function: "f", File: "f.go", Line: 1})
+	f.Add(e.buf)
+	deep := slog.Int("x", 1)
+	for i := 0; i <= maxGroupDepth; i++ {
+		deep = slog.Group("g", deep)
+	}
+	e = Encoder{}
+	e.EncodeKey(deep.Key)
+	e.EncodeValue(deep.Value)
+	f.Add(e.buf)
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		r, err := decodeRecord(frame)
+		if err != nil {
+			return
+		}
+		// A decoded record encodes to a frame that decodes to the same record.
+		var e Encoder
+		e.EncodeRecord(r)
+		r2, err := decodeRecord(e.buf)
+		if err != nil {
+			t.Fatalf("decoding re-encoded %x: %v", frame, err)
+		}
+		if got, want := recordString(r2), recordString(r); got != want {
+			t.Errorf("round trip of %x:\ngot  %s\nwant %s", frame, got, want)
+		}
+	})
+}
+
+func recordString(r slog.Record) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %q", r.Time.UTC().Format(time.RFC3339Nano), r.Level, r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %q=%v", a.Key, a.Value)
+		return true
+	})
+	return b.String()
+}
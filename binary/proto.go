@@ -0,0 +1,394 @@
+package binary
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// This file implements the protocol buffer encoding of slog records
+// described in record.proto. It is an alternative to the custom
+// format of Encoder for consumers that are not written in Go.
+
+// Field numbers from record.proto.
+const (
+	protoRecordTime    = 1
+	protoRecordLevel   = 2
+	protoRecordMessage = 3
+	protoRecordAttrs   = 4
+
+	protoAttrKey   = 1
+	protoAttrValue = 2
+
+	protoValueString   = 1
+	protoValueInt64    = 2
+	protoValueUint64   = 3
+	protoValueFloat64  = 4
+	protoValueBool     = 5
+	protoValueDuration = 6
+	protoValueTime     = 7
+	protoValueBytes    = 8
+	protoValueGroup    = 9
+
+	protoGroupAttrs = 1
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// WriteProtoRecord writes r to w as a Record message from record.proto,
+// preceded by its length as a varint.
+func WriteProtoRecord(w io.Writer, r slog.Record) error {
+	buf := AppendProtoRecord(make([]byte, 0, 1024), r)
+	_, err := w.Write(buf)
+	return err
+}
+
+// AppendProtoRecord appends the length-delimited encoding of r to buf
+// and returns the extended buffer.
+func AppendProtoRecord(buf []byte, r slog.Record) []byte {
+	return appendProtoLengthDelimited(buf, func(buf []byte) []byte {
+		if !r.Time.IsZero() {
+			buf = appendProtoTag(buf, protoRecordTime, wireVarint)
+			buf = binary.AppendUvarint(buf, uint64(r.Time.UnixNano()))
+		}
+		if r.Level != 0 {
+			buf = appendProtoTag(buf, protoRecordLevel, wireVarint)
+			buf = binary.AppendVarint(buf, int64(r.Level))
+		}
+		if r.Message != "" {
+			buf = appendProtoString(buf, protoRecordMessage, r.Message)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			buf = appendProtoAttr(buf, protoRecordAttrs, a)
+			return true
+		})
+		return buf
+	})
+}
+
+func appendProtoAttr(buf []byte, field int, a slog.Attr) []byte {
+	buf = appendProtoTag(buf, field, wireBytes)
+	return appendProtoLengthDelimited(buf, func(buf []byte) []byte {
+		if a.Key != "" {
+			buf = appendProtoString(buf, protoAttrKey, a.Key)
+		}
+		buf = appendProtoTag(buf, protoAttrValue, wireBytes)
+		return appendProtoLengthDelimited(buf, func(buf []byte) []byte {
+			return appendProtoValue(buf, a.Value)
+		})
+	})
+}
+
+func appendProtoValue(buf []byte, v slog.Value) []byte {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return appendProtoString(buf, protoValueString, v.String())
+	case slog.KindInt64:
+		buf = appendProtoTag(buf, protoValueInt64, wireVarint)
+		return binary.AppendVarint(buf, v.Int64())
+	case slog.KindUint64:
+		buf = appendProtoTag(buf, protoValueUint64, wireVarint)
+		return binary.AppendUvarint(buf, v.Uint64())
+	case slog.KindFloat64:
+		buf = appendProtoTag(buf, protoValueFloat64, wireFixed64)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float64()))
+	case slog.KindBool:
+		buf = appendProtoTag(buf, protoValueBool, wireVarint)
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case slog.KindDuration:
+		buf = appendProtoTag(buf, protoValueDuration, wireVarint)
+		return binary.AppendUvarint(buf, uint64(v.Duration()))
+	case slog.KindTime:
+		var n int64
+		if t := v.Time(); !t.IsZero() {
+			n = t.UnixNano()
+		}
+		buf = appendProtoTag(buf, protoValueTime, wireVarint)
+		return binary.AppendUvarint(buf, uint64(n))
+	case slog.KindGroup:
+		buf = appendProtoTag(buf, protoValueGroup, wireBytes)
+		return appendProtoLengthDelimited(buf, func(buf []byte) []byte {
+			for _, a := range v.Group() {
+				buf = appendProtoAttr(buf, protoGroupAttrs, a)
+			}
+			return buf
+		})
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case []byte:
+			buf = appendProtoTag(buf, protoValueBytes, wireBytes)
+			buf = binary.AppendUvarint(buf, uint64(len(x)))
+			return append(buf, x...)
+		case encoding.TextMarshaler:
+			data, err := x.MarshalText()
+			if err != nil {
+				return appendProtoString(buf, protoValueString, err.Error())
+			}
+			return appendProtoString(buf, protoValueString, string(data))
+		default:
+			return appendProtoString(buf, protoValueString, fmt.Sprint(x))
+		}
+	default:
+		panic("unknown kind")
+	}
+}
+
+func appendProtoTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func appendProtoString(buf []byte, field int, s string) []byte {
+	buf = appendProtoTag(buf, field, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendProtoLengthDelimited appends the bytes produced by f,
+// preceded by their length.
+func appendProtoLengthDelimited(buf []byte, f func([]byte) []byte) []byte {
+	start := len(buf)
+	buf = f(buf)
+	n := len(buf) - start
+	var lbuf [binary.MaxVarintLen64]byte
+	l := binary.PutUvarint(lbuf[:], uint64(n))
+	// Make room for the length, then shift the contents over.
+	buf = append(buf, lbuf[:l]...)
+	copy(buf[start+l:], buf[start:start+n])
+	copy(buf[start:], lbuf[:l])
+	return buf
+}
+
+////////////////////////////////////////////////////////////////
+
+// A ProtoReader reads records written by [WriteProtoRecord].
+type ProtoReader struct {
+	r       *bufio.Reader
+	buf     []byte
+	maxSize uint64
+}
+
+// ProtoReaderOptions are options for a [ProtoReader].
+type ProtoReaderOptions struct {
+	// MaxSize is the largest encoded record, in bytes, that the reader
+	// accepts. If zero, 64 MiB is used.
+	MaxSize int
+}
+
+// NewProtoReader returns a ProtoReader that reads from r with the
+// default options.
+func NewProtoReader(r io.Reader) *ProtoReader {
+	return ProtoReaderOptions{}.New(r)
+}
+
+// New returns a ProtoReader that reads from r.
+func (opts ProtoReaderOptions) New(r io.Reader) *ProtoReader {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 64 << 20
+	}
+	return &ProtoReader{r: bufio.NewReader(r), maxSize: uint64(opts.MaxSize)}
+}
+
+// Read returns the next record.
+// It returns io.EOF when there are no more records.
+// It returns an error, without reading the record, if the record's
+// length is greater than the reader's MaxSize; the reader can't
+// continue after that.
+func (pr *ProtoReader) Read() (slog.Record, error) {
+	n, err := binary.ReadUvarint(pr.r)
+	if err != nil {
+		return slog.Record{}, err
+	}
+	if n > pr.maxSize {
+		return slog.Record{}, fmt.Errorf("binary: record of %d bytes is larger than the maximum of %d", n, pr.maxSize)
+	}
+	if uint64(cap(pr.buf)) < n {
+		pr.buf = make([]byte, n)
+	}
+	pr.buf = pr.buf[:n]
+	if _, err := io.ReadFull(pr.r, pr.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return slog.Record{}, err
+	}
+	return decodeProtoRecord(pr.buf)
+}
+
+var (
+	errProtoTruncated = errors.New("truncated protocol buffer")
+)
+
+func decodeProtoRecord(buf []byte) (slog.Record, error) {
+	var (
+		t     time.Time
+		level slog.Level
+		msg   string
+		attrs []slog.Attr
+	)
+	err := decodeProtoFields(buf, func(field, wireType int, u uint64, data []byte) error {
+		switch field {
+		case protoRecordTime:
+			if u != 0 {
+				t = time.Unix(0, int64(u)).UTC()
+			}
+		case protoRecordLevel:
+			level = slog.Level(zigzag(u))
+		case protoRecordMessage:
+			msg = string(data)
+		case protoRecordAttrs:
+			a, err := decodeProtoAttr(data, 0)
+			if err != nil {
+				return err
+			}
+			attrs = append(attrs, a)
+		}
+		return nil
+	})
+	if err != nil {
+		return slog.Record{}, err
+	}
+	r := slog.NewRecord(t, level, msg, 0)
+	r.AddAttrs(attrs...)
+	return r, nil
+}
+
+// decodeProtoAttr decodes an Attr that is nested depth groups deep.
+func decodeProtoAttr(buf []byte, depth int) (slog.Attr, error) {
+	var a slog.Attr
+	err := decodeProtoFields(buf, func(field, wireType int, u uint64, data []byte) error {
+		switch field {
+		case protoAttrKey:
+			a.Key = string(data)
+		case protoAttrValue:
+			v, err := decodeProtoValue(data, depth)
+			if err != nil {
+				return err
+			}
+			a.Value = v
+		}
+		return nil
+	})
+	return a, err
+}
+
+func decodeProtoValue(buf []byte, depth int) (slog.Value, error) {
+	var v slog.Value
+	err := decodeProtoFields(buf, func(field, wireType int, u uint64, data []byte) error {
+		switch field {
+		case protoValueString:
+			v = slog.StringValue(string(data))
+		case protoValueInt64:
+			v = slog.Int64Value(zigzag(u))
+		case protoValueUint64:
+			v = slog.Uint64Value(u)
+		case protoValueFloat64:
+			v = slog.Float64Value(math.Float64frombits(u))
+		case protoValueBool:
+			v = slog.BoolValue(u != 0)
+		case protoValueDuration:
+			v = slog.DurationValue(time.Duration(u))
+		case protoValueTime:
+			var t time.Time
+			if u != 0 {
+				t = time.Unix(0, int64(u)).UTC()
+			}
+			v = slog.TimeValue(t)
+		case protoValueBytes:
+			v = slog.AnyValue(append([]byte(nil), data...))
+		case protoValueGroup:
+			if depth >= maxGroupDepth {
+				return errTooDeep
+			}
+			var as []slog.Attr
+			err := decodeProtoFields(data, func(field, _ int, _ uint64, data []byte) error {
+				if field != protoGroupAttrs {
+					return nil
+				}
+				a, err := decodeProtoAttr(data, depth+1)
+				if err != nil {
+					return err
+				}
+				as = append(as, a)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			v = slog.GroupValue(as...)
+		}
+		return nil
+	})
+	return v, err
+}
+
+// decodeProtoFields calls f for each field in buf.
+// For varint and fixed-width fields, u holds the value.
+// For length-delimited fields, data holds the contents.
+func decodeProtoFields(buf []byte, f func(field, wireType int, u uint64, data []byte) error) error {
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		buf = buf[n:]
+		field, wireType := int(tag>>3), int(tag&7)
+		var (
+			u    uint64
+			data []byte
+		)
+		switch wireType {
+		case wireVarint:
+			u, n = binary.Uvarint(buf)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			buf = buf[n:]
+		case wireFixed64:
+			if len(buf) < 8 {
+				return errProtoTruncated
+			}
+			u = binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+		case wireFixed32:
+			if len(buf) < 4 {
+				return errProtoTruncated
+			}
+			u = uint64(binary.LittleEndian.Uint32(buf))
+			buf = buf[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return errProtoTruncated
+			}
+			data = buf[n : n+int(l)]
+			buf = buf[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wireType)
+		}
+		if err := f(field, wireType, u, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zigzag decodes a sint64 value.
+func zigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
@@ -0,0 +1,147 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 4, time.UTC)
+	r1 := slog.NewRecord(tm, slog.LevelDebug, "message", 0)
+	r1.AddAttrs(
+		slog.String("s", "x"),
+		slog.Int("i", -7),
+		slog.Uint64("u", 1<<40),
+		slog.Float64("f", 1.5),
+		slog.Bool("b", true),
+		slog.Duration("d", -time.Second),
+		slog.Time("t", tm),
+		slog.Any("bs", []byte("xy")),
+		slog.Any("l", slog.LevelWarn),
+		slog.Group("g", slog.Int("a", 1), slog.Group("h", slog.String("b", "c"))),
+	)
+	r2 := slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+
+	var buf bytes.Buffer
+	for _, r := range []slog.Record{r1, r2} {
+		if err := WriteProtoRecord(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pr := NewProtoReader(&buf)
+	for _, want := range []slog.Record{r1, r2} {
+		got, err := pr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Time.Equal(want.Time) || got.Level != want.Level || got.Message != want.Message {
+			t.Errorf("got (%s, %s, %q), want (%s, %s, %q)",
+				got.Time, got.Level, got.Message, want.Time, want.Level, want.Message)
+		}
+		if g, w := recordAttrs(got), recordAttrs(want); !slog.GroupValue(g...).Equal(slog.GroupValue(w...)) {
+			t.Errorf("got attrs %v, want %v", g, w)
+		}
+	}
+	if _, err := pr.Read(); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestProtoEncoding(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "m", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	got := hex.EncodeToString(AppendProtoRecord(nil, r))
+	want := "0e" + // length
+		"1008" + // level: 4
+		"1a016d" + // message: "m"
+		"2207" + "0a0161" + "12021002" // attrs: {key: "a", value: {int64: 1}}
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestProtoTruncated(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "message", 0)
+	r.AddAttrs(slog.String("a", "b"))
+	data := AppendProtoRecord(nil, r)
+	pr := NewProtoReader(bytes.NewReader(data[:len(data)-2]))
+	if _, err := pr.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want ErrUnexpectedEOF", err)
+	}
+}
+
+func TestProtoMaxSize(t *testing.T) {
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, strings.Repeat("x", 100), 0)
+	data := AppendProtoRecord(nil, r)
+	if _, err := (ProtoReaderOptions{MaxSize: 100}).New(bytes.NewReader(data)).Read(); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got %v, want error about size", err)
+	}
+	if _, err := (ProtoReaderOptions{MaxSize: len(data)}).New(bytes.NewReader(data)).Read(); err != nil {
+		t.Error(err)
+	}
+	// The default maximum is well below the 4 GiB a length can claim.
+	huge := binary.AppendUvarint(nil, 1<<32-1)
+	if _, err := NewProtoReader(bytes.NewReader(huge)).Read(); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got %v, want error about size", err)
+	}
+}
+
+func TestProtoDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		a := slog.Int("x", 1)
+		for i := 0; i < n; i++ {
+			a = slog.Group("g", a)
+		}
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(a)
+		return AppendProtoRecord(nil, r)
+	}
+	if _, err := NewProtoReader(bytes.NewReader(nested(maxGroupDepth))).Read(); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewProtoReader(bytes.NewReader(nested(maxGroupDepth + 1))).Read(); err != errTooDeep {
+		t.Errorf("got %v, want errTooDeep", err)
+	}
+}
+
+func FuzzProtoRecord(f *testing.F) {
+	r := slog.NewRecord(time.Date(2023, time.April, 3, 1, 2, 3, 4, time.UTC), slog.LevelWarn, "m", 0)
+	r.AddAttrs(slog.Int("a", -1), slog.Group("g", slog.String("b", "x"), slog.Duration("d", time.Second)))
+	f.Add(AppendProtoRecord(nil, r))
+	deep := slog.Int("x", 1)
+	for i := 0; i <= maxGroupDepth; i++ {
+		deep = slog.Group("g", deep)
+	}
+	r = slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+	r.AddAttrs(deep)
+	f.Add(AppendProtoRecord(nil, r))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pr := NewProtoReader(bytes.NewReader(data))
+		for {
+			if _, err := pr.Read(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func recordAttrs(r slog.Record) []slog.Attr {
+	var as []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		// Values of kind Any are encoded as strings.
+		// Convert byte slices to strings so they can be compared.
+		if a.Value.Kind() == slog.KindAny {
+			a.Value = slog.StringValue(a.Value.String())
+		}
+		as = append(as, a)
+		return true
+	})
+	return as
+}
@@ -0,0 +1,186 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"log/slog"
+)
+
+// maxFrameSize is the largest frame a StreamDecoder will accept.
+// A header with a larger length is treated as garbage.
+const maxFrameSize = 1 << 28
+
+// minRead is the minimum number of bytes a StreamDecoder asks for
+// when reading.
+const minRead = 4096
+
+// magicBytes is the encoding of magic at the start of each frame.
+var magicBytes = binary.LittleEndian.AppendUint32(nil, magic)
+
+// A StreamDecoder decodes a sequence of frames from an io.Reader.
+//
+// Unlike [Decode], a StreamDecoder does not give up when it encounters
+// bytes that are not a valid frame. Instead it skips ahead to the next
+// occurrence of the magic number and tries again from there.
+// That makes it suitable for reading files that were truncated or
+// rotated while being written.
+type StreamDecoder struct {
+	r       io.Reader
+	buf     []byte // bytes read from r but not yet consumed
+	skipped int64
+}
+
+// NewStreamDecoder returns a StreamDecoder that reads from r.
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	return &StreamDecoder{r: r}
+}
+
+// Next decodes the next valid frame, calling the methods of v as
+// [Decode] does. The byte slices passed to v are valid only for the
+// duration of the call.
+//
+// Next returns io.EOF if r is exhausted and there is nothing left to
+// decode. It returns io.ErrUnexpectedEOF if r is exhausted in the middle
+// of a frame. In both cases the partial data is retained, so if r
+// can produce more data later, as when tailing a file that is being
+// written, Next can be called again.
+func (d *StreamDecoder) Next(v DecodeVisitor) error {
+	data, err := d.nextFrame()
+	if err != nil {
+		return err
+	}
+	return decodeFrame(data, v)
+}
+
+// NextRecord decodes the next valid frame, which must have been written
+// by [Encoder.EncodeRecord], into a record. It returns errors as Next
+// does. As with [Query], use [RecordSource] for the source location.
+func (d *StreamDecoder) NextRecord() (slog.Record, error) {
+	data, err := d.nextFrame()
+	if err != nil {
+		return slog.Record{}, err
+	}
+	return decodeRecord(data)
+}
+
+// NextFrame returns the contents of the next valid frame, without
+// decoding them. They are valid only until the next call to a method of d.
+// It returns errors as Next does.
+func (d *StreamDecoder) NextFrame() ([]byte, error) {
+	return d.nextFrame()
+}
+
+// nextFrame returns the contents of the next valid frame, which are
+// valid until the next call.
+func (d *StreamDecoder) nextFrame() ([]byte, error) {
+	for {
+		if err := d.fill(headerSize); err != nil {
+			return nil, d.eofError(err)
+		}
+		if !bytes.Equal(d.buf[:4], magicBytes) {
+			d.resync()
+			continue
+		}
+		length := binary.LittleEndian.Uint32(d.buf[5:9])
+		if d.buf[4] != version || length > maxFrameSize {
+			d.skip(1)
+			continue
+		}
+		size := headerSize + int(length)
+		if err := d.fill(size); err != nil {
+			// The length may be garbage. If there is a complete frame
+			// later in the buffer, resume from there.
+			if i := d.findFrame(1); i >= 0 {
+				d.skip(i)
+				continue
+			}
+			return nil, d.eofError(err)
+		}
+		if !validFrame(d.buf[:size]) {
+			d.skip(1)
+			continue
+		}
+		data := d.buf[headerSize:size]
+		d.buf = d.buf[size:]
+		return data, nil
+	}
+}
+
+// Skipped returns the number of bytes that have been discarded
+// because they were not part of a valid frame.
+func (d *StreamDecoder) Skipped() int64 {
+	return d.skipped
+}
+
+// resync discards bytes up to the next possible start of a frame.
+func (d *StreamDecoder) resync() {
+	if i := bytes.Index(d.buf[1:], magicBytes); i >= 0 {
+		d.skip(i + 1)
+		return
+	}
+	// Keep a suffix that may be the beginning of the magic number.
+	d.skip(len(d.buf) - (len(magicBytes) - 1))
+}
+
+// findFrame returns the index of the first complete, valid frame in the
+// buffer at or after start, or -1 if there is none.
+func (d *StreamDecoder) findFrame(start int) int {
+	for start < len(d.buf) {
+		i := bytes.Index(d.buf[start:], magicBytes)
+		if i < 0 {
+			return -1
+		}
+		start += i
+		if h := d.buf[start:]; len(h) >= headerSize {
+			size := headerSize + int(binary.LittleEndian.Uint32(h[5:9]))
+			if size <= len(h) && validFrame(h[:size]) {
+				return start
+			}
+		}
+		start++
+	}
+	return -1
+}
+
+// validFrame reports whether frame, which begins with a header with the
+// right magic number, has a supported version and a matching checksum.
+func validFrame(frame []byte) bool {
+	return frame[4] == version &&
+		crc32.ChecksumIEEE(frame[headerSize:]) == binary.LittleEndian.Uint32(frame[9:13])
+}
+
+func (d *StreamDecoder) skip(n int) {
+	d.buf = d.buf[n:]
+	d.skipped += int64(n)
+}
+
+func (d *StreamDecoder) eofError(err error) error {
+	if err == io.EOF && len(d.buf) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// fill reads from r until at least n bytes are buffered.
+// The buffer grows only as bytes arrive, not to n at once, because n
+// may come from the length in a header that is garbage.
+func (d *StreamDecoder) fill(n int) error {
+	for len(d.buf) < n {
+		if cap(d.buf)-len(d.buf) < minRead {
+			nb := make([]byte, len(d.buf), max(2*cap(d.buf), len(d.buf)+minRead))
+			copy(nb, d.buf)
+			d.buf = nb
+		}
+		m, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+m]
+		if err != nil {
+			if len(d.buf) >= n {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
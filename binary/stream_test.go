@@ -0,0 +1,172 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("garbage")
+	writeFrame(t, &buf, slog.Int("a", 1))
+	writeFrame(t, &buf, slog.Int("b", 2))
+	buf.Write(magicBytes) // a false start
+	writeFrame(t, &buf, slog.Int("c", 3))
+	start := buf.Len()
+	writeFrame(t, &buf, slog.Int("d", 4))
+	buf.Bytes()[start+headerSize+1] ^= 0xff // corrupt d
+	writeFrame(t, &buf, slog.Int("e", 5))
+
+	d := NewStreamDecoder(&buf)
+	var got []string
+	for {
+		v := &recordingVisitor{}
+		err := d.Next(v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.out...)
+	}
+	if g, w := strings.Join(got, " "), "a=1 b=2 c=3 e=5"; g != w {
+		t.Errorf("got %q, want %q", g, w)
+	}
+	if g, w := d.Skipped(), int64(len("garbage")+len(magicBytes)+headerSize+4); g != w {
+		t.Errorf("skipped %d bytes, want %d", g, w)
+	}
+}
+
+func TestStreamDecoderTail(t *testing.T) {
+	var frames bytes.Buffer
+	writeFrame(t, &frames, slog.String("a", "x"))
+	writeFrame(t, &frames, slog.String("b", "y"))
+	data := frames.Bytes()
+
+	// Simulate a file that is being appended to.
+	var file bytes.Buffer
+	d := NewStreamDecoder(&file)
+	file.Write(data[:5])
+	if err := d.Next(&recordingVisitor{}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want ErrUnexpectedEOF", err)
+	}
+	file.Write(data[5:])
+	for _, want := range []string{"a=x", "b=y"} {
+		v := &recordingVisitor{}
+		if err := d.Next(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(v.out, " "); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if err := d.Next(&recordingVisitor{}); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestStreamDecoderLargeLength(t *testing.T) {
+	// A header claiming the largest frame, followed by a small one.
+	var buf bytes.Buffer
+	header := append(slices.Clone(magicBytes), version)
+	header = binary.LittleEndian.AppendUint32(header, maxFrameSize)
+	header = binary.LittleEndian.AppendUint32(header, 0) // checksum
+	buf.Write(header)
+	writeFrame(t, &buf, slog.String("a", "small"))
+
+	d := NewStreamDecoder(&buf)
+	v := &recordingVisitor{}
+	if err := d.Next(v); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(v.out, " "), "a=small"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The buffer grew only with the input, not to the claimed length.
+	if c := cap(d.buf); c > 1<<16 {
+		t.Errorf("buffer capacity is %d", c)
+	}
+}
+
+func TestStreamDecoderNextRecord(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+	var buf bytes.Buffer
+	for _, msg := range []string{"a", "b"} {
+		buf.WriteString("junk")
+		r := slog.NewRecord(tm, slog.LevelWarn, msg, 0)
+		r.AddAttrs(slog.Int("n", 1))
+		e := GetEncoder()
+		e.EncodeRecord(r)
+		if _, err := e.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		PutEncoder(e)
+	}
+	d := NewStreamDecoder(&buf)
+	var got []string
+	for {
+		r, err := d.NextRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s n=%s", r.Time.Format(time.RFC3339), r.Level, r.Message, attrValue(r, "n")))
+	}
+	want := "2023-04-03T01:02:03Z WARN a n=1, 2023-04-03T01:02:03Z WARN b n=1"
+	if g := strings.Join(got, ", "); g != want {
+		t.Errorf("got %q, want %q", g, want)
+	}
+}
+
+// attrValue returns the value of the top-level Attr with the given key.
+func attrValue(r slog.Record, key string) string {
+	var v string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			v = a.Value.String()
+		}
+		return true
+	})
+	return v
+}
+
+func TestAppendFrame(t *testing.T) {
+	var buf []byte
+	buf = AppendFrame(buf, []byte("first"))
+	buf = append(buf, "junk"...)
+	buf = AppendFrame(buf, nil)
+	start := len(buf)
+	buf = AppendFrame(buf, []byte("corrupt"))
+	buf[start+headerSize] ^= 0xff
+	buf = AppendFrame(buf, []byte("last\n"))
+
+	d := NewStreamDecoder(bytes.NewReader(buf))
+	var got []string
+	for {
+		data, err := d.NextFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(data))
+	}
+	want := []string{"first", "", "last\n"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if d.Skipped() == 0 {
+		t.Error("nothing skipped")
+	}
+}
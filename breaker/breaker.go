@@ -0,0 +1,127 @@
+// Package breaker detects storms of errors in log output.
+//
+// A [Breaker] counts the records at or above a level, and trips when
+// too many arrive within a window of time, calling a function or
+// canceling a context. A supervisor can use it to restart a component
+// that is stuck failing:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	h := breaker.Options{Threshold: 100, Window: time.Minute, Cancel: cancel}.NewHandler(h)
+//	go component.Run(ctx, slog.New(h))
+package breaker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jba/slog/middleware"
+)
+
+// Options are options for a [Breaker].
+type Options struct {
+	// Level is the lowest level of the records that are counted.
+	// If nil, [slog.LevelError] is used.
+	Level slog.Leveler
+
+	// Threshold is the number of records within Window that trips the
+	// Breaker. It must be positive.
+	Threshold int
+
+	// Window is the length of the sliding window of time in which records
+	// are counted. If it is zero, a minute is used.
+	Window time.Duration
+
+	// OnTrip, if non-nil, is called when the Breaker trips, with the
+	// record that tripped it. It is called by the goroutine that handles
+	// the record, with no locks held.
+	OnTrip func(ctx context.Context, r slog.Record)
+
+	// Cancel, if non-nil, is called when the Breaker trips, after OnTrip.
+	Cancel context.CancelFunc
+
+	// Clock, if non-nil, is used instead of [time.Now] to tell when
+	// records are handled.
+	Clock func() time.Time
+}
+
+// A Breaker is a [middleware.RecordTransformer] that counts records and
+// passes them through unchanged. After it trips, it starts counting
+// again from zero, so that it trips again if the storm goes on.
+type Breaker struct {
+	opts  Options
+	trips atomic.Int64
+
+	mu    sync.Mutex
+	times []time.Time // of the last Threshold records, a ring
+	next  int         // index of the oldest time, once times is full
+}
+
+// New returns a Breaker with the given options.
+// It panics if opts.Threshold is not positive.
+func (opts Options) New() *Breaker {
+	if opts.Threshold <= 0 {
+		panic("breaker: Threshold must be positive")
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelError
+	}
+	if opts.Window == 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	return &Breaker{opts: opts, times: make([]time.Time, 0, opts.Threshold)}
+}
+
+// NewHandler returns a handler that passes records through a new Breaker
+// to h. Only records that h is enabled for are counted.
+func (opts Options) NewHandler(h slog.Handler) slog.Handler {
+	return middleware.Transform(opts.New())(h)
+}
+
+// Transform counts r and returns it.
+func (b *Breaker) Transform(ctx context.Context, r slog.Record) (slog.Record, bool) {
+	if r.Level >= b.opts.Level.Level() && b.count() {
+		b.trips.Add(1)
+		if b.opts.OnTrip != nil {
+			b.opts.OnTrip(ctx, r)
+		}
+		if b.opts.Cancel != nil {
+			b.opts.Cancel()
+		}
+	}
+	return r, true
+}
+
+// count counts a record handled now, and reports whether the Breaker trips.
+func (b *Breaker) count() bool {
+	now := b.opts.Clock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.times) < b.opts.Threshold {
+		b.times = append(b.times, now)
+		if len(b.times) < b.opts.Threshold {
+			return false
+		}
+	} else {
+		b.times[b.next] = now
+		b.next = (b.next + 1) % len(b.times)
+	}
+	// The ring is full, so times[next] is the oldest of the last
+	// Threshold records.
+	if now.Sub(b.times[b.next]) >= b.opts.Window {
+		return false
+	}
+	b.times = b.times[:0]
+	b.next = 0
+	return true
+}
+
+// Trips returns the number of times the Breaker has tripped.
+func (b *Breaker) Trips() int64 {
+	return b.trips.Load()
+}
@@ -0,0 +1,68 @@
+package breaker
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers/memory"
+	"github.com/jba/slog/handlertest"
+)
+
+func TestBreaker(t *testing.T) {
+	clock := handlertest.NewFakeClock(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 0)
+	var tripped []string
+	b := Options{
+		Threshold: 3,
+		Window:    10 * time.Second,
+		OnTrip: func(_ context.Context, r slog.Record) {
+			tripped = append(tripped, r.Message)
+		},
+		Clock: clock.Now,
+	}.New()
+	for _, e := range []struct {
+		after time.Duration
+		level slog.Level
+		msg   string
+	}{
+		{0, slog.LevelError, "e1"},
+		{time.Second, slog.LevelWarn, "w1"}, // not counted
+		{time.Second, slog.LevelError, "e2"},
+		{8 * time.Second, slog.LevelError, "e3"}, // e1 is 10s old, out of the window
+		{time.Second, slog.LevelError + 4, "e4"}, // e2, e3, e4 trip it
+		{time.Second, slog.LevelError, "e5"},     // counting starts again
+		{time.Second, slog.LevelError, "e6"},
+		{time.Second, slog.LevelError, "e7"},
+	} {
+		clock.Advance(e.after)
+		r := slog.NewRecord(time.Time{}, e.level, e.msg, 0)
+		if r2, ok := b.Transform(context.Background(), r); !ok || r2.Message != r.Message {
+			t.Errorf("%s: record not passed through", e.msg)
+		}
+	}
+	if want := []string{"e4", "e7"}; !slices.Equal(tripped, want) {
+		t.Errorf("tripped at %q, want %q", tripped, want)
+	}
+	if got := b.Trips(); got != 2 {
+		t.Errorf("got %d trips, want 2", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mh := memory.New(nil)
+	l := slog.New(Options{Level: slog.LevelWarn, Threshold: 2, Cancel: cancel}.NewHandler(mh))
+	l.Warn("w")
+	if ctx.Err() != nil {
+		t.Fatal("canceled too soon")
+	}
+	l.Error("e")
+	if ctx.Err() == nil {
+		t.Error("not canceled")
+	}
+	if got := len(mh.Records()); got != 2 {
+		t.Errorf("got %d records, want 2", got)
+	}
+}
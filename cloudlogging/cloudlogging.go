@@ -0,0 +1,216 @@
+// Package cloudlogging provides a slog.Handler that writes JSON lines
+// in the structured logging format of Google Cloud Logging.
+//
+// Cloud Run, GKE and the other environments whose logging agents read
+// standard output or standard error parse these lines into log entries:
+// the severity, timestamp, message, source location, trace and HTTP request
+// of each entry are taken from the special fields written by the Handler.
+package cloudlogging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/internal/severity"
+	"github.com/jba/slog/withsupport"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the special fields.
+const (
+	SeverityKey       = "severity"
+	TimestampKey      = "timestamp"
+	MessageKey        = "message"
+	SourceLocationKey = "logging.googleapis.com/sourceLocation"
+	TraceKey          = "logging.googleapis.com/trace"
+	SpanIDKey         = "logging.googleapis.com/spanId"
+	TraceSampledKey   = "logging.googleapis.com/trace_sampled"
+	HTTPRequestKey    = "httpRequest"
+)
+
+// Options are options for a [Handler].
+type Options struct {
+	// Level reports the minimum level to log.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+
+	// If AddSource is true, the source location is written.
+	AddSource bool
+
+	// ProjectID is the Google Cloud project that holds the traces.
+	// If it is set, the trace field is the trace's resource name,
+	// "projects/PROJECT_ID/traces/TRACE_ID", which Cloud Logging needs
+	// to link the entry to the trace. Otherwise the trace field is the
+	// bare trace ID.
+	ProjectID string
+
+	// ReplaceAttr rewrites Attrs, as for [slog.HandlerOptions].
+	// It is called before the built-in Attrs are mapped to the special
+	// fields, so it sees them with their usual keys and values.
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+}
+
+// Handler writes each record as a line of JSON:
+//
+//   - the level is written as the severity, with the names given by [Severity];
+//   - the time is written as a timestamp object with seconds and nanos;
+//   - the message is written with the key "message";
+//   - the source location, if requested, is written as the sourceLocation
+//     field, with the file, line and function;
+//   - if the context passed to Handle holds an OpenTelemetry span, its
+//     trace ID, span ID and sampling decision are written as the trace,
+//     spanId and trace_sampled fields.
+//
+// Use [HTTPRequest] to write an httpRequest field.
+//
+// The mapping of built-in Attrs applies to all top-level Attrs with
+// the built-in keys, so a top-level Attr with the key "msg" is also
+// written as "message".
+type Handler struct {
+	opts Options
+	goa  *withsupport.GroupOrAttrs
+	h    *general.Handler
+}
+
+// New returns a Handler that writes to w with the default options.
+func New(w io.Writer) *Handler {
+	return Options{}.New(w)
+}
+
+// New returns a Handler that writes to w.
+func (opts Options) New(w io.Writer) *Handler {
+	gopts := general.Options{
+		Level:       opts.Level,
+		ReplaceAttr: opts.replaceAttr,
+	}
+	if opts.AddSource {
+		gopts.PCAttrs = sourceLocation
+	}
+	return &Handler{opts: opts, h: gopts.New(w, newFormatter)}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	return &h2
+}
+
+// Handle writes r. The trace fields must be at the top level, so
+// Handle applies the groups and Attrs of WithGroup and WithAttrs
+// itself, after the trace fields.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	if sc := otrace.SpanContextFromContext(ctx); sc.IsValid() {
+		tr := sc.TraceID().String()
+		if h.opts.ProjectID != "" {
+			tr = "projects/" + h.opts.ProjectID + "/traces/" + tr
+		}
+		r2.AddAttrs(
+			slog.String(TraceKey, tr),
+			slog.String(SpanIDKey, sc.SpanID().String()),
+			slog.Bool(TraceSampledKey, sc.IsSampled()))
+	}
+	r2.AddAttrs(h.goa.Nest(withsupport.RecordAttrs(r))...)
+	return h.h.Handle(ctx, r2)
+}
+
+// replaceAttr calls the user's ReplaceAttr, then maps the built-in
+// Attrs to the special fields.
+func (opts Options) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if opts.ReplaceAttr != nil {
+		a = opts.ReplaceAttr(groups, a)
+	}
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() == slog.KindTime {
+			t := a.Value.Time()
+			return slog.Group(TimestampKey, slog.Int64("seconds", t.Unix()), slog.Int("nanos", t.Nanosecond()))
+		}
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(SeverityKey, Severity(l))
+		}
+	case slog.MessageKey:
+		a.Key = MessageKey
+	}
+	return a
+}
+
+// Severity returns the Cloud Logging severity for l:
+// DEBUG below [slog.LevelInfo], INFO below [slog.LevelWarn],
+// WARNING below [slog.LevelError], and ERROR, CRITICAL, ALERT and
+// EMERGENCY for each step of 4 above that.
+func Severity(l slog.Level) string {
+	return severities[severity.Of(l)]
+}
+
+var severities = [...]string{"DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL", "ALERT", "EMERGENCY"}
+
+// sourceLocation returns the sourceLocation field for pc.
+// The line is a string, as in the JSON form of the LogEntry API.
+func sourceLocation(pc uintptr) []slog.Attr {
+	if pc == 0 {
+		return nil
+	}
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	return []slog.Attr{slog.Group(SourceLocationKey,
+		slog.String("file", f.File),
+		slog.String("line", strconv.Itoa(f.Line)),
+		slog.String("function", f.Function))}
+}
+
+// HTTPRequest returns an httpRequest field describing r, which was
+// answered with the given status and number of body bytes after
+// the given latency.
+func HTTPRequest(r *http.Request, status int, size int64, latency time.Duration) slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("requestMethod", r.Method),
+		slog.String("requestUrl", r.URL.String()),
+		slog.Int("status", status),
+		slog.String("responseSize", strconv.FormatInt(size, 10)),
+		slog.String("userAgent", r.UserAgent()),
+		slog.String("remoteIp", r.RemoteAddr),
+		slog.String("protocol", r.Proto),
+		slog.String("latency", strconv.FormatFloat(latency.Seconds(), 'f', -1, 64)+"s"),
+	}
+	if r.ContentLength > 0 {
+		attrs = append(attrs, slog.String("requestSize", strconv.FormatInt(r.ContentLength, 10)))
+	}
+	if ref := r.Referer(); ref != "" {
+		attrs = append(attrs, slog.String("referer", ref))
+	}
+	return slog.Attr{Key: HTTPRequestKey, Value: slog.GroupValue(attrs...)}
+}
+
+// lineFormatter is a JSON formatter that ends each object with a newline.
+type lineFormatter struct {
+	general.Formatter
+}
+
+func newFormatter() general.Formatter {
+	return lineFormatter{general.NewJSONFormatter()}
+}
+
+func (f lineFormatter) AppendEnd(buf []byte) []byte {
+	return append(f.Formatter.AppendEnd(buf), '\n')
+}
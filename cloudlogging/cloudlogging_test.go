@@ -0,0 +1,179 @@
+package cloudlogging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jba/slog/handlertest"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+var testTime = time.Date(2000, 1, 2, 3, 4, 5, 6, time.UTC)
+
+func TestHandler(t *testing.T) {
+	sc := otrace.NewSpanContext(otrace.SpanContextConfig{
+		TraceID:    otrace.TraceID{1, 2, 3},
+		SpanID:     otrace.SpanID{4, 5},
+		TraceFlags: otrace.FlagsSampled,
+	})
+	req := httptest.NewRequest("GET", "http://example.com/x", nil)
+	req.Header.Set("User-Agent", "test")
+
+	for _, test := range []struct {
+		name  string
+		opts  Options
+		ctx   context.Context
+		with  func(slog.Handler) slog.Handler
+		level slog.Level
+		attrs []slog.Attr
+		want  map[string]any
+	}{
+		{
+			name:  "basic",
+			ctx:   context.Background(),
+			level: slog.LevelWarn,
+			attrs: []slog.Attr{slog.Int("a", 1)},
+			want: map[string]any{
+				"timestamp": map[string]any{"seconds": 946782245.0, "nanos": 6.0},
+				"severity":  "WARNING",
+				"message":   "m",
+				"a":         1.0,
+			},
+		},
+		{
+			name:  "trace",
+			opts:  Options{ProjectID: "proj"},
+			ctx:   otrace.ContextWithSpanContext(context.Background(), sc),
+			with:  func(h slog.Handler) slog.Handler { return h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g") },
+			level: slog.LevelError + 4,
+			attrs: []slog.Attr{slog.Int("b", 2)},
+			want: map[string]any{
+				"timestamp":                            map[string]any{"seconds": 946782245.0, "nanos": 6.0},
+				"severity":                             "CRITICAL",
+				"message":                              "m",
+				"logging.googleapis.com/trace":         "projects/proj/traces/01020300000000000000000000000000",
+				"logging.googleapis.com/spanId":        "0405000000000000",
+				"logging.googleapis.com/trace_sampled": true,
+				"a":                                    1.0,
+				"g":                                    map[string]any{"b": 2.0},
+			},
+		},
+		{
+			name: "ReplaceAttr",
+			opts: Options{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey || a.Key == "b" {
+					return slog.Attr{}
+				}
+				return a
+			}},
+			ctx:   context.Background(),
+			with:  func(h slog.Handler) slog.Handler { return h.WithGroup("g") },
+			level: slog.LevelDebug - 4,
+			attrs: []slog.Attr{slog.Int("b", 2), HTTPRequest(req, 200, 10, 1500*time.Millisecond)},
+			want: map[string]any{
+				"severity": "DEBUG",
+				"message":  "m",
+				"g": map[string]any{"httpRequest": map[string]any{
+					"requestMethod": "GET",
+					"requestUrl":    "http://example.com/x",
+					"status":        200.0,
+					"responseSize":  "10",
+					"userAgent":     "test",
+					"remoteIp":      "192.0.2.1:1234",
+					"protocol":      "HTTP/1.1",
+					"latency":       "1.5s",
+				}},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			test.opts.Level = slog.LevelDebug - 4
+			var h slog.Handler = test.opts.New(&buf)
+			if test.with != nil {
+				h = test.with(h)
+			}
+			r := slog.NewRecord(testTime, test.level, "m", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(test.ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(buf.String(), "}\n") {
+				t.Errorf("output does not end in a newline: %q", buf.String())
+			}
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSourceLocation(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(Options{AddSource: true}.New(&buf)).Info("m")
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	loc, ok := got[SourceLocationKey].(map[string]any)
+	if !ok {
+		t.Fatalf("no source location: %s", buf.String())
+	}
+	if f, _ := loc["file"].(string); !strings.HasSuffix(f, "cloudlogging_test.go") {
+		t.Errorf("file: got %q", f)
+	}
+	if fn, _ := loc["function"].(string); !strings.HasSuffix(fn, "TestSourceLocation") {
+		t.Errorf("function: got %q", fn)
+	}
+	if _, ok := loc["line"].(string); !ok {
+		t.Errorf("line: got %v, want a string", loc["line"])
+	}
+}
+
+func TestConformance(t *testing.T) {
+	handlertest.Suite{
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			var copts Options
+			if opts != nil {
+				copts.Level = opts.Level
+				copts.AddSource = opts.AddSource
+				copts.ReplaceAttr = opts.ReplaceAttr
+			}
+			return copts.New(w)
+		},
+		Parse: parseEntries,
+	}.Run(t)
+}
+
+// parseEntries parses log entries for handlertest, restoring the keys
+// of the built-in Attrs and slog's names for the common levels.
+func parseEntries(data []byte) ([]map[string]any, error) {
+	ms, err := handlertest.ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		for from, to := range map[string]string{TimestampKey: slog.TimeKey, SeverityKey: slog.LevelKey, MessageKey: slog.MessageKey} {
+			if v, ok := m[from]; ok {
+				delete(m, from)
+				m[to] = v
+			}
+		}
+		if m[slog.LevelKey] == "WARNING" {
+			m[slog.LevelKey] = "WARN"
+		}
+	}
+	return ms, nil
+}
@@ -0,0 +1,163 @@
+// Slogreplay replays recorded logs through a handler, writing the output
+// to standard output and statistics to standard error.
+//
+// Usage:
+//
+//	slogreplay [flags] [file ...]
+//
+// With no files, it reads standard input. Files ending in ".bin" are read
+// as the output of a BinaryHandler, and others as JSON lines, unless
+// -format is given. For example, to replay a day of JSON logs at 60 times
+// their original pace as binary, with current times:
+//
+//	slogreplay -speed 60 -retime -out binary prod.jsonl > prod.bin
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jba/slog/handlers"
+	"github.com/jba/slog/handlers/discard"
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/jsonlog"
+	"github.com/jba/slog/levels"
+	"github.com/jba/slog/replay"
+)
+
+var (
+	format = flag.String("format", "", `input format, "json" or "binary"; if empty, chosen by file extension`)
+	out    = flag.String("out", "text", `output handler: "text", "json", "console", "binary" or "discard"`)
+	speed  = flag.Float64("speed", 0, "pace of the replay, as a multiple of the original; 0 for as fast as possible")
+	retime = flag.Bool("retime", false, "set each record's time to when it is replayed")
+	limit  = flag.Int("n", 0, "if positive, the most records to replay")
+	level  = flag.String("level", "DEBUG", "minimum level of records to replay")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("slogreplay: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: slogreplay [flags] [file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, flag.Args(), os.Stdin, os.Stdout, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, files []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	l, err := levels.Parse(*level)
+	if err != nil {
+		return err
+	}
+	h, closeHandler, err := newHandler(*out, l, stdout)
+	if err != nil {
+		return err
+	}
+	opts := replay.Options{
+		Speed:   *speed,
+		Retime:  *retime,
+		Limit:   *limit,
+		OnError: func(err error) { fmt.Fprintf(stderr, "slogreplay: %v\n", err) },
+	}
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var total replay.Stats
+	for _, file := range files {
+		src, closeSource, err := openSource(file, stdin)
+		if err != nil {
+			return err
+		}
+		stats, err := opts.Replay(ctx, src, h)
+		closeSource()
+		total.Records += stats.Records
+		total.Handled += stats.Handled
+		total.Errors += stats.Errors
+		total.Elapsed += stats.Elapsed
+		total.Recorded += stats.Recorded
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if opts.Limit > 0 {
+			if opts.Limit -= stats.Records; opts.Limit <= 0 {
+				break
+			}
+		}
+	}
+	if err := closeHandler(); err != nil {
+		return err
+	}
+	rate := 0.0
+	if s := total.Elapsed.Seconds(); s > 0 {
+		rate = float64(total.Handled) / s
+	}
+	fmt.Fprintf(stderr, "read %d records spanning %s; handled %d in %s (%.0f/s) with %d errors\n",
+		total.Records, total.Recorded, total.Handled, total.Elapsed, rate, total.Errors)
+	return nil
+}
+
+// openSource opens the named file, or stdin if the name is "-".
+func openSource(file string, stdin io.Reader) (replay.Source, func(), error) {
+	r := stdin
+	closeSource := func() {}
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		r = f
+		closeSource = func() { f.Close() }
+	}
+	f := *format
+	if f == "" {
+		f = "json"
+		if strings.HasSuffix(file, ".bin") {
+			f = "binary"
+		}
+	}
+	switch f {
+	case "json":
+		return jsonlog.NewReader(r), closeSource, nil
+	case "binary":
+		return replay.BinarySource(r), closeSource, nil
+	default:
+		closeSource()
+		return nil, nil, fmt.Errorf("unknown format %q", f)
+	}
+}
+
+// newHandler returns the handler named by out, which writes to w, and
+// a function to call when it is done.
+func newHandler(out string, l slog.Level, w io.Writer) (slog.Handler, func() error, error) {
+	nop := func() error { return nil }
+	hopts := &slog.HandlerOptions{Level: l, ReplaceAttr: levels.ReplaceAttr}
+	switch out {
+	case "text":
+		return slog.NewTextHandler(w, hopts), nop, nil
+	case "json":
+		return slog.NewJSONHandler(w, hopts), nop, nil
+	case "console":
+		t := general.DetectTerminal(w)
+		f := general.ConsoleOptions{Color: t.Color, Width: t.Width}.NewFormatter
+		return general.Options{Level: l}.New(w, f), nop, nil
+	case "binary":
+		h := handlers.NewBinaryHandler(w, l)
+		return h, h.Close, nil
+	case "discard":
+		return discard.New(l), nop, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown output %q", out)
+	}
+}
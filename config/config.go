@@ -0,0 +1,241 @@
+// Package config builds a handler pipeline from a configuration,
+// which can be read from JSON, YAML or TOML.
+//
+// A configuration lists outputs, each with its own format, destination
+// and level. Files can be rotated when they reach a size, and an output
+// can be limited to records with certain values of an Attr. A [Pipeline]
+// built from it fans records out to every output, and can be given a
+// new configuration at run time with [Pipeline.Reload], or whenever a
+// configuration file changes with [Pipeline.WatchFile].
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of a handler pipeline.
+// Its JSON form uses the field names in lower case, for example
+//
+//	{
+//	  "level": "INFO",
+//	  "outputs": [
+//	    {"format": "text", "path": "stderr"},
+//	    {"format": "json", "path": "/var/log/app.log", "level": "DEBUG", "addSource": true}
+//	  ]
+//	}
+type Config struct {
+	// Level is the minimum level of records, for outputs that do not
+	// have their own. It is a level name as accepted by
+	// [slog.Level.UnmarshalText], like "DEBUG" or "WARN+2".
+	// If empty, "INFO" is used.
+	Level string `json:"level,omitempty"`
+
+	// Outputs are the destinations of records. If empty, records are
+	// written to stderr in text format.
+	Outputs []Output `json:"outputs,omitempty"`
+}
+
+// Output configures one destination of a pipeline.
+type Output struct {
+	// Format is one of
+	//	text     slog.TextHandler
+	//	json     slog.JSONHandler
+	//	glog     general.Handler with the glog formatter
+	//	cbor     general.Handler with the CBOR formatter
+	//	console  general.Handler with a console formatter, colored and
+	//	         wrapped if the output is a terminal
+	//	binary   handlers.BinaryHandler
+	//	msgpack  msgpack.Handler
+	// If empty, "text" is used.
+	Format string `json:"format,omitempty"`
+
+	// Path is "stdout", "stderr" or the name of a file, which is
+	// created if necessary and appended to. If empty, "stderr" is used.
+	Path string `json:"path,omitempty"`
+
+	// MaxSize, if positive, is the largest size in bytes of the file at
+	// Path. When a record would make it larger, the file is rotated:
+	// it is renamed to Path followed by ".1", after the file with ".1"
+	// is renamed to ".2" and so on, and a new file is started.
+	MaxSize int64 `json:"maxSize,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep. The oldest
+	// are removed. If zero, one is kept.
+	MaxBackups int `json:"maxBackups,omitempty"`
+
+	// Level is the minimum level of records for this output.
+	// If empty, the Config's level is used.
+	Level string `json:"level,omitempty"`
+
+	// AddSource adds the source location to records, for formats
+	// that support it.
+	AddSource bool `json:"addSource,omitempty"`
+
+	// SampleEvery, if greater than 1, keeps only one out of every
+	// SampleEvery records below WARN. Records at WARN and above are
+	// always kept.
+	SampleEvery int `json:"sampleEvery,omitempty"`
+
+	// Tag is the Fluentd tag for the msgpack format.
+	Tag string `json:"tag,omitempty"`
+
+	// Route, if non-nil, restricts the output to the records it selects.
+	Route *Route `json:"route,omitempty"`
+}
+
+// A Route selects records by the value of an Attr, as in
+//
+//	{"key": "component", "values": ["db", "cache"]}
+type Route struct {
+	// Key is the Attr's key. Only Attrs outside of groups are
+	// considered, including those added with [slog.Logger.With].
+	Key string `json:"key"`
+
+	// Values are the values that select a record, compared with the
+	// Attr's value as formatted by [slog.Value.String].
+	Values []string `json:"values"`
+}
+
+var formats = map[string]bool{
+	"text":    true,
+	"json":    true,
+	"glog":    true,
+	"cbor":    true,
+	"console": true,
+	"binary":  true,
+	"msgpack": true,
+}
+
+// Load reads a Config in JSON form from r and validates it.
+// Unknown fields are an error.
+func Load(r io.Reader) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// LoadYAML is like [Load], reading the Config in YAML form. The keys
+// are the same as those of the JSON form.
+func LoadYAML(r io.Reader) (Config, error) {
+	var m map[string]any
+	if err := yaml.NewDecoder(r).Decode(&m); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	return loadMap(m)
+}
+
+// LoadTOML is like [Load], reading the Config in TOML form. The keys
+// are the same as those of the JSON form, with each output in an
+// [[outputs]] table.
+func LoadTOML(r io.Reader) (Config, error) {
+	var m map[string]any
+	if _, err := toml.NewDecoder(r).Decode(&m); err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	return loadMap(m)
+}
+
+// loadMap loads a Config from the generic form of a YAML or TOML file,
+// by way of JSON, so that all forms are read by the same rules.
+func loadMap(m map[string]any) (Config, error) {
+	if m == nil {
+		m = map[string]any{}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	return Load(bytes.NewReader(data))
+}
+
+// LoadFile reads a Config from the named file with Load, LoadYAML or
+// LoadTOML, according to whether its extension is ".json", ".yaml" or
+// ".yml", or ".toml".
+func LoadFile(name string) (Config, error) {
+	var load func(io.Reader) (Config, error)
+	switch filepath.Ext(name) {
+	case ".json":
+		load = Load
+	case ".yaml", ".yml":
+		load = LoadYAML
+	case ".toml":
+		load = LoadTOML
+	default:
+		return Config{}, fmt.Errorf("config: %s: unknown file extension", name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+	return load(f)
+}
+
+// Validate reports all the problems with c.
+func (c Config) Validate() error {
+	var errs []error
+	if _, err := parseLevel(c.Level, slog.LevelInfo); err != nil {
+		errs = append(errs, fmt.Errorf("config: %w", err))
+	}
+	for i, o := range c.Outputs {
+		for _, err := range o.validate() {
+			errs = append(errs, fmt.Errorf("config: output %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o Output) validate() []error {
+	var errs []error
+	if o.Format != "" && !formats[o.Format] {
+		errs = append(errs, fmt.Errorf("unknown format %q", o.Format))
+	}
+	if _, err := parseLevel(o.Level, slog.LevelInfo); err != nil {
+		errs = append(errs, err)
+	}
+	if o.SampleEvery < 0 {
+		errs = append(errs, errors.New("negative sampleEvery"))
+	}
+	if o.MaxSize < 0 || o.MaxBackups < 0 {
+		errs = append(errs, errors.New("negative maxSize or maxBackups"))
+	}
+	if o.MaxSize > 0 && (o.Path == "" || o.Path == "stderr" || o.Path == "stdout") {
+		errs = append(errs, errors.New("maxSize needs a file path"))
+	}
+	if o.Format == "msgpack" && o.Tag == "" {
+		errs = append(errs, errors.New("msgpack format needs a tag"))
+	}
+	if o.Route != nil && (o.Route.Key == "" || len(o.Route.Values) == 0) {
+		errs = append(errs, errors.New("route needs a key and values"))
+	}
+	return errs
+}
+
+// parseLevel parses s as a level, returning def if s is empty.
+func parseLevel(s string, def slog.Level) (slog.Level, error) {
+	if s == "" {
+		return def, nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, err
+	}
+	return l, nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load(strings.NewReader(`{
+		"level": "DEBUG",
+		"outputs": [{"format": "json", "path": "stdout", "sampleEvery": 10}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != "DEBUG" || len(c.Outputs) != 1 || c.Outputs[0].SampleEvery != 10 {
+		t.Errorf("got %+v", c)
+	}
+
+	if _, err := Load(strings.NewReader(`{"levle": "DEBUG"}`)); err == nil {
+		t.Error("unknown field: got nil error")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	want := Config{
+		Level: "DEBUG",
+		Outputs: []Output{
+			{Format: "json", Path: "stdout", SampleEvery: 10},
+			{Path: "app.log", AddSource: true, Route: &Route{Key: "c", Values: []string{"db"}}},
+		},
+	}
+	dir := t.TempDir()
+	for _, f := range []struct {
+		name, contents string
+	}{
+		{"c.json", `{
+			"level": "DEBUG",
+			"outputs": [
+				{"format": "json", "path": "stdout", "sampleEvery": 10},
+				{"path": "app.log", "addSource": true, "route": {"key": "c", "values": ["db"]}}
+			]
+		}`},
+		{"c.yaml", `
+level: DEBUG
+outputs:
+  - format: json
+    path: stdout
+    sampleEvery: 10
+  - path: app.log
+    addSource: true
+    route:
+      key: c
+      values: [db]
+`},
+		{"c.toml", `
+level = "DEBUG"
+
+[[outputs]]
+format = "json"
+path = "stdout"
+sampleEvery = 10
+
+[[outputs]]
+path = "app.log"
+addSource = true
+
+[outputs.route]
+key = "c"
+values = ["db"]
+`},
+	} {
+		name := filepath.Join(dir, f.name)
+		if err := os.WriteFile(name, []byte(f.contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadFile(name)
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", f.name, got, want)
+		}
+	}
+
+	// Unknown keys are errors in every form.
+	for _, test := range []struct {
+		load func(io.Reader) (Config, error)
+		in   string
+	}{
+		{Load, `{"levle": "DEBUG"}`},
+		{LoadYAML, "levle: DEBUG\n"},
+		{LoadTOML, "levle = 'DEBUG'\n"},
+	} {
+		if _, err := test.load(strings.NewReader(test.in)); err == nil || !strings.Contains(err.Error(), "levle") {
+			t.Errorf("%q: got %v, want unknown field error", test.in, err)
+		}
+	}
+	if _, err := LoadFile(filepath.Join(dir, "c.ini")); err == nil {
+		t.Error("unknown extension: got nil error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		c    Config
+		want []string // substrings of the error; none if valid
+	}{
+		{Config{}, nil},
+		{Config{Level: "WARN+2", Outputs: []Output{{Format: "msgpack", Tag: "app"}}}, nil},
+		{Config{Level: "LOUD"}, []string{"config: slog: level string \"LOUD\""}},
+		{
+			Config{Outputs: []Output{
+				{Format: "xml"},
+				{Level: "nope", SampleEvery: -1},
+				{Format: "msgpack"},
+				{MaxSize: 100},
+				{Route: &Route{Key: "k"}},
+			}},
+			[]string{
+				`config: output 0: unknown format "xml"`,
+				`config: output 1: slog: level string "nope"`,
+				"config: output 1: negative sampleEvery",
+				"config: output 2: msgpack format needs a tag",
+				"config: output 3: maxSize needs a file path",
+				"config: output 4: route needs a key and values",
+			},
+		},
+	} {
+		err := test.c.Validate()
+		if len(test.want) == 0 {
+			if err != nil {
+				t.Errorf("%+v: got %v, want nil", test.c, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%+v: got nil error", test.c)
+			continue
+		}
+		for _, w := range test.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%+v: error %q does not contain %q", test.c, err, w)
+			}
+		}
+	}
+}
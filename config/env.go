@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// Environment variables read by [FromEnv].
+const (
+	EnvLevel  = "LOG_LEVEL"  // minimum level, like "DEBUG"
+	EnvFormat = "LOG_FORMAT" // an Output format, like "json" or "console"
+	EnvOutput = "LOG_OUTPUT" // "stderr", "stdout" or a file name
+	EnvSource = "LOG_SOURCE" // a boolean, as parsed by strconv.ParseBool
+)
+
+// FromEnv returns a Config with a single output, built from the
+// environment variables LOG_LEVEL, LOG_FORMAT, LOG_OUTPUT and LOG_SOURCE.
+// Unset variables have their default values.
+func FromEnv() (Config, error) {
+	return configFromEnv(os.Getenv)
+}
+
+// NewLoggerFromEnv returns a logger for the Config from [FromEnv].
+// Files it opens stay open for the life of the program.
+func NewLoggerFromEnv() (*slog.Logger, error) {
+	c, err := FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	p, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(p.Handler()), nil
+}
+
+func configFromEnv(getenv func(string) string) (Config, error) {
+	o := Output{
+		Format: getenv(EnvFormat),
+		Path:   getenv(EnvOutput),
+	}
+	if s := getenv(EnvSource); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: %s: %w", EnvSource, err)
+		}
+		o.AddSource = b
+	}
+	c := Config{Level: getenv(EnvLevel), Outputs: []Output{o}}
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	for _, test := range []struct {
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			env:  nil,
+			want: Config{Outputs: []Output{{}}},
+		},
+		{
+			env: map[string]string{
+				EnvLevel:  "debug",
+				EnvFormat: "console",
+				EnvOutput: "/tmp/x.log",
+				EnvSource: "1",
+			},
+			want: Config{Level: "debug", Outputs: []Output{{Format: "console", Path: "/tmp/x.log", AddSource: true}}},
+		},
+		{
+			env:  map[string]string{EnvFormat: "binary"},
+			want: Config{Outputs: []Output{{Format: "binary"}}},
+		},
+		{env: map[string]string{EnvSource: "maybe"}, wantErr: true},
+		{env: map[string]string{EnvFormat: "xml"}, wantErr: true},
+		{env: map[string]string{EnvLevel: "LOUD"}, wantErr: true},
+	} {
+		got, err := configFromEnv(func(k string) string { return test.env[k] })
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: got nil error", test.env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", test.env, err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%v: mismatch (-want, +got):\n%s", test.env, diff)
+		}
+	}
+}
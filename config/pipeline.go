@@ -0,0 +1,333 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jba/slog/handlers"
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/handlers/msgpack"
+	"github.com/jba/slog/middleware"
+	"github.com/jba/slog/withsupport"
+)
+
+// A Pipeline is a handler built from a Config.
+type Pipeline struct {
+	mu    sync.Mutex // serializes Reload and Close
+	state atomic.Pointer[state]
+}
+
+// state is what is built from one Config.
+type state struct {
+	h       slog.Handler
+	closers []io.Closer
+}
+
+// New builds a Pipeline from c.
+func New(c Config) (*Pipeline, error) {
+	st, err := build(c)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipeline{}
+	p.state.Store(st)
+	return p, nil
+}
+
+// Handler returns a handler that writes to the pipeline's outputs.
+// The handler, and those derived from it with WithAttrs and WithGroup,
+// use the pipeline's current configuration, even after a Reload.
+func (p *Pipeline) Handler() slog.Handler {
+	return &handler{p: p, cache: &atomic.Pointer[cachedHandler]{}}
+}
+
+// Reload replaces the pipeline's configuration with c.
+// If c is invalid or cannot be built, the pipeline is unchanged.
+// Files opened for the previous configuration are closed; records
+// being written to them concurrently may be lost.
+func (p *Pipeline) Reload(c Config) error {
+	st, err := build(c)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Swap(st).close()
+}
+
+// Close closes the files opened by the pipeline.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Load().close()
+}
+
+func (st *state) close() error {
+	var errs []error
+	for _, c := range st.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// build constructs the handlers for c.
+func build(c Config) (_ *state, err error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	st := &state{}
+	defer func() {
+		if err != nil {
+			st.close()
+		}
+	}()
+	outputs := c.Outputs
+	if len(outputs) == 0 {
+		outputs = []Output{{}}
+	}
+	def, _ := parseLevel(c.Level, slog.LevelInfo)
+	var hs []slog.Handler
+	for _, o := range outputs {
+		w, err := st.open(o)
+		if err != nil {
+			return nil, err
+		}
+		level, _ := parseLevel(o.Level, def)
+		h, err := newHandler(w, o, level)
+		if err != nil {
+			return nil, err
+		}
+		if o.SampleEvery > 1 {
+			h = sample(int64(o.SampleEvery))(h)
+		}
+		if o.Route != nil {
+			h = newRoute(h, o.Route)
+		}
+		hs = append(hs, h)
+	}
+	if len(hs) == 1 {
+		st.h = hs[0]
+	} else {
+		st.h = fanout(hs)
+	}
+	return st, nil
+}
+
+// open returns the destination of o.
+func (st *state) open(o Output) (io.Writer, error) {
+	switch o.Path {
+	case "", "stderr":
+		return os.Stderr, nil
+	case "stdout":
+		return os.Stdout, nil
+	}
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	if o.MaxSize > 0 {
+		w, err = openRotating(o.Path, o.MaxSize, o.MaxBackups)
+	} else {
+		w, err = os.OpenFile(o.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.closers = append(st.closers, w)
+	return w, nil
+}
+
+func newHandler(w io.Writer, o Output, level slog.Level) (slog.Handler, error) {
+	hopts := &slog.HandlerOptions{Level: level, AddSource: o.AddSource}
+	gopts := general.Options{Level: level}
+	if o.AddSource {
+		gopts.PCAttrs = general.SourceOptions{Format: general.SourceBaseName, FileLine: true}.Attrs
+	}
+	switch o.Format {
+	case "", "text":
+		return slog.NewTextHandler(w, hopts), nil
+	case "json":
+		return slog.NewJSONHandler(w, hopts), nil
+	case "glog":
+		return gopts.New(w, general.NewGlogFormatter), nil
+	case "cbor":
+		return gopts.New(w, general.NewCBORFormatter), nil
+	case "console":
+		t := general.DetectTerminal(w)
+		return gopts.New(w, general.ConsoleOptions{Color: t.Color, Width: t.Width}.NewFormatter), nil
+	case "binary":
+		return handlers.BinaryOptions{Level: level, AddSource: o.AddSource}.New(w)
+	case "msgpack":
+		return msgpack.New(w, o.Tag, hopts), nil
+	default:
+		panic("unknown format " + o.Format) // checked by Validate
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// handler forwards to the current state's handler, with the attrs and
+// groups added by WithAttrs and WithGroup applied.
+type handler struct {
+	p     *Pipeline
+	goa   *withsupport.GroupOrAttrs
+	cache *atomic.Pointer[cachedHandler] // not shared among clones
+}
+
+// A cachedHandler is the handler derived from a state.
+type cachedHandler struct {
+	st *state
+	h  slog.Handler
+}
+
+func (h *handler) current() slog.Handler {
+	st := h.p.state.Load()
+	if c := h.cache.Load(); c != nil && c.st == st {
+		return c.h
+	}
+	sh := st.h
+	for _, g := range h.goa.Collect() {
+		if g.Group != "" {
+			sh = sh.WithGroup(g.Group)
+		} else {
+			sh = sh.WithAttrs(g.Attrs)
+		}
+	}
+	h.cache.Store(&cachedHandler{st, sh})
+	return sh
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.current().Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	return &handler{p: h.p, goa: h.goa.WithAttrs(as), cache: &atomic.Pointer[cachedHandler]{}}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{p: h.p, goa: h.goa.WithGroup(name), cache: &atomic.Pointer[cachedHandler]{}}
+}
+
+////////////////////////////////////////////////////////////////
+
+// fanout sends records to all of its handlers that are enabled.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(as []slog.Attr) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithAttrs(as)
+	}
+	return f2
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithGroup(name)
+	}
+	return f2
+}
+
+// sample returns a Middleware that keeps one out of every "every"
+// records below WARN.
+func sample(every int64) middleware.Middleware {
+	var n atomic.Int64 // shared among clones
+	return middleware.Transform(middleware.RecordTransformerFunc(func(_ context.Context, r slog.Record) (slog.Record, bool) {
+		return r, r.Level >= slog.LevelWarn || (n.Add(1)-1)%every == 0
+	}))
+}
+
+////////////////////////////////////////////////////////////////
+
+// route passes to h only the records selected by a Route.
+type route struct {
+	h       slog.Handler
+	key     string
+	values  map[string]bool
+	matched bool // an Attr from WithAttrs was selected
+	grouped bool // later Attrs are in a group, so they aren't considered
+}
+
+func newRoute(h slog.Handler, r *Route) *route {
+	values := map[string]bool{}
+	for _, v := range r.Values {
+		values[v] = true
+	}
+	return &route{h: h, key: r.Key, values: values}
+}
+
+func (r *route) selects(a slog.Attr) bool {
+	return a.Key == r.key && r.values[a.Value.Resolve().String()]
+}
+
+func (r *route) Enabled(ctx context.Context, level slog.Level) bool {
+	return r.h.Enabled(ctx, level)
+}
+
+func (r *route) Handle(ctx context.Context, rec slog.Record) error {
+	ok := r.matched
+	if !ok && !r.grouped {
+		rec.Attrs(func(a slog.Attr) bool {
+			ok = r.selects(a)
+			return !ok
+		})
+	}
+	if !ok {
+		return nil
+	}
+	return r.h.Handle(ctx, rec)
+}
+
+func (r *route) WithAttrs(as []slog.Attr) slog.Handler {
+	r2 := *r
+	r2.h = r.h.WithAttrs(as)
+	if !r.grouped && !r.matched {
+		for _, a := range as {
+			if r.selects(a) {
+				r2.matched = true
+				break
+			}
+		}
+	}
+	return &r2
+}
+
+func (r *route) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	r2 := *r
+	r2.h = r.h.WithGroup(name)
+	r2.grouped = true
+	return &r2
+}
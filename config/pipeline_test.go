@@ -0,0 +1,145 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/binary"
+)
+
+func TestPipeline(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "text.log")
+	jsonFile := filepath.Join(dir, "json.log")
+	p, err := New(Config{
+		Level: "WARN",
+		Outputs: []Output{
+			{Path: text},
+			{Format: "json", Path: jsonFile, Level: "DEBUG"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(p.Handler()).With("a", 1).WithGroup("g")
+	l.Debug("d", "b", 2)
+	l.Warn("w", "b", 3)
+
+	if err := p.Reload(Config{Outputs: []Output{{Path: text, SampleEvery: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Info("i", "n", i)
+	}
+	l.Warn("w2")
+	if err := p.Reload(Config{Level: "bad"}); err == nil {
+		t.Error("Reload with bad config: got nil error")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkLines(t, text,
+		"level=WARN msg=w a=1 g.b=3",
+		"level=INFO msg=i a=1 g.n=0",
+		"level=INFO msg=i a=1 g.n=2",
+		"level=WARN msg=w2 a=1",
+	)
+	checkLines(t, jsonFile,
+		`"level":"DEBUG","msg":"d","a":1,"g":{"b":2}}`,
+		`"level":"WARN","msg":"w","a":1,"g":{"b":3}}`,
+	)
+}
+
+func TestRoute(t *testing.T) {
+	dir := t.TempDir()
+	all := filepath.Join(dir, "all.log")
+	db := filepath.Join(dir, "db.log")
+	p, err := New(Config{Outputs: []Output{
+		{Path: all},
+		{Path: db, Route: &Route{Key: "component", Values: []string{"db", "cache"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(p.Handler())
+	l.Info("a", "component", "web")
+	l.Info("b", "component", "db")
+	l.With("component", "cache").Info("c")
+	l.With("component", "cache").WithGroup("g").Info("d", "x", 1)
+	// Only Attrs outside groups select a record.
+	l.WithGroup("g").Info("e", "component", "db")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkLines(t, all,
+		"msg=a component=web",
+		"msg=b component=db",
+		"msg=c component=cache",
+		"msg=d component=cache g.x=1",
+		"msg=e g.component=db",
+	)
+	checkLines(t, db,
+		"msg=b component=db",
+		"msg=c component=cache",
+		"msg=d component=cache g.x=1",
+	)
+}
+
+func TestConsole(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "console.log")
+	p, err := New(Config{Outputs: []Output{{Format: "console", Path: file}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(p.Handler()).Info("m", "a", 1)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// A file is not a terminal, so there are no colors.
+	checkLines(t, file, " INFO  m a=1")
+}
+
+func TestBinaryAddSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log.bin")
+	p, err := New(Config{Outputs: []Output{{Format: "binary", Path: file, AddSource: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(p.Handler()).Info("m")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := binary.Query(file, binary.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("got %d records, want 1", len(rs))
+	}
+	if src := binary.RecordSource(rs[0]); src == nil || filepath.Base(src.File) != "pipeline_test.go" {
+		t.Errorf("got source %+v, want one in pipeline_test.go", src)
+	}
+}
+
+// checkLines checks that each line of the file ends with the
+// corresponding suffix, ignoring the time.
+func checkLines(t *testing.T, filename string, suffixes ...string) {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(suffixes) {
+		t.Fatalf("%s: got %d lines, want %d:\n%s", filepath.Base(filename), len(lines), len(suffixes), data)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, suffixes[i]) {
+			t.Errorf("%s: line %d:\ngot  %s\nwant suffix %s", filepath.Base(filename), i, line, suffixes[i])
+		}
+	}
+}
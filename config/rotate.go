@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// A rotatingFile is a file that is renamed, and replaced by a new one,
+// when a write would make it larger than maxSize. The renamed files
+// are the path followed by ".1", ".2" and so on, most recent first;
+// only maxBackups of them are kept.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openRotating(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxBackups: max(maxBackups, 1)}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = info.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it first if p would make it too
+// large. A record larger than maxSize is written to an empty file.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, renames it and the older backups, and opens
+// a new file. The file is opened again even if a rename fails, so that
+// later writes succeed, going to the old file if it wasn't renamed.
+func (rf *rotatingFile) rotate() error {
+	err := rf.f.Close()
+	if err == nil {
+		err = rf.renameBackups()
+	}
+	if oerr := rf.open(); oerr != nil {
+		return errors.Join(err, oerr)
+	}
+	return err
+}
+
+// renameBackups renames the file to the first backup, after renaming
+// each backup to the next. The oldest backup, if there are maxBackups,
+// is replaced.
+func (rf *rotatingFile) renameBackups() error {
+	for i := rf.maxBackups - 1; i >= 0; i-- {
+		from := rf.path
+		if i > 0 {
+			from += "." + strconv.Itoa(i)
+		}
+		err := os.Rename(from, rf.path+"."+strconv.Itoa(i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
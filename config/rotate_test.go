@@ -0,0 +1,98 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf, err := openRotating(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "a record too long\n", "dddd\n", "eeee\n"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The first file, with "aaaa" and "bbbb", was removed.
+	for name, want := range map[string]string{
+		"app.log":   "dddd eeee",
+		"app.log.1": "a record too long",
+		"app.log.2": "cccc",
+	} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(strings.Fields(string(data)), " "); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("too many backups")
+	}
+}
+
+func TestPipelineRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	p, err := New(Config{Outputs: []Output{{Format: "json", Path: path, MaxSize: 100}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(p.Handler())
+	l.Info("first")
+	l.Info("second")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkLines(t, path, `"msg":"second"}`)
+	checkLines(t, path+".1", `"msg":"first"}`)
+}
+
+func TestRotatingFileRenameError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	rf, err := openRotating(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	// The file can't be renamed over a directory.
+	if err := os.Mkdir(path+".1", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("aaaa\nbbbb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("cccc\n")); err == nil {
+		t.Fatal("got nil, want rename error")
+	}
+	// The file is still open, and rotates once it can.
+	if err := os.Remove(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"dddd\n", "eeee\n"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{
+		"app.log":   "dddd eeee",
+		"app.log.1": "aaaa bbbb",
+	} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(strings.Fields(string(data)), " "); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
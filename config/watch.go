@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchFile reloads the pipeline with the Config read from the named
+// file by [LoadFile], and again whenever fsnotify reports that the file
+// was written or created. The file's directory is watched, so a file
+// that is replaced by renaming another over it, as editors and
+// configuration systems do, is still seen. The file is read once before
+// WatchFile returns, and its error, if any, is returned along with the
+// stop function. Later errors, which leave the pipeline unchanged, are
+// passed to onError if it is non-nil.
+//
+// WatchFile returns a function that stops watching; after it returns,
+// the pipeline is no longer reloaded. The stop function may be called
+// more than once.
+func (p *Pipeline) WatchFile(name string, onError func(error)) (stop func(), err error) {
+	name = filepath.Clean(name)
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return func() {}, fmt.Errorf("config: %w", err)
+	}
+	if err := w.Add(filepath.Dir(name)); err != nil {
+		w.Close()
+		return func() {}, fmt.Errorf("config: %w", err)
+	}
+	reload := func() error {
+		c, err := LoadFile(name)
+		if err != nil {
+			return err
+		}
+		return p.Reload(c)
+	}
+	err = reload()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		report := func(err error) {
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case ev := <-w.Events:
+				if filepath.Clean(ev.Name) == name && ev.Has(fsnotify.Write|fsnotify.Create) {
+					report(reload())
+				}
+			case err := <-w.Errors:
+				report(fmt.Errorf("config: %w", err))
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			w.Close()
+		})
+	}, err
+}
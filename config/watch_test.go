@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "log.yaml")
+	out := filepath.Join(dir, "out.log")
+	write := func(s string) {
+		t.Helper()
+		// Replace the file by renaming, so it is never seen partly written.
+		tmp := filepath.Join(dir, "tmp")
+		if err := os.WriteFile(tmp, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("level: WARN\noutputs: [{path: " + out + "}]\n")
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	p, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	stop, err := p.WatchFile(name, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	ctx := context.Background()
+	h := p.Handler()
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		for i := 0; i < 5000; i++ {
+			if cond() {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("timed out")
+	}
+	if h.Enabled(ctx, slog.LevelInfo) {
+		t.Fatal("initial config not loaded")
+	}
+
+	write("level: DEBUG\noutputs: [{path: " + out + "}]\n")
+	waitFor(func() bool { return h.Enabled(ctx, slog.LevelDebug) })
+
+	write("level: LOUD\n")
+	waitFor(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("invalid file replaced the config")
+	}
+
+	stop()
+	stop()
+	write("level: ERROR\n")
+	time.Sleep(10 * time.Millisecond)
+	if !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("config reloaded after stop")
+	}
+}
@@ -0,0 +1,79 @@
+package enrich
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/jba/slog/middleware"
+)
+
+// ResourceOptions are options for the Attrs that describe the process
+// that logs records: the host, process ID, service and build.
+// By default, all that are known are included.
+type ResourceOptions struct {
+	// Service is the name of the service, with key "service".
+	// It is omitted if empty.
+	Service string
+
+	// Version is the version of the service, with key "version".
+	// If empty, the version of the main module from the build info is
+	// used, unless it is unknown.
+	Version string
+
+	// Group, if non-empty, is the name of a group that holds the Attrs.
+	Group string
+
+	NoHostname  bool // omit "host", from os.Hostname
+	NoPID       bool // omit "pid"
+	NoGoVersion bool // omit "go_version", from runtime.Version
+	NoBuildInfo bool // omit "revision", the VCS revision from the build info
+}
+
+// Attrs returns the Attrs for opts.
+func (opts ResourceOptions) Attrs() []slog.Attr {
+	var as []slog.Attr
+	if !opts.NoHostname {
+		if h, err := os.Hostname(); err == nil {
+			as = append(as, slog.String("host", h))
+		}
+	}
+	if !opts.NoPID {
+		as = append(as, slog.Int("pid", os.Getpid()))
+	}
+	if opts.Service != "" {
+		as = append(as, slog.String("service", opts.Service))
+	}
+	info, ok := debug.ReadBuildInfo()
+	version := opts.Version
+	if version == "" && ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	if version != "" {
+		as = append(as, slog.String("version", version))
+	}
+	if !opts.NoGoVersion {
+		as = append(as, slog.String("go_version", runtime.Version()))
+	}
+	if !opts.NoBuildInfo && ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				as = append(as, slog.String("revision", s.Value))
+			}
+		}
+	}
+	if opts.Group != "" && len(as) > 0 {
+		as = []slog.Attr{{Key: opts.Group, Value: slog.GroupValue(as...)}}
+	}
+	return as
+}
+
+// Middleware returns a Middleware that adds the Attrs for opts to a
+// handler with WithAttrs, so they are computed and formatted once.
+func (opts ResourceOptions) Middleware() middleware.Middleware {
+	as := opts.Attrs()
+	return func(h slog.Handler) slog.Handler {
+		return h.WithAttrs(as)
+	}
+}
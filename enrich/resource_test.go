@@ -0,0 +1,47 @@
+package enrich
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestResource(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, test := range []struct {
+		name string
+		opts ResourceOptions
+		want string
+	}{
+		{
+			name: "all",
+			opts: ResourceOptions{Service: "svc", Version: "v1.2.3", NoBuildInfo: true},
+			want: fmt.Sprintf("msg=m host=%s pid=%d service=svc version=v1.2.3 go_version=%s\n",
+				host, os.Getpid(), runtime.Version()),
+		},
+		{
+			name: "group",
+			opts: ResourceOptions{Group: "res", NoHostname: true, NoGoVersion: true, NoBuildInfo: true},
+			want: fmt.Sprintf("msg=m res.pid=%d\n", os.Getpid()),
+		},
+		{
+			name: "none",
+			opts: ResourceOptions{Group: "res", NoHostname: true, NoPID: true, NoGoVersion: true, NoBuildInfo: true},
+			want: "msg=m\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(test.opts.Middleware()(newTextHandler(&buf))).Info("m")
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
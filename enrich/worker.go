@@ -0,0 +1,81 @@
+// Package enrich adds attributes that describe where records come from.
+package enrich
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/jba/slog/middleware"
+)
+
+type workerKey struct{}
+
+// WithWorkerID returns a copy of ctx that holds id, to identify the
+// worker that logs records with ctx. It is logged by handlers made with
+// [WorkerOptions.Middleware].
+func WithWorkerID(ctx context.Context, id any) context.Context {
+	return context.WithValue(ctx, workerKey{}, id)
+}
+
+// WorkerID returns the ID stored in ctx by [WithWorkerID], or nil.
+func WorkerID(ctx context.Context) any {
+	return ctx.Value(workerKey{})
+}
+
+// WorkerOptions are options for adding the worker that logs a record
+// to it, to help tell apart the interleaved output of concurrent code.
+type WorkerOptions struct {
+	// Key is the key of the Attr. If empty, "worker" is used.
+	Key string
+
+	// If Goroutine is true, records whose contexts have no worker ID get
+	// the ID of the goroutine that logs them. Getting it takes about a
+	// microsecond.
+	Goroutine bool
+}
+
+// Middleware returns a Middleware that adds the worker ID of each
+// record's context as an Attr. Records without one are unchanged,
+// unless opts.Goroutine is set.
+//
+// As with other handlers made by [middleware.Transform], the Attr is
+// in the groups added by WithGroup after the Middleware is applied.
+func (opts WorkerOptions) Middleware() middleware.Middleware {
+	key := opts.Key
+	if key == "" {
+		key = "worker"
+	}
+	return middleware.Transform(middleware.RecordTransformerFunc(func(ctx context.Context, r slog.Record) (slog.Record, bool) {
+		var a slog.Attr
+		if id := WorkerID(ctx); id != nil {
+			a = slog.Any(key, id)
+		} else if opts.Goroutine {
+			a = slog.Uint64(key, goroutineID())
+		} else {
+			return r, true
+		}
+		r = r.Clone()
+		r.AddAttrs(a)
+		return r, true
+	}))
+}
+
+// goroutineID returns the ID of the current goroutine, from the first
+// line of its stack trace, which looks like "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	const prefix = "goroutine "
+	if len(b) < len(prefix) || string(b[:len(prefix)]) != prefix {
+		return 0
+	}
+	var id uint64
+	for _, c := range b[len(prefix):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+	}
+	return id
+}
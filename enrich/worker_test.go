@@ -0,0 +1,70 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestWorker(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts WorkerOptions
+		id   any
+		want string // regexp
+	}{
+		{"id", WorkerOptions{}, 3, `^msg=m worker=3\n$`},
+		{"key", WorkerOptions{Key: "w", Goroutine: true}, "fetch-1", `^msg=m w=fetch-1\n$`},
+		{"none", WorkerOptions{}, nil, `^msg=m\n$`},
+		{"goroutine", WorkerOptions{Goroutine: true}, nil, `^msg=m worker=[1-9][0-9]*\n$`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := slog.New(test.opts.Middleware()(newTextHandler(&buf)))
+			ctx := context.Background()
+			if test.id != nil {
+				ctx = WithWorkerID(ctx, test.id)
+			}
+			l.InfoContext(ctx, "m")
+			if got := buf.String(); !regexp.MustCompile(test.want).MatchString(got) {
+				t.Errorf("got %q, want match for %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	if id == 0 {
+		t.Fatal("got 0")
+	}
+	if goroutineID() != id {
+		t.Error("not stable")
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var other uint64
+	go func() {
+		defer wg.Done()
+		other = goroutineID()
+	}()
+	wg.Wait()
+	if other == id || other == 0 {
+		t.Errorf("other goroutine: got %d; this one is %d", other, id)
+	}
+}
+
+// newTextHandler returns a text handler that omits the time and level.
+func newTextHandler(buf *bytes.Buffer) slog.Handler {
+	return slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
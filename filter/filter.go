@@ -0,0 +1,140 @@
+// Package filter provides a handler that removes attributes by key.
+package filter
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Options are options for a [Handler].
+//
+// Patterns are dotted paths of group names and keys, like
+// "http.request.method". Each element can be a pattern as accepted by
+// [path.Match], so "http.request.headers.*" matches every key in the
+// headers group. A pattern that matches a group matches every attribute
+// in it, however deeply nested.
+type Options struct {
+	// Allow, if non-empty, lists the patterns of attributes to keep.
+	// Other attributes are removed.
+	Allow []string
+
+	// Deny lists the patterns of attributes to remove.
+	// It applies after Allow.
+	Deny []string
+}
+
+// Handler removes attributes from records and passes them to another
+// handler. The built-in attributes are never removed.
+//
+// LogValuers are resolved before filtering, so the attributes of the groups
+// they produce are filtered as well. Groups left empty are removed.
+type Handler struct {
+	allow, deny [][]string // split patterns
+	groups      []string
+	h           slog.Handler
+}
+
+// New returns a Handler that passes filtered records to h.
+func (opts Options) New(h slog.Handler) *Handler {
+	return &Handler{
+		allow: splitPatterns(opts.Allow),
+		deny:  splitPatterns(opts.Deny),
+		h:     h,
+	}
+}
+
+func splitPatterns(ps []string) [][]string {
+	var res [][]string
+	for _, p := range ps {
+		res = append(res, strings.Split(p, "."))
+	}
+	return res
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	h2.h = h.h.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	as = h.filter(h.groups, as)
+	if len(as) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.h = h.h.WithAttrs(as)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var as []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		as = append(as, a)
+		return true
+	})
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r2.AddAttrs(h.filter(h.groups, as)...)
+	return h.h.Handle(ctx, r2)
+}
+
+// filter returns the attributes of as, which are in the given groups,
+// that should be kept.
+func (h *Handler) filter(groups []string, as []slog.Attr) []slog.Attr {
+	var res []slog.Attr
+	for _, a := range as {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			gs := groups
+			if a.Key != "" {
+				gs = append(slices.Clip(groups), a.Key)
+			}
+			if members := h.filter(gs, a.Value.Group()); len(members) > 0 {
+				if a.Key == "" {
+					res = append(res, members...)
+				} else {
+					res = append(res, slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)})
+				}
+			}
+			continue
+		}
+		p := append(slices.Clip(groups), a.Key)
+		if (len(h.allow) == 0 || matchAny(h.allow, p)) && !matchAny(h.deny, p) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// matchAny reports whether any of the patterns matches a prefix of p.
+func matchAny(patterns [][]string, p []string) bool {
+	for _, pat := range patterns {
+		if matchPrefix(pat, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPrefix(pat, p []string) bool {
+	if len(pat) > len(p) {
+		return false
+	}
+	for i, e := range pat {
+		if ok, _ := path.Match(e, p[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/handlertest"
+)
+
+type request struct{}
+
+func (request) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("method", "GET"),
+		slog.Group("headers", slog.String("Accept", "*/*"), slog.String("Cookie", "c")),
+	)
+}
+
+func TestHandler(t *testing.T) {
+	for _, test := range []struct {
+		opts Options
+		want string
+	}{
+		{
+			Options{},
+			"a=1 http.request.method=GET http.request.headers.Accept=*/* http.request.headers.Cookie=c http.b=2",
+		},
+		{
+			Options{Deny: []string{"http.request.headers.*"}},
+			"a=1 http.request.method=GET http.b=2",
+		},
+		{
+			Options{Deny: []string{"http.*.headers.Cookie", "a"}},
+			"http.request.method=GET http.request.headers.Accept=*/* http.b=2",
+		},
+		{
+			Options{Allow: []string{"http.request"}, Deny: []string{"*.*.method"}},
+			"http.request.headers.Accept=*/* http.request.headers.Cookie=c",
+		},
+		{
+			Options{Allow: []string{"a"}},
+			"a=1",
+		},
+	} {
+		var buf bytes.Buffer
+		th := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+		l := slog.New(test.opts.New(th))
+		l.With("a", 1).WithGroup("http").Info("m", "request", request{}, "b", 2)
+		if got := strings.TrimSpace(buf.String()); got != test.want {
+			t.Errorf("%+v:\ngot  %s\nwant %s", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	handlertest.TestHandler(t, func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+		return Options{}.New(slog.NewJSONHandler(w, opts))
+	}, handlertest.ParseJSON)
+}
@@ -3,12 +3,17 @@ module github.com/jba/slog
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-kit/log v0.2.1
+	github.com/go-logr/logr v1.4.2
 	github.com/google/go-cmp v0.5.9
 	go.opentelemetry.io/otel/trace v1.11.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/go-logfmt/logfmt v0.5.1 // indirect
 	go.opentelemetry.io/otel v1.11.2 // indirect
+	golang.org/x/sys v0.4.0 // indirect
 )
@@ -1,4 +1,5 @@
-// Package gokit provides a go-kit/log.Logger that uses a slog.Handler.
+// Package gokit connects go-kit/log and slog: it provides a go-kit/log.Logger
+// that uses a slog.Handler, and a slog.Handler that uses a go-kit/log.Logger.
 //
 // This is a PROOF OF CONCEPT. It is not production-ready.
 package gokit
@@ -11,6 +12,7 @@ import (
 
 	gklog "github.com/go-kit/log"
 	gklevel "github.com/go-kit/log/level"
+	"github.com/jba/slog/internal/keyvals"
 )
 
 // New returns go-kit logger that calls h.Handle.
@@ -73,3 +75,82 @@ func (l *logger) Log(keyvals ...any) error {
 	r.AddAttrs(attrs...)
 	return l.h.Handle(context.Background(), r)
 }
+
+////////////////////////////////////////////////////////////////
+
+// NewHandler returns a slog.Handler that writes to l.
+// It is the inverse of [New].
+//
+// The message is passed to l with the key messageKey, unless messageKey
+// is empty, in which case the message is omitted. The level is passed as a
+// go-kit/log/level.Value: levels below INFO become debug, and the others
+// become the level with the same name as the slog level's range.
+// Groups are represented by prefixing keys with the group name and a dot.
+//
+// The handler is enabled for records at or above level. If level is nil,
+// it uses slog.LevelInfo.
+//
+// The record's time is not passed to l. Use go-kit's log.With and
+// log.DefaultTimestamp to add timestamps.
+func NewHandler(l gklog.Logger, messageKey string, level slog.Leveler) slog.Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &handler{l: l, messageKey: messageKey, level: level}
+}
+
+type handler struct {
+	l          gklog.Logger
+	messageKey string
+	level      slog.Leveler
+	prefix     string // group names, each followed by a dot
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	kvs := make([]any, 0, 4+2*r.NumAttrs())
+	kvs = append(kvs, gklevel.Key(), gokitLevel(r.Level))
+	if h.messageKey != "" {
+		kvs = append(kvs, h.messageKey, r.Message)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = keyvals.Append(kvs, h.prefix, a)
+		return true
+	})
+	return h.l.Log(kvs...)
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	var kvs []any
+	for _, a := range as {
+		kvs = keyvals.Append(kvs, h.prefix, a)
+	}
+	h2 := *h
+	h2.l = gklog.With(h.l, kvs...)
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+func gokitLevel(l slog.Level) gklevel.Value {
+	switch {
+	case l < slog.LevelInfo:
+		return gklevel.DebugValue()
+	case l < slog.LevelWarn:
+		return gklevel.InfoValue()
+	case l < slog.LevelError:
+		return gklevel.WarnValue()
+	default:
+		return gklevel.ErrorValue()
+	}
+}
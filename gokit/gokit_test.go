@@ -2,11 +2,16 @@ package gokit
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"log/slog"
 	"strings"
 	"testing"
 
+	gklog "github.com/go-kit/log"
 	gklevel "github.com/go-kit/log/level"
+
+	"github.com/jba/slog/handlertest"
 )
 
 func Test(t *testing.T) {
@@ -23,3 +28,82 @@ func Test(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	gl := gklog.NewLogfmtLogger(&buf)
+	logger := slog.New(NewHandler(gl, "msg", slog.LevelDebug))
+	logger.Debug("debug", "a", 1)
+	logger.Log(context.Background(), slog.LevelDebug-1, "disabled")
+	logger.Info("info")
+	logger.With("b", 2).WithGroup("g").Warn("warn", slog.Group("h", "c", 3), "d", 4)
+	logger.Error("error", "err", io.EOF)
+	got := buf.String()
+	want := `level=debug msg=debug a=1
+level=info msg=info
+b=2 level=warn msg=warn g.h.c=3 g.d=4
+level=error msg=error err=EOF
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHandlerEmptyGroup(t *testing.T) {
+	h := NewHandler(gklog.NewNopLogger(), "msg", nil)
+	if h.WithGroup("") != h {
+		t.Error("WithGroup with an empty name did not return the receiver")
+	}
+}
+
+func TestHandlerConformance(t *testing.T) {
+	handlertest.Suite{
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			gl := gklog.NewJSONLogger(gklog.NewSyncWriter(w))
+			gl = gklog.With(gl, slog.TimeKey, gklog.DefaultTimestampUTC)
+			var level slog.Leveler
+			if opts != nil {
+				level = opts.Level
+			}
+			return NewHandler(gl, slog.MessageKey, level)
+		},
+		Parse: parseJSON,
+		Skip: []string{
+			// The time comes from the go-kit logger, not the record.
+			"zero time",
+			// There is no ReplaceAttr option.
+			"ReplaceAttr remove built-ins", "ReplaceAttr groups", "ReplaceAttr not called on groups",
+		},
+	}.Run(t)
+}
+
+// parseJSON parses the output of a go-kit JSON logger for handlertest.
+// It upper-cases go-kit level names and turns the dotted keys of
+// groups into nested maps.
+func parseJSON(data []byte) ([]map[string]any, error) {
+	ms, err := handlertest.ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range ms {
+		nested := map[string]any{}
+		for k, v := range m {
+			if k == slog.LevelKey {
+				v = strings.ToUpper(v.(string))
+			}
+			path := strings.Split(k, ".")
+			g := nested
+			for _, name := range path[:len(path)-1] {
+				sub, ok := g[name].(map[string]any)
+				if !ok {
+					sub = map[string]any{}
+					g[name] = sub
+				}
+				g = sub
+			}
+			g[path[len(path)-1]] = v
+		}
+		ms[i] = nested
+	}
+	return ms, nil
+}
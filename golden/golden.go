@@ -0,0 +1,101 @@
+// Package golden compares log output with golden files.
+//
+// Records, typically captured with a [memory.Handler], are rendered in a
+// deterministic text format and compared with the contents of a file.
+// Run tests with the -golden.update flag to rewrite the files with the
+// current output.
+//
+// [memory.Handler]: https://pkg.go.dev/github.com/jba/slog/handlers/memory#Handler
+package golden
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/handlers/general"
+)
+
+// update is named for the package so it doesn't clash with an -update
+// flag defined by the test itself.
+var update = flag.Bool("golden.update", false, "update golden files")
+
+// Render returns a deterministic rendering of rs, one record per line.
+// Each line is formatted like the output of [slog.TextHandler], but
+// without the record's time or source location.
+func Render(rs []slog.Record) []byte {
+	var buf bytes.Buffer
+	h := general.Options{
+		Level:       slog.Level(math.MinInt), // render every record
+		ReplaceAttr: removeTime,
+	}.New(&buf, general.NewTextFormatter)
+	for _, r := range rs {
+		_ = h.Handle(context.Background(), r) // writing to a bytes.Buffer cannot fail
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func removeTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+// Check compares the rendering of rs with the file testdata/name.golden.
+// See [CheckFile].
+func Check(t testing.TB, name string, rs []slog.Record) {
+	t.Helper()
+	CheckFile(t, filepath.Join("testdata", name+".golden"), rs)
+}
+
+// CheckFile compares the rendering of rs with the contents of the file.
+// If the -golden.update flag is set, it writes the rendering to the file instead.
+func CheckFile(t testing.TB, filename string, rs []slog.Record) {
+	t.Helper()
+	got := Render(rs)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("%v (run with -golden.update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: output differs from golden file (run with -golden.update to update it)\n%s",
+			filename, diff(string(got), string(want)))
+	}
+}
+
+// diff describes the first difference between the lines of got and want.
+func diff(got, want string) string {
+	gl := strings.Split(got, "\n")
+	wl := strings.Split(want, "\n")
+	for i := 0; i < len(gl) || i < len(wl); i++ {
+		var g, w string
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if g != w {
+			return fmt.Sprintf("first difference at line %d:\ngot  %s\nwant %s", i+1, g, w)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package golden
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers/memory"
+)
+
+func records() []slog.Record {
+	h := memory.New(&slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(h)
+	logger.Debug("starting", "n", 3)
+	logger.With("req", 7).WithGroup("g").Info("handled", "dur", time.Second)
+	logger.Error("failed", "err", "bad thing")
+	return h.Records()
+}
+
+func TestCheck(t *testing.T) {
+	Check(t, "records", records())
+}
+
+func TestRender(t *testing.T) {
+	got := string(Render(records()))
+	want := `level=DEBUG msg=starting n=3
+level=INFO msg=handled req=7 g.dur=1s
+level=ERROR msg=failed err="bad thing"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sub", "x.golden")
+	rs := records()
+
+	ft := &fakeT{TB: t}
+	CheckFile(ft, filename, rs)
+	if !ft.failed {
+		t.Fatal("missing golden file: did not fail")
+	}
+
+	setUpdate(t, true)
+	CheckFile(t, filename, rs)
+	setUpdate(t, false)
+	CheckFile(t, filename, rs)
+
+	ft = &fakeT{TB: t}
+	CheckFile(ft, filename, rs[:1])
+	if !ft.failed {
+		t.Error("different output: did not fail")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(Render(rs)) {
+		t.Errorf("file contents:\n%s", data)
+	}
+}
+
+// A test's own -update flag must not clash with the package's.
+var _ = flag.Bool("update", false, "update this package's test data")
+
+func setUpdate(t *testing.T, b bool) {
+	old := *update
+	*update = b
+	t.Cleanup(func() { *update = old })
+}
+
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(string, ...any) { t.failed = true }
+
+func (t *fakeT) Fatalf(string, ...any) { t.failed = true }
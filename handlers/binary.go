@@ -1,26 +1,166 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/binary"
+	"github.com/jba/slog/slogsize"
+	"github.com/jba/slog/withsupport"
+	"github.com/jba/slog/writers/compress"
 )
 
-// BinaryHandler uses the format in github.com/jba/slog/binary
+// BinaryHandler uses the format in github.com/jba/slog/binary.
+// Each record is written as a single frame, encoded with
+// [binary.Encoder.EncodeRecord]. The source location is recorded only if
+// [BinaryOptions.AddSource] is set.
 type BinaryHandler struct {
-	w     io.Writer
 	level slog.Leveler
+	goa   *withsupport.GroupOrAttrs
+	cw    *compress.Writer    // non-nil if compressing
+	iw    *binary.IndexWriter // non-nil if indexing
+	loc   *time.Location      // if non-nil, convert record times to this
+	src   bool                // record the source location
+
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// BinaryOptions are options for a [BinaryHandler].
+type BinaryOptions struct {
+	// Level reports the minimum level to log.
+	// If nil, the handler uses [slog.LevelInfo].
+	Level slog.Leveler
+
+	// Compress, if non-nil, causes the handler to compress its output
+	// with a [compress.Writer] created with these options.
+	// Call [BinaryHandler.Close] to complete the compressed stream.
+	Compress *compress.Options
+
+	// If TimeUTC is true, the record's time is converted to UTC before
+	// it is encoded. Otherwise, if Location is non-nil, it is converted
+	// to Location. Times in other Attrs are unchanged.
+	TimeUTC  bool
+	Location *time.Location
+
+	// If AddSource is true, the source file, line and function of the
+	// log call are looked up and recorded, at some cost in time. Use
+	// [binary.RecordSource] to retrieve them from a decoded record.
+	AddSource bool
+
+	// Index, if non-nil, receives an index of the output, for
+	// [binary.Query]. It should write to the file named by the output
+	// file's name followed by [binary.IndexSuffix]. If the output has a
+	// Stat method, like [os.File], its size is taken as the offset of
+	// the first record, so that records can be appended to a file.
+	// Call [BinaryHandler.Close] to index the last records.
+	// Index can't be used with Compress.
+	Index io.Writer
 }
 
 func NewBinaryHandler(w io.Writer, level slog.Leveler) *BinaryHandler {
-	if level == nil {
-		level = slog.LevelInfo
-	}
-	return &BinaryHandler{
+	h, _ := BinaryOptions{Level: level}.New(w) // no error without compression
+	return h
+}
+
+// New constructs a BinaryHandler with the given options.
+func (opts BinaryOptions) New(w io.Writer) (*BinaryHandler, error) {
+	h := &BinaryHandler{
 		w:     w,
-		level: level,
+		level: opts.Level,
+		loc:   opts.Location,
+		src:   opts.AddSource,
+		mu:    &sync.Mutex{},
+	}
+	if opts.TimeUTC {
+		h.loc = time.UTC
+	}
+	if h.level == nil {
+		h.level = slog.LevelInfo
+	}
+	if opts.Compress != nil && opts.Index != nil {
+		return nil, errors.New("BinaryOptions: can't index compressed output")
+	}
+	if opts.Index != nil {
+		var offset int64
+		if f, ok := w.(interface{ Stat() (fs.FileInfo, error) }); ok {
+			info, err := f.Stat()
+			if err != nil {
+				return nil, err
+			}
+			offset = info.Size()
+		}
+		h.iw = binary.NewIndexWriter(w, opts.Index, offset)
+		h.w = h.iw
+	}
+	if opts.Compress != nil {
+		cw, err := compress.NewWriter(w, opts.Compress)
+		if err != nil {
+			return nil, err
+		}
+		h.cw = cw
+		h.w = cw
 	}
+	return h, nil
 }
 
-func (h *BinaryHandler) Enabled(l slog.Level) bool {
+// Close completes the compressed stream or the index, if any.
+// It does not close the underlying writers.
+func (h *BinaryHandler) Close() error {
+	if h.iw != nil {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.iw.Flush()
+	}
+	if h.cw == nil {
+		return nil
+	}
+	return h.cw.Close()
+}
+
+func (h *BinaryHandler) Enabled(ctx context.Context, l slog.Level) bool {
 	return l >= h.level.Level()
 }
+
+func (h *BinaryHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	return &h2
+}
+
+func (h *BinaryHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	return &h2
+}
+
+func (h *BinaryHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.goa != nil {
+		r = h.withGroupsAndAttrs(r)
+	}
+	if !h.src {
+		r.PC = 0
+	}
+	if h.loc != nil && !r.Time.IsZero() {
+		r.Time = r.Time.In(h.loc)
+	}
+	e := binary.GetEncoder()
+	defer binary.PutEncoder(e)
+	e.Grow(slogsize.EstimateSize(r))
+	e.EncodeRecord(r)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := e.WriteTo(h.w)
+	return err
+}
+
+// withGroupsAndAttrs returns a copy of r whose attributes include
+// those from WithGroup and WithAttrs.
+func (h *BinaryHandler) withGroupsAndAttrs(r slog.Record) slog.Record {
+	return h.goa.Record(r)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/binary"
+	"github.com/jba/slog/handlertest"
+	"github.com/jba/slog/slogmap"
+	"github.com/jba/slog/writers/compress"
+)
+
+func TestBinaryHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewBinaryHandler(&buf, nil))
+	logger.Debug("disabled")
+	logger.With("a", 1).WithGroup("g").With("b", 2).Info("msg", "c", 3)
+	v := &textVisitor{}
+	if err := binary.Decode(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(v.out, " ")
+	want := "time=T level=0 msg=msg a=1 group(2) b=2 c=3"
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left over", buf.Len())
+	}
+}
+
+func TestBinaryHandlerConformance(t *testing.T) {
+	handlertest.Suite{
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			var bopts BinaryOptions
+			if opts != nil {
+				bopts.Level = opts.Level
+			}
+			h, err := bopts.New(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return h
+		},
+		Parse: parseBinary,
+		// There is no ReplaceAttr option.
+		Skip: []string{"ReplaceAttr remove built-ins", "ReplaceAttr groups", "ReplaceAttr not called on groups"},
+	}.Run(t)
+}
+
+// parseBinary decodes the records in data for handlertest.
+func parseBinary(data []byte) ([]map[string]any, error) {
+	d := binary.NewStreamDecoder(bytes.NewReader(data))
+	var ms []map[string]any
+	for {
+		r, err := d.NextRecord()
+		if err == io.EOF {
+			return ms, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, slogmap.FromRecord(r))
+	}
+}
+
+func TestBinaryHandlerAddSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, addSource := range []bool{false, true} {
+		h, err := BinaryOptions{AddSource: addSource}.New(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).WithGroup("g").Info("m", "a", 1)
+	}
+	recs, err := binary.Query(file, binary.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if src := binary.RecordSource(recs[0]); src != nil {
+		t.Errorf("without AddSource: got %+v, want nil", src)
+	}
+	src := binary.RecordSource(recs[1])
+	if src == nil {
+		t.Fatal("with AddSource: got nil")
+	}
+	if !strings.HasSuffix(src.File, "binary_test.go") || src.Line == 0 ||
+		!strings.HasSuffix(src.Function, "TestBinaryHandlerAddSource") {
+		t.Errorf("got %+v", src)
+	}
+}
+
+func TestBinaryHandlerCompress(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := BinaryOptions{Compress: &compress.Options{PerWrite: true}}.New(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h)
+	for i := 0; i < 3; i++ {
+		logger.Info("msg", "i", i)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := binary.NewStreamDecoder(zr)
+	var got []string
+	for {
+		v := &textVisitor{}
+		err := d.Next(v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.out[len(v.out)-1])
+	}
+	if g, w := strings.Join(got, " "), "i=0 i=1 i=2"; g != w {
+		t.Errorf("got %q, want %q", g, w)
+	}
+}
+
+func TestBinaryHandlerTimeUTC(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := BinaryOptions{TimeUTC: true}.New(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.FixedZone("EST", -5*60*60))
+	r := slog.NewRecord(tm, slog.LevelInfo, "msg", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	v := &timeVisitor{}
+	if err := binary.Decode(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	if !v.t.Equal(tm) || v.t.Location() != time.UTC {
+		t.Errorf("got %s, want %s", v.t, tm.UTC())
+	}
+}
+
+func TestBinaryHandlerIndex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "log")
+	write := func(msgs ...string) {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		idx, err := os.OpenFile(file+binary.IndexSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		h, err := BinaryOptions{Index: idx}.New(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h)
+		for _, m := range msgs {
+			logger.Warn(m)
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a", "b")
+	// Append to the file; the new index entries have the right offsets.
+	write("c")
+	recs, err := binary.Query(file, binary.Filter{Level: slog.LevelWarn, Start: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range recs {
+		got = append(got, r.Message)
+	}
+	if g, w := strings.Join(got, " "), "a b c"; g != w {
+		t.Errorf("got %q, want %q", g, w)
+	}
+
+	if _, err := (BinaryOptions{Index: io.Discard, Compress: &compress.Options{}}).New(io.Discard); err == nil {
+		t.Error("Index with Compress: got nil, want error")
+	}
+}
+
+// timeVisitor records the last time it sees.
+type timeVisitor struct {
+	textVisitor
+	t time.Time
+}
+
+func (v *timeVisitor) Time(key []byte, val time.Time) { v.t = val }
+
+type textVisitor struct {
+	out []string
+}
+
+func (v *textVisitor) add(key []byte, val any) {
+	v.out = append(v.out, fmt.Sprintf("%s=%v", key, val))
+}
+
+func (v *textVisitor) Int(key []byte, val int64)              { v.add(key, val) }
+func (v *textVisitor) Uint(key []byte, val uint64)            { v.add(key, val) }
+func (v *textVisitor) String(key, val []byte)                 { v.add(key, string(val)) }
+func (v *textVisitor) Bytes(key, val []byte)                  { v.add(key, string(val)) }
+func (v *textVisitor) Bool(key []byte, val bool)              { v.add(key, val) }
+func (v *textVisitor) Float(key []byte, val float64)          { v.add(key, val) }
+func (v *textVisitor) Duration(key []byte, val time.Duration) { v.add(key, val) }
+func (v *textVisitor) Time(key []byte, val time.Time)         { v.add(key, "T") }
+func (v *textVisitor) Group(n int)                            { v.out = append(v.out, fmt.Sprintf("group(%d)", n)) }
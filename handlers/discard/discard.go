@@ -0,0 +1,87 @@
+// Package discard provides slog.Handlers that drop their records.
+package discard
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Handler discards all records. Unlike a handler that writes to
+// io.Discard, it does no formatting, so it measures only the cost of
+// the caller and the slog.Logger.
+type Handler struct {
+	level slog.Leveler
+}
+
+// New returns a Handler that is enabled for records at or above level.
+// If level is nil, the Handler uses [slog.LevelInfo].
+func New(level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &Handler{level: level}
+}
+
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *Handler) Handle(context.Context, slog.Record) error { return nil }
+
+func (h *Handler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *Handler) WithGroup(string) slog.Handler { return h }
+
+// CountingHandler discards all records, but counts them by level.
+// Handlers derived from it with WithAttrs and WithGroup share its counts.
+type CountingHandler struct {
+	Handler
+	counts *sync.Map // from slog.Level to *atomic.Int64
+}
+
+// NewCounting returns a CountingHandler that is enabled for records at
+// or above level. If level is nil, the CountingHandler uses [slog.LevelInfo].
+func NewCounting(level slog.Leveler) *CountingHandler {
+	return &CountingHandler{Handler: *New(level), counts: &sync.Map{}}
+}
+
+func (h *CountingHandler) Handle(_ context.Context, r slog.Record) error {
+	c, ok := h.counts.Load(r.Level)
+	if !ok {
+		c, _ = h.counts.LoadOrStore(r.Level, new(atomic.Int64))
+	}
+	c.(*atomic.Int64).Add(1)
+	return nil
+}
+
+func (h *CountingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *CountingHandler) WithGroup(string) slog.Handler { return h }
+
+// Count returns the number of records handled at level l.
+func (h *CountingHandler) Count(l slog.Level) int64 {
+	if c, ok := h.counts.Load(l); ok {
+		return c.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// Total returns the number of records handled at all levels.
+func (h *CountingHandler) Total() int64 {
+	var n int64
+	h.counts.Range(func(_, c any) bool {
+		n += c.(*atomic.Int64).Load()
+		return true
+	})
+	return n
+}
+
+// Reset sets all counts to zero.
+func (h *CountingHandler) Reset() {
+	h.counts.Range(func(_, c any) bool {
+		c.(*atomic.Int64).Store(0)
+		return true
+	})
+}
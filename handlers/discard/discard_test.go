@@ -0,0 +1,68 @@
+package discard
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	h := New(slog.LevelWarn)
+	if h.Enabled(ctx, slog.LevelInfo) {
+		t.Error("Info enabled")
+	}
+	if !h.Enabled(ctx, slog.LevelWarn) {
+		t.Error("Warn disabled")
+	}
+	if !New(nil).Enabled(ctx, slog.LevelInfo) {
+		t.Error("default: Info disabled")
+	}
+
+	var lv slog.LevelVar
+	h = New(&lv)
+	lv.Set(slog.LevelError)
+	if h.Enabled(ctx, slog.LevelWarn) {
+		t.Error("LevelVar: Warn enabled")
+	}
+}
+
+func TestCountingHandler(t *testing.T) {
+	h := NewCounting(slog.LevelDebug)
+	logger := slog.New(h)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("m")
+			logger.With("a", 1).WithGroup("g").Error("m")
+		}()
+	}
+	wg.Wait()
+	logger.Debug("m")
+	logger.Log(context.Background(), slog.LevelDebug-1, "disabled")
+
+	for _, test := range []struct {
+		level slog.Level
+		want  int64
+	}{
+		{slog.LevelDebug, 1},
+		{slog.LevelInfo, 10},
+		{slog.LevelWarn, 0},
+		{slog.LevelError, 10},
+		{slog.LevelDebug - 1, 0},
+	} {
+		if got := h.Count(test.level); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.level, got, test.want)
+		}
+	}
+	if got, want := h.Total(), int64(21); got != want {
+		t.Errorf("Total: got %d, want %d", got, want)
+	}
+	h.Reset()
+	if got := h.Total(); got != 0 {
+		t.Errorf("after Reset: got %d", got)
+	}
+}
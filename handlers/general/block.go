@@ -0,0 +1,291 @@
+package general
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// BlockOptions are options for a block Formatter, which writes each
+// record as a block of lines for people to read: a header line with
+// the time, level and message, followed by a line for each Attr,
+// indented by the depth of its groups:
+//
+//	2023-04-03 01:02:03.000 INFO  request done
+//	  method: GET
+//	  req:
+//	    path: /a
+//	    size: 12
+//
+// A group's Attrs follow a line with the group's name. Values are written
+// as by [NewTextFormatter], so each fits on one line.
+type BlockOptions struct {
+	// TimeFormat is the layout of the time in the header, as for
+	// [time.Time.Format]. If empty, "2006-01-02 15:04:05.000" is used.
+	TimeFormat string
+
+	// If Color is true, the level is colored with ANSI escape sequences.
+	// See [DetectTerminal].
+	Color bool
+
+	// If Align is true, the values of consecutive Attrs in the same
+	// group are aligned with each other.
+	Align bool
+
+	// Separator is written after each record.
+	// If empty, "\n" is used, so that a blank line follows each record.
+	Separator string
+
+	// Catalog, if non-nil, translates the level and message.
+	Catalog Catalog
+}
+
+// NewFormatter returns a block Formatter with the given options.
+// Pass the method value opts.NewFormatter to [Options.New].
+func (opts BlockOptions) NewFormatter() Formatter {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "2006-01-02 15:04:05.000"
+	}
+	if opts.Separator == "" {
+		opts.Separator = "\n"
+	}
+	return &blockFormatter{opts: opts}
+}
+
+// alignMark separates the key and value of an Attr's line when aligning.
+// It can't appear in keys or values, because they are quoted if they
+// contain control characters.
+const alignMark = '\x00'
+
+type blockFormatter struct {
+	indentingFormatter
+	headerCapture
+	opts  BlockOptions
+	start int // offset of the event in the buffer
+
+	time, level, logger, msg []byte // nil if missing
+}
+
+func (f *blockFormatter) AppendBegin(buf []byte) []byte {
+	f.start = len(buf)
+	f.beginHeader()
+	f.indent = 0
+	f.time, f.level, f.logger, f.msg = nil, nil, nil, nil
+	return buf
+}
+
+func (f *blockFormatter) AppendEnd(buf []byte) []byte {
+	// Until now, buf has held only the lines of the Attrs.
+	var lines []byte
+	if f.opts.Align {
+		lines = alignLines(buf[f.start:])
+	} else {
+		lines = slices.Clone(buf[f.start:])
+	}
+	buf = f.appendHeader(buf[:f.start])
+	buf = append(buf, lines...)
+	return append(buf, f.opts.Separator...)
+}
+
+// appendHeader appends the header line, if there is anything in it.
+func (f *blockFormatter) appendHeader(buf []byte) []byte {
+	n := len(buf)
+	for _, field := range [][]byte{f.time, f.level, f.logger, f.msg} {
+		if field != nil {
+			if len(buf) > n {
+				buf = append(buf, ' ')
+			}
+			buf = append(buf, field...)
+		}
+	}
+	if len(buf) == n {
+		return buf
+	}
+	// Remove the level's padding if nothing follows it.
+	buf = buf[:n+len(bytes.TrimRight(buf[n:], " "))]
+	return append(buf, '\n')
+}
+
+func (f *blockFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	f.endHeader()
+	buf = f.appendIndent(buf)
+	buf = appendTextString(buf, name)
+	buf = append(buf, ":\n"...)
+	f.indent++
+	return buf
+}
+
+func (f *blockFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+	// The Handler calls this before appending preformatted Attrs,
+	// which follow the built-in ones.
+	f.endHeader()
+	return buf
+}
+
+func (f *blockFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if f.captureHeader(a, openGroups, f.setHeaderField) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			buf = f.AppendOpenGroup(buf, a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		if a.Key != "" {
+			buf = f.AppendCloseGroup(buf, a.Key)
+		}
+		return buf
+	}
+	buf = f.appendIndent(buf)
+	buf = appendTextString(buf, a.Key)
+	buf = append(buf, ':')
+	if f.opts.Align {
+		buf = append(buf, alignMark)
+	}
+	buf = append(buf, ' ')
+	buf = appendTextValue(buf, a.Value)
+	return append(buf, '\n')
+}
+
+// appendIndent indents a line by one step more than the depth of the
+// open groups, so that Attrs are indented under the header.
+func (f *blockFormatter) appendIndent(buf []byte) []byte {
+	buf = append(buf, "  "...)
+	return f.indentingFormatter.appendIndent(buf)
+}
+
+// setHeaderField saves a if it is a built-in Attr, and reports whether
+// it did.
+func (f *blockFormatter) setHeaderField(a slog.Attr) bool {
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() != slog.KindTime {
+			return false
+		}
+		f.time = a.Value.Time().AppendFormat([]byte{}, f.opts.TimeFormat)
+	case slog.LevelKey:
+		f.level = f.appendLevel([]byte{}, a.Value)
+	case LoggerKey:
+		f.logger = fmt.Appendf(nil, "[%s]", a.Value)
+	case slog.MessageKey:
+		f.msg = append([]byte{}, translateMessage(f.opts.Catalog, a.Value)...)
+	default:
+		return false
+	}
+	return true
+}
+
+// appendLevel appends the level, padded to the width of the longest
+// level name and colored if f.opts.Color is set.
+func (f *blockFormatter) appendLevel(buf []byte, v slog.Value) []byte {
+	return appendPaddedLevel(buf, v, f.opts.Color, f.opts.Catalog)
+}
+
+// appendPaddedLevel appends the level in v, padded to the width of the
+// longest level name, and colored if color is true and v holds a Level.
+// If cat is non-nil, it supplies the name of the level.
+func appendPaddedLevel(buf []byte, v slog.Value, color bool, cat Catalog) []byte {
+	l, isLevel := v.Any().(slog.Level)
+	name := v.String()
+	if isLevel && v.Kind() == slog.KindAny {
+		name = levels.String(l)
+		if cat != nil {
+			if s := cat.LevelName(l); s != "" {
+				name = s
+			}
+		}
+	}
+	esc := ""
+	if color && isLevel {
+		esc = levelColor(l)
+	}
+	if esc != "" {
+		buf = append(buf, esc...)
+	}
+	buf = append(buf, name...)
+	if esc != "" {
+		buf = append(buf, "\x1b[0m"...)
+	}
+	for n := utf8.RuneCountInString(name); n < 5; n++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// translateMessage returns the message in v, translated by cat
+// if it is non-nil.
+func translateMessage(cat Catalog, v slog.Value) string {
+	if cat == nil {
+		return v.String()
+	}
+	return cat.Message(v.String())
+}
+
+// levelColor returns the ANSI escape sequence that sets the color for l.
+func levelColor(l slog.Level) string {
+	switch {
+	case l < slog.LevelInfo:
+		return "\x1b[34m" // blue
+	case l < slog.LevelWarn:
+		return "\x1b[32m" // green
+	case l < slog.LevelError:
+		return "\x1b[33m" // yellow
+	case l < levels.LevelPanic:
+		return "\x1b[31m" // red
+	default:
+		return "\x1b[1;31m" // bold red
+	}
+}
+
+// alignLines returns lines with the alignMark in each line replaced by
+// enough spaces to align the values of each run of consecutive lines
+// with the same indentation.
+func alignLines(lines []byte) []byte {
+	var res []byte
+	var run [][]byte // lines with marks at the same indentation
+	runIndent := -1
+	flush := func() {
+		width := 0
+		for _, l := range run {
+			width = max(width, utf8.RuneCount(l[:bytes.IndexByte(l, alignMark)]))
+		}
+		for _, l := range run {
+			i := bytes.IndexByte(l, alignMark)
+			res = append(res, l[:i]...)
+			for n := utf8.RuneCount(l[:i]); n < width; n++ {
+				res = append(res, ' ')
+			}
+			res = append(res, l[i+1:]...)
+		}
+		run = run[:0]
+	}
+	for len(lines) > 0 {
+		line := lines
+		if i := bytes.IndexByte(lines, '\n'); i >= 0 {
+			line = lines[:i+1]
+		}
+		lines = lines[len(line):]
+		indent := len(line) - len(bytes.TrimLeft(line, " "))
+		if bytes.IndexByte(line, alignMark) < 0 {
+			flush()
+			runIndent = -1
+			res = append(res, line...)
+			continue
+		}
+		if indent != runIndent {
+			flush()
+			runIndent = indent
+		}
+		run = append(run, line)
+	}
+	flush()
+	return res
+}
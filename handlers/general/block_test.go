@@ -0,0 +1,96 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jba/slog/levels"
+)
+
+func TestBlockFormatter(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		opts  BlockOptions
+		hopts Options
+		want  string
+	}{
+		{
+			"default",
+			BlockOptions{},
+			Options{},
+			"2000-01-02 03:04:05.000 WARN  hello\n" +
+				"  w: 1\n" +
+				"  G:\n" +
+				"    long_key: \"a b\"\n" +
+				"    H:\n" +
+				"      x: 2\n" +
+				"    c: 3\n" +
+				"\n",
+		},
+		{
+			"align",
+			BlockOptions{Align: true, Separator: "---\n"},
+			Options{},
+			"2000-01-02 03:04:05.000 WARN  hello\n" +
+				"  w: 1\n" +
+				"  G:\n" +
+				"    long_key: \"a b\"\n" +
+				"    H:\n" +
+				"      x: 2\n" +
+				"    c: 3\n" +
+				"---\n",
+		},
+		{
+			"color",
+			BlockOptions{Color: true, TimeFormat: "15:04"},
+			Options{ReplaceAttr: removeKeys(slog.MessageKey)},
+			"03:04 \x1b[33mWARN\x1b[0m\n" +
+				"  w: 1\n" +
+				"  G:\n" +
+				"    long_key: \"a b\"\n" +
+				"    H:\n" +
+				"      x: 2\n" +
+				"    c: 3\n" +
+				"\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := test.hopts.New(&buf, test.opts.NewFormatter).
+				WithAttrs([]slog.Attr{slog.Int("w", 1)}).
+				WithGroup("G").
+				WithAttrs([]slog.Attr{slog.String("long_key", "a b")})
+			r := slog.NewRecord(testTime, slog.LevelWarn, "hello", 0)
+			r.AddAttrs(slog.Group("H", "x", 2), slog.Int("c", 3))
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBlockAlign(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey)}.New(&buf, BlockOptions{Align: true}.NewFormatter)
+	r := slog.NewRecord(testTime, levels.LevelFatal, "m", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Int("bbb", 2), slog.Group("g", "cc", 3, "d", 4), slog.Int("eeeee", 5))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "FATAL m\n" +
+		"  a:   1\n" +
+		"  bbb: 2\n" +
+		"  g:\n" +
+		"    cc: 3\n" +
+		"    d:  4\n" +
+		"  eeeee: 5\n" +
+		"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot\n%s\nwant\n%s", got, want)
+	}
+}
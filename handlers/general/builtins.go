@@ -0,0 +1,120 @@
+package general
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jba/slog/levels"
+)
+
+// A builtinFormatter is a Formatter whose output for a top-level Attr
+// does not depend on what precedes it, apart from the separator written
+// by AppendSeparatorIfNeeded. For such a Formatter, the Handler formats
+// the keys of the built-in Attrs, and the whole level Attr for the common
+// levels, once instead of for each record.
+type builtinFormatter interface {
+	Formatter
+	// appendBuiltinKey appends key as AppendAttr writes the key of a
+	// top-level Attr, including what separates it from the value.
+	appendBuiltinKey(buf []byte, key string) []byte
+	// appendBuiltinTime and appendBuiltinString append values as
+	// AppendAttr writes them.
+	appendBuiltinTime(buf []byte, t time.Time) []byte
+	appendBuiltinString(buf []byte, s string) []byte
+}
+
+// builtins holds the preformatted parts of the built-in Attrs.
+type builtins struct {
+	timeKey []byte
+	msgKey  []byte
+	levels  [6][]byte // DEBUG through FATAL; see levelIndex
+}
+
+// newBuiltins returns the preformatted built-ins for the Formatters
+// returned by newFormatter, or nil if they can't be preformatted.
+// Options that can change the built-ins, like ReplaceAttr, prevent it.
+func newBuiltins(opts Options, newFormatter func() Formatter) *builtins {
+	if newFormatter == nil || opts.ReplaceAttr != nil || opts.Limits != nil {
+		return nil
+	}
+	f, ok := newFormatter().(builtinFormatter)
+	if !ok {
+		return nil
+	}
+	b := &builtins{
+		timeKey: f.appendBuiltinKey(nil, slog.TimeKey),
+		msgKey:  f.appendBuiltinKey(nil, slog.MessageKey),
+	}
+	for i := range b.levels {
+		l := slog.LevelDebug + slog.Level(4*i)
+		b.levels[i] = newFormatter().AppendAttr(nil, slog.Any(slog.LevelKey, l), nil)
+	}
+	return b
+}
+
+// levelIndex returns the index of l in builtins.levels,
+// or -1 if it isn't there.
+func levelIndex(l slog.Level) int {
+	if l < slog.LevelDebug || l > levels.LevelFatal || (l-slog.LevelDebug)%4 != 0 {
+		return -1
+	}
+	return int(l-slog.LevelDebug) / 4
+}
+
+// append appends the built-in Attrs: the time, if it isn't zero,
+// the level and the message.
+func (b *builtins) append(buf []byte, f builtinFormatter, t time.Time, l slog.Level, msg string) []byte {
+	if !t.IsZero() {
+		buf = f.AppendSeparatorIfNeeded(buf)
+		buf = append(buf, b.timeKey...)
+		buf = f.appendBuiltinTime(buf, t)
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	if i := levelIndex(l); i >= 0 {
+		buf = append(buf, b.levels[i]...)
+	} else {
+		buf = f.AppendAttr(buf, slog.Any(slog.LevelKey, l), nil)
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	buf = append(buf, b.msgKey...)
+	return f.appendBuiltinString(buf, msg)
+}
+
+func (f jsonFormatter) appendBuiltinKey(buf []byte, key string) []byte {
+	if f.datadog {
+		key = f.datadogAttr(slog.Attr{Key: key}).Key
+	}
+	if f.flatten {
+		return appendFlatJSONKey(buf, nil, key)
+	}
+	return appendJSONKey(buf, key)
+}
+
+func (f jsonFormatter) appendBuiltinTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, '"')
+	if f.datadog {
+		buf = appendTimeRFC3339Millis(buf, t)
+	} else {
+		buf = t.AppendFormat(buf, time.RFC3339)
+	}
+	return append(buf, '"')
+}
+
+func (jsonFormatter) appendBuiltinString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = appendEscapedJSONString(buf, s)
+	return append(buf, '"')
+}
+
+func (textFormatter) appendBuiltinKey(buf []byte, key string) []byte {
+	buf = appendTextKey(buf, nil, key)
+	return append(buf, '=')
+}
+
+func (textFormatter) appendBuiltinTime(buf []byte, t time.Time) []byte {
+	return appendTimeRFC3339Millis(buf, t)
+}
+
+func (textFormatter) appendBuiltinString(buf []byte, s string) []byte {
+	return appendTextString(buf, s)
+}
@@ -0,0 +1,91 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/levels"
+)
+
+func TestBuiltins(t *testing.T) {
+	// The preformatted built-ins must match what the Handler writes
+	// without them.
+	est := time.FixedZone("EST", -5*60*60)
+	for _, test := range []struct {
+		name string
+		opts Options
+		nf   func() Formatter
+	}{
+		{"text", Options{}, NewTextFormatter},
+		{"json", Options{}, NewJSONFormatter},
+		{"flatten", Options{}, JSONOptions{Flatten: true}.NewFormatter},
+		{"datadog", Options{}, JSONOptions{Datadog: true}.NewFormatter},
+		{"location", Options{Location: est}, NewJSONFormatter},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := test.opts.New(io.Discard, test.nf)
+			if h.builtins == nil {
+				t.Fatal("built-ins not preformatted")
+			}
+			for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError, levels.LevelPanic, levels.LevelFatal, slog.LevelInfo + 1, slog.LevelDebug - 4} {
+				for _, tm := range []time.Time{testTime, {}} {
+					r := slog.NewRecord(tm, l, `a "message"`, 0)
+					r.AddAttrs(slog.Int("a", 1))
+					var got, want bytes.Buffer
+					h1 := test.opts.New(&got, test.nf).WithAttrs([]slog.Attr{slog.Int("w", 2)})
+					h2 := test.opts.New(&want, test.nf)
+					h2.builtins = nil
+					if err := h1.Handle(context.Background(), r); err != nil {
+						t.Fatal(err)
+					}
+					if err := h2.WithAttrs([]slog.Attr{slog.Int("w", 2)}).Handle(context.Background(), r); err != nil {
+						t.Fatal(err)
+					}
+					if got.String() != want.String() {
+						t.Errorf("%s, %v:\ngot  %s\nwant %s", l, tm, got.String(), want.String())
+					}
+				}
+			}
+		})
+	}
+	if h := (Options{ReplaceAttr: removeKeys(slog.TimeKey)}).New(io.Discard, NewTextFormatter); h.builtins != nil {
+		t.Error("built-ins preformatted with ReplaceAttr")
+	}
+	if h := New(io.Discard, NewGlogFormatter); h.builtins != nil {
+		t.Error("built-ins preformatted for glog")
+	}
+}
+
+func BenchmarkBuiltins(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		nf   func() Formatter
+	}{
+		{"text", NewTextFormatter},
+		{"json", NewJSONFormatter},
+	} {
+		for _, pre := range []bool{false, true} {
+			name := test.name
+			if pre {
+				name += "/preformatted"
+			}
+			b.Run(name, func(b *testing.B) {
+				h := New(io.Discard, test.nf)
+				if !pre {
+					h.builtins = nil
+				}
+				r := slog.NewRecord(testTime, slog.LevelInfo, "a message", 0)
+				ctx := context.Background()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_ = h.Handle(ctx, r)
+				}
+			})
+		}
+	}
+}
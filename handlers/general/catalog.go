@@ -0,0 +1,58 @@
+package general
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+// A Catalog translates the level names and messages that a Formatter
+// writes for people, so that console output can be localized.
+// Formatters for machines, like those of [NewJSONFormatter] and
+// [NewTextFormatter], don't use one, so their output stays canonical.
+type Catalog interface {
+	// LevelName returns the name to write for l,
+	// or "" to write the usual name.
+	LevelName(l slog.Level) string
+
+	// Message returns the translation of msg,
+	// or msg itself if there is none.
+	Message(msg string) string
+}
+
+// MapCatalog is a Catalog whose translations are in maps.
+type MapCatalog struct {
+	// Levels maps levels to their names. As with [LevelBadges], a level
+	// without an entry uses the entry of the nearest level below it,
+	// followed by the difference, as in "INFO+2".
+	Levels map[slog.Level]string
+
+	// Messages maps messages to their translations.
+	// If nil, messages are not translated.
+	Messages map[string]string
+}
+
+// LevelName implements [Catalog.LevelName].
+func (c MapCatalog) LevelName(l slog.Level) string {
+	var (
+		name  string
+		found bool
+		best  slog.Level
+	)
+	for cl, s := range c.Levels {
+		if cl <= l && (!found || cl > best) {
+			name, found, best = s, true, cl
+		}
+	}
+	if found && l > best {
+		name += "+" + strconv.Itoa(int(l-best))
+	}
+	return name
+}
+
+// Message implements [Catalog.Message].
+func (c MapCatalog) Message(msg string) string {
+	if t, ok := c.Messages[msg]; ok {
+		return t
+	}
+	return msg
+}
@@ -0,0 +1,88 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jba/slog/levels"
+)
+
+var testCatalog = MapCatalog{
+	Levels: map[slog.Level]string{
+		slog.LevelInfo:    "INFO",
+		slog.LevelWarn:    "WARNUNG",
+		slog.LevelError:   "FEHLER",
+		levels.LevelPanic: "PANIK",
+	},
+	Messages: map[string]string{"disk full": "Festplatte voll"},
+}
+
+func TestMapCatalog(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, ""},
+		{slog.LevelInfo, "INFO"},
+		{slog.LevelInfo + 2, "INFO+2"},
+		{slog.LevelError, "FEHLER"},
+		{levels.LevelPanic + 1, "PANIK+1"},
+	} {
+		if got := testCatalog.LevelName(test.level); got != test.want {
+			t.Errorf("LevelName(%d): got %q, want %q", test.level, got, test.want)
+		}
+	}
+	if got, want := testCatalog.Message("disk full"), "Festplatte voll"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := testCatalog.Message("other"), "other"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCatalogFormatters(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		newFormatter func() Formatter
+		want         string
+	}{
+		{
+			"console",
+			ConsoleOptions{TimeFormat: "15:04", Catalog: testCatalog}.NewFormatter,
+			"03:04 WARNUNG Festplatte voll a=1\n" +
+				"03:04 DEBUG untranslated a=1\n",
+		},
+		{
+			"block",
+			BlockOptions{TimeFormat: "15:04", Catalog: testCatalog}.NewFormatter,
+			"03:04 WARNUNG Festplatte voll\n  a: 1\n\n" +
+				"03:04 DEBUG untranslated\n  a: 1\n\n",
+		},
+		{
+			// Formatters for machines ignore catalogs.
+			"JSON",
+			NewJSONFormatter,
+			`{"time":"2000-01-02T03:04:05Z","level":"WARN","msg":"disk full","a":1}` +
+				`{"time":"2000-01-02T03:04:05Z","level":"DEBUG","msg":"untranslated","a":1}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := Options{Level: slog.LevelDebug}.New(&buf, test.newFormatter)
+			for _, r := range []slog.Record{
+				slog.NewRecord(testTime, slog.LevelWarn, "disk full", 0),
+				slog.NewRecord(testTime, slog.LevelDebug, "untranslated", 0),
+			} {
+				r.AddAttrs(slog.Int("a", 1))
+				if err := h.Handle(context.Background(), r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package general
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jba/slog/internal/logvalue"
+)
+
+// NewCBORFormatter returns a Formatter that writes each log event as a
+// CBOR (RFC 8949) map.
+//
+// Maps, including those for groups, are written with indefinite length, so
+// that preformatted attributes can be concatenated with the others.
+// Times are written as epoch-based date/times (tag 1), with an integer
+// number of seconds if the time falls on a second and a float otherwise.
+// Durations are written as integer nanoseconds.
+func NewCBORFormatter() Formatter {
+	return cborFormatter{}
+}
+
+type cborFormatter struct{}
+
+// CBOR major types.
+const (
+	cborUint   byte = 0 << 5
+	cborNegInt byte = 1 << 5
+	cborBytes  byte = 2 << 5
+	cborText   byte = 3 << 5
+	cborMap    byte = 5 << 5
+	cborTag    byte = 6 << 5
+)
+
+// Other CBOR initial bytes.
+const (
+	cborFalse          byte = 0xf4
+	cborTrue           byte = 0xf5
+	cborNull           byte = 0xf6
+	cborFloat64        byte = 0xfb
+	cborIndefiniteMap  byte = cborMap | 31
+	cborBreak          byte = 0xff
+	cborTagEpochSecond      = 1
+)
+
+func (cborFormatter) AppendBegin(buf []byte) []byte {
+	return append(buf, cborIndefiniteMap)
+}
+
+func (cborFormatter) AppendEnd(buf []byte) []byte {
+	return append(buf, cborBreak)
+}
+
+func (cborFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	buf = appendCBORText(buf, name)
+	return append(buf, cborIndefiniteMap)
+}
+
+func (cborFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	return append(buf, cborBreak)
+}
+
+// AppendSeparatorIfNeeded does nothing, because CBOR map entries
+// are not separated.
+func (cborFormatter) AppendSeparatorIfNeeded(buf []byte) []byte { return buf }
+
+func (f cborFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			buf = f.AppendOpenGroup(buf, a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		if a.Key != "" {
+			buf = f.AppendCloseGroup(buf, a.Key)
+		}
+		return buf
+	}
+	buf = appendCBORText(buf, a.Key)
+	return appendCBORValue(buf, a.Value)
+}
+
+func appendCBORValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendCBORText(buf, v.String())
+	case slog.KindInt64:
+		return appendCBORInt(buf, v.Int64())
+	case slog.KindUint64:
+		return appendCBORHead(buf, cborUint, v.Uint64())
+	case slog.KindFloat64:
+		return appendCBORFloat(buf, v.Float64())
+	case slog.KindBool:
+		if v.Bool() {
+			return append(buf, cborTrue)
+		}
+		return append(buf, cborFalse)
+	case slog.KindDuration:
+		return appendCBORInt(buf, v.Duration().Nanoseconds())
+	case slog.KindTime:
+		return appendCBORTime(buf, v.Time())
+	case slog.KindAny:
+		return appendCBORAny(buf, v.Any())
+	default:
+		return appendCBORText(buf, v.String())
+	}
+}
+
+func appendCBORAny(buf []byte, x any) []byte {
+	switch x := x.(type) {
+	case nil:
+		return append(buf, cborNull)
+	case error:
+		return appendCBORText(buf, x.Error())
+	case encoding.TextMarshaler:
+		data, err := x.MarshalText()
+		if err != nil {
+			return appendCBORText(buf, err.Error())
+		}
+		return appendCBORText(buf, string(data))
+	}
+	if bs, ok := byteSlice(x); ok {
+		buf = appendCBORHead(buf, cborBytes, uint64(len(bs)))
+		return append(buf, bs...)
+	}
+	return appendCBORText(buf, fmt.Sprint(x))
+}
+
+func appendCBORTime(buf []byte, t time.Time) []byte {
+	buf = appendCBORHead(buf, cborTag, cborTagEpochSecond)
+	if t.Nanosecond() == 0 {
+		return appendCBORInt(buf, t.Unix())
+	}
+	return appendCBORFloat(buf, float64(t.UnixNano())/1e9)
+}
+
+func appendCBORInt(buf []byte, i int64) []byte {
+	if i < 0 {
+		return appendCBORHead(buf, cborNegInt, uint64(-1-i))
+	}
+	return appendCBORHead(buf, cborUint, uint64(i))
+}
+
+func appendCBORFloat(buf []byte, f float64) []byte {
+	buf = append(buf, cborFloat64)
+	return binary.BigEndian.AppendUint64(buf, math.Float64bits(f))
+}
+
+func appendCBORText(buf []byte, s string) []byte {
+	buf = appendCBORHead(buf, cborText, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendCBORHead appends the initial byte and argument of a data item
+// with the given major type.
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+	}
+}
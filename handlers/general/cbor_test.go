@@ -0,0 +1,61 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	hexenc "encoding/hex"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCBOR(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{ReplaceAttr: removeKeys(slog.LevelKey)}.New(&buf, NewCBORFormatter)
+	var hl slog.Handler = h.WithAttrs([]Attr{slog.Bool("p", true)}).WithGroup("g")
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	r.AddAttrs(
+		slog.Int("a", -2),
+		slog.Group("h", slog.Duration("d", time.Second)),
+		slog.Any("b", []byte{1}),
+	)
+	if err := hl.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "bf" + // begin map
+		"6474696d65" + "c1" + "1a386ec025" + // "time": 1(946782245)
+		"636d7367" + "616d" + // "msg": "m"
+		"6170" + "f5" + // "p": true
+		"6167" + "bf" + // "g": {
+		"6161" + "21" + // "a": -2
+		"6168" + "bf" + "6164" + "1a3b9aca00" + "ff" + // "h": {"d": 1e9}
+		"6162" + "4101" + // "b": h'01'
+		"ff" + // }
+		"ff" // end map
+	if got := hexenc.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestCBORHead(t *testing.T) {
+	for _, test := range []struct {
+		in   int64
+		want string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{-1, "20"},
+		{-25, "3818"},
+		{1000, "1903e8"},
+		{1000000, "1a000f4240"},
+		{math.MaxInt64, "1b7fffffffffffffff"},
+		{math.MinInt64, "3b7fffffffffffffff"},
+	} {
+		got := hexenc.EncodeToString(appendCBORInt(nil, test.in))
+		if got != test.want {
+			t.Errorf("%d: got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
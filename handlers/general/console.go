@@ -0,0 +1,264 @@
+package general
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// ConsoleOptions are options for a console Formatter, which writes each
+// record on one line for people to read at a terminal:
+//
+//	01:02:03.000 INFO  request done method=GET req.path=/a req.size=12
+//
+// The Attrs are written as by [NewTextFormatter]. If Width is set, a
+// line that would be wider is wrapped between Attrs, with a hanging
+// indent that lines the Attrs up with the message:
+//
+//	01:02:03.000 INFO  request done method=GET
+//	                   req.path=/a req.size=12
+//
+// With Badges set, a symbol precedes the level:
+//
+//	01:02:03.000 ⚠ WARN  disk almost full
+//
+// Use [DetectTerminal] to choose Color and Width for the output.
+type ConsoleOptions struct {
+	// TimeFormat is the layout of the time, as for [time.Time.Format].
+	// If empty, "15:04:05.000" is used.
+	TimeFormat string
+
+	// If Color is true, the level is colored and the keys are dimmed
+	// with ANSI escape sequences.
+	Color bool
+
+	// Width, if positive, is the width in columns to wrap lines to.
+	// An Attr that is wider than the space left for it is not broken.
+	Width int
+
+	// Badges, if non-nil, are symbols written before the level, like
+	// "✗" for ERROR. They are colored like the level, and padded to the
+	// width of the widest one. See [DefaultBadges].
+	Badges LevelBadges
+
+	// Catalog, if non-nil, translates the level and message.
+	Catalog Catalog
+}
+
+// LevelBadges maps levels to the symbols written before them.
+// A level without an entry uses the entry of the nearest level below
+// it, so a custom level like INFO+2 can have its own badge, or share
+// that of INFO.
+type LevelBadges map[slog.Level]string
+
+// DefaultBadges are badges for the levels of package log/slog and
+// package github.com/jba/slog/levels.
+var DefaultBadges = LevelBadges{
+	slog.LevelDebug:   "·",
+	slog.LevelInfo:    "ℹ",
+	slog.LevelWarn:    "⚠",
+	slog.LevelError:   "✗",
+	levels.LevelPanic: "‼",
+}
+
+// Badge returns the badge for l, or "" if there is none.
+func (b LevelBadges) Badge(l slog.Level) string {
+	var (
+		badge string
+		found bool
+		best  slog.Level
+	)
+	for bl, s := range b {
+		if bl <= l && (!found || bl > best) {
+			badge, found, best = s, true, bl
+		}
+	}
+	return badge
+}
+
+// NewFormatter returns a console Formatter with the given options.
+// Pass the method value opts.NewFormatter to [Options.New].
+func (opts ConsoleOptions) NewFormatter() Formatter {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "15:04:05.000"
+	}
+	f := &consoleFormatter{opts: opts}
+	for _, b := range opts.Badges {
+		f.badgeWidth = max(f.badgeWidth, utf8.RuneCountInString(b))
+	}
+	return f
+}
+
+// attrMark separates Attrs in the buffer until AppendEnd wraps them.
+// Like alignMark, it can't appear in keys or values.
+const attrMark = '\x00'
+
+type consoleFormatter struct {
+	headerCapture
+	opts       ConsoleOptions
+	badgeWidth int // of the widest badge
+	start      int // offset of the event in the buffer
+
+	time, level, logger, msg []byte // nil if missing
+}
+
+func (f *consoleFormatter) AppendBegin(buf []byte) []byte {
+	f.start = len(buf)
+	f.beginHeader()
+	f.time, f.level, f.logger, f.msg = nil, nil, nil, nil
+	return buf
+}
+
+func (f *consoleFormatter) AppendEnd(buf []byte) []byte {
+	// Until now, buf has held only the Attrs, separated by attrMarks.
+	attrs := slices.Clone(buf[f.start:])
+	buf = buf[:f.start]
+	for _, field := range [][]byte{f.time, f.level, f.logger} {
+		if field != nil {
+			buf = append(buf, field...)
+			buf = append(buf, ' ')
+		}
+	}
+	indent := displayWidth(buf[f.start:])
+	buf = append(buf, f.msg...)
+	// Remove the level's padding if nothing follows it.
+	buf = buf[:f.start+len(bytes.TrimRight(buf[f.start:], " "))]
+	col := displayWidth(buf[f.start:])
+	for _, a := range bytes.Split(attrs, []byte{attrMark}) {
+		if len(a) == 0 {
+			continue
+		}
+		w := displayWidth(a)
+		switch {
+		case f.opts.Width > 0 && col > indent && col+1+w > f.opts.Width:
+			buf = append(buf, '\n')
+			for i := 0; i < indent; i++ {
+				buf = append(buf, ' ')
+			}
+			col = indent
+		case col > 0:
+			buf = append(buf, ' ')
+			col++
+		}
+		buf = append(buf, a...)
+		col += w
+	}
+	return append(buf, '\n')
+}
+
+func (f *consoleFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	f.endHeader()
+	return buf
+}
+
+func (f *consoleFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	return buf
+}
+
+func (f *consoleFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+	// The Handler calls this before appending preformatted Attrs,
+	// which follow the built-in ones.
+	f.endHeader()
+	if len(buf) > f.start && buf[len(buf)-1] != attrMark {
+		return append(buf, attrMark)
+	}
+	return buf
+}
+
+func (f *consoleFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if f.captureHeader(a, openGroups, f.setHeaderField) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			openGroups = append(slices.Clip(openGroups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		return buf
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	if f.opts.Color {
+		buf = append(buf, "\x1b[2m"...)
+	}
+	buf = appendTextKey(buf, openGroups, a.Key)
+	buf = append(buf, '=')
+	if f.opts.Color {
+		buf = append(buf, "\x1b[0m"...)
+	}
+	return appendTextValue(buf, a.Value)
+}
+
+// setHeaderField saves a if it is a built-in Attr, and reports whether
+// it did.
+func (f *consoleFormatter) setHeaderField(a slog.Attr) bool {
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() != slog.KindTime {
+			return false
+		}
+		f.time = a.Value.Time().AppendFormat([]byte{}, f.opts.TimeFormat)
+	case slog.LevelKey:
+		f.level = f.appendBadge([]byte{}, a.Value)
+		f.level = appendPaddedLevel(f.level, a.Value, f.opts.Color, f.opts.Catalog)
+	case LoggerKey:
+		f.logger = fmt.Appendf(nil, "[%s]", a.Value)
+	case slog.MessageKey:
+		f.msg = append([]byte{}, translateMessage(f.opts.Catalog, a.Value)...)
+	default:
+		return false
+	}
+	return true
+}
+
+// appendBadge appends the badge for the level in v and a space, if
+// there are badges. The badge is padded so that the levels line up.
+func (f *consoleFormatter) appendBadge(buf []byte, v slog.Value) []byte {
+	if f.badgeWidth == 0 {
+		return buf
+	}
+	var badge string
+	if l, ok := v.Any().(slog.Level); ok && v.Kind() == slog.KindAny {
+		badge = f.opts.Badges.Badge(l)
+		if f.opts.Color && badge != "" {
+			buf = append(buf, levelColor(l)...)
+			buf = append(buf, badge...)
+			buf = append(buf, "\x1b[0m"...)
+		} else {
+			buf = append(buf, badge...)
+		}
+	}
+	for n := utf8.RuneCountInString(badge); n < f.badgeWidth; n++ {
+		buf = append(buf, ' ')
+	}
+	return append(buf, ' ')
+}
+
+// displayWidth returns the number of columns that b takes up on a
+// terminal, not counting ANSI escape sequences. It counts one column
+// per rune.
+func displayWidth(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		if b[0] == '\x1b' {
+			// Skip to the final byte of the sequence, a letter.
+			i := 1
+			for i < len(b) && !('a' <= b[i] && b[i] <= 'z' || 'A' <= b[i] && b[i] <= 'Z') {
+				i++
+			}
+			b = b[min(i+1, len(b)):]
+			continue
+		}
+		_, size := utf8.DecodeRune(b)
+		b = b[size:]
+		n++
+	}
+	return n
+}
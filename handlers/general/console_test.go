@@ -0,0 +1,126 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jba/slog/levels"
+)
+
+func TestConsoleFormatter(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		opts  ConsoleOptions
+		hopts Options
+		want  string
+	}{
+		{
+			"default",
+			ConsoleOptions{},
+			Options{},
+			"03:04:05.000 WARN  hello w=1 G.long_key=\"a b\" G.H.x=2 G.c=3\n",
+		},
+		{
+			"wrap",
+			ConsoleOptions{Width: 40},
+			Options{},
+			"03:04:05.000 WARN  hello w=1\n" +
+				"                   G.long_key=\"a b\"\n" +
+				"                   G.H.x=2 G.c=3\n",
+		},
+		{
+			"color",
+			ConsoleOptions{Color: true, TimeFormat: "15:04", Width: 20},
+			Options{ReplaceAttr: removeKeys(slog.MessageKey)},
+			"03:04 \x1b[33mWARN\x1b[0m \x1b[2mw=\x1b[0m1\n" +
+				"            \x1b[2mG.long_key=\x1b[0m\"a b\"\n" +
+				"            \x1b[2mG.H.x=\x1b[0m2\n" +
+				"            \x1b[2mG.c=\x1b[0m3\n",
+		},
+		{
+			"badges",
+			ConsoleOptions{Badges: LevelBadges{slog.LevelWarn: "!!", slog.LevelError: "E"}, Width: 40},
+			Options{},
+			"03:04:05.000 !! WARN  hello w=1\n" +
+				"                      G.long_key=\"a b\"\n" +
+				"                      G.H.x=2 G.c=3\n",
+		},
+		{
+			"badges color",
+			ConsoleOptions{Badges: DefaultBadges, Color: true, TimeFormat: "15:04"},
+			Options{ReplaceAttr: removeKeys(slog.MessageKey)},
+			"03:04 \x1b[33m⚠\x1b[0m \x1b[33mWARN\x1b[0m \x1b[2mw=\x1b[0m1 \x1b[2mG.long_key=\x1b[0m\"a b\" \x1b[2mG.H.x=\x1b[0m2 \x1b[2mG.c=\x1b[0m3\n",
+		},
+		{
+			"no header",
+			ConsoleOptions{Width: 14},
+			Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)},
+			"w=1\nG.long_key=\"a b\"\nG.H.x=2 G.c=3\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := test.hopts.New(&buf, test.opts.NewFormatter).
+				WithAttrs([]slog.Attr{slog.Int("w", 1)}).
+				WithGroup("G")
+			r := slog.NewRecord(testTime, slog.LevelWarn, "hello", 0)
+			r.AddAttrs(slog.String("long_key", "a b"), slog.Group("H", "x", 2), slog.Int("c", 3))
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConsoleBadgePadding(t *testing.T) {
+	var buf bytes.Buffer
+	f := ConsoleOptions{Badges: LevelBadges{slog.LevelError: "!!"}}.NewFormatter
+	l := slog.New(Options{ReplaceAttr: removeKeys(slog.TimeKey)}.New(&buf, f))
+	l.Info("a")
+	l.Error("b")
+	want := "   INFO  a\n!! ERROR b\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLevelBadges(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug - 4, ""},
+		{slog.LevelDebug, "·"},
+		{slog.LevelInfo, "ℹ"},
+		{slog.LevelInfo + 2, "ℹ"},
+		{slog.LevelWarn, "⚠"},
+		{slog.LevelError, "✗"},
+		{levels.LevelFatal, "‼"},
+	} {
+		if got := DefaultBadges.Badge(test.level); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.level, got, test.want)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"\x1b[1;31mERROR\x1b[0m", 5},
+		{"héllo", 5},
+		{"\x1b[", 0},
+	} {
+		if got := displayWidth([]byte(test.in)); got != test.want {
+			t.Errorf("%q: got %d, want %d", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package general
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"strconv"
+
+	"github.com/jba/slog/internal/severity"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+// Keys of Datadog's reserved attributes.
+const (
+	DatadogTraceIDKey    = "dd.trace_id"
+	DatadogSpanIDKey     = "dd.span_id"
+	DatadogLoggerNameKey = "logger.name"
+)
+
+// datadogAttr renames a top-level Attr for Datadog.
+func (f jsonFormatter) datadogAttr(a slog.Attr) slog.Attr {
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = "timestamp"
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok && a.Value.Kind() == slog.KindAny {
+			return slog.String("status", DatadogStatus(l))
+		}
+		a.Key = "status"
+	case slog.MessageKey:
+		a.Key = "message"
+	case "":
+		// Don't match an unset loggerKey.
+	case f.loggerKey:
+		a.Key = DatadogLoggerNameKey
+	}
+	return a
+}
+
+// DatadogStatus returns the Datadog status for l:
+// "debug" below [slog.LevelInfo], "info" below [slog.LevelWarn],
+// "warn" below [slog.LevelError], and "error", "critical", "alert"
+// and "emergency" for each step of 4 above that.
+func DatadogStatus(l slog.Level) string {
+	return datadogStatuses[severity.Of(l)]
+}
+
+var datadogStatuses = [...]string{"debug", "info", "warn", "error", "critical", "alert", "emergency"}
+
+// DatadogTraceAttrs returns Datadog's trace and span IDs for the
+// OpenTelemetry span in ctx, or nil if there is none. As Datadog expects,
+// the trace ID is the low 64 bits of the OpenTelemetry trace ID, and
+// both IDs are written in decimal.
+//
+// Use DatadogTraceAttrs as the ContextAttrs of a Handler's [Options].
+func DatadogTraceAttrs(ctx context.Context) []slog.Attr {
+	sc := otrace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	tid, sid := sc.TraceID(), sc.SpanID()
+	return []slog.Attr{
+		slog.String(DatadogTraceIDKey, strconv.FormatUint(binary.BigEndian.Uint64(tid[8:]), 10)),
+		slog.String(DatadogSpanIDKey, strconv.FormatUint(binary.BigEndian.Uint64(sid[:]), 10)),
+	}
+}
@@ -0,0 +1,69 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestDatadog(t *testing.T) {
+	sc := otrace.NewSpanContext(otrace.SpanContextConfig{
+		TraceID: otrace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 0, 0, 0, 0, 0, 0, 1, 2},
+		SpanID:  otrace.SpanID{0, 0, 0, 0, 0, 0, 0, 3},
+	})
+	ctx := otrace.ContextWithSpanContext(context.Background(), sc)
+	var buf bytes.Buffer
+	opts := Options{Level: slog.LevelDebug, ContextAttrs: DatadogTraceAttrs}
+	h := opts.New(&buf, JSONOptions{Datadog: true, LoggerNameKey: "logger"}.NewFormatter).
+		WithAttrs([]Attr{slog.String("logger", "db")}).
+		WithGroup("g")
+	r := slog.NewRecord(testTime, slog.LevelWarn, "m", 0)
+	r.AddAttrs(slog.Time("msg", testTime))
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	want := map[string]any{
+		"timestamp":   "2000-01-02T03:04:05.000Z",
+		"status":      "warn",
+		"message":     "m",
+		"dd.trace_id": "258",
+		"dd.span_id":  "3",
+		"logger.name": "db",
+		"g":           map[string]any{"msg": "2000-01-02T03:04:05.000Z"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	if got := DatadogTraceAttrs(context.Background()); got != nil {
+		t.Errorf("no span: got %v, want nil", got)
+	}
+}
+
+func TestDatadogStatus(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug - 4, "debug"},
+		{slog.LevelInfo, "info"},
+		{slog.LevelWarn + 1, "warn"},
+		{slog.LevelError, "error"},
+		{slog.LevelError + 4, "critical"},
+		{slog.LevelError + 8, "alert"},
+		{slog.LevelError + 12, "emergency"},
+	} {
+		if got := DatadogStatus(test.level); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.level, got, test.want)
+		}
+	}
+}
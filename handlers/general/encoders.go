@@ -0,0 +1,54 @@
+package general
+
+import (
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jba/slog/internal/logvalue"
+)
+
+var (
+	valueEncoders   sync.Map // reflect.Type to func(any) slog.Value
+	anyValueEncoder atomic.Bool
+)
+
+// RegisterValueEncoder arranges for every Handler to convert values of
+// type T with f before writing them, whatever its Formatter. It applies
+// to values of kind KindAny whose dynamic type is exactly T, after
+// LogValuers are resolved and before ExpandStructs and ReplaceAttr.
+// The value that f returns is resolved in turn.
+//
+// Call RegisterValueEncoder during initialization, for types like
+// uuid.UUID or netip.Addr whose default rendering is unsuitable.
+// A later registration for the same type replaces an earlier one.
+func RegisterValueEncoder[T any](f func(T) slog.Value) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	valueEncoders.Store(t, func(x any) slog.Value { return f(x.(T)) })
+	anyValueEncoder.Store(true)
+}
+
+var levelType = reflect.TypeOf(slog.Level(0))
+
+// hasEncoder reports whether an encoder is registered for t.
+func hasEncoder(t reflect.Type) bool {
+	if !anyValueEncoder.Load() {
+		return false
+	}
+	_, ok := valueEncoders.Load(t)
+	return ok
+}
+
+// encodeValue returns the result of the encoder registered for the type
+// of v, which must be of kind KindAny, or v if there is none.
+func encodeValue(v slog.Value) slog.Value {
+	if !anyValueEncoder.Load() {
+		return v
+	}
+	x := v.Any()
+	if e, ok := valueEncoders.Load(reflect.TypeOf(x)); ok {
+		return logvalue.Resolve(e.(func(any) slog.Value)(x))
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testID [2]byte
+
+func TestRegisterValueEncoder(t *testing.T) {
+	RegisterValueEncoder(func(id testID) slog.Value {
+		return slog.StringValue(fmt.Sprintf("%02x-%02x", id[0], id[1]))
+	})
+	RegisterValueEncoder(func(a netip.Addr) slog.Value {
+		return slog.GroupValue(slog.String("ip", a.String()), slog.Bool("private", a.IsPrivate()))
+	})
+	defer func() {
+		valueEncoders.Delete(reflect.TypeOf(testID{}))
+		valueEncoders.Delete(reflect.TypeOf(netip.Addr{}))
+	}()
+
+	for _, test := range []struct {
+		nf   func() Formatter
+		want string
+	}{
+		{NewTextFormatter, "id=0a-ff g.addr.ip=10.0.0.1 g.addr.private=true"},
+		{NewJSONFormatter, `{"id":"0a-ff","g":{"addr":{"ip":"10.0.0.1","private":true}}}`},
+	} {
+		var buf bytes.Buffer
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, test.nf)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Any("id", testID{0x0a, 0xff}), slog.Group("g", "addr", netip.MustParseAddr("10.0.0.1")))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("\ngot  %s\nwant %s", got, test.want)
+		}
+	}
+}
+
+func TestRegisterLevelEncoder(t *testing.T) {
+	RegisterValueEncoder(func(l slog.Level) slog.Value {
+		return slog.StringValue(strings.ToLower(l.String()))
+	})
+	defer valueEncoders.Delete(levelType)
+
+	for _, test := range []struct {
+		nf   func() Formatter
+		want string
+	}{
+		{NewTextFormatter, "level=warn msg=m"},
+		{NewJSONFormatter, `{"level":"warn","msg":"m"}`},
+	} {
+		var buf bytes.Buffer
+		h := New(&buf, test.nf)
+		if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelWarn, "m", 0)); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("\ngot  %q\nwant %q", got, test.want)
+		}
+	}
+}
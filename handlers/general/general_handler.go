@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"reflect"
 	"slices"
 	"strconv"
@@ -15,6 +17,13 @@ import (
 	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+	"github.com/jba/slog/metrics"
+	"github.com/jba/slog/slogsize"
+	"github.com/jba/slog/slogstruct"
+	"github.com/jba/slog/withsupport"
 )
 
 // Handler implements a [slog.Handler] that can produce a variety of output
@@ -28,7 +37,14 @@ type Handler struct {
 	newFormatter func() Formatter
 	preformatted []byte
 	groups       []string
-	mu           sync.Mutex
+	nOpen        int                       // number of groups opened in preformatted
+	nTruncated   int                       // number of values truncated in preformatted
+	goa          *withsupport.GroupOrAttrs // instead of preformatted, if deduping
+	name         string                    // logger name; see Options.LoggerName
+	named        bool                      // the first group was taken as the name
+	builtins     *builtins                 // preformatted parts of built-in Attrs, if possible
+	mu           *sync.Mutex               // shared among clones
+	stats        *metrics.Tracker          // shared among clones
 	w            io.Writer
 }
 
@@ -45,8 +61,127 @@ type Options struct {
 	// PCAttrs returns the Attrs to use for source location.
 	// If nil, no source information is output.
 	PCAttrs func(pc uintptr) []slog.Attr
+
+	// ContextAttrs returns Attrs taken from the context passed to Handle,
+	// such as trace IDs. They are written after the source location,
+	// outside of any groups.
+	// If nil, nothing is taken from the context.
+	ContextAttrs func(ctx context.Context) []slog.Attr
+
+	// If SortKeys is true, Attrs other than the built-in ones are sorted
+	// by key within each group, before ReplaceAttr is called. The Attrs
+	// of each call to WithAttrs are sorted separately from each other and
+	// from those of the record, and appear before them.
+	SortKeys bool
+
+	// If ExpandStructs is true, values of kind KindAny that hold structs,
+	// or pointers to them, are written as groups of their fields, as by
+	// [slogstruct.Value].
+	ExpandStructs bool
+
+	// DedupKeys determines what happens to Attrs with the same key in the
+	// same group, counting those from WithAttrs and WithGroup as well as the
+	// record's. The built-in Attrs are not considered. Keys are compared
+	// before ReplaceAttr is called, and groups with the same key are merged.
+	// The default, DedupNone, writes all of them.
+	//
+	// When DedupKeys is set, WithAttrs does not preformat its Attrs,
+	// because a later Attr may replace one of them.
+	DedupKeys DedupMode
+
+	// Limits, if non-nil, bounds the size of the output.
+	Limits *Limits
+
+	// OnError, if non-nil, is called with the error when writing a record
+	// fails, after any retries.
+	OnError func(error)
+
+	// Fallback, if non-nil, receives a record when writing it to the
+	// Handler's writer fails. If the write to Fallback succeeds, Handle
+	// returns nil.
+	Fallback io.Writer
+
+	// Retries is the number of times to retry a write that fails with
+	// a transient error: one with a Timeout or Temporary method that
+	// returns true. Only the unwritten part is retried.
+	Retries int
+
+	// Hooks, if non-nil, is told about records, drops and errors.
+	Hooks metrics.Hooks
+
+	// If CheckContext is true, Handle drops the record and returns
+	// the context's error if the context passed to it is done, before
+	// formatting the record and before each retry.
+	CheckContext bool
+
+	// WriteTimeout, if positive, limits the time of each write to a
+	// writer with a SetWriteDeadline method, like a net.Conn.
+	// If CheckContext is true and the context has an earlier deadline,
+	// that deadline is used.
+	WriteTimeout time.Duration
+
+	// If TimeUTC is true, the record's time is converted to UTC before
+	// it is formatted. Otherwise, if Location is non-nil, it is converted
+	// to Location. Times in other Attrs are unchanged.
+	TimeUTC  bool
+	Location *time.Location
+
+	// Clock, if non-nil, supplies the time of records whose time is zero,
+	// which would otherwise have no time. Tests can use it to make output
+	// deterministic; see [github.com/jba/slog/handlertest.FakeClock].
+	Clock func() time.Time
+
+	// LoggerName says where the Handler finds the name of a logger, like
+	// the name of a zap Named logger. The name is written after the
+	// message, as an Attr with key [LoggerKey]. The default, NameNone,
+	// writes no name.
+	LoggerName LoggerNameMode
+
+	// OnBefore, if non-nil, is called at the start of Handle with a copy
+	// of the record, which it may change, as by adding Attrs. The changed
+	// record is handled.
+	OnBefore func(ctx context.Context, r *slog.Record)
+
+	// OnAfter, if non-nil, is called at the end of Handle with the
+	// record that was handled and the error that Handle returns.
+	OnAfter func(ctx context.Context, r slog.Record, err error)
+
+	// LoggerNameKey is the key of the Attr that holds the name when
+	// LoggerName is NameFromAttr, like the one that package registry
+	// adds. If empty, [LoggerKey] is used.
+	LoggerNameKey string
 }
 
+// location returns the location that record times are converted to,
+// or nil if they are left alone.
+func (opts Options) location() *time.Location {
+	if opts.TimeUTC {
+		return time.UTC
+	}
+	return opts.Location
+}
+
+// DropCanceled is the reason given to Hooks.OnDrop when a record is
+// dropped because its context is done.
+const DropCanceled = "canceled"
+
+// A ContextWriter is a writer that takes a context.
+// If a Handler's writer implements ContextWriter, the Handler calls
+// WriteContext with the context passed to Handle instead of Write.
+type ContextWriter interface {
+	WriteContext(ctx context.Context, p []byte) (int, error)
+}
+
+// A DedupMode says how a Handler treats duplicate keys.
+type DedupMode int
+
+const (
+	DedupNone      DedupMode = iota // keep all Attrs
+	DedupKeepLast                   // keep the last Attr with a key
+	DedupKeepFirst                  // keep the first Attr with a key
+	DedupRename                     // rename later Attrs to key#2, key#3, ...
+)
+
 // New constructs a Handler with the default options.
 func New(w io.Writer, newFormatter func() Formatter) *Handler {
 	return Options{}.New(w, newFormatter)
@@ -58,6 +193,9 @@ func (opts Options) New(w io.Writer, newFormatter func() Formatter) *Handler {
 		w:            w,
 		opts:         opts,
 		newFormatter: newFormatter,
+		builtins:     newBuiltins(opts, newFormatter),
+		mu:           &sync.Mutex{},
+		stats:        &metrics.Tracker{},
 	}
 }
 
@@ -69,69 +207,507 @@ func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
 	return level >= minLevel
 }
 
+var bufPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	buf := make([]byte, 0, 1024) // TODO: use a sync.Pool.
+	if h.opts.OnBefore != nil {
+		r = h.before(ctx, r)
+	}
+	err := h.handle(ctx, r)
+	if h.opts.OnAfter != nil {
+		h.opts.OnAfter(ctx, r, err)
+	}
+	return err
+}
+
+// before calls the OnBefore hook on a copy of r, which it returns.
+// It is a separate function so that r doesn't escape from Handle.
+func (h *Handler) before(ctx context.Context, r slog.Record) slog.Record {
+	// Clone r so that Attrs added by the hook don't affect other
+	// copies of r.
+	r = r.Clone()
+	h.opts.OnBefore(ctx, &r)
+	return r
+}
+
+func (h *Handler) handle(ctx context.Context, r slog.Record) error {
+	// Enabled is only a question, so the level is enforced here too,
+	// for records that reach Handle anyway.
+	if !h.Enabled(ctx, r.Level) {
+		if h.opts.Hooks != nil {
+			h.opts.Hooks.OnDrop(metrics.DropLevel)
+		}
+		return nil
+	}
+	bufp := bufPool.Get().(*[]byte)
+	// Make room for the whole record, so buf grows at most once.
+	buf := slices.Grow((*bufp)[:0], slogsize.EstimateSize(r)+len(h.preformatted))
+	defer func() {
+		// Don't hold on to large buffers.
+		if cap(buf) <= 16<<10 {
+			*bufp = buf
+			bufPool.Put(bufp)
+		}
+	}()
+	if h.opts.Hooks != nil {
+		h.opts.Hooks.OnRecord(r.Level)
+	}
+	if err := h.checkContext(ctx); err != nil {
+		return err
+	}
 	f := h.newFormatter()
+	ntrunc := h.nTruncated
 	buf = f.AppendBegin(buf)
-	if !r.Time.IsZero() {
-		buf = h.appendAttr(buf, f, slog.Time(slog.TimeKey, r.Time), false)
+	t := r.Time
+	if t.IsZero() && h.opts.Clock != nil {
+		t = h.opts.Clock()
+	}
+	if loc := h.opts.location(); loc != nil && !t.IsZero() {
+		t = t.In(loc)
+	}
+	// The preformatted levels would bypass an encoder for slog.Level.
+	if h.builtins != nil && !hasEncoder(levelType) {
+		buf = h.builtins.append(buf, f.(builtinFormatter), t, r.Level, r.Message)
+	} else {
+		if !t.IsZero() {
+			buf = h.appendAttr(buf, f, slog.Time(slog.TimeKey, t), false, &ntrunc)
+		}
+		buf = h.appendAttr(buf, f, slog.Any(slog.LevelKey, r.Level), false, &ntrunc)
+		buf = h.appendAttr(buf, f, slog.String(slog.MessageKey, r.Message), false, &ntrunc)
+	}
+	if h.name != "" {
+		buf = h.appendAttr(buf, f, slog.String(LoggerKey, h.name), false, &ntrunc)
 	}
-	buf = h.appendAttr(buf, f, slog.Any(slog.LevelKey, r.Level), false)
-	buf = h.appendAttr(buf, f, slog.String(slog.MessageKey, r.Message), false)
 	if h.opts.PCAttrs != nil {
 		for _, a := range h.opts.PCAttrs(r.PC) {
-			buf = h.appendAttr(buf, f, a, false)
+			buf = h.appendAttr(buf, f, a, false, &ntrunc)
 		}
 	}
-	if len(h.preformatted) > 0 {
-		buf = f.AppendSeparatorIfNeeded(buf)
-		buf = append(buf, h.preformatted...)
+	if h.opts.ContextAttrs != nil {
+		for _, a := range h.opts.ContextAttrs(ctx) {
+			buf = h.appendAttr(buf, f, a, false, &ntrunc)
+		}
 	}
-	r.Attrs(func(a slog.Attr) bool {
-		buf = h.appendAttr(buf, f, a, true)
-		return true
-	})
-	for i := len(h.groups) - 1; i >= 0; i-- {
-		buf = f.AppendCloseGroup(buf, h.groups[i])
+	if h.opts.DedupKeys != DedupNone {
+		as := dedupAttrs(h.allAttrs(r, &ntrunc), h.opts.DedupKeys)
+		if h.opts.SortKeys {
+			as = sortAttrs(as)
+		}
+		for _, a := range as {
+			buf = h.appendAttr(buf, f, a, false, &ntrunc)
+		}
+	} else {
+		if len(h.preformatted) > 0 {
+			buf = f.AppendSeparatorIfNeeded(buf)
+			buf = append(buf, h.preformatted...)
+			setOpenGroups(f, h.groups[:h.nOpen])
+		}
+		nOpen := h.nOpen
+		if r.NumAttrs() > 0 {
+			var wrote bool
+			buf, wrote = h.appendInPendingGroups(buf, f, func(buf []byte) []byte {
+				if h.opts.SortKeys {
+					for _, a := range sortAttrs(h.recordAttrs(r, &ntrunc)) {
+						buf = h.appendAttr(buf, f, a, true, &ntrunc)
+					}
+					return buf
+				}
+				n := 0
+				r.Attrs(func(a slog.Attr) bool {
+					if h.attrLimitReached(n, &ntrunc) {
+						return true
+					}
+					n++
+					buf = h.appendAttr(buf, f, a, true, &ntrunc)
+					return true
+				})
+				return buf
+			})
+			if wrote {
+				nOpen = len(h.groups)
+			}
+		}
+		for i := nOpen - 1; i >= 0; i-- {
+			buf = f.AppendCloseGroup(buf, h.groups[i])
+		}
+	}
+	if ntrunc > 0 {
+		buf = h.appendAttr(buf, f, slog.Int(TruncatedKey, ntrunc), false, &ntrunc)
 	}
 	buf = f.AppendEnd(buf)
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	_, err := h.w.Write(buf)
+	return h.write(ctx, buf)
+}
+
+// Status reports the records and bytes that h and the Handlers derived
+// from it have written, and the errors from writing. Records sent to
+// the Fallback writer count as errors.
+func (h *Handler) Status() metrics.Status {
+	return h.stats.Status()
+}
+
+// checkContext returns ctx.Err() if the CheckContext option is set,
+// reporting the drop to the hooks.
+func (h *Handler) checkContext(ctx context.Context) error {
+	if !h.opts.CheckContext {
+		return nil
+	}
+	err := ctx.Err()
+	if err != nil && h.opts.Hooks != nil {
+		h.opts.Hooks.OnDrop(DropCanceled)
+	}
 	return err
 }
 
+// write writes buf, retrying, reporting and falling back as the
+// options say. h.mu must be held.
+func (h *Handler) write(ctx context.Context, buf []byte) error {
+	if dw, ok := h.w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if d, ok := h.writeDeadline(ctx); ok {
+			if err := dw.SetWriteDeadline(d); err == nil {
+				defer dw.SetWriteDeadline(time.Time{})
+			}
+		}
+	}
+	n, err := h.writeOnce(ctx, buf)
+	for i := 0; err != nil && i < h.opts.Retries && isTransient(err); i++ {
+		if cerr := h.checkContext(ctx); cerr != nil {
+			return cerr
+		}
+		var m int
+		m, err = h.writeOnce(ctx, buf[n:])
+		n += m
+	}
+	if err == nil {
+		h.stats.Wrote(len(buf))
+		return nil
+	}
+	h.stats.Failed(err)
+	if h.opts.OnError != nil {
+		h.opts.OnError(err)
+	}
+	if h.opts.Hooks != nil {
+		h.opts.Hooks.OnError(err)
+	}
+	if h.opts.Fallback != nil {
+		if _, ferr := h.opts.Fallback.Write(buf); ferr == nil {
+			return nil
+		}
+	}
+	if h.opts.Hooks != nil {
+		h.opts.Hooks.OnDrop(metrics.DropError)
+	}
+	return err
+}
+
+func (h *Handler) writeOnce(ctx context.Context, buf []byte) (int, error) {
+	if cw, ok := h.w.(ContextWriter); ok {
+		return cw.WriteContext(ctx, buf)
+	}
+	return h.w.Write(buf)
+}
+
+// writeDeadline returns the deadline for a write, if there is one.
+func (h *Handler) writeDeadline(ctx context.Context) (time.Time, bool) {
+	var d time.Time
+	if h.opts.WriteTimeout > 0 {
+		d = time.Now().Add(h.opts.WriteTimeout)
+	}
+	if h.opts.CheckContext {
+		if cd, ok := ctx.Deadline(); ok && (d.IsZero() || cd.Before(d)) {
+			d = cd
+		}
+	}
+	return d, !d.IsZero()
+}
+
+// isTransient reports whether err is a temporary condition, like a timeout.
+func isTransient(err error) bool {
+	var t interface{ Timeout() bool }
+	if errors.As(err, &t) && t.Timeout() {
+		return true
+	}
+	var tmp interface{ Temporary() bool }
+	return errors.As(err, &tmp) && tmp.Temporary()
+}
+
+// WithGroup returns a Handler for the group. The group is not opened
+// in the output until an Attr is written to it, so that empty groups
+// do not appear.
 func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	if h.opts.LoggerName == NameFromGroup && !h.named {
+		c := h.clone()
+		c.name = name
+		c.named = true
+		return c
+	}
 	c := h.clone()
 	c.groups = append(c.groups, name)
-	f := c.newFormatter()
-	c.preformatted = f.AppendOpenGroup(c.preformatted, name)
+	if c.opts.DedupKeys != DedupNone {
+		c.goa = c.goa.WithGroup(name)
+	}
 	return c
 }
 
 func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	if h.opts.LoggerName == NameFromAttr && len(h.groups) == 0 {
+		if name, rest, ok := h.opts.nameFromAttrs(as); ok {
+			c := h.clone()
+			c.name = name
+			return c.WithAttrs(rest)
+		}
+	}
+	if len(as) == 0 {
+		return h
+	}
+	if h.opts.DedupKeys != DedupNone {
+		c := h.clone()
+		c.goa = c.goa.WithAttrs(as)
+		return c
+	}
+	if h.opts.SortKeys {
+		as = sortAttrs(as)
+	}
 	c := h.clone()
 	f := c.newFormatter()
-	for _, a := range as {
-		c.preformatted = c.appendAttr(c.preformatted, f, a, true)
+	setOpenGroups(f, c.groups[:c.nOpen])
+	var wrote bool
+	c.preformatted, wrote = c.appendInPendingGroups(c.preformatted, f, func(buf []byte) []byte {
+		for _, a := range as {
+			buf = c.appendAttr(buf, f, a, true, &c.nTruncated)
+		}
+		return buf
+	})
+	if wrote {
+		c.nOpen = len(c.groups)
 	}
 	return c
 }
 
-func (h *Handler) appendAttr(buf []byte, f Formatter, a slog.Attr, includeGroups bool) []byte {
+// appendInPendingGroups opens the groups that have not yet been opened,
+// then calls appendAttrs. If appendAttrs writes nothing, the groups are
+// not opened after all. appendInPendingGroups reports whether anything
+// was written.
+func (h *Handler) appendInPendingGroups(buf []byte, f Formatter, appendAttrs func([]byte) []byte) ([]byte, bool) {
+	mark := len(buf)
+	for _, g := range h.groups[h.nOpen:] {
+		buf = f.AppendOpenGroup(buf, g)
+	}
+	start := len(buf)
+	buf = appendAttrs(buf)
+	if len(buf) == start {
+		setOpenGroups(f, h.groups[:h.nOpen])
+		return buf[:mark], false
+	}
+	return buf, true
+}
+
+// appendAttr appends a, calling ReplaceAttr on it and,
+// if it is a group, on each of its non-group members.
+// The number of values changed by Limits is added to *ntrunc.
+func (h *Handler) appendAttr(buf []byte, f Formatter, a slog.Attr, includeGroups bool, ntrunc *int) []byte {
 	var groups []string
 	if includeGroups {
 		groups = h.groups
 	}
+	return h.appendAttrInGroups(buf, f, a, groups, ntrunc)
+}
+
+func (h *Handler) appendAttrInGroups(buf []byte, f Formatter, a slog.Attr, groups []string, ntrunc *int) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Value.Kind() == slog.KindAny {
+		a.Value = encodeValue(a.Value)
+	}
+	if h.opts.ExpandStructs && a.Value.Kind() == slog.KindAny {
+		a.Value = logvalue.Resolve(slogstruct.Value(a.Value.Any()))
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a2, ok := h.opts.Limits.limitGroup(a, len(groups)); ok {
+			a = a2
+			*ntrunc++
+		} else if len(groups) >= maxGroupDepth {
+			// Probably a LogValuer that returns itself in a group.
+			a = slog.String(a.Key, "!ERROR: groups nested too deeply")
+		}
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			if ef, ok := f.(interface{ keepsEmptyGroups() bool }); ok && ef.keepsEmptyGroups() && a.Key != "" {
+				return appendAttrSafely(buf, f, a, groups)
+			}
+			return buf
+		}
+		if h.opts.SortKeys {
+			attrs = sortAttrs(attrs)
+		}
+		if a.Key != "" {
+			buf = f.AppendOpenGroup(buf, a.Key)
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, a2 := range attrs {
+			buf = h.appendAttrInGroups(buf, f, a2, groups, ntrunc)
+		}
+		if a.Key != "" {
+			buf = f.AppendCloseGroup(buf, a.Key)
+		}
+		return buf
+	}
 	if h.opts.ReplaceAttr != nil {
 		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = logvalue.Resolve(a.Value)
+	}
+	if a2, ok := h.opts.Limits.limitValue(a); ok {
+		a = a2
+		*ntrunc++
 	}
 	if a.Key != "" || a.Value.Kind() == slog.KindGroup {
-		return f.AppendAttr(buf, a, groups)
+		return appendAttrSafely(buf, f, a, groups)
 	}
 	return buf
 }
 
+// maxGroupDepth is the deepest nesting of groups that a Handler will write.
+const maxGroupDepth = 100
+
+// appendAttrSafely calls f.AppendAttr. If that panics, perhaps in
+// a MarshalJSON or MarshalText method, appendAttrSafely discards
+// what f.AppendAttr wrote and appends the panic value instead,
+// prefixed with "!PANIC: ".
+func appendAttrSafely(buf []byte, f Formatter, a slog.Attr, groups []string) (res []byte) {
+	n := len(buf)
+	defer func() {
+		if p := recover(); p != nil {
+			res = f.AppendAttr(buf[:n], slog.String(a.Key, fmt.Sprintf("!PANIC: %v", p)), groups)
+		}
+	}()
+	return f.AppendAttr(buf, a, groups)
+}
+
+// sortAttrs returns a copy of as, sorted stably by key. LogValuers are
+// resolved, and the members of groups with empty keys are inlined so they
+// are sorted with the others.
+func sortAttrs(as []slog.Attr) []slog.Attr {
+	res := inlineAttrs(nil, as)
+	slices.SortStableFunc(res, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	return res
+}
+
+// inlineAttrs appends as to res, resolving LogValuers and replacing
+// groups with empty keys by their members.
+func inlineAttrs(res, as []slog.Attr) []slog.Attr {
+	for _, a := range as {
+		a.Value = logvalue.Resolve(a.Value)
+		if a.Key == "" && a.Value.Kind() == slog.KindGroup {
+			res = inlineAttrs(res, a.Value.Group())
+		} else {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// mergeGroups returns as with the members of each group appended to
+// those of the first group with the same key.
+func mergeGroups(as []slog.Attr) []slog.Attr {
+	var res []slog.Attr
+	groupIndex := map[string]int{}
+	for _, a := range as {
+		if a.Value.Kind() == slog.KindGroup {
+			if i, ok := groupIndex[a.Key]; ok {
+				members := append(slices.Clip(res[i].Value.Group()), a.Value.Group()...)
+				res[i].Value = slog.GroupValue(members...)
+				continue
+			}
+			groupIndex[a.Key] = len(res)
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// recordAttrs returns the Attrs of r, up to the limit.
+// The number dropped is added to *ntrunc.
+func (h *Handler) recordAttrs(r slog.Record, ntrunc *int) []slog.Attr {
+	as := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		if !h.attrLimitReached(len(as), ntrunc) {
+			as = append(as, a)
+		}
+		return true
+	})
+	return as
+}
+
+// attrLimitReached reports whether n record Attrs are already the maximum.
+// If so, it counts another dropped Attr in *ntrunc.
+func (h *Handler) attrLimitReached(n int, ntrunc *int) bool {
+	if l := h.opts.Limits; l != nil && l.MaxAttrs > 0 && n >= l.MaxAttrs {
+		*ntrunc++
+		return true
+	}
+	return false
+}
+
+// allAttrs returns the Attrs from WithAttrs and WithGroup followed by
+// those of r, with the groups as group Attrs.
+func (h *Handler) allAttrs(r slog.Record, ntrunc *int) []slog.Attr {
+	return h.goa.Nest(h.recordAttrs(r, ntrunc))
+}
+
+// dedupAttrs returns the Attrs of as and their group members
+// with duplicate keys removed or renamed according to mode.
+// Groups with the same key are first merged into one.
+func dedupAttrs(as []slog.Attr, mode DedupMode) []slog.Attr {
+	as = mergeGroups(inlineAttrs(nil, as))
+	var last map[string]int // index of last occurrence of each key
+	if mode == DedupKeepLast {
+		last = map[string]int{}
+		for i, a := range as {
+			last[a.Key] = i
+		}
+	}
+	counts := map[string]int{}
+	used := map[string]bool{} // output keys, for DedupRename
+	res := make([]slog.Attr, 0, len(as))
+	for i, a := range as {
+		counts[a.Key]++
+		n := counts[a.Key]
+		switch mode {
+		case DedupKeepLast:
+			if last[a.Key] != i {
+				continue
+			}
+		case DedupKeepFirst:
+			if n > 1 {
+				continue
+			}
+		case DedupRename:
+			if used[a.Key] {
+				// Skip suffixes taken by other keys, as in "a#2".
+				n = max(n, 2)
+				for used[a.Key+"#"+strconv.Itoa(n)] {
+					n++
+				}
+				counts[a.Key] = n
+				a.Key += "#" + strconv.Itoa(n)
+			}
+			used[a.Key] = true
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(dedupAttrs(a.Value.Group(), mode)...)
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
 func (h *Handler) clone() *Handler {
 	c := *h
 	c.groups = slices.Clip(c.groups)
@@ -159,45 +735,259 @@ type Formatter interface {
 	AppendSeparatorIfNeeded([]byte) []byte
 }
 
+// A StatefulFormatter is a Formatter whose output depends on the groups
+// it has opened, like one that indents the members of groups.
+//
+// The Handler formats the Attrs of each call to WithAttrs once, and
+// appends the result to the output of each record, so the groups that
+// the preformatted Attrs opened were opened by a different Formatter.
+// The Handler calls SetOpenGroups to tell a StatefulFormatter about
+// them: after appending preformatted Attrs, before formatting the Attrs
+// of WithAttrs, and after discarding groups that turned out to be empty.
+type StatefulFormatter interface {
+	Formatter
+	// SetOpenGroups sets the groups that are open, outermost first.
+	SetOpenGroups(groups []string)
+}
+
+// setOpenGroups calls f.SetOpenGroups if f is a StatefulFormatter.
+func setOpenGroups(f Formatter, groups []string) {
+	if sf, ok := f.(StatefulFormatter); ok {
+		sf.SetOpenGroups(groups)
+	}
+}
+
 ////////////////////////////////////////////////////////////////
 
 type jsonFormatter struct {
+	flatten        bool
+	datadog        bool
+	loggerKey      string
+	durationString bool
+	durationUnit   time.Duration
+	durationObject bool
+	quoteBigInts   bool
+	empty          EmptyPolicy
+	expandErrors   bool
+	anyOrder       []AnyEncoding
+	encodeAny      func(buf []byte, v any) ([]byte, bool)
+}
+
+// NewJSONFormatter returns a Formatter that writes each log event as
+// a JSON object.
+func NewJSONFormatter() Formatter {
+	return jsonFormatter{}
+}
+
+// JSONOptions are options for a JSON Formatter.
+type JSONOptions struct {
+	// If Flatten is true, groups are not written as nested objects.
+	// Instead, each key is prefixed with the names of its groups,
+	// separated by dots: {"g.h.c":3}. Dots and backslashes in the
+	// original group names and keys are escaped with a backslash,
+	// so the key "a.b" in group "g" is written as "g.a\\.b" (that is,
+	// g.a\.b after JSON decoding).
+	Flatten bool
+
+	// If Datadog is true, the built-in Attrs are written with the names
+	// of Datadog's reserved attributes: the time as "timestamp", the level
+	// as "status", with the values of [DatadogStatus], and the message as
+	// "message". Times are written with millisecond precision.
+	// Like all renaming by the Formatter, this applies to every top-level
+	// Attr with a built-in key.
+	// Use [DatadogTraceAttrs] as the handler's ContextAttrs to add
+	// Datadog's trace and span IDs.
+	Datadog bool
+
+	// LoggerNameKey, if non-empty and Datadog is true, is the key of
+	// a top-level Attr holding the logger's name, such as the one added
+	// by package registry. It is written as "logger.name".
+	LoggerNameKey string
+
+	// If DurationString is true, durations are written as strings in the
+	// format of [time.Duration.String], like "1.5s". Otherwise they are
+	// written as integer nanoseconds, as [slog.JSONHandler] does.
+	DurationString bool
+
+	// DurationUnit, if non-zero, is the unit of the numbers that durations
+	// are written as, instead of nanoseconds. It must be one of
+	// time.Nanosecond, time.Microsecond, time.Millisecond, time.Second,
+	// time.Minute and time.Hour. Durations that are not a whole number of
+	// units are written with a fraction, so with a unit of milliseconds,
+	// 1500ms is written as 1500 and 1.5ms as 1.5. It is ignored if
+	// DurationString is true.
+	DurationUnit time.Duration
+
+	// If DurationObject is true, durations are written as objects holding
+	// the number and the name of its unit, one of "ns", "us", "ms", "s",
+	// "m" and "h": {"value":1500,"unit":"ms"}. It is ignored if
+	// DurationString is true.
+	DurationObject bool
+
+	// If QuoteBigInts is true, integers that a float64 cannot hold
+	// exactly, those greater than 2^53-1 or less than -(2^53-1), are
+	// written as JSON strings, so that consumers that read numbers as
+	// float64, like JavaScript, don't lose precision. This applies to
+	// values of kind Int64 and Uint64, and to durations written as
+	// integers.
+	QuoteBigInts bool
+
+	// Empty says how empty values are written: groups with no Attrs,
+	// zero times, and nil values of kind KindAny, either untyped nil or
+	// nil pointers. Since [slog.Record.AddAttrs] and [slog.GroupValue]
+	// drop empty groups, they usually come from LogValuers.
+	// The Handler's own time Attr is not affected: a record with a zero
+	// time has none. Groups from [Handler.WithGroup] that end up empty
+	// are always omitted.
+	Empty EmptyPolicy
+
+	// If ExpandErrors is true, errors are written as objects with the
+	// error's message and the name of its type, as printed by %T:
+	// {"msg":"open x: no such file","type":"*fs.PathError"}.
+	// Otherwise they are written as their message.
+	ExpandErrors bool
+
+	// AnyOrder is the order in which the ways of encoding a value of kind
+	// KindAny, other than a level or an error, are tried. The first that
+	// applies to the value is used; EncodeReflect always applies.
+	// If nil, DefaultAnyOrder is used.
+	AnyOrder []AnyEncoding
+
+	// EncodeAny, if non-nil, is called before the encodings of AnyOrder are
+	// tried. If it returns true, it has appended the value's JSON encoding
+	// to buf and returned the result. If it returns false, it must return
+	// buf unchanged.
+	EncodeAny func(buf []byte, v any) ([]byte, bool)
 }
 
-func newJSONFormatter() Formatter {
-	return &jsonFormatter{}
+// An AnyEncoding is a way for a JSON Formatter to encode a value of kind
+// KindAny.
+type AnyEncoding int
+
+const (
+	// EncodeMarshalJSON uses the value's MarshalJSON method, if it has one.
+	EncodeMarshalJSON AnyEncoding = iota
+	// EncodeMarshalText writes the result of the value's MarshalText
+	// method, if it has one, as a JSON string.
+	EncodeMarshalText
+	// EncodeString writes the result of the value's String method,
+	// if it has one, as a JSON string.
+	EncodeString
+	// EncodeReflect encodes the value with [json.Marshal].
+	EncodeReflect
+)
+
+// An EmptyPolicy says how a JSON Formatter writes empty values.
+type EmptyPolicy int
+
+const (
+	// EmptyDefault omits empty groups, writes zero times as times,
+	// like "0001-01-01T00:00:00Z", and writes nil values as null,
+	// as [slog.JSONHandler] does.
+	EmptyDefault EmptyPolicy = iota
+	// EmptyOmit omits empty values along with their keys.
+	EmptyOmit
+	// EmptyNull writes empty values as null.
+	EmptyNull
+	// EmptyZero writes empty groups as {} and zero times and nil
+	// values as "".
+	EmptyZero
+)
+
+// DefaultAnyOrder is the order of encodings used when
+// JSONOptions.AnyOrder is nil.
+var DefaultAnyOrder = []AnyEncoding{EncodeMarshalJSON, EncodeMarshalText, EncodeString, EncodeReflect}
+
+// durationUnitNames are the units allowed for JSONOptions.DurationUnit,
+// with their names.
+var durationUnitNames = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "us",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+	time.Minute:      "m",
+	time.Hour:        "h",
+}
+
+// NewFormatter returns a JSON Formatter with the given options.
+// Pass the method value opts.NewFormatter to [Options.New].
+// It panics if opts.DurationUnit is not one of the allowed units.
+func (opts JSONOptions) NewFormatter() Formatter {
+	unit := opts.DurationUnit
+	if unit == 0 {
+		unit = time.Nanosecond
+	}
+	if _, ok := durationUnitNames[unit]; !ok {
+		panic(fmt.Sprintf("general: bad DurationUnit %s", unit))
+	}
+	return jsonFormatter{
+		flatten:        opts.Flatten,
+		datadog:        opts.Datadog,
+		loggerKey:      opts.LoggerNameKey,
+		durationString: opts.DurationString,
+		durationUnit:   unit,
+		durationObject: opts.DurationObject,
+		quoteBigInts:   opts.QuoteBigInts,
+		empty:          opts.Empty,
+		expandErrors:   opts.ExpandErrors,
+		anyOrder:       opts.AnyOrder,
+		encodeAny:      opts.EncodeAny,
+	}
 }
 
-func (f *jsonFormatter) AppendBegin(buf []byte) []byte {
+func (f jsonFormatter) AppendBegin(buf []byte) []byte {
 	return append(buf, '{')
 }
 
-func (f *jsonFormatter) AppendEnd(buf []byte) []byte {
+func (f jsonFormatter) AppendEnd(buf []byte) []byte {
 	return append(buf, '}')
 }
 
-func (f *jsonFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+func (f jsonFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	if f.flatten {
+		return buf
+	}
 	buf = f.AppendSeparatorIfNeeded(buf)
-	return fmt.Appendf(buf, "%q:{", name)
+	buf = appendJSONKey(buf, name)
+	return append(buf, '{')
 }
 
-func (f *jsonFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+func (f jsonFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	if f.flatten {
+		return buf
+	}
 	return append(buf, '}')
 }
 
-func (f *jsonFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+func (f jsonFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
 	if len(buf) > 0 && buf[len(buf)-1] != '{' && buf[len(buf)-1] != ',' {
 		return append(buf, ',')
 	}
 	return buf
 }
 
-func (f *jsonFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
-	a.Value = a.Value.Resolve()
+func (f jsonFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if f.datadog && len(openGroups) == 0 {
+		a = f.datadogAttr(a)
+	}
+	if f.empty != EmptyDefault && isEmpty(a.Value) {
+		return f.appendEmpty(buf, a, openGroups)
+	}
+	if f.flatten && a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			openGroups = append(slices.Clip(openGroups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		return buf
+	}
 	buf = f.AppendSeparatorIfNeeded(buf)
 	if a.Value.Kind() == slog.KindGroup {
 		if a.Key != "" {
-			buf = fmt.Appendf(buf, "%q:{", a.Key)
+			buf = appendJSONKey(buf, a.Key)
+			buf = append(buf, '{')
 		}
 		for _, a2 := range a.Value.Group() {
 			buf = f.AppendAttr(buf, a2, openGroups)
@@ -206,7 +996,11 @@ func (f *jsonFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string)
 			buf = append(buf, '}')
 		}
 	} else {
-		buf = fmt.Appendf(buf, "%q:", a.Key)
+		if f.flatten {
+			buf = appendFlatJSONKey(buf, openGroups, a.Key)
+		} else {
+			buf = appendJSONKey(buf, a.Key)
+		}
 		v := a.Value
 		switch v.Kind() {
 		case slog.KindString:
@@ -215,35 +1009,288 @@ func (f *jsonFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string)
 			buf = append(buf, '"')
 
 		case slog.KindInt64:
-			buf = strconv.AppendInt(buf, v.Int64(), 10)
+			buf = f.appendInt(buf, v.Int64())
+		case slog.KindUint64:
+			buf = f.appendUint(buf, v.Uint64())
+		case slog.KindFloat64:
+			buf = appendJSONFloat(buf, v.Float64())
+		case slog.KindBool:
+			buf = strconv.AppendBool(buf, v.Bool())
+		case slog.KindDuration:
+			buf = f.appendDuration(buf, v.Duration())
 		case slog.KindTime:
-			buf = strconv.AppendQuote(buf, v.Time().Format(time.RFC3339))
+			buf = append(buf, '"')
+			if f.datadog {
+				buf = appendTimeRFC3339Millis(buf, v.Time())
+			} else {
+				buf = v.Time().AppendFormat(buf, time.RFC3339)
+			}
+			buf = append(buf, '"')
 		case slog.KindAny:
 			a := v.Any()
-			if err, ok := a.(error); ok {
-				buf = append(buf, err.Error()...)
+			if l, ok := a.(slog.Level); ok {
+				buf = append(buf, '"')
+				buf = append(buf, levels.String(l)...)
+				buf = append(buf, '"')
+			} else if a2, ok := a.(slog.Attr); ok {
+				// An Attr that is the value of another Attr is
+				// written as an object with one member.
+				f2 := f
+				f2.datadog = false
+				buf = append(buf, '{')
+				buf = f2.AppendAttr(buf, a2, nil)
+				buf = append(buf, '}')
+			} else if err, ok := a.(error); ok {
+				buf = f.appendError(buf, err)
 			} else {
-				bs, err := json.Marshal(a)
-				if err != nil {
-					buf = append(buf, `"?"`...)
-				} else {
-					buf = append(buf, bs...)
-				}
+				buf = f.appendAny(buf, a)
 			}
 
 		default:
+			buf = append(buf, '"')
 			buf = appendEscapedJSONString(buf, v.String())
+			buf = append(buf, '"')
+		}
+	}
+	return buf
+}
+
+// isEmpty reports whether v is a value that JSONOptions.Empty applies to.
+func isEmpty(v slog.Value) bool {
+	switch v.Kind() {
+	case slog.KindGroup:
+		return len(v.Group()) == 0
+	case slog.KindTime:
+		return v.Time().IsZero()
+	case slog.KindAny:
+		x := v.Any()
+		if x == nil {
+			return true
+		}
+		rv := reflect.ValueOf(x)
+		return rv.Kind() == reflect.Pointer && rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// appendEmpty appends a, whose value is empty, according to f.empty.
+func (f jsonFormatter) appendEmpty(buf []byte, a slog.Attr, openGroups []string) []byte {
+	if f.empty == EmptyOmit || a.Key == "" {
+		return buf
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	if f.flatten {
+		buf = appendFlatJSONKey(buf, openGroups, a.Key)
+	} else {
+		buf = appendJSONKey(buf, a.Key)
+	}
+	switch {
+	case f.empty == EmptyNull:
+		return append(buf, "null"...)
+	case a.Value.Kind() == slog.KindGroup:
+		return append(buf, "{}"...)
+	default:
+		return append(buf, `""`...)
+	}
+}
+
+// keepsEmptyGroups reports whether f writes groups with no Attrs,
+// so the Handler should pass them to it.
+func (f jsonFormatter) keepsEmptyGroups() bool {
+	return f.empty == EmptyNull || f.empty == EmptyZero
+}
+
+// appendAny appends the JSON encoding of a, using the first of f.encodeAny
+// and the encodings of f.anyOrder that applies.
+func (f jsonFormatter) appendAny(buf []byte, a any) []byte {
+	if f.encodeAny != nil {
+		if b, ok := f.encodeAny(buf, a); ok {
+			return b
 		}
 	}
+	order := f.anyOrder
+	if order == nil {
+		order = DefaultAnyOrder
+	}
+	for _, e := range order {
+		switch e {
+		case EncodeMarshalJSON:
+			if _, ok := a.(json.Marshaler); ok {
+				// json.Marshal calls MarshalJSON, then validates
+				// and compacts the result.
+				return appendJSONMarshal(buf, a)
+			}
+		case EncodeMarshalText:
+			if m, ok := a.(encoding.TextMarshaler); ok {
+				text, err := m.MarshalText()
+				if err != nil {
+					return appendJSONError(buf, err)
+				}
+				buf = append(buf, '"')
+				buf = appendEscapedJSONString(buf, string(text))
+				return append(buf, '"')
+			}
+		case EncodeString:
+			if s, ok := a.(fmt.Stringer); ok {
+				buf = append(buf, '"')
+				buf = appendEscapedJSONString(buf, s.String())
+				return append(buf, '"')
+			}
+		case EncodeReflect:
+			return appendJSONMarshal(buf, a)
+		}
+	}
+	return appendJSONMarshal(buf, a)
+}
+
+func appendJSONMarshal(buf []byte, a any) []byte {
+	bs, err := json.Marshal(a)
+	if err != nil {
+		return appendJSONError(buf, err)
+	}
+	return append(buf, bs...)
+}
+
+// appendJSONError appends a string describing an error in encoding a value.
+func appendJSONError(buf []byte, err error) []byte {
+	buf = append(buf, `"!ERROR: `...)
+	buf = appendEscapedJSONString(buf, err.Error())
+	return append(buf, '"')
+}
+
+// appendError appends err as a JSON string, or as an object if
+// f.expandErrors is true.
+func (f jsonFormatter) appendError(buf []byte, err error) []byte {
+	if f.expandErrors {
+		buf = append(buf, `{"msg":`...)
+	}
+	buf = append(buf, '"')
+	buf = appendEscapedJSONString(buf, err.Error())
+	buf = append(buf, '"')
+	if f.expandErrors {
+		buf = append(buf, `,"type":"`...)
+		buf = appendEscapedJSONString(buf, fmt.Sprintf("%T", err))
+		buf = append(buf, `"}`...)
+	}
 	return buf
 }
 
+// maxSafeInt is the largest integer n such that n and all smaller
+// non-negative integers can be held exactly by a float64.
+const maxSafeInt = 1<<53 - 1
+
+// appendInt appends i as a JSON number, or as a string if it is too big
+// and f.quoteBigInts is true.
+func (f jsonFormatter) appendInt(buf []byte, i int64) []byte {
+	if f.quoteBigInts && (i > maxSafeInt || i < -maxSafeInt) {
+		buf = append(buf, '"')
+		buf = strconv.AppendInt(buf, i, 10)
+		return append(buf, '"')
+	}
+	return strconv.AppendInt(buf, i, 10)
+}
+
+// appendUint is like appendInt for unsigned integers.
+func (f jsonFormatter) appendUint(buf []byte, u uint64) []byte {
+	if f.quoteBigInts && u > maxSafeInt {
+		buf = append(buf, '"')
+		buf = strconv.AppendUint(buf, u, 10)
+		return append(buf, '"')
+	}
+	return strconv.AppendUint(buf, u, 10)
+}
+
+// appendDuration appends d as a string, a number of f.durationUnit
+// or an object, as the options say.
+func (f jsonFormatter) appendDuration(buf []byte, d time.Duration) []byte {
+	if f.durationString {
+		buf = append(buf, '"')
+		buf = append(buf, d.String()...)
+		return append(buf, '"')
+	}
+	if f.durationObject {
+		buf = append(buf, `{"value":`...)
+	}
+	u := f.durationUnit
+	if u == 0 {
+		// The zero jsonFormatter, from NewJSONFormatter.
+		u = time.Nanosecond
+	}
+	if d%u == 0 {
+		buf = f.appendInt(buf, int64(d/u))
+	} else {
+		buf = appendJSONFloat(buf, float64(d)/float64(u))
+	}
+	if f.durationObject {
+		buf = append(buf, `,"unit":"`...)
+		buf = append(buf, durationUnitNames[u]...)
+		buf = append(buf, `"}`...)
+	}
+	return buf
+}
+
+// appendJSONFloat appends f as a JSON number. JSON has no representation
+// for NaN and the infinities, so they are written as the strings "NaN",
+// "+Inf" and "-Inf".
+func appendJSONFloat(buf []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(buf, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(buf, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(buf, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, 64)
+}
+
+// appendJSONKey appends s as a JSON string, followed by a colon.
+func appendJSONKey(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = appendEscapedJSONString(buf, s)
+	return append(buf, '"', ':')
+}
+
+// appendFlatJSONKey appends the key formed by joining groups and key
+// with dots, after escaping dots and backslashes in each, followed by a colon.
+func appendFlatJSONKey(buf []byte, groups []string, key string) []byte {
+	buf = append(buf, '"')
+	for _, g := range groups {
+		buf = appendEscapedJSONString(buf, escapeDots(g))
+		buf = append(buf, '.')
+	}
+	buf = appendEscapedJSONString(buf, escapeDots(key))
+	return append(buf, '"', ':')
+}
+
+// escapeDots escapes dots and backslashes in s with a backslash.
+func escapeDots(s string) string {
+	if !strings.ContainsAny(s, `.\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
 ////////////////////////////////////////////////////////////////
 
+// indentingFormatter writes each Attr on its own line, indented by the
+// depth of its groups.
 type indentingFormatter struct {
 	indent int
 }
 
+func (f *indentingFormatter) SetOpenGroups(groups []string) {
+	f.indent = len(groups)
+}
+
 func (f *indentingFormatter) appendIndent(buf []byte) []byte {
 	return append(buf, strings.Repeat("  ", f.indent)...)
 }
@@ -471,7 +1518,45 @@ var htmlSafeSet = [utf8.RuneSelf]bool{
 
 ////////////////////////////////////////////////////////////////
 
-type textFormatter struct{}
+// NewTextFormatter returns a Formatter that writes each log event as
+// a sequence of key=value pairs, like [slog.TextHandler].
+func NewTextFormatter() Formatter {
+	return textFormatter{}
+}
+
+// TextOptions are options for a text Formatter.
+type TextOptions struct {
+	// Collections determines how maps, slices and arrays are written.
+	// The default, CollectionsSprint, writes them with fmt.Sprint.
+	Collections CollectionMode
+}
+
+// A CollectionMode says how a text Formatter writes maps, slices and arrays.
+// Byte slices are always written as quoted strings.
+type CollectionMode int
+
+const (
+	// CollectionsSprint writes collections as fmt.Sprint does: m=map[a:1 b:2].
+	CollectionsSprint CollectionMode = iota
+	// CollectionsKeys writes each element under its own key, formed from
+	// the collection's key and the element's map key or index: m.a=1 m.b=2,
+	// s.0=x s.1=y. Map keys are sorted. Nested collections are written
+	// the same way. Empty collections are written as {} or [].
+	CollectionsKeys
+	// CollectionsJSON writes collections as quoted JSON: m="{\"a\":1,\"b\":2}".
+	// If a collection can't be marshaled, it is written as by fmt.Sprint.
+	CollectionsJSON
+)
+
+// NewFormatter returns a text Formatter with the given options.
+// Pass the method value opts.NewFormatter to [Options.New].
+func (opts TextOptions) NewFormatter() Formatter {
+	return textFormatter{collections: opts.Collections}
+}
+
+type textFormatter struct {
+	collections CollectionMode
+}
 
 func (textFormatter) AppendBegin(buf []byte) []byte {
 	return buf
@@ -498,7 +1583,10 @@ func (textFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
 
 func (f textFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
 	openGroups = slices.Clip(openGroups)
-	a.Value = a.Value.Resolve()
+	a.Value = logvalue.Resolve(a.Value)
+	if f.collections != CollectionsSprint && a.Value.Kind() == slog.KindAny {
+		a.Value = f.collectionValue(a.Value)
+	}
 	buf = f.AppendSeparatorIfNeeded(buf)
 	if a.Value.Kind() == slog.KindGroup {
 		if a.Key != "" {
@@ -508,17 +1596,73 @@ func (f textFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string)
 			buf = f.AppendAttr(buf, a2, openGroups)
 		}
 	} else {
-		k := a.Key
-		if len(openGroups) > 0 {
-			k = strings.Join(openGroups, ".") + "." + k
-		}
-		buf = appendTextString(buf, k)
+		buf = appendTextKey(buf, openGroups, a.Key)
 		buf = append(buf, '=')
 		buf = appendTextValue(buf, a.Value)
 	}
 	return buf
 }
 
+// collectionValue returns the value to write for v according to
+// f.collections, if v holds a map, slice or array. Otherwise it returns v.
+func (f textFormatter) collectionValue(v slog.Value) slog.Value {
+	x := v.Any()
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return v
+	}
+	if _, ok := byteSlice(x); ok {
+		return v
+	}
+	if f.collections == CollectionsJSON {
+		bs, err := json.Marshal(x)
+		if err != nil {
+			return v
+		}
+		return slog.StringValue(string(bs))
+	}
+	if rv.Len() == 0 {
+		if rv.Kind() == reflect.Map {
+			return slog.StringValue("{}")
+		}
+		return slog.StringValue("[]")
+	}
+	var attrs []slog.Attr
+	if rv.Kind() == reflect.Map {
+		iter := rv.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, slog.Any(fmt.Sprint(iter.Key().Interface()), iter.Value().Interface()))
+		}
+		slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	} else {
+		for i := 0; i < rv.Len(); i++ {
+			attrs = append(attrs, slog.Any(strconv.Itoa(i), rv.Index(i).Interface()))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// appendTextKey appends the key formed by joining groups and key with dots.
+func appendTextKey(buf []byte, groups []string, key string) []byte {
+	quote := needsQuoting(key)
+	for _, g := range groups {
+		quote = quote || needsQuoting(g)
+	}
+	if quote {
+		if len(groups) > 0 {
+			key = strings.Join(groups, ".") + "." + key
+		}
+		return strconv.AppendQuote(buf, key)
+	}
+	for _, g := range groups {
+		buf = append(buf, g...)
+		buf = append(buf, '.')
+	}
+	return append(buf, key...)
+}
+
 func appendTextString(buf []byte, s string) []byte {
 	if needsQuoting(s) {
 		return strconv.AppendQuote(buf, s)
@@ -531,9 +1675,20 @@ func appendTextValue(buf []byte, v slog.Value) []byte {
 	switch v.Kind() {
 	case slog.KindString:
 		return appendTextString(buf, v.String())
+	case slog.KindInt64:
+		buf = strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		buf = strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		buf = strconv.AppendFloat(buf, v.Float64(), 'g', -1, 64)
+	case slog.KindBool:
+		buf = strconv.AppendBool(buf, v.Bool())
 	case slog.KindTime:
 		buf = appendTimeRFC3339Millis(buf, v.Time())
 	case slog.KindAny:
+		if l, ok := v.Any().(slog.Level); ok {
+			return append(buf, levels.String(l)...)
+		}
 		if tm, ok := v.Any().(encoding.TextMarshaler); ok {
 			data, err := tm.MarshalText()
 			if err != nil {
@@ -563,7 +1718,7 @@ func byteSlice(a any) ([]byte, bool) {
 	}
 	// Like Printf's %s, we allow both the slice type and the byte element type to be named.
 	t := reflect.TypeOf(a)
-	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+	if t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
 		return reflect.ValueOf(a).Bytes(), true
 	}
 	return nil, false
@@ -4,10 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/slogtest"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jba/slog/handlertest"
+	"github.com/jba/slog/levels"
 )
 
 type Attr = slog.Attr
@@ -178,7 +191,7 @@ func TestHandler(t *testing.T) {
 		{
 			name:     "GroupValue as Attr value",
 			replace:  removeKeys(slog.TimeKey, slog.LevelKey),
-			attrs:    []Attr{{"v", slog.AnyValue(slog.IntValue(3))}},
+			attrs:    []Attr{{Key: "v", Value: slog.AnyValue(slog.IntValue(3))}},
 			wantText: "msg=message v=3",
 			wantJSON: `{"msg":"message","v":3}`,
 		},
@@ -207,8 +220,8 @@ func TestHandler(t *testing.T) {
 				h    slog.Handler
 				want string
 			}{
-				{"text", opts.New(&buf, func() Formatter { return textFormatter{} }), test.wantText},
-				{"json", opts.New(&buf, func() Formatter { return newJSONFormatter() }), test.wantJSON},
+				{"text", opts.New(&buf, NewTextFormatter), test.wantText},
+				{"json", opts.New(&buf, NewJSONFormatter), test.wantJSON},
 			} {
 				t.Run(handler.name, func(t *testing.T) {
 					h := handler.h
@@ -229,6 +242,570 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestAllocs(t *testing.T) {
+	r := slog.NewRecord(testTime, slog.LevelInfo, "message", 0)
+	r.AddAttrs(
+		slog.String("s", "x"),
+		slog.Int("i", 1),
+		slog.Float64("f", 1.5),
+		slog.Bool("b", true),
+		slog.Time("t", testTime),
+	)
+	for _, test := range []struct {
+		name string
+		nf   func() Formatter
+	}{
+		{"text", NewTextFormatter},
+		{"json", NewJSONFormatter},
+	} {
+		h := New(io.Discard, test.nf).WithAttrs([]Attr{slog.Int("p", 1)}).WithGroup("g")
+		got := testing.AllocsPerRun(100, func() {
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if got != 0 {
+			t.Errorf("%s: got %.1f allocs, want 0", test.name, got)
+		}
+	}
+}
+
+func TestJSONFlatten(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey)}.New(&buf, JSONOptions{Flatten: true}.NewFormatter)
+	var hl slog.Handler = h.WithGroup("g").WithAttrs([]Attr{slog.Int("a", 1)}).WithGroup("h")
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	r.AddAttrs(
+		slog.Int("c", 3),
+		slog.Group("i", slog.String("d.e", "x"), slog.Group("empty")),
+		slog.Any("n", logValueName{"Ren", "Hoek"}),
+	)
+	if err := hl.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"INFO","msg":"m","g.a":1,"g.h.c":3,"g.h.i.d\\.e":"x","g.h.n.first":"Ren","g.h.n.last":"Hoek"}`
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m[`g.h.i.d\.e`]; got != "x" {
+		t.Errorf("escaped key: got %v, want x", got)
+	}
+}
+
+func TestJSONQuoteBigInts(t *testing.T) {
+	const safe = 1<<53 - 1
+	for _, test := range []struct {
+		attr slog.Attr
+		want string // with QuoteBigInts
+	}{
+		{slog.Int64("a", 0), `0`},
+		{slog.Int64("a", safe), `9007199254740991`},
+		{slog.Int64("a", safe+1), `"9007199254740992"`},
+		{slog.Int64("a", -safe), `-9007199254740991`},
+		{slog.Int64("a", -safe-1), `"-9007199254740992"`},
+		{slog.Int64("a", math.MaxInt64), `"9223372036854775807"`},
+		{slog.Int64("a", math.MinInt64), `"-9223372036854775808"`},
+		{slog.Uint64("a", safe), `9007199254740991`},
+		{slog.Uint64("a", safe+1), `"9007199254740992"`},
+		{slog.Uint64("a", math.MaxUint64), `"18446744073709551615"`},
+		{slog.Duration("a", safe), `9007199254740991`},
+		{slog.Duration("a", 200*24*time.Hour), `"17280000000000000"`},
+		{slog.Float64("a", safe+1), `9.007199254740992e+15`}, // floats are never quoted
+	} {
+		for _, quote := range []bool{false, true} {
+			var buf bytes.Buffer
+			opts := JSONOptions{QuoteBigInts: quote}
+			h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, opts.NewFormatter)
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+			r.AddAttrs(test.attr)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			want := test.want
+			if !quote {
+				want = strings.Trim(want, `"`)
+			}
+			want = `{"a":` + want + `}`
+			if got := buf.String(); got != want {
+				t.Errorf("%v, quote=%t: got %s, want %s", test.attr, quote, got, want)
+			}
+			// Quoted or not, an integer decodes to its digits.
+			if k := test.attr.Value.Kind(); k == slog.KindInt64 || k == slog.KindUint64 {
+				var m map[string]json.Number
+				if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+					t.Fatal(err)
+				}
+				if got, want := m["a"].String(), test.attr.Value.String(); got != want {
+					t.Errorf("%v, quote=%t: decoded %s", test.attr, quote, got)
+				}
+			}
+		}
+	}
+}
+
+func TestJSONDurationUnitPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	JSONOptions{DurationUnit: 2 * time.Second}.NewFormatter()
+}
+
+func TestNewJSONFormatterDuration(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, NewJSONFormatter)
+	slog.New(h).Info("m", "d", 1500*time.Millisecond)
+	if got, want := buf.String(), `{"d":1500000000}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestJSONKinds(t *testing.T) {
+	for _, test := range []struct {
+		opts JSONOptions
+		attr slog.Attr
+		want string
+	}{
+		{JSONOptions{}, slog.Int64("a", -3), `-3`},
+		{JSONOptions{}, slog.Uint64("a", math.MaxUint64), `18446744073709551615`},
+		{JSONOptions{}, slog.Float64("a", 3.14), `3.14`},
+		{JSONOptions{}, slog.Float64("a", 1e21), `1e+21`},
+		{JSONOptions{}, slog.Float64("a", math.NaN()), `"NaN"`},
+		{JSONOptions{}, slog.Float64("a", math.Inf(1)), `"+Inf"`},
+		{JSONOptions{}, slog.Float64("a", math.Inf(-1)), `"-Inf"`},
+		{JSONOptions{}, slog.Bool("a", true), `true`},
+		{JSONOptions{}, slog.Duration("a", 1500*time.Millisecond), `1500000000`},
+		{JSONOptions{DurationString: true}, slog.Duration("a", 1500*time.Millisecond), `"1.5s"`},
+		{JSONOptions{DurationUnit: time.Millisecond}, slog.Duration("a", 1500*time.Millisecond), `1500`},
+		{JSONOptions{DurationUnit: time.Millisecond}, slog.Duration("a", 1500*time.Microsecond), `1.5`},
+		{JSONOptions{DurationUnit: time.Second}, slog.Duration("a", -90*time.Second), `-90`},
+		{JSONOptions{DurationObject: true}, slog.Duration("a", 7), `{"value":7,"unit":"ns"}`},
+		{
+			JSONOptions{DurationUnit: time.Millisecond, DurationObject: true},
+			slog.Duration("a", 1500*time.Millisecond),
+			`{"value":1500,"unit":"ms"}`,
+		},
+		{
+			JSONOptions{DurationUnit: time.Hour, DurationObject: true, Flatten: true},
+			slog.Duration("a", 90*time.Minute),
+			`{"value":1.5,"unit":"h"}`,
+		},
+		{JSONOptions{DurationString: true, DurationUnit: time.Second}, slog.Duration("a", time.Second), `"1s"`},
+		{JSONOptions{}, slog.Any("a", slog.Int("b", 1)), `{"b":1}`},
+		{JSONOptions{}, slog.Any("a", slog.Group("b", "c", 1)), `{"b":{"c":1}}`},
+		{JSONOptions{}, slog.Any("a", []int{1, 2}), `[1,2]`},
+	} {
+		var buf bytes.Buffer
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, test.opts.NewFormatter)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(test.attr)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"a":` + test.want + `}`
+		if got := buf.String(); got != want {
+			t.Errorf("%v:\ngot  %s\nwant %s", test.attr, got, want)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("%v: invalid JSON", test.attr)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	_, pathErr := os.Open("/does/not/exist")
+	wrapped := fmt.Errorf("loading: %w", pathErr)
+	for _, test := range []struct {
+		opts JSONOptions
+		err  error
+		want string
+	}{
+		{JSONOptions{}, errors.New(`bad "quote"`), `"bad \"quote\""`},
+		{JSONOptions{}, wrapped, `"loading: open /does/not/exist: no such file or directory"`},
+		{JSONOptions{ExpandErrors: true}, errors.New("e"), `{"msg":"e","type":"*errors.errorString"}`},
+		{JSONOptions{ExpandErrors: true}, pathErr, `{"msg":"open /does/not/exist: no such file or directory","type":"*fs.PathError"}`},
+		{JSONOptions{ExpandErrors: true}, wrapped, `{"msg":"loading: open /does/not/exist: no such file or directory","type":"*fmt.wrapError"}`},
+	} {
+		var buf bytes.Buffer
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, test.opts.NewFormatter)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Any("err", test.err))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"err":` + test.want + `}`
+		if got := buf.String(); got != want {
+			t.Errorf("\ngot  %s\nwant %s", got, want)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("%v: invalid JSON", test.err)
+		}
+	}
+}
+
+// allEnc has every method the JSON formatter looks for.
+type allEnc struct{}
+
+func (allEnc) MarshalJSON() ([]byte, error) { return []byte(`{"j": 1}`), nil }
+func (allEnc) MarshalText() ([]byte, error) { return []byte("text"), nil }
+func (allEnc) String() string               { return "string" }
+
+type textEnc struct{ X int }
+
+func (textEnc) MarshalText() ([]byte, error) { return []byte(`t"x`), nil }
+
+func TestJSONAnyOrder(t *testing.T) {
+	encodeInt := func(buf []byte, v any) ([]byte, bool) {
+		if i, ok := v.(textEnc); ok {
+			return fmt.Appendf(buf, "%d", i.X), true
+		}
+		return buf, false
+	}
+	for _, test := range []struct {
+		opts JSONOptions
+		val  any
+		want string
+	}{
+		{JSONOptions{}, allEnc{}, `{"j":1}`},
+		{JSONOptions{AnyOrder: []AnyEncoding{EncodeMarshalText, EncodeMarshalJSON}}, allEnc{}, `"text"`},
+		{JSONOptions{AnyOrder: []AnyEncoding{EncodeString}}, allEnc{}, `"string"`},
+		{JSONOptions{}, textEnc{3}, `"t\"x"`},
+		{JSONOptions{AnyOrder: []AnyEncoding{EncodeString}}, textEnc{3}, `"t\"x"`},
+		{JSONOptions{EncodeAny: encodeInt}, textEnc{3}, `3`},
+		{JSONOptions{EncodeAny: encodeInt}, allEnc{}, `{"j":1}`},
+		{JSONOptions{}, make(chan int), `"!ERROR: json: unsupported type: chan int"`},
+	} {
+		var buf bytes.Buffer
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, test.opts.NewFormatter)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Any("a", test.val))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"a":` + test.want + `}`
+		if got := buf.String(); got != want {
+			t.Errorf("%T, %v:\ngot  %s\nwant %s", test.val, test.opts.AnyOrder, got, want)
+		}
+	}
+}
+
+func TestStatefulFormatter(t *testing.T) {
+	newIndenting := func() Formatter { return &indentingFormatter{} }
+	var buf bytes.Buffer
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey)}.New(&buf, newIndenting)
+	var hl slog.Handler = h.WithGroup("G").WithAttrs([]Attr{slog.Int("a", 1)}).WithGroup("H").WithAttrs([]Attr{slog.Int("b", 2)})
+	for _, test := range []struct {
+		h     slog.Handler
+		attrs []Attr
+		want  string
+	}{
+		{
+			hl,
+			[]Attr{slog.Int("c", 3), slog.Group("I", "d", 4)},
+			"msg: m\nG:\n  a: 1\n  H:\n    b: 2\n    c: 3\n    I:\n      d: 4\n",
+		},
+		{
+			// The empty group K is opened and then discarded.
+			hl.WithGroup("J").WithAttrs([]Attr{slog.Int("e", 5)}).WithGroup("K"),
+			[]Attr{slog.Group("L")},
+			"msg: m\nG:\n  a: 1\n  H:\n    b: 2\n    J:\n      e: 5\n",
+		},
+		{
+			h.WithGroup("G").WithGroup("H"),
+			[]Attr{slog.Int("c", 3)},
+			"msg: m\nG:\n  H:\n    c: 3\n",
+		},
+	} {
+		buf.Reset()
+		r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+		r.AddAttrs(test.attrs...)
+		if err := test.h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("\ngot\n%s\nwant\n%s", got, test.want)
+		}
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{SortKeys: true, ReplaceAttr: removeKeys(slog.TimeKey)}.New(&buf, NewTextFormatter)
+	var hl slog.Handler = h.WithAttrs([]Attr{slog.Int("z", 1), slog.Int("y", 2)}).WithGroup("g")
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	r.AddAttrs(
+		slog.Int("c", 3),
+		slog.Group("b", slog.Int("y", 1), slog.Int("x", 2)),
+		slog.Group("", slog.Int("d", 4), slog.Int("a", 5)),
+		slog.Any("n", logValueName{"Ren", "Hoek"}),
+		slog.Int("c", 6),
+	)
+	if err := hl.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "level=INFO msg=m y=2 z=1 g.a=5 g.b.x=2 g.b.y=1 g.c=3 g.c=6 g.d=4 g.n.first=Ren g.n.last=Hoek"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestDedupKeys(t *testing.T) {
+	for _, test := range []struct {
+		mode DedupMode
+		want string
+	}{
+		{DedupNone, "a=1 b=2 a=3 g.c=4 g.c=5 g.h.d=6 a=7 g.c=8"},
+		{DedupKeepLast, "b=2 g.h.d=6 g.c=8 a=7"},
+		{DedupKeepFirst, "a=1 b=2 g.c=4 g.h.d=6"},
+		{DedupRename, "a=1 b=2 a#2=3 g.c=4 g.c#2=5 g.h.d=6 g.c#3=8 a#3=7"},
+	} {
+		var buf bytes.Buffer
+		opts := Options{DedupKeys: test.mode, ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}
+		h := opts.New(&buf, NewTextFormatter).
+			WithAttrs([]Attr{slog.Int("a", 1), slog.Int("b", 2)}).
+			WithAttrs([]Attr{slog.Int("a", 3)})
+		r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+		r.AddAttrs(
+			slog.Group("g", slog.Int("c", 4), slog.Int("c", 5), slog.Group("h", slog.Int("d", 6))),
+			slog.Int("a", 7),
+			slog.Group("g", slog.Int("c", 8)),
+		)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%d:\ngot  %s\nwant %s", test.mode, got, test.want)
+		}
+	}
+}
+
+func TestDedupRenameTaken(t *testing.T) {
+	// A renamed key doesn't clash with a key that already has a suffix.
+	for _, test := range []struct {
+		with []any
+		args []any
+		want string
+	}{
+		{[]any{"a", 1, "a#2", 0}, []any{"a", 2}, "a=1 a#2=0 a#3=2"},
+		{[]any{"a", 1, "a", 2}, []any{"a#2", 3, "a", 4}, "a=1 a#2=2 a#2#2=3 a#3=4"},
+	} {
+		var buf bytes.Buffer
+		opts := Options{DedupKeys: DedupRename, ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}
+		slog.New(opts.New(&buf, NewTextFormatter)).With(test.with...).Info("m", test.args...)
+		if got := buf.String(); got != test.want {
+			t.Errorf("With(%v).Info(%v):\ngot  %s\nwant %s", test.with, test.args, got, test.want)
+		}
+	}
+}
+
+func TestTimeLocation(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 0, est)
+	for _, test := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "time=2023-04-03T01:02:03.000-05:00 t=2023-04-03T01:02:03.000-05:00"},
+		{Options{TimeUTC: true}, "time=2023-04-03T06:02:03.000Z t=2023-04-03T01:02:03.000-05:00"},
+		{Options{Location: time.FixedZone("CET", 60*60)}, "time=2023-04-03T07:02:03.000+01:00 t=2023-04-03T01:02:03.000-05:00"},
+		{Options{TimeUTC: true, Location: est}, "time=2023-04-03T06:02:03.000Z t=2023-04-03T01:02:03.000-05:00"},
+	} {
+		var buf bytes.Buffer
+		test.opts.ReplaceAttr = removeKeys(slog.LevelKey, slog.MessageKey)
+		h := test.opts.New(&buf, NewTextFormatter)
+		r := slog.NewRecord(tm, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Time("t", tm))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%+v:\ngot  %s\nwant %s", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+	for _, nf := range []func() Formatter{NewTextFormatter, NewJSONFormatter} {
+		clock := handlertest.NewFakeClock(tm, time.Second)
+		var buf bytes.Buffer
+		h := Options{Clock: clock.Now, TimeUTC: true}.New(&buf, nf)
+		// The clock supplies the time only for records without one.
+		for _, rt := range []time.Time{{}, tm.Add(time.Hour)} {
+			if err := h.Handle(context.Background(), slog.NewRecord(rt, slog.LevelInfo, "m", 0)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got := buf.String()
+		for _, want := range []string{"2023-04-03T01:02:03", "2023-04-03T02:02:03"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q does not contain %q", got, want)
+			}
+		}
+		if got, want := clock.Now(), tm.Add(time.Second); !got.Equal(want) {
+			t.Errorf("clock was called more than once: got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestOnBeforeAfter(t *testing.T) {
+	var buf bytes.Buffer
+	var after []string
+	h := Options{
+		ReplaceAttr: removeKeys(slog.TimeKey),
+		OnBefore: func(_ context.Context, r *slog.Record) {
+			r.AddAttrs(slog.String("added", "yes"))
+		},
+		OnAfter: func(_ context.Context, r slog.Record, err error) {
+			after = append(after, fmt.Sprintf("%s %d %v", r.Message, r.NumAttrs(), err))
+		},
+		CheckContext: true,
+	}.New(&buf, NewTextFormatter)
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Int("a", 1))
+	if err := h.WithGroup("g").Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.Handle(ctx, r); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if got, want := buf.String(), "level=INFO msg=m g.a=1 g.added=yes"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The caller's record is unchanged.
+	if n := r.NumAttrs(); n != 1 {
+		t.Errorf("record has %d attrs, want 1", n)
+	}
+	want := []string{"m 2 <nil>", "m 2 context canceled"}
+	if !slices.Equal(after, want) {
+		t.Errorf("got %q, want %q", after, want)
+	}
+}
+
+func TestExpandStructs(t *testing.T) {
+	type point struct {
+		X, Y int
+		Name string `slog:"name,omitempty"`
+	}
+	for _, test := range []struct {
+		opts Options
+		nf   func() Formatter
+		want string
+	}{
+		{Options{}, NewTextFormatter, "p=&{1 2 }"},
+		{Options{ExpandStructs: true}, NewTextFormatter, "p.X=1 p.Y=2"},
+		{Options{ExpandStructs: true}, NewJSONFormatter, `{"p":{"X":1,"Y":2}}`},
+	} {
+		var buf bytes.Buffer
+		test.opts.ReplaceAttr = removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)
+		h := test.opts.New(&buf, test.nf)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Any("p", &point{X: 1, Y: 2}))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%+v:\ngot  %s\nwant %s", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestTextCollections(t *testing.T) {
+	m := map[string]any{"b": 2, "a": []int{1, 2}}
+	s := []string{"x", "y z"}
+	for _, test := range []struct {
+		mode CollectionMode
+		want string
+	}{
+		{CollectionsSprint, `m=map[a:[1 2] b:2] s=[x y z] e=[] bs="hi"`},
+		{CollectionsKeys, `m.a.0=1 m.a.1=2 m.b=2 s.0=x s.1="y z" e=[] bs="hi"`},
+		{CollectionsJSON, `m="{\"a\":[1,2],\"b\":2}" s="[\"x\",\"y z\"]" e=[] bs="hi"`},
+	} {
+		var buf bytes.Buffer
+		opts := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}
+		h := opts.New(&buf, TextOptions{Collections: test.mode}.NewFormatter)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(slog.Any("m", m), slog.Any("s", s), slog.Any("e", []int{}), slog.Any("bs", []byte("hi")))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%d:\ngot  %s\nwant %s", test.mode, got, test.want)
+		}
+	}
+}
+
+func TestLevelNames(t *testing.T) {
+	for _, test := range []struct {
+		nf   func() Formatter
+		want string
+	}{
+		{NewTextFormatter, "level=FATAL msg=m"},
+		{NewJSONFormatter, `{"level":"FATAL","msg":"m"}`},
+	} {
+		var buf bytes.Buffer
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey)}.New(&buf, test.nf)
+		r := slog.NewRecord(testTime, levels.LevelFatal, "m", 0)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+type panicMarshaler struct{}
+
+func (panicMarshaler) MarshalJSON() ([]byte, error) { panic("json boom") }
+func (panicMarshaler) MarshalText() ([]byte, error) { panic("text boom") }
+
+type panicValuer struct{}
+
+func (panicValuer) LogValue() slog.Value { panic("valuer boom") }
+
+// recursiveValuer's LogValue contains itself.
+type recursiveValuer struct{}
+
+func (recursiveValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("r", recursiveValuer{}))
+}
+
+func TestPanics(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		nf   func() Formatter
+		want []string
+	}{
+		{"text", NewTextFormatter, []string{`m="!PANIC: text boom" a=1`, `v="!PANIC: valuer boom"`}},
+		{"json", NewJSONFormatter, []string{`"m":"!PANIC: json boom","a":1`, `"v":"!PANIC: valuer boom"`}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, test.nf)
+			r := slog.NewRecord(testTime, slog.LevelInfo, "message", 0)
+			r.AddAttrs(slog.Any("m", panicMarshaler{}), slog.Int("a", 1), slog.Any("v", panicValuer{}),
+				slog.Any("rec", recursiveValuer{}))
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			for _, w := range append(test.want, "groups nested too deeply") {
+				if !strings.Contains(got, w) {
+					t.Errorf("output does not contain %q:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
 // removeKeys returns a function suitable for HandlerOptions.ReplaceAttr
 // that removes all Attrs with the given keys.
 func removeKeys(keys ...string) func([]string, Attr) Attr {
@@ -258,3 +835,307 @@ func (n logValueName) LogValue() slog.Value {
 }
 
 var testTime = time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestConformance(t *testing.T) {
+	for _, mode := range []DedupMode{DedupNone, DedupKeepLast} {
+		t.Run(fmt.Sprintf("dedup=%d", mode), func(t *testing.T) {
+			handlertest.TestHandler(t, func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+				o := Options{DedupKeys: mode}
+				if opts != nil {
+					o.Level = opts.Level
+					o.ReplaceAttr = opts.ReplaceAttr
+				}
+				return o.New(w, NewJSONFormatter)
+			}, handlertest.ParseJSON)
+		})
+	}
+}
+
+// TestCompatibility compares the output of the text and JSON formatters
+// with that of the corresponding handlers of log/slog.
+func TestCompatibility(t *testing.T) {
+	newHandler := func(nf func() Formatter) func(io.Writer, *slog.HandlerOptions) slog.Handler {
+		return func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			var o Options
+			if opts != nil {
+				o.Level = opts.Level
+				o.ReplaceAttr = opts.ReplaceAttr
+			}
+			// The formatters don't end records with newlines.
+			return o.New(lineWriter{w}, nf)
+		}
+	}
+	t.Run("text", func(t *testing.T) {
+		handlertest.Comparison{
+			Reference: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+				return slog.NewTextHandler(w, opts)
+			},
+			NewHandler: newHandler(NewTextFormatter),
+			Parse:      handlertest.ParseText,
+		}.Run(t)
+	})
+	t.Run("json", func(t *testing.T) {
+		handlertest.Comparison{
+			Reference: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+				return slog.NewJSONHandler(w, opts)
+			},
+			NewHandler: newHandler(NewJSONFormatter),
+			Parse:      handlertest.ParseJSON,
+			// The JSON formatter writes times to the second.
+			Ignore: []string{slog.TimeKey},
+		}.Run(t)
+	})
+}
+
+// lineWriter ends each Write with a newline.
+type lineWriter struct{ w io.Writer }
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	if _, err := w.w.Write(append(p, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// recordWriter collects each Write as a separate record.
+type recordWriter struct {
+	records [][]byte
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	w.records = append(w.records, bytes.Clone(p))
+	return len(p), nil
+}
+
+func TestSlogtest(t *testing.T) {
+	parseJSON := func(b []byte) (map[string]any, error) {
+		var m map[string]any
+		err := json.Unmarshal(b, &m)
+		return m, err
+	}
+	for _, test := range []struct {
+		name  string
+		opts  Options
+		nf    func() Formatter
+		parse func([]byte) (map[string]any, error)
+	}{
+		{"text", Options{}, NewTextFormatter, parseTextRecord},
+		{"json", Options{}, NewJSONFormatter, parseJSON},
+		{"text/sort", Options{SortKeys: true}, NewTextFormatter, parseTextRecord},
+		{"json/sort", Options{SortKeys: true}, NewJSONFormatter, parseJSON},
+		{"text/dedup", Options{DedupKeys: DedupKeepLast}, NewTextFormatter, parseTextRecord},
+		{"json/dedup", Options{DedupKeys: DedupKeepLast}, NewJSONFormatter, parseJSON},
+		{"json/sort/dedup", Options{SortKeys: true, DedupKeys: DedupKeepFirst}, NewJSONFormatter, parseJSON},
+		{"json/limits", Options{Limits: &Limits{MaxAttrs: 100}}, NewJSONFormatter, parseJSON},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var w recordWriter
+			h := test.opts.New(&w, test.nf)
+			results := func() []map[string]any {
+				var ms []map[string]any
+				for _, rec := range w.records {
+					m, err := test.parse(rec)
+					if err != nil {
+						t.Fatalf("%s: %v", rec, err)
+					}
+					ms = append(ms, m)
+				}
+				return ms
+			}
+			if err := slogtest.TestHandler(h, results); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// parseTextRecord parses the output of the text formatter into a map,
+// treating dotted keys as nested groups.
+func parseTextRecord(b []byte) (map[string]any, error) {
+	m := map[string]any{}
+	s := string(b)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+		var key, val string
+		var err error
+		key, s, err = textToken(s, "=")
+		if err != nil {
+			return nil, err
+		}
+		if s == "" || s[0] != '=' {
+			return nil, fmt.Errorf("missing '=' after %q", key)
+		}
+		val, s, err = textToken(s[1:], " ")
+		if err != nil {
+			return nil, err
+		}
+		keys := strings.Split(key, ".")
+		mm := m
+		for _, k := range keys[:len(keys)-1] {
+			sub, ok := mm[k].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				mm[k] = sub
+			}
+			mm = sub
+		}
+		mm[keys[len(keys)-1]] = val
+	}
+	return m, nil
+}
+
+// textToken returns the quoted or unquoted token at the start of s,
+// ending before one of the bytes in stop, and the rest of s.
+func textToken(s, stop string) (tok, rest string, err error) {
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", err
+		}
+		tok, err := strconv.Unquote(q)
+		return tok, s[len(q):], err
+	}
+	i := strings.IndexAny(s, stop)
+	if i < 0 {
+		return s, "", nil
+	}
+	return s[:i], s[i:], nil
+}
+
+type failWriter struct{ n int }
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.n++
+	if w.n > 1 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestStatus(t *testing.T) {
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey)}.New(&failWriter{}, NewTextFormatter)
+	ctx := context.Background()
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	if err := h.Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	// The clone shares the counts.
+	if err := h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).Handle(ctx, r); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	s := h.Status()
+	if s.Records != 1 || s.Bytes != int64(len("level=INFO msg=m")) || s.Errors != 1 {
+		t.Errorf("got %+v", s)
+	}
+	if s.LastError != "disk full" || s.LastErrorTime.IsZero() {
+		t.Errorf("last error: got %q at %s", s.LastError, s.LastErrorTime)
+	}
+}
+
+var fuzzStrings = []string{
+	"", "a", `"quoted"`, `back\slash`, "a=b", "tab\there", "nl\n", "\x00\x1f\x7f",
+	"<script>&", "  ", "\xff\xfe", "caf\xc3", "日本語", "�", "a b", " ",
+}
+
+func FuzzAppendEscapedJSONString(f *testing.F) {
+	for _, s := range fuzzStrings {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		buf := append([]byte{'"'}, appendEscapedJSONString(nil, s)...)
+		buf = append(buf, '"')
+		if !json.Valid(buf) {
+			t.Fatalf("%q: invalid JSON %s", s, buf)
+		}
+		var got string
+		if err := json.Unmarshal(buf, &got); err != nil {
+			t.Fatal(err)
+		}
+		// Each byte that isn't part of valid UTF-8 becomes U+FFFD,
+		// as in a conversion to []rune.
+		if want := string([]rune(s)); got != want {
+			t.Errorf("%q: round trip got %q, want %q", s, got, want)
+		}
+	})
+}
+
+func FuzzNeedsQuoting(f *testing.F) {
+	for _, s := range fuzzStrings {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		// A text value must be read back as written: quoted values
+		// unquote to s, and unquoted ones can't be mistaken for the
+		// end of the value, another key or a quoted value.
+		out := string(appendTextString(nil, s))
+		if needsQuoting(s) {
+			got, err := strconv.Unquote(out)
+			if err != nil || got != s {
+				t.Errorf("%q: unquoting %s: got %q, %v", s, out, got, err)
+			}
+			return
+		}
+		if out != s {
+			t.Errorf("%q: unquoted output is %q", s, out)
+		}
+		if !utf8.ValidString(s) {
+			t.Errorf("%q: invalid UTF-8 is not quoted", s)
+		}
+		for _, r := range s {
+			if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+				t.Errorf("%q: %q is not quoted", s, r)
+			}
+		}
+	})
+}
+
+// emptyValuer resolves to an empty group, which Record.AddAttrs
+// would otherwise drop.
+type emptyValuer struct{}
+
+func (emptyValuer) LogValue() slog.Value { return slog.GroupValue() }
+
+func TestJSONEmpty(t *testing.T) {
+	var nilp *int
+	attrs := []slog.Attr{
+		slog.Any("g", emptyValuer{}),
+		slog.Time("t", time.Time{}),
+		slog.Any("n", nil),
+		slog.Any("p", nilp),
+		slog.Group("h", slog.Any("n", nil)),
+		slog.Int("x", 1),
+	}
+	for _, test := range []struct {
+		empty   EmptyPolicy
+		flatten bool
+		want    string
+	}{
+		{EmptyDefault, false, `{"t":"0001-01-01T00:00:00Z","n":null,"p":null,"h":{"n":null},"x":1}`},
+		{EmptyOmit, false, `{"h":{},"x":1}`},
+		{EmptyNull, false, `{"g":null,"t":null,"n":null,"p":null,"h":{"n":null},"x":1}`},
+		{EmptyZero, false, `{"g":{},"t":"","n":"","p":"","h":{"n":""},"x":1}`},
+		{EmptyNull, true, `{"g":null,"t":null,"n":null,"p":null,"h.n":null,"x":1}`},
+		{EmptyZero, true, `{"g":{},"t":"","n":"","p":"","h.n":"","x":1}`},
+	} {
+		var buf bytes.Buffer
+		opts := JSONOptions{Empty: test.empty, Flatten: test.flatten}
+		h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, opts.NewFormatter)
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+		r.AddAttrs(attrs...)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("Empty=%d, flatten=%t:\ngot  %s\nwant %s", test.empty, test.flatten, got, test.want)
+		}
+	}
+
+	// Empty groups from WithGroup are omitted under every policy.
+	var buf bytes.Buffer
+	opts := JSONOptions{Empty: EmptyNull}
+	h := Options{ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey)}.New(&buf, opts.NewFormatter)
+	slog.New(h).WithGroup("w").Info("m")
+	if got, want := buf.String(), `{}`; got != want {
+		t.Errorf("WithGroup: got %s, want %s", got, want)
+	}
+}
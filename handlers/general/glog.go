@@ -0,0 +1,228 @@
+package general
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/verbosity"
+)
+
+// NewGlogFormatter returns a Formatter that writes each log event as a line
+// in the format of github.com/golang/glog and k8s.io/klog:
+//
+//	Lmmdd hh:mm:ss.uuuuuu threadid file:line] "msg" key="value" ...
+//
+// L is the severity: I, W or E. The severity is determined from the
+// verbosity of the level (see package verbosity): non-negative verbosities
+// are I, verbosities down to that of [slog.LevelWarn] are W, and lower
+// ones are E. Since Go does not expose thread IDs, the process ID is used,
+// as klog does.
+//
+// The message and string values are quoted as by klog's structured logging
+// functions, and groups are written as dotted keys.
+//
+// The header is built from the time, level, message and source attributes.
+// For the file:line part, use [SourceOptions] with FileLine set and
+// Format set to [SourceBaseName] as the handler's PCAttrs; if there is no
+// source, "???:1" is written. Each line ends in a newline.
+func NewGlogFormatter() Formatter {
+	return &glogFormatter{}
+}
+
+type glogFormatter struct {
+	headerCapture
+	pending  bool // AppendBegin was called and the header is not yet written
+	inSource bool // in the header's source group
+	time     time.Time
+	severity byte
+	msg      string
+	file     string
+	line     int
+}
+
+var pid = os.Getpid()
+
+func (f *glogFormatter) AppendBegin(buf []byte) []byte {
+	*f = glogFormatter{pending: true, severity: 'I'}
+	f.beginHeader()
+	return buf
+}
+
+func (f *glogFormatter) AppendEnd(buf []byte) []byte {
+	buf = f.flushHeader(buf)
+	return append(buf, '\n')
+}
+
+func (f *glogFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	if f.captureSourceGroup(name) {
+		f.inSource = true
+		return buf
+	}
+	return f.flushHeader(buf)
+}
+
+func (f *glogFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	f.inSource = false
+	return buf
+}
+
+func (f *glogFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+	buf = f.flushHeader(buf)
+	if len(buf) > 0 && buf[len(buf)-1] != ' ' {
+		return append(buf, ' ')
+	}
+	return buf
+}
+
+func (f *glogFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	if f.inSource {
+		f.setSourceField(a)
+		return buf
+	}
+	if f.captureHeader(a, openGroups, f.setHeaderField) {
+		return buf
+	}
+	buf = f.flushHeader(buf)
+	return f.appendAttr(buf, a, openGroups)
+}
+
+// setHeaderField records a if it is one of the built-in attributes
+// that appear in the header, and reports whether it did.
+func (f *glogFormatter) setHeaderField(a slog.Attr) bool {
+	v := logvalue.Resolve(a.Value)
+	switch {
+	case a.Key == slog.TimeKey && v.Kind() == slog.KindTime:
+		f.time = v.Time()
+	case a.Key == slog.LevelKey && v.Kind() == slog.KindAny:
+		l, ok := v.Any().(slog.Level)
+		if !ok {
+			return false
+		}
+		f.severity = glogSeverity(l)
+	case a.Key == slog.MessageKey && v.Kind() == slog.KindString:
+		f.msg = v.String()
+	case a.Key == slog.SourceKey:
+		return f.setSource(v)
+	default:
+		return false
+	}
+	return true
+}
+
+// setSource sets the file and line from a source value, which is either
+// a "file:line" string or a group with "file" and "line" members,
+// as produced by [SourceOptions.Attrs].
+func (f *glogFormatter) setSource(v slog.Value) bool {
+	switch v.Kind() {
+	case slog.KindString:
+		s := v.String()
+		for i := len(s) - 1; i >= 0; i-- {
+			if s[i] == ':' {
+				line, err := strconv.Atoi(s[i+1:])
+				if err != nil {
+					return false
+				}
+				f.file, f.line = filepath.Base(s[:i]), line
+				return true
+			}
+		}
+	case slog.KindGroup:
+		for _, a := range v.Group() {
+			f.setSourceField(a)
+		}
+		return f.file != ""
+	}
+	return false
+}
+
+// setSourceField sets the file or line from a member of a source group.
+func (f *glogFormatter) setSourceField(a slog.Attr) {
+	switch a.Key {
+	case "file":
+		f.file = filepath.Base(a.Value.String())
+	case "line":
+		f.line = int(a.Value.Int64())
+	}
+}
+
+func glogSeverity(l slog.Level) byte {
+	switch v := verbosity.FromLevel(l); {
+	case v >= 0:
+		return 'I'
+	case v >= verbosity.FromLevel(slog.LevelWarn):
+		return 'W'
+	default:
+		return 'E'
+	}
+}
+
+// flushHeader writes the header, if it has not been written yet.
+func (f *glogFormatter) flushHeader(buf []byte) []byte {
+	if !f.pending {
+		return buf
+	}
+	f.pending = false
+	f.endHeader()
+	buf = append(buf, f.severity)
+	if !f.time.IsZero() {
+		buf = f.time.AppendFormat(buf, "0102 15:04:05.000000")
+	}
+	buf = append(buf, ' ')
+	buf = appendPadded(buf, pid, 7)
+	buf = append(buf, ' ')
+	if f.file == "" {
+		buf = append(buf, "???:1"...)
+	} else {
+		buf = append(buf, f.file...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(f.line), 10)
+	}
+	buf = append(buf, "] "...)
+	return strconv.AppendQuote(buf, f.msg)
+}
+
+func (f *glogFormatter) appendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			openGroups = append(slices.Clip(openGroups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.appendAttr(buf, a2, openGroups)
+		}
+		return buf
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	buf = appendTextKey(buf, openGroups, a.Key)
+	buf = append(buf, '=')
+	return appendGlogValue(buf, a.Value)
+}
+
+// appendGlogValue appends v as klog does: strings and errors are quoted,
+// and other values are written as by the text formatter.
+func appendGlogValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return strconv.AppendQuote(buf, v.String())
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return strconv.AppendQuote(buf, err.Error())
+		}
+	}
+	return appendTextValue(buf, v)
+}
+
+// appendPadded appends n right-aligned in a field of the given width.
+func appendPadded(buf []byte, n, width int) []byte {
+	var a [20]byte
+	s := strconv.AppendInt(a[:0], int64(n), 10)
+	for i := len(s); i < width; i++ {
+		buf = append(buf, ' ')
+	}
+	return append(buf, s...)
+}
@@ -0,0 +1,89 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+)
+
+func TestGlog(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	fs := runtime.CallersFrames(pcs[:])
+	fr, _ := fs.Next()
+	header := func(sev string, file string) string {
+		return fmt.Sprintf("%s0102 03:04:05.000006 %7d %s] ", sev, pid, file)
+	}
+	loc := fmt.Sprintf("glog_test.go:%d", fr.Line)
+	tm := testTime.Add(6 * 1000)
+
+	for _, test := range []struct {
+		name  string
+		opts  Options
+		level slog.Level
+		with  func(slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name:  "basic",
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.String("a", "x y"), slog.Int("b", 2), slog.Any("err", errors.New("bad"))},
+			want:  header("I", "???:1") + `"msg" a="x y" b=2 err="bad"` + "\n",
+		},
+		{
+			name:  "source",
+			opts:  Options{PCAttrs: SourceOptions{Format: SourceBaseName, FileLine: true}.Attrs},
+			level: slog.LevelWarn,
+			want:  header("W", loc) + `"msg"` + "\n",
+		},
+		{
+			name:  "source group",
+			opts:  Options{PCAttrs: SourceAttrs},
+			level: slog.LevelError + 4,
+			want:  header("E", loc) + `"msg"` + "\n",
+		},
+		{
+			name:  "verbose",
+			opts:  Options{Level: slog.LevelDebug},
+			level: slog.LevelDebug,
+			attrs: []slog.Attr{slog.Group("g", slog.Bool("c", true))},
+			want:  header("I", "???:1") + `"msg" g.c=true` + "\n",
+		},
+		{
+			name: "with",
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("msg", "pre")}).WithGroup("g")
+			},
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.Int("a", 1)},
+			want:  header("I", "???:1") + `"msg" msg="pre" g.a=1` + "\n",
+		},
+		{
+			name:  "built-in keys",
+			level: slog.LevelInfo,
+			attrs: []slog.Attr{slog.Any("level", slog.LevelError), slog.String("msg", "m2")},
+			want:  header("I", "???:1") + `"msg" level=ERROR msg="m2"` + "\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var h slog.Handler = test.opts.New(&buf, NewGlogFormatter)
+			if test.with != nil {
+				h = test.with(h)
+			}
+			r := slog.NewRecord(tm, test.level, "msg", pcs[0])
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
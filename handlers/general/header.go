@@ -0,0 +1,80 @@
+package general
+
+import "log/slog"
+
+// headerCapture is the state of a formatter that moves the built-in
+// Attrs of a record, which the Handler appends first, out of the stream
+// of Attrs and into a header. Capture stops at the first Attr that is
+// not a built-in one, or is one that was already captured, so that a
+// later Attr with a key like "msg" is written as an ordinary Attr.
+type headerCapture struct {
+	inHeader bool // still reading the built-in Attrs
+	seen     headerField
+}
+
+// A headerField is a set of built-in keys.
+type headerField uint8
+
+const (
+	headerTime headerField = 1 << iota
+	headerLevel
+	headerMessage
+	headerSource
+	headerLogger
+)
+
+func headerFieldOf(key string) headerField {
+	switch key {
+	case slog.TimeKey:
+		return headerTime
+	case slog.LevelKey:
+		return headerLevel
+	case slog.MessageKey:
+		return headerMessage
+	case slog.SourceKey:
+		return headerSource
+	case LoggerKey:
+		return headerLogger
+	default:
+		return 0
+	}
+}
+
+// beginHeader starts capturing the built-in Attrs of a record.
+func (h *headerCapture) beginHeader() {
+	h.inHeader = true
+	h.seen = 0
+}
+
+// endHeader stops capturing. Formatters call it when something other
+// than a built-in Attr arrives, like a group or preformatted Attrs.
+func (h *headerCapture) endHeader() {
+	h.inHeader = false
+}
+
+// captureHeader reports whether a, appended in openGroups, is a built-in
+// Attr that belongs in the header. If a has a built-in key that hasn't
+// been captured, captureHeader calls set, which saves a and reports
+// whether it could. Otherwise, or if set returns false, capture ends.
+func (h *headerCapture) captureHeader(a slog.Attr, openGroups []string, set func(slog.Attr) bool) bool {
+	if h.inHeader && len(openGroups) == 0 {
+		if hf := headerFieldOf(a.Key); hf != 0 && h.seen&hf == 0 && set(a) {
+			h.seen |= hf
+			return true
+		}
+	}
+	h.inHeader = false
+	return false
+}
+
+// captureSourceGroup reports whether a group with the given name holds
+// the source of the record, for formatters that take the source from
+// the group's members. Otherwise, capture ends.
+func (h *headerCapture) captureSourceGroup(name string) bool {
+	if h.inHeader && name == slog.SourceKey && h.seen&headerSource == 0 {
+		h.seen |= headerSource
+		return true
+	}
+	h.inHeader = false
+	return false
+}
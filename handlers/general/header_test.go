@@ -0,0 +1,43 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHeaderCapture(t *testing.T) {
+	tmpl, err := TemplateOptions{Template: "{level} {message} {attrs}"}.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		nf   func() Formatter
+	}{
+		{"glog", NewGlogFormatter},
+		{"template", tmpl.NewFormatter},
+		{"block", BlockOptions{}.NewFormatter},
+		{"syslog", SyslogOptions{}.NewFormatter},
+		{"console", ConsoleOptions{}.NewFormatter},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := New(&buf, test.nf)
+			r := slog.NewRecord(testTime, slog.LevelInfo, "m1", 0)
+			// Built-in keys after the built-in Attrs are ordinary Attrs.
+			r.AddAttrs(slog.String("msg", "m2"), slog.Any("level", slog.LevelError))
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			for _, want := range []string{"m1", "m2", "ERROR"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package general
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/jba/slog/metrics"
+)
+
+// LevelFormatters maps levels to the Formatters used for records at
+// those levels. As with [LevelBadges], a level without an entry uses the
+// entry of the nearest level below it. Levels below every entry use the
+// entry of the lowest level.
+type LevelFormatters map[slog.Level]func() Formatter
+
+// A LevelHandler is a handler that formats each record with the
+// Formatter for its level. For example, a LevelHandler can write
+// warnings and errors as blocks, with room for stack traces, and other
+// records as single lines:
+//
+//	h := general.Options{}.NewLevelHandler(os.Stderr, general.LevelFormatters{
+//		slog.LevelDebug: general.ConsoleOptions{}.NewFormatter,
+//		slog.LevelWarn:  general.BlockOptions{}.NewFormatter,
+//	})
+//
+// It has a [Handler] for each Formatter, which share the writer, the
+// lock on it and the counts of [Handler.Status], and calls the one for
+// the record's level.
+type LevelHandler struct {
+	levels   []slog.Level // in increasing order
+	handlers []*Handler   // handlers[i] formats levels[i] up to levels[i+1]
+}
+
+// NewLevelHandler returns a LevelHandler that writes to w, formatting
+// records as given by formatters. It panics if formatters is empty.
+// Options that apply to the whole record, like Level and ReplaceAttr,
+// apply to all levels.
+func (opts Options) NewLevelHandler(w io.Writer, formatters LevelFormatters) *LevelHandler {
+	if len(formatters) == 0 {
+		panic("general: no formatters")
+	}
+	h := &LevelHandler{}
+	for l := range formatters {
+		h.levels = append(h.levels, l)
+	}
+	slices.Sort(h.levels)
+	for i, l := range h.levels {
+		lh := opts.New(w, formatters[l])
+		if i > 0 {
+			lh.mu = h.handlers[0].mu
+			lh.stats = h.handlers[0].stats
+		}
+		h.handlers = append(h.handlers, lh)
+	}
+	return h
+}
+
+// handler returns the Handler for records at level l.
+func (h *LevelHandler) handler(l slog.Level) *Handler {
+	i := len(h.levels) - 1
+	for i > 0 && h.levels[i] > l {
+		i--
+	}
+	return h.handlers[i]
+}
+
+// Status reports the counts for all levels, as [Handler.Status] does.
+func (h *LevelHandler) Status() metrics.Status {
+	return h.handlers[0].Status()
+}
+
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// All the Handlers have the same options.
+	return h.handlers[0].Enabled(ctx, level)
+}
+
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler(r.Level).Handle(ctx, r)
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(lh *Handler) slog.Handler { return lh.WithGroup(name) })
+}
+
+func (h *LevelHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	if len(as) == 0 {
+		return h
+	}
+	return h.with(func(lh *Handler) slog.Handler { return lh.WithAttrs(as) })
+}
+
+// with returns a LevelHandler whose Handlers are the results of calling
+// f on those of h.
+func (h *LevelHandler) with(f func(*Handler) slog.Handler) *LevelHandler {
+	h2 := &LevelHandler{levels: h.levels, handlers: make([]*Handler, len(h.handlers))}
+	for i, lh := range h.handlers {
+		h2.handlers[i] = f(lh).(*Handler)
+	}
+	return h2
+}
@@ -0,0 +1,51 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/jba/slog/levels"
+)
+
+func TestLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{Level: slog.LevelDebug}.NewLevelHandler(&buf, LevelFormatters{
+		slog.LevelInfo: ConsoleOptions{TimeFormat: "15:04"}.NewFormatter,
+		slog.LevelWarn: BlockOptions{TimeFormat: "15:04"}.NewFormatter,
+	})
+	l := slog.New(h).With("a", 1).WithGroup("g")
+	for _, lv := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, levels.LevelPanic} {
+		r := slog.NewRecord(testTime, lv, "m", 0)
+		r.AddAttrs(slog.Int("b", 2))
+		if err := l.Handler().Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "03:04 DEBUG m a=1 g.b=2\n" +
+		"03:04 INFO  m a=1 g.b=2\n" +
+		"03:04 WARN  m\n  a: 1\n  g:\n    b: 2\n\n" +
+		"03:04 PANIC m\n  a: 1\n  g:\n    b: 2\n\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if h.Enabled(context.Background(), slog.LevelDebug-1) {
+		t.Error("enabled below the minimum level")
+	}
+}
+
+func TestLevelHandlerShared(t *testing.T) {
+	// The Handlers for each level share a lock.
+	h := Options{}.NewLevelHandler(&bytes.Buffer{}, LevelFormatters{
+		slog.LevelInfo:  NewTextFormatter,
+		slog.LevelWarn:  NewJSONFormatter,
+		slog.LevelError: NewTextFormatter,
+	})
+	h2 := h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).(*LevelHandler)
+	for _, lh := range h2.handlers {
+		if lh.mu != h.handlers[0].mu {
+			t.Fatal("Handlers don't share a lock")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package general
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// Limits bound the size of a Handler's output.
+// A zero field means no limit.
+type Limits struct {
+	// MaxStringLen is the maximum length in bytes of a string value,
+	// including the message. Longer strings are cut at a rune boundary
+	// and end with [Ellipsis].
+	// Other values of kind [slog.KindAny], like errors, Stringers and
+	// structs, are limited by the length of their text, and are
+	// replaced by the cut text when it is too long.
+	MaxStringLen int
+
+	// MaxBytesLen is the maximum length of a []byte value.
+	// Longer slices are cut and end with the bytes of [Ellipsis].
+	MaxBytesLen int
+
+	// MaxAttrs is the maximum number of Attrs of a record, not counting
+	// the built-in ones or those from WithAttrs. Further Attrs are dropped.
+	MaxAttrs int
+
+	// MaxGroupDepth is the maximum nesting of groups, counting those from
+	// WithGroup. Groups nested more deeply are replaced by [Ellipsis].
+	MaxGroupDepth int
+}
+
+// Ellipsis marks truncated values.
+const Ellipsis = "…"
+
+// TruncatedKey is the key of the Attr that is added to the end of
+// a record when Limits truncated or dropped anything. Its value is
+// the number of values affected.
+const TruncatedKey = "!TRUNCATED"
+
+// limitGroup reports whether a group Attr at the given depth should be
+// replaced, and returns the replacement.
+func (l *Limits) limitGroup(a slog.Attr, depth int) (slog.Attr, bool) {
+	if l == nil || l.MaxGroupDepth <= 0 || depth < l.MaxGroupDepth {
+		return a, false
+	}
+	return slog.String(a.Key, Ellipsis), true
+}
+
+// limitValue returns a with its value truncated, and reports
+// whether it was.
+func (l *Limits) limitValue(a slog.Attr) (slog.Attr, bool) {
+	if l == nil {
+		return a, false
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s, ok := l.truncate(a.Value.String()); ok {
+			return slog.String(a.Key, s), true
+		}
+	case slog.KindAny:
+		v := a.Value.Any()
+		if bs, ok := byteSlice(v); ok {
+			if l.MaxBytesLen > 0 && len(bs) > l.MaxBytesLen {
+				return slog.Any(a.Key, append(bs[:l.MaxBytesLen:l.MaxBytesLen], Ellipsis...)), true
+			}
+			break
+		}
+		if _, ok := v.(slog.Level); ok || v == nil || l.MaxStringLen <= 0 {
+			break
+		}
+		if s, ok := l.truncate(anyText(v)); ok {
+			return slog.String(a.Key, s), true
+		}
+	}
+	return a, false
+}
+
+// truncate returns s cut to MaxStringLen at a rune boundary and followed
+// by Ellipsis, and reports whether it was cut.
+func (l *Limits) truncate(s string) (string, bool) {
+	if l.MaxStringLen <= 0 || len(s) <= l.MaxStringLen {
+		return s, false
+	}
+	n := l.MaxStringLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + Ellipsis, true
+}
+
+// anyText returns the text of v as a formatter would write it.
+// If a method of v panics, anyText returns the empty string, leaving
+// the formatter to report the panic.
+func anyText(v any) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case encoding.TextMarshaler:
+		data, err := v.MarshalText()
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
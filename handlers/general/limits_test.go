@@ -0,0 +1,101 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	limits := &Limits{MaxStringLen: 4, MaxBytesLen: 2, MaxAttrs: 3, MaxGroupDepth: 2}
+	for _, test := range []struct {
+		name  string
+		opts  Options
+		with  func(slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name:  "none",
+			opts:  Options{},
+			attrs: []slog.Attr{slog.String("s", "abcdef")},
+			want:  "msg=message s=abcdef",
+		},
+		{
+			name:  "strings",
+			opts:  Options{Limits: limits},
+			attrs: []slog.Attr{slog.String("s", "abcdef"), slog.String("t", "ab€"), slog.String("u", "abcd")},
+			want:  "msg=mess… s=abcd… t=ab… u=abcd !TRUNCATED=3",
+		},
+		{
+			name:  "bytes",
+			opts:  Options{Limits: &Limits{MaxBytesLen: 2}},
+			attrs: []slog.Attr{slog.Any("b", []byte("xyz"))},
+			want:  `msg=message b="xy…" !TRUNCATED=1`,
+		},
+		{
+			name: "any",
+			opts: Options{Limits: &Limits{MaxStringLen: 3}},
+			attrs: []slog.Attr{
+				slog.Any("e", errors.New("boom")),
+				slog.Any("d", time.Hour),
+				slog.Any("s", struct{ A, B int }{1, 2}),
+				slog.Any("ip", netip.MustParseAddr("10.0.0.1")),
+				slog.Any("short", errors.New("ok")),
+			},
+			want: "msg=mes… e=boo… d=1h0m0s s={A:… ip=10.… short=ok !TRUNCATED=4",
+		},
+		{
+			name: "attrs",
+			opts: Options{Limits: &Limits{MaxAttrs: 2}},
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.Int("p", 0)})
+			},
+			attrs: []slog.Attr{slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3), slog.Int("d", 4)},
+			want:  "msg=message p=0 a=1 b=2 !TRUNCATED=2",
+		},
+		{
+			name: "depth",
+			opts: Options{Limits: &Limits{MaxGroupDepth: 2}},
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithGroup("g").WithAttrs([]slog.Attr{slog.Group("h", slog.Group("i", slog.Int("x", 1)))})
+			},
+			attrs: []slog.Attr{slog.Group("j", slog.Int("y", 2))},
+			want:  "msg=message g.h.i=… g.j.y=2 !TRUNCATED=1",
+		},
+		{
+			name:  "sorted",
+			opts:  Options{Limits: &Limits{MaxAttrs: 1}, SortKeys: true},
+			attrs: []slog.Attr{slog.Int("b", 1), slog.Int("a", 2)},
+			want:  "msg=message b=1 !TRUNCATED=1",
+		},
+		{
+			name:  "dedup",
+			opts:  Options{Limits: &Limits{MaxAttrs: 2}, DedupKeys: DedupKeepLast},
+			attrs: []slog.Attr{slog.Int("a", 1), slog.Int("a", 2), slog.Int("a", 3)},
+			want:  "msg=message a=2 !TRUNCATED=1",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			test.opts.ReplaceAttr = removeKeys(slog.TimeKey, slog.LevelKey)
+			var h slog.Handler = test.opts.New(&buf, NewTextFormatter)
+			if test.with != nil {
+				h = test.with(h)
+			}
+			r := slog.NewRecord(testTime, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(buf.String()); got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
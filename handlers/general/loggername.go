@@ -0,0 +1,56 @@
+package general
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/jba/slog/internal/logvalue"
+)
+
+// LoggerKey is the key of the Attr holding a logger's name.
+// See [Options.LoggerName].
+const LoggerKey = "logger"
+
+// A LoggerNameMode says where a Handler finds the name of a logger.
+//
+// The JSON and text Formatters write the name like other Attrs. The
+// block and console Formatters write it in brackets after the level:
+//
+//	01:02:03.000 INFO  [db] connected
+//
+// To write it as Datadog's "logger.name", set JSONOptions.LoggerNameKey
+// to LoggerKey.
+type LoggerNameMode int
+
+const (
+	// NameNone means loggers have no names.
+	NameNone LoggerNameMode = iota
+	// NameFromGroup takes the name of the first call to WithGroup as the
+	// logger's name, instead of opening a group. Later calls open groups
+	// as usual.
+	NameFromGroup
+	// NameFromAttr takes the value of a top-level Attr passed to
+	// WithAttrs with key Options.LoggerNameKey as the logger's name,
+	// instead of writing the Attr. A later such Attr replaces the name.
+	NameFromAttr
+)
+
+// nameFromAttrs returns the last logger name in as and the other Attrs,
+// and reports whether there was a name.
+func (opts Options) nameFromAttrs(as []slog.Attr) (name string, rest []slog.Attr, ok bool) {
+	key := opts.LoggerNameKey
+	if key == "" {
+		key = LoggerKey
+	}
+	if !slices.ContainsFunc(as, func(a slog.Attr) bool { return a.Key == key }) {
+		return "", nil, false
+	}
+	for _, a := range as {
+		if a.Key == key {
+			name, ok = logvalue.Resolve(a.Value).String(), true
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return name, rest, ok
+}
@@ -0,0 +1,79 @@
+package general
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerName(t *testing.T) {
+	noTime := removeKeys(slog.TimeKey)
+	for _, test := range []struct {
+		name   string
+		opts   Options
+		newF   func() Formatter
+		logger func(*slog.Logger) *slog.Logger
+		want   string
+	}{
+		{
+			"none",
+			Options{ReplaceAttr: noTime},
+			NewTextFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.WithGroup("db").With("a", 1) },
+			"level=INFO msg=m db.a=1 db.b=2",
+		},
+		{
+			"group text",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromGroup},
+			NewTextFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.With("a", 1).WithGroup("db").WithGroup("g") },
+			"level=INFO msg=m logger=db a=1 g.b=2",
+		},
+		{
+			"group json",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromGroup},
+			NewJSONFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.WithGroup("db") },
+			`{"level":"INFO","msg":"m","logger":"db","b":2}`,
+		},
+		{
+			"attr",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromAttr},
+			NewTextFormatter,
+			func(l *slog.Logger) *slog.Logger {
+				return l.With(LoggerKey, "a", "x", 1).With(LoggerKey, "b").WithGroup("g").With(LoggerKey, "c")
+			},
+			"level=INFO msg=m logger=b x=1 g.logger=c g.b=2",
+		},
+		{
+			"attr key",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromAttr, LoggerNameKey: "component", DedupKeys: DedupKeepLast},
+			NewTextFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.With("component", "db", "logger", "x") },
+			"level=INFO msg=m logger=db logger=x b=2",
+		},
+		{
+			"console",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromGroup},
+			ConsoleOptions{Width: 18}.NewFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.With("a", 1).WithGroup("db") },
+			"INFO  [db] m a=1\n           b=2\n",
+		},
+		{
+			"block",
+			Options{ReplaceAttr: noTime, LoggerName: NameFromGroup},
+			BlockOptions{}.NewFormatter,
+			func(l *slog.Logger) *slog.Logger { return l.WithGroup("db") },
+			"INFO  [db] m\n  b: 2\n\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := test.logger(slog.New(test.opts.New(&buf, test.newF)))
+			l.Info("m", "b", 2)
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
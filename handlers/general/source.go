@@ -0,0 +1,98 @@
+package general
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jba/slog/internal/frames"
+)
+
+// A SourceFormat determines how the file name of a source location is
+// written.
+type SourceFormat int
+
+const (
+	// SourceFullPath writes the file name as reported by the runtime.
+	SourceFullPath SourceFormat = iota
+	// SourceBaseName writes only the last element of the file name.
+	SourceBaseName
+	// SourcePackageRelative writes the file name relative to its
+	// package's import path, as in "github.com/jba/slog/handlers/general/source.go".
+	SourcePackageRelative
+)
+
+// SourceOptions control the Attrs produced by [SourceOptions.Attrs].
+type SourceOptions struct {
+	// Format determines how the file name is written.
+	Format SourceFormat
+
+	// TrimPrefixes is a list of prefixes, such as a GOPATH or module
+	// directory, to remove from the file name. The first matching prefix
+	// is removed. TrimPrefixes is only consulted for SourceFullPath.
+	TrimPrefixes []string
+
+	// If FileLine is true, the source location is a single string of the
+	// form "file:line" instead of a group of function, file and line.
+	FileLine bool
+}
+
+// SourceAttrs returns the source location for pc using the default
+// SourceOptions. It is suitable for [Options.PCAttrs].
+func SourceAttrs(pc uintptr) []slog.Attr {
+	return SourceOptions{}.Attrs(pc)
+}
+
+// Attrs returns the source location for pc as a list of Attrs
+// whose key is [slog.SourceKey].
+// By default the value is a group with the fields of a [slog.Source].
+// Attrs returns nil if pc is zero.
+// The method value opts.Attrs is suitable for [Options.PCAttrs].
+func (opts SourceOptions) Attrs(pc uintptr) []slog.Attr {
+	if pc == 0 {
+		return nil
+	}
+	f := frames.Frame(pc)
+	file := opts.fileName(f.Function, f.File)
+	if opts.FileLine {
+		return []slog.Attr{slog.String(slog.SourceKey, file+":"+strconv.Itoa(f.Line))}
+	}
+	return []slog.Attr{slog.Group(slog.SourceKey,
+		slog.String("function", f.Function),
+		slog.String("file", file),
+		slog.Int("line", f.Line),
+	)}
+}
+
+func (opts SourceOptions) fileName(function, file string) string {
+	switch opts.Format {
+	case SourceBaseName:
+		return filepath.Base(file)
+	case SourcePackageRelative:
+		if pkg := packagePath(function); pkg != "" {
+			return pkg + "/" + filepath.Base(file)
+		}
+		return filepath.Base(file)
+	default:
+		for _, p := range opts.TrimPrefixes {
+			if rest, ok := strings.CutPrefix(file, p); ok {
+				return strings.TrimPrefix(rest, "/")
+			}
+		}
+		return file
+	}
+}
+
+// packagePath returns the import path of the package containing
+// the function with the given fully qualified name.
+func packagePath(function string) string {
+	// The package path ends at the first dot after the last slash.
+	// For example, "github.com/jba/slog/handlers/general.(*Handler).Handle".
+	i := strings.LastIndexByte(function, '/')
+	j := strings.IndexByte(function[i+1:], '.')
+	if j < 0 {
+		return ""
+	}
+	return function[:i+1+j]
+}
@@ -0,0 +1,77 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSourceAttrs(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	pc := pcs[0]
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	dir := filepath.Dir(f.File)
+	const pkg = "github.com/jba/slog/handlers/general"
+
+	for _, test := range []struct {
+		opts SourceOptions
+		want string
+	}{
+		{
+			SourceOptions{},
+			fmt.Sprintf("source.function=%s source.file=%s source.line=%d", f.Function, f.File, f.Line),
+		},
+		{
+			SourceOptions{Format: SourceBaseName, FileLine: true},
+			fmt.Sprintf("source=source_test.go:%d", f.Line),
+		},
+		{
+			SourceOptions{Format: SourcePackageRelative, FileLine: true},
+			fmt.Sprintf("source=%s/source_test.go:%d", pkg, f.Line),
+		},
+		{
+			SourceOptions{TrimPrefixes: []string{"/nonexistent", dir}, FileLine: true},
+			fmt.Sprintf("source=source_test.go:%d", f.Line),
+		},
+	} {
+		var buf bytes.Buffer
+		opts := Options{
+			PCAttrs:     test.opts.Attrs,
+			ReplaceAttr: removeKeys(slog.TimeKey, slog.LevelKey, slog.MessageKey),
+		}
+		h := opts.New(&buf, NewTextFormatter)
+		r := slog.NewRecord(testTime, slog.LevelInfo, "m", pc)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.TrimSpace(buf.String())
+		if got != test.want {
+			t.Errorf("%+v:\ngot  %s\nwant %s", test.opts, got, test.want)
+		}
+	}
+	if got := SourceAttrs(0); got != nil {
+		t.Errorf("SourceAttrs(0) = %v, want nil", got)
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"main.main", "main"},
+		{"github.com/jba/slog/handlers/general.(*Handler).Handle", "github.com/jba/slog/handlers/general"},
+		{"example.com/a.b/c.F", "example.com/a.b/c"},
+		{"nodot", ""},
+	} {
+		if got := packagePath(test.in); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package general
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// A SyslogFormat is a syslog message format.
+type SyslogFormat int
+
+const (
+	RFC5424 SyslogFormat = iota // the current syslog protocol
+	RFC3164                     // the older BSD syslog format
+)
+
+// SyslogOptions are options for a syslog Formatter, which writes each
+// log event as a syslog message. In the RFC 5424 format, a message looks
+// like
+//
+//	<14>1 2023-04-03T01:02:03.000000Z host app 1234 - - request done method=GET
+//
+// and in the RFC 3164 format, like
+//
+//	<14>Apr  3 01:02:03 host app[1234]: request done method=GET
+//
+// The priority at the start is computed from the facility and the
+// severity of the level (see [SyslogSeverity]). The message is followed
+// by the Attrs, written as by [NewTextFormatter], and a newline.
+//
+// To send messages to a syslog server, use package
+// github.com/jba/slog/writers/netwriter. Set its OctetCounting option
+// for servers that expect the octet-counting framing of RFC 6587, like
+// Heroku's Logplex.
+type SyslogOptions struct {
+	// Format is the message format.
+	Format SyslogFormat
+
+	// Facility is the syslog facility, from 0 to 23. Since facility 0
+	// (kernel messages) can't be used by processes, zero means 1 (user).
+	Facility int
+
+	// Hostname is the name of the host. If empty, [os.Hostname] is used.
+	Hostname string
+
+	// AppName is the name of the application, which is the tag of an
+	// RFC 3164 message. If empty, the base name of the program is used.
+	AppName string
+
+	// MsgID is the MSGID field of an RFC 5424 message.
+	// If empty, the field is nil ("-").
+	MsgID string
+}
+
+// NewFormatter returns a syslog Formatter with the given options.
+// Pass the method value opts.NewFormatter to [Options.New].
+func (opts SyslogOptions) NewFormatter() Formatter {
+	if opts.Facility <= 0 || opts.Facility > 23 {
+		opts.Facility = 1
+	}
+	if opts.Hostname == "" {
+		opts.Hostname = hostname()
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	return &syslogFormatter{opts: opts}
+}
+
+var hostname = sync.OnceValue(func() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return h
+})
+
+// SyslogSeverity returns the syslog severity for l:
+// 7 (debug) for levels below [slog.LevelInfo], 6 (informational) up to
+// [slog.LevelWarn], 4 (warning) up to [slog.LevelError], 3 (error) up to
+// [levels.LevelPanic], and 2 (critical) from there on.
+func SyslogSeverity(l slog.Level) int {
+	switch {
+	case l < slog.LevelInfo:
+		return 7
+	case l < slog.LevelWarn:
+		return 6
+	case l < slog.LevelError:
+		return 4
+	case l < levels.LevelPanic:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// A syslogFormatter writes Attrs with a textFormatter, which it doesn't
+// embed so that the Handler won't preformat the built-in Attrs.
+type syslogFormatter struct {
+	headerCapture
+	text  textFormatter
+	opts  SyslogOptions
+	start int // offset of the event in the buffer
+
+	time     time.Time
+	severity int
+	msg      string
+}
+
+func (f *syslogFormatter) AppendBegin(buf []byte) []byte {
+	f.start = len(buf)
+	f.beginHeader()
+	f.time = time.Time{}
+	f.severity = SyslogSeverity(slog.LevelInfo)
+	f.msg = ""
+	return buf
+}
+
+func (f *syslogFormatter) AppendEnd(buf []byte) []byte {
+	// Until now, buf has held only the Attrs.
+	attrs := bytes.TrimLeft(slices.Clone(buf[f.start:]), " ")
+	buf = f.appendHeader(buf[:f.start])
+	buf = append(buf, f.msg...)
+	if len(attrs) > 0 {
+		if f.msg != "" {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, attrs...)
+	}
+	return append(buf, '\n')
+}
+
+// appendHeader appends everything before the message.
+func (f *syslogFormatter) appendHeader(buf []byte) []byte {
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(f.opts.Facility*8+f.severity), 10)
+	buf = append(buf, '>')
+	if f.opts.Format == RFC3164 {
+		t := f.time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		buf = t.AppendFormat(buf, time.Stamp)
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, f.opts.Hostname, 255)
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, f.opts.AppName, 32)
+		buf = append(buf, '[')
+		buf = strconv.AppendInt(buf, int64(pid), 10)
+		return append(buf, "]: "...)
+	}
+	buf = append(buf, "1 "...)
+	if f.time.IsZero() {
+		buf = append(buf, '-')
+	} else {
+		buf = f.time.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	}
+	for _, field := range []struct {
+		s   string
+		max int
+	}{
+		{f.opts.Hostname, 255},
+		{f.opts.AppName, 48},
+		{strconv.Itoa(pid), 128},
+		{f.opts.MsgID, 32},
+	} {
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, field.s, field.max)
+	}
+	// There is no structured data; the Attrs follow the message.
+	return append(buf, " - "...)
+}
+
+// appendSyslogField appends s as a header field: at most max printable
+// ASCII characters, with others replaced by '_', or "-" if s is empty.
+func appendSyslogField(buf []byte, s string, max int) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+	for i := 0; i < len(s) && i < max; i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (f *syslogFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	f.endHeader()
+	return buf
+}
+
+func (f *syslogFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	return buf
+}
+
+func (f *syslogFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+	f.endHeader()
+	if len(buf) > f.start && buf[len(buf)-1] != ' ' {
+		return append(buf, ' ')
+	}
+	return buf
+}
+
+func (f *syslogFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if f.captureHeader(a, openGroups, f.setHeaderField) {
+		return buf
+	}
+	return f.text.AppendAttr(buf, a, openGroups)
+}
+
+// setHeaderField saves a if it is a built-in Attr, and reports whether
+// it did.
+func (f *syslogFormatter) setHeaderField(a slog.Attr) bool {
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() != slog.KindTime {
+			return false
+		}
+		f.time = a.Value.Time()
+	case slog.LevelKey:
+		l, ok := a.Value.Any().(slog.Level)
+		if !ok || a.Value.Kind() != slog.KindAny {
+			return false
+		}
+		f.severity = SyslogSeverity(l)
+	case slog.MessageKey:
+		f.msg = a.Value.String()
+	default:
+		return false
+	}
+	return true
+}
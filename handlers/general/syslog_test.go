@@ -0,0 +1,92 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/levels"
+)
+
+func TestSyslogFormatter(t *testing.T) {
+	p := strconv.Itoa(pid)
+	for _, test := range []struct {
+		name string
+		opts SyslogOptions
+		time time.Time
+		want string
+	}{
+		{
+			"5424",
+			SyslogOptions{Hostname: "host", AppName: "app"},
+			testTime,
+			"<12>1 2000-01-02T03:04:05.000000Z host app " + p + " - - hello w=1 G.a=\"x y\" G.b=2\n",
+		},
+		{
+			"5424 no time",
+			SyslogOptions{Facility: 16, Hostname: "my host", AppName: "app", MsgID: "ID1"},
+			time.Time{},
+			"<132>1 - my_host app " + p + " ID1 - hello w=1 G.a=\"x y\" G.b=2\n",
+		},
+		{
+			"3164",
+			SyslogOptions{Format: RFC3164, Facility: 3, Hostname: "host", AppName: "app"},
+			testTime,
+			"<28>Jan  2 03:04:05 host app[" + p + "]: hello w=1 G.a=\"x y\" G.b=2\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := Options{}.New(&buf, test.opts.NewFormatter).WithAttrs([]slog.Attr{slog.Int("w", 1)}).WithGroup("G")
+			r := slog.NewRecord(test.time, slog.LevelWarn, "hello", 0)
+			r.AddAttrs(slog.String("a", "x y"), slog.Int("b", 2))
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("\ngot  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSyslogNoAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	h := Options{}.New(&buf, SyslogOptions{Hostname: "h", AppName: "a"}.NewFormatter)
+	// A user Attr with a built-in key is not part of the header.
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.String(slog.MessageKey, "x"))
+	for _, r := range []slog.Record{slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0), r} {
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := strconv.Itoa(pid)
+	want := "<14>1 - h a " + p + " - - m\n" +
+		"<14>1 - h a " + p + " - - m msg=x\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  int
+	}{
+		{slog.LevelDebug, 7},
+		{slog.LevelInfo, 6},
+		{slog.LevelInfo + 2, 6},
+		{slog.LevelWarn, 4},
+		{slog.LevelError, 3},
+		{levels.LevelPanic, 2},
+		{levels.LevelFatal, 2},
+	} {
+		if got := SyslogSeverity(test.level); got != test.want {
+			t.Errorf("%v: got %d, want %d", test.level, got, test.want)
+		}
+	}
+}
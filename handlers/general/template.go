@@ -0,0 +1,377 @@
+package general
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// TemplateOptions describe a line template for [Template.NewFormatter].
+type TemplateOptions struct {
+	// Template is the layout of each line. Text outside braces is
+	// written as is, except that "{{" and "}}" stand for "{" and "}".
+	// A field in braces is replaced by a part of the log event:
+	//
+	//	{time}     the time
+	//	{level}    the level
+	//	{message}  the message ({msg} is a synonym)
+	//	{source}   the source location, as "file:line"
+	//	{attrs}    the remaining Attrs, as key=value pairs
+	//	{name}     the Attr whose key, joined to its groups by dots, is name
+	//
+	// An Attr written by a {name} field is not repeated in {attrs}.
+	// Only Attrs passed to Handle are eligible for {name} fields: those
+	// from WithAttrs are preformatted and always appear in {attrs}, unless
+	// the handler's [Options.DedupKeys] is set.
+	//
+	// A field name may be followed by a colon and a width, as in {level:5},
+	// to pad the field with spaces to that many characters. A positive width
+	// right-aligns the field, and a negative one left-aligns it. The width
+	// may be followed by a dot and a maximum, as in {level:-4.4}, to truncate
+	// longer fields.
+	//
+	// A field that is missing from the event is written as an empty string.
+	// Each line ends in a newline, which is added if Template does not end
+	// in one.
+	Template string
+
+	// TimeFormat is the layout for the time, as for [time.Time.Format].
+	// If empty, the time is written in RFC 3339 format with milliseconds.
+	TimeFormat string
+
+	// FormatLevel, if non-nil, returns the text of a level.
+	// By default, [levels.String] is used.
+	FormatLevel func(slog.Level) string
+
+	// FormatKey, if non-nil, returns the text of a key in {attrs}.
+	// Its argument is the key joined to its groups by dots.
+	// By default, keys are written as by [NewTextFormatter].
+	FormatKey func(key string) string
+
+	// FormatValue, if non-nil, returns the text of a value in {attrs}
+	// or a {name} field.
+	// By default, values are written as by [NewTextFormatter].
+	FormatValue func(slog.Value) string
+}
+
+// A Template is a parsed [TemplateOptions].
+type Template struct {
+	opts  TemplateOptions
+	segs  []segment
+	names []string // keys of {name} fields
+}
+
+type fieldKind int
+
+const (
+	fieldLiteral fieldKind = iota
+	fieldTime
+	fieldLevel
+	fieldMessage
+	fieldSource
+	fieldAttrs
+	fieldAttr
+)
+
+// A segment is a piece of a Template.
+type segment struct {
+	kind  fieldKind
+	text  string // for fieldLiteral
+	name  int    // index into Template.names, for fieldAttr
+	width int    // pad to abs(width); left-align if negative
+	max   int    // if positive, truncate to max
+}
+
+// Parse parses opts.Template.
+// Pass the method value t.NewFormatter of the result to [Options.New].
+func (opts TemplateOptions) Parse() (*Template, error) {
+	t := &Template{opts: opts}
+	s := opts.Template
+	var lit strings.Builder
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "{{"):
+			lit.WriteByte('{')
+			s = s[2:]
+		case strings.HasPrefix(s, "}}"):
+			lit.WriteByte('}')
+			s = s[2:]
+		case s[0] == '}':
+			return nil, errors.New("general: unmatched '}' in template")
+		case s[0] == '{':
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return nil, errors.New("general: unclosed '{' in template")
+			}
+			seg, err := t.parseField(s[1:end])
+			if err != nil {
+				return nil, err
+			}
+			if lit.Len() > 0 {
+				t.segs = append(t.segs, segment{text: lit.String()})
+				lit.Reset()
+			}
+			t.segs = append(t.segs, seg)
+			s = s[end+1:]
+		default:
+			lit.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+	if !strings.HasSuffix(lit.String(), "\n") {
+		lit.WriteByte('\n')
+	}
+	t.segs = append(t.segs, segment{text: lit.String()})
+	return t, nil
+}
+
+// parseField parses the contents of a field: a name, optionally followed
+// by a colon, a width, a dot and a maximum.
+func (t *Template) parseField(s string) (segment, error) {
+	name, format, hasFormat := strings.Cut(s, ":")
+	if name == "" {
+		return segment{}, fmt.Errorf("general: empty field name in template field {%s}", s)
+	}
+	var seg segment
+	switch name {
+	case "time":
+		seg.kind = fieldTime
+	case "level":
+		seg.kind = fieldLevel
+	case "message", "msg":
+		seg.kind = fieldMessage
+	case "source":
+		seg.kind = fieldSource
+	case "attrs":
+		seg.kind = fieldAttrs
+	default:
+		seg.kind = fieldAttr
+		seg.name = len(t.names)
+		t.names = append(t.names, name)
+	}
+	if hasFormat {
+		w, m, hasMax := strings.Cut(format, ".")
+		var err error
+		if seg.width, err = strconv.Atoi(w); err != nil {
+			return segment{}, fmt.Errorf("general: bad width in template field {%s}", s)
+		}
+		if hasMax {
+			if seg.max, err = strconv.Atoi(m); err != nil || seg.max <= 0 {
+				return segment{}, fmt.Errorf("general: bad maximum in template field {%s}", s)
+			}
+		}
+	}
+	return seg, nil
+}
+
+// NewFormatter returns a Formatter that writes each log event as a line
+// laid out by t.
+//
+// The time, level, message and source are taken from the built-in Attrs,
+// after ReplaceAttr is called on them. The source may be a "file:line"
+// string or a group with "file" and "line" members, as produced by
+// [SourceOptions.Attrs].
+func (t *Template) NewFormatter() Formatter {
+	return &templateFormatter{t: t, values: make([][]byte, len(t.names))}
+}
+
+type templateFormatter struct {
+	headerCapture
+	t        *Template
+	inEvent  bool // AppendBegin was called
+	start    int  // offset of the event in the buffer
+	inSource bool // in the source group
+	file     string
+	line     int
+
+	time, level, msg, source []byte   // nil if missing
+	values                   [][]byte // of {name} fields; nil if missing
+}
+
+func (f *templateFormatter) AppendBegin(buf []byte) []byte {
+	f.inEvent = true
+	f.start = len(buf)
+	f.beginHeader()
+	f.inSource = false
+	f.time, f.level, f.msg, f.source = nil, nil, nil, nil
+	clear(f.values)
+	return buf
+}
+
+func (f *templateFormatter) AppendEnd(buf []byte) []byte {
+	// Until now, buf has held only the remaining Attrs.
+	attrs := slices.Clone(buf[f.start:])
+	buf = buf[:f.start]
+	for _, seg := range f.t.segs {
+		var field []byte
+		switch seg.kind {
+		case fieldLiteral:
+			buf = append(buf, seg.text...)
+			continue
+		case fieldTime:
+			field = f.time
+		case fieldLevel:
+			field = f.level
+		case fieldMessage:
+			field = f.msg
+		case fieldSource:
+			field = f.source
+		case fieldAttrs:
+			field = attrs
+		case fieldAttr:
+			field = f.values[seg.name]
+		}
+		buf = appendField(buf, field, seg.width, seg.max)
+	}
+	return buf
+}
+
+func (f *templateFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	if f.captureSourceGroup(name) {
+		f.inSource = true
+		f.file, f.line = "", 0
+	}
+	return buf
+}
+
+func (f *templateFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	if f.inSource {
+		f.inSource = false
+		if f.file != "" {
+			f.source = strconv.AppendInt(append([]byte(f.file), ':'), int64(f.line), 10)
+		}
+	}
+	return buf
+}
+
+func (f *templateFormatter) AppendSeparatorIfNeeded(buf []byte) []byte {
+	f.endHeader()
+	if len(buf) > f.start && buf[len(buf)-1] != ' ' {
+		return append(buf, ' ')
+	}
+	return buf
+}
+
+func (f *templateFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if f.inSource {
+		switch a.Key {
+		case "file":
+			f.file = a.Value.String()
+		case "line":
+			f.line = int(a.Value.Int64())
+		}
+		return buf
+	}
+	if f.captureHeader(a, openGroups, f.setHeaderField) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			openGroups = append(slices.Clip(openGroups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		return buf
+	}
+	key := a.Key
+	if len(openGroups) > 0 && (len(f.t.names) > 0 || f.t.opts.FormatKey != nil) {
+		key = strings.Join(openGroups, ".") + "." + a.Key
+	}
+	// Preformatted Attrs, written outside of an event, can't be moved.
+	if f.inEvent {
+		for i, name := range f.t.names {
+			if name == key && f.values[i] == nil {
+				f.values[i] = f.appendValue([]byte{}, a.Value)
+				return buf
+			}
+		}
+	}
+	buf = f.AppendSeparatorIfNeeded(buf)
+	if f.t.opts.FormatKey != nil {
+		buf = append(buf, f.t.opts.FormatKey(key)...)
+	} else {
+		buf = appendTextKey(buf, openGroups, a.Key)
+	}
+	buf = append(buf, '=')
+	return f.appendValue(buf, a.Value)
+}
+
+// setHeaderField records a if it is one of the built-in attributes,
+// and reports whether it did.
+func (f *templateFormatter) setHeaderField(a slog.Attr) bool {
+	v := a.Value
+	switch {
+	case a.Key == slog.TimeKey:
+		switch {
+		case v.Kind() != slog.KindTime:
+			f.time = append([]byte{}, v.String()...)
+		case f.t.opts.TimeFormat != "":
+			f.time = v.Time().AppendFormat([]byte{}, f.t.opts.TimeFormat)
+		default:
+			f.time = appendTimeRFC3339Millis([]byte{}, v.Time())
+		}
+	case a.Key == slog.LevelKey:
+		if l, ok := v.Any().(slog.Level); ok && v.Kind() == slog.KindAny {
+			format := levels.String
+			if f.t.opts.FormatLevel != nil {
+				format = f.t.opts.FormatLevel
+			}
+			f.level = append([]byte{}, format(l)...)
+		} else {
+			f.level = append([]byte{}, v.String()...)
+		}
+	case a.Key == slog.MessageKey:
+		f.msg = append([]byte{}, v.String()...)
+	case a.Key == slog.SourceKey && v.Kind() != slog.KindGroup:
+		f.source = append([]byte{}, v.String()...)
+	default:
+		return false
+	}
+	return true
+}
+
+func (f *templateFormatter) appendValue(buf []byte, v slog.Value) []byte {
+	if f.t.opts.FormatValue != nil {
+		return append(buf, f.t.opts.FormatValue(v)...)
+	}
+	return appendTextValue(buf, v)
+}
+
+// appendField appends field, truncated to maxLen characters if maxLen is
+// positive and padded with spaces to abs(width) characters.
+func appendField(buf, field []byte, width, maxLen int) []byte {
+	n := utf8.RuneCount(field)
+	if maxLen > 0 && n > maxLen {
+		i := 0
+		for j := 0; j < maxLen; j++ {
+			_, size := utf8.DecodeRune(field[i:])
+			i += size
+		}
+		field, n = field[:i], maxLen
+	}
+	pad := max(width, -width) - n
+	if width > 0 {
+		buf = appendSpaces(buf, pad)
+	}
+	buf = append(buf, field...)
+	if width < 0 {
+		buf = appendSpaces(buf, pad)
+	}
+	return buf
+}
+
+func appendSpaces(buf []byte, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
@@ -0,0 +1,129 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	fs := runtime.CallersFrames(pcs[:])
+	fr, _ := fs.Next()
+	loc := fmt.Sprintf("template_test.go:%d", fr.Line)
+
+	for _, test := range []struct {
+		name  string
+		topts TemplateOptions
+		opts  Options
+		with  func(slog.Handler) slog.Handler
+		attrs []slog.Attr
+		want  string
+	}{
+		{
+			name:  "basic",
+			topts: TemplateOptions{Template: "{time} [{level}] {message} {attrs}"},
+			attrs: []slog.Attr{slog.String("a", "x y"), slog.Group("g", slog.Int("b", 2))},
+			want:  `2000-01-02T03:04:05.000Z [INFO] msg a="x y" g.b=2` + "\n",
+		},
+		{
+			name:  "source",
+			topts: TemplateOptions{Template: "{level:-5}|{msg} ({source})"},
+			opts:  Options{PCAttrs: SourceOptions{Format: SourceBaseName}.Attrs},
+			want:  "INFO |msg (" + loc + ")\n",
+		},
+		{
+			name:  "source string",
+			topts: TemplateOptions{Template: "{level:5}|{msg} ({source})"},
+			opts:  Options{PCAttrs: SourceOptions{Format: SourceBaseName, FileLine: true}.Attrs},
+			want:  " INFO|msg (" + loc + ")\n",
+		},
+		{
+			name:  "missing",
+			topts: TemplateOptions{Template: "{{{source}}} {msg:-5.2}|{user:3}\n"},
+			want:  "{} ms   |   \n",
+		},
+		{
+			name:  "named",
+			topts: TemplateOptions{Template: "{user} {g.id} {msg} {attrs}"},
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("user", "pre")}).WithGroup("g")
+			},
+			attrs: []slog.Attr{slog.Int("id", 7), slog.Int("b", 2)},
+			want:  " 7 msg user=pre g.b=2\n",
+		},
+		{
+			name:  "named dedup",
+			topts: TemplateOptions{Template: "{user} {g.id} {msg} {attrs}"},
+			opts:  Options{DedupKeys: DedupKeepLast},
+			with: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("user", "pre")}).WithGroup("g")
+			},
+			attrs: []slog.Attr{slog.Int("id", 7), slog.Int("b", 2)},
+			want:  "pre 7 msg g.b=2\n",
+		},
+		{
+			name: "hooks",
+			topts: TemplateOptions{
+				Template:    "{time} {level:-4.4} {msg} {attrs}",
+				TimeFormat:  "15:04:05",
+				FormatLevel: func(l slog.Level) string { return strings.ToLower(l.String()) },
+				FormatKey:   func(k string) string { return "<" + k + ">" },
+				FormatValue: func(v slog.Value) string { return "'" + v.String() + "'" },
+			},
+			attrs: []slog.Attr{slog.Group("g", slog.Int("b", 2))},
+			want:  "03:04:05 info msg <g.b>='2'\n",
+		},
+		{
+			name:  "ReplaceAttr",
+			topts: TemplateOptions{Template: "{time}|{level}|{msg}|{attrs}"},
+			opts: Options{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				switch a.Key {
+				case slog.TimeKey:
+					return slog.Attr{}
+				case slog.LevelKey:
+					return slog.String(a.Key, "I")
+				}
+				return a
+			}},
+			attrs: []slog.Attr{slog.Int("a", 1)},
+			want:  "|I|msg|a=1\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := test.topts.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			var h slog.Handler = test.opts.New(&buf, tmpl.NewFormatter)
+			if test.with != nil {
+				h = test.with(h)
+			}
+			for i := 0; i < 2; i++ {
+				buf.Reset()
+				r := slog.NewRecord(testTime, slog.LevelInfo, "msg", pcs[0])
+				r.AddAttrs(test.attrs...)
+				if err := h.Handle(context.Background(), r); err != nil {
+					t.Fatal(err)
+				}
+				if got := buf.String(); got != test.want {
+					t.Errorf("\ngot  %q\nwant %q", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateParseErrors(t *testing.T) {
+	for _, tmpl := range []string{"{msg", "msg}", "{}", "{level:x}", "{level:5.0}", "{level:5.y}"} {
+		if _, err := (TemplateOptions{Template: tmpl}).Parse(); err == nil {
+			t.Errorf("%q: got nil, want error", tmpl)
+		}
+	}
+}
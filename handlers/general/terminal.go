@@ -0,0 +1,63 @@
+package general
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// A Terminal describes the capabilities of the terminal that output
+// goes to, as reported by [DetectTerminal].
+type Terminal struct {
+	// IsTerminal reports whether the output is a terminal.
+	IsTerminal bool
+
+	// Color reports whether colors should be used: the output is a
+	// terminal, NO_COLOR is unset or empty, and TERM is not "dumb".
+	Color bool
+
+	// TrueColor reports whether the terminal supports 24-bit colors,
+	// as shown by a COLORTERM of "truecolor" or "24bit".
+	TrueColor bool
+
+	// Width is the width of the terminal in columns, or zero if it
+	// isn't known. If the size of the terminal can't be found, the
+	// COLUMNS environment variable is used.
+	Width int
+}
+
+// DetectTerminal returns the capabilities of the terminal that w writes
+// to. If w is not an [*os.File] for a terminal, as when output is
+// redirected to a file or pipe, the result is the zero Terminal.
+//
+// Use it to set the options of a console Formatter:
+//
+//	t := general.DetectTerminal(os.Stderr)
+//	f := general.ConsoleOptions{Color: t.Color, Width: t.Width}.NewFormatter
+//	h := general.Options{}.New(os.Stderr, f)
+func DetectTerminal(w io.Writer) Terminal {
+	f, ok := w.(*os.File)
+	if !ok {
+		return Terminal{}
+	}
+	width, ok := terminalWidth(f)
+	if !ok {
+		return Terminal{}
+	}
+	return terminalFromEnv(width, os.Getenv)
+}
+
+// terminalFromEnv returns the Terminal for a terminal of the given
+// width, which may be zero, and the environment.
+func terminalFromEnv(width int, getenv func(string) string) Terminal {
+	t := Terminal{IsTerminal: true, Width: width}
+	if t.Width <= 0 {
+		if c, err := strconv.Atoi(getenv("COLUMNS")); err == nil && c > 0 {
+			t.Width = c
+		}
+	}
+	t.Color = getenv("NO_COLOR") == "" && getenv("TERM") != "dumb"
+	ct := getenv("COLORTERM")
+	t.TrueColor = t.Color && (ct == "truecolor" || ct == "24bit")
+	return t
+}
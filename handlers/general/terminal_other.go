@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package general
+
+import "os"
+
+// terminalWidth reports whether f is a character device, which is the
+// best guess of whether it is a terminal. It doesn't know the width.
+func terminalWidth(f *os.File) (int, bool) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false
+	}
+	return 0, info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,42 @@
+package general
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	if got := DetectTerminal(&bytes.Buffer{}); got != (Terminal{}) {
+		t.Errorf("buffer: got %+v, want zero", got)
+	}
+	f, err := os.Create(t.TempDir() + "/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := DetectTerminal(f); got != (Terminal{}) {
+		t.Errorf("file: got %+v, want zero", got)
+	}
+}
+
+func TestTerminalFromEnv(t *testing.T) {
+	for _, test := range []struct {
+		width int
+		env   map[string]string
+		want  Terminal
+	}{
+		{80, nil, Terminal{IsTerminal: true, Color: true, Width: 80}},
+		{0, map[string]string{"COLUMNS": "100"}, Terminal{IsTerminal: true, Color: true, Width: 100}},
+		{0, map[string]string{"COLUMNS": "x"}, Terminal{IsTerminal: true, Color: true}},
+		{80, map[string]string{"COLUMNS": "100"}, Terminal{IsTerminal: true, Color: true, Width: 80}},
+		{80, map[string]string{"NO_COLOR": "1", "COLORTERM": "truecolor"}, Terminal{IsTerminal: true, Width: 80}},
+		{80, map[string]string{"TERM": "dumb"}, Terminal{IsTerminal: true, Width: 80}},
+		{80, map[string]string{"COLORTERM": "24bit"}, Terminal{IsTerminal: true, Color: true, TrueColor: true, Width: 80}},
+	} {
+		got := terminalFromEnv(test.width, func(k string) string { return test.env[k] })
+		if got != test.want {
+			t.Errorf("%d, %v: got %+v, want %+v", test.width, test.env, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package general
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth returns the width of the terminal f, and reports whether
+// f is a terminal.
+func terminalWidth(f *os.File) (int, bool) {
+	var ws struct{ row, col, x, y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, false
+	}
+	return int(ws.col), true
+}
@@ -0,0 +1,236 @@
+package general
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// W3COptions describe output in the W3C Extended Log File Format, for
+// [W3C.NewFormatter]. The output begins with a header of directives,
+// the last of which lists the fields:
+//
+//	#Version: 1.0
+//	#Date: 2023-04-03 01:02:03
+//	#Fields: date time cs-method cs-uri-stem sc-status
+//	2023-04-03 01:02:03 GET /index.html 200
+//
+// Each record is written as a line of its fields' values, separated by
+// spaces. A missing value is written as "-". A value that is empty or
+// contains spaces, quotes or control characters is quoted, with each
+// quote inside doubled and each control character replaced by a space.
+type W3COptions struct {
+	// Fields are the fields of each line. A field is a W3C field name,
+	// optionally followed by "=" and the path of the Attr that holds its
+	// value: its key joined to its groups by dots. Without a path, the
+	// name is the path. For example, "cs-method=req.method" writes the
+	// Attr "method" in the group "req" in the field "cs-method".
+	//
+	// The fields "date" and "time" are the date and time of the record,
+	// in UTC as the format requires. Paths may name the built-in level
+	// and message Attrs, with the keys [slog.LevelKey] and [slog.MessageKey].
+	Fields []string
+
+	// Software, if non-empty, is written in a "#Software" directive.
+	Software string
+}
+
+// A W3C writes the W3C Extended Log File Format.
+// Its header is written before the first record formatted by one of its
+// Formatters.
+type W3C struct {
+	names, paths  []string
+	header        string // without the #Date directive
+	headerWritten atomic.Bool
+}
+
+// New checks the fields of opts and returns a W3C.
+// Pass the method value w.NewFormatter of the result to [Options.New].
+func (opts W3COptions) New() (*W3C, error) {
+	if len(opts.Fields) == 0 {
+		return nil, errors.New("general: no W3C fields")
+	}
+	w := &W3C{}
+	for _, f := range opts.Fields {
+		name, path, ok := strings.Cut(f, "=")
+		if !ok {
+			path = name
+		}
+		if name == "" || path == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, fmt.Errorf("general: bad W3C field %q", f)
+		}
+		w.names = append(w.names, name)
+		w.paths = append(w.paths, path)
+	}
+	var b strings.Builder
+	b.WriteString("#Version: 1.0\n")
+	if opts.Software != "" {
+		fmt.Fprintf(&b, "#Software: %s\n", opts.Software)
+	}
+	w.header = b.String()
+	return w, nil
+}
+
+// ResetHeader causes the header to be written again before the next
+// record, as is needed when the output moves to a new file.
+func (w *W3C) ResetHeader() {
+	w.headerWritten.Store(false)
+}
+
+// NewFormatter returns a Formatter for w.
+//
+// Unlike other Formatters, it writes the fields in the order of
+// W3COptions.Fields, not the order of the Attrs, and omits Attrs that
+// don't name a field.
+func (w *W3C) NewFormatter() Formatter {
+	return &w3cFormatter{w: w}
+}
+
+// A w3cFormatter writes each Attr to the buffer as an entry holding its
+// path and value, which AppendEnd replaces with the line. Since entries
+// are written to the buffer, Attrs preformatted by WithAttrs can fill
+// fields too.
+type w3cFormatter struct {
+	w     *W3C
+	start int // offset of the event in the buffer
+	time  time.Time
+}
+
+func (f *w3cFormatter) AppendBegin(buf []byte) []byte {
+	f.start = len(buf)
+	f.time = time.Time{}
+	return buf
+}
+
+func (f *w3cFormatter) AppendEnd(buf []byte) []byte {
+	values := make([]string, len(f.w.paths))
+	found := make([]bool, len(f.w.paths))
+	for entries := buf[f.start:]; len(entries) > 0; {
+		var path, val string
+		path, entries = readW3CEntry(entries)
+		val, entries = readW3CEntry(entries)
+		if i := slices.Index(f.w.paths, path); i >= 0 && !found[i] {
+			values[i], found[i] = val, true
+		}
+	}
+	buf = buf[:f.start]
+	if !f.w.headerWritten.Swap(true) {
+		buf = append(buf, f.w.header...)
+		t := f.time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		buf = append(buf, "#Date: "...)
+		buf = t.UTC().AppendFormat(buf, "2006-01-02 15:04:05")
+		buf = append(buf, "\n#Fields:"...)
+		for _, n := range f.w.names {
+			buf = append(buf, ' ')
+			buf = append(buf, n...)
+		}
+		buf = append(buf, '\n')
+	}
+	for i, path := range f.w.paths {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		switch {
+		case path == "date" && !f.time.IsZero():
+			buf = f.time.UTC().AppendFormat(buf, "2006-01-02")
+		case path == "time" && !f.time.IsZero():
+			buf = f.time.UTC().AppendFormat(buf, "15:04:05")
+		case found[i]:
+			buf = appendW3CString(buf, values[i])
+		default:
+			buf = append(buf, '-')
+		}
+	}
+	return append(buf, '\n')
+}
+
+func (*w3cFormatter) AppendOpenGroup(buf []byte, name string) []byte  { return buf }
+func (*w3cFormatter) AppendCloseGroup(buf []byte, name string) []byte { return buf }
+func (*w3cFormatter) AppendSeparatorIfNeeded(buf []byte) []byte       { return buf }
+
+func (f *w3cFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			openGroups = append(slices.Clip(openGroups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		return buf
+	}
+	if len(openGroups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime && f.time.IsZero() {
+		f.time = a.Value.Time()
+		return buf
+	}
+	path := a.Key
+	if len(openGroups) > 0 {
+		path = strings.Join(openGroups, ".") + "." + a.Key
+	}
+	buf = appendW3CEntry(buf, path)
+	return appendW3CEntry(buf, w3cValueString(a.Value))
+}
+
+func w3cValueString(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().UTC().Format("2006-01-02 15:04:05")
+	case slog.KindAny:
+		if l, ok := v.Any().(slog.Level); ok {
+			return levels.String(l)
+		}
+	}
+	return v.String()
+}
+
+// appendW3CEntry appends s preceded by its length.
+func appendW3CEntry(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// readW3CEntry reads a string written by appendW3CEntry from the start
+// of buf, and returns it and the rest of buf.
+func readW3CEntry(buf []byte) (string, []byte) {
+	n, size := binary.Uvarint(buf)
+	if size <= 0 || n > uint64(len(buf)-size) {
+		return "", nil
+	}
+	buf = buf[size:]
+	return string(buf[:n]), buf[n:]
+}
+
+// appendW3CString appends s, quoted if necessary.
+func appendW3CString(buf []byte, s string) []byte {
+	needsQuotes := s == ""
+	for i := 0; i < len(s) && !needsQuotes; i++ {
+		c := s[i]
+		needsQuotes = c <= ' ' || c == '"' || c == 0x7f
+	}
+	if !needsQuotes {
+		return append(buf, s...)
+	}
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			buf = append(buf, `""`...)
+		case c < ' ' || c == 0x7f:
+			buf = append(buf, ' ')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"')
+}
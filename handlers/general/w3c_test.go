@@ -0,0 +1,64 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestW3C(t *testing.T) {
+	w, err := W3COptions{
+		Fields:   []string{"date", "time", "cs-method=req.method", "cs-uri-stem=req.path", "sc-status=status", "x-msg=msg", "x-user=user"},
+		Software: "test 1.0",
+	}.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	h := Options{}.New(&buf, w.NewFormatter)
+	// Preformatted Attrs fill fields too.
+	l := slog.New(h).With("user", `a "b"`).WithGroup("req")
+	handle := func(msg string, args ...any) {
+		t.Helper()
+		r := slog.NewRecord(testTime, slog.LevelInfo, msg, 0)
+		r.Add(args...)
+		if err := l.Handler().Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handle("done", "method", "GET", "path", "/index.html", slog.Group("", "extra", 1))
+	handle("", "path", "/a b\n", "method", "POST")
+	want := "#Version: 1.0\n" +
+		"#Software: test 1.0\n" +
+		"#Date: 2000-01-02 03:04:05\n" +
+		"#Fields: date time cs-method cs-uri-stem sc-status x-msg x-user\n" +
+		`2000-01-02 03:04:05 GET /index.html - done "a ""b"""` + "\n" +
+		`2000-01-02 03:04:05 POST "/a b " - "" "a ""b"""` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// After ResetHeader, the header is written again.
+	buf.Reset()
+	w.ResetHeader()
+	handle("again")
+	handle("again")
+	if got, want := bytes.Count(buf.Bytes(), []byte("#Fields:")), 1; got != want {
+		t.Errorf("got %d headers, want %d:\n%s", got, want, buf.String())
+	}
+}
+
+func TestW3CBadFields(t *testing.T) {
+	for _, fields := range [][]string{
+		nil,
+		{"date", ""},
+		{"=x"},
+		{"a b"},
+		{"a="},
+	} {
+		if _, err := (W3COptions{Fields: fields}).New(); err == nil {
+			t.Errorf("%q: got nil, want error", fields)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/metrics"
+)
+
+// flakyWriter fails the first n writes after writing half of the data.
+type flakyWriter struct {
+	n         int
+	transient bool
+	buf       bytes.Buffer
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.n > 0 {
+		w.n--
+		half := len(p) / 2
+		w.buf.Write(p[:half])
+		if w.transient {
+			return half, timeoutError{}
+		}
+		return half, errors.New("broken")
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriteErrors(t *testing.T) {
+	const want = "level=INFO msg=message"
+	for _, test := range []struct {
+		name         string
+		w            *flakyWriter
+		retries      int
+		fallback     bool
+		wantErr      bool
+		wantPrimary  string
+		wantFallback string
+		wantOnError  int
+	}{
+		{"ok", &flakyWriter{}, 0, false, false, want, "", 0},
+		{"retried", &flakyWriter{n: 2, transient: true}, 2, false, false, want, "", 0},
+		{"too many", &flakyWriter{n: 3, transient: true}, 2, false, true, "level=INFO msg=mess", "", 1},
+		{"not transient", &flakyWriter{n: 1}, 2, false, true, "level=INFO ", "", 1},
+		{"fallback", &flakyWriter{n: 1}, 0, true, false, "level=INFO ", want, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var fallback bytes.Buffer
+			nOnError := 0
+			opts := Options{
+				ReplaceAttr: removeKeys(slog.TimeKey),
+				Retries:     test.retries,
+				OnError:     func(error) { nOnError++ },
+			}
+			if test.fallback {
+				opts.Fallback = &fallback
+			}
+			h := opts.New(test.w, NewTextFormatter)
+			err := h.Handle(context.Background(), slog.NewRecord(testTime, slog.LevelInfo, "message", 0))
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got := test.w.buf.String(); got != test.wantPrimary {
+				t.Errorf("primary: got %q, want %q", got, test.wantPrimary)
+			}
+			if got := fallback.String(); got != test.wantFallback {
+				t.Errorf("fallback: got %q, want %q", got, test.wantFallback)
+			}
+			if nOnError != test.wantOnError {
+				t.Errorf("OnError called %d times, want %d", nOnError, test.wantOnError)
+			}
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var c metrics.Counters
+	opts := Options{Hooks: &c}
+	h := opts.New(&flakyWriter{n: 1}, NewTextFormatter)
+	l := slog.New(h)
+	l.Debug("d")
+	l.Info("i")
+	l.Info("i")
+	if got := c.Drops(metrics.DropLevel); got != 0 {
+		t.Errorf("level drops from Enabled: got %d, want 0", got)
+	}
+	h.Handle(context.Background(), slog.NewRecord(testTime, slog.LevelDebug, "d", 0))
+	if got := c.Records(slog.LevelInfo); got != 2 {
+		t.Errorf("records: got %d, want 2", got)
+	}
+	if got := c.Drops(metrics.DropLevel); got != 1 {
+		t.Errorf("level drops: got %d, want 1", got)
+	}
+	if got := c.Drops(metrics.DropError); got != 1 {
+		t.Errorf("error drops: got %d, want 1", got)
+	}
+	if got := c.Errors(); got != 1 {
+		t.Errorf("errors: got %d, want 1", got)
+	}
+}
+
+func TestHooksSameOutput(t *testing.T) {
+	// Turning on metrics must not change what is written.
+	write := func(hooks metrics.Hooks) string {
+		var buf bytes.Buffer
+		h := Options{Hooks: hooks}.New(&buf, NewTextFormatter)
+		ctx := context.Background()
+		for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn} {
+			h.Handle(ctx, slog.NewRecord(testTime, level, "m", 0))
+		}
+		return buf.String()
+	}
+	without := write(nil)
+	with := write(&metrics.Counters{})
+	if with != without {
+		t.Errorf("with Hooks:\n%s\nwithout:\n%s", with, without)
+	}
+	if strings.Contains(without, "DEBUG") {
+		t.Errorf("record below Level was written:\n%s", without)
+	}
+}
+
+type ctxKey struct{}
+
+// ctxWriter records the value of ctxKey in the context passed to WriteContext.
+type ctxWriter struct {
+	bytes.Buffer
+	got any
+}
+
+func (w *ctxWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	w.got = ctx.Value(ctxKey{})
+	return w.Write(p)
+}
+
+func TestContext(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		var buf bytes.Buffer
+		var c metrics.Counters
+		h := Options{CheckContext: true, Hooks: &c}.New(&buf, NewTextFormatter)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := h.Handle(ctx, slog.NewRecord(testTime, slog.LevelInfo, "m", 0))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("wrote %q", buf.String())
+		}
+		if got := c.Drops(DropCanceled); got != 1 {
+			t.Errorf("drops: got %d, want 1", got)
+		}
+	})
+	t.Run("ContextWriter", func(t *testing.T) {
+		var w ctxWriter
+		h := New(&w, NewTextFormatter)
+		ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+		if err := h.Handle(ctx, slog.NewRecord(testTime, slog.LevelInfo, "m", 0)); err != nil {
+			t.Fatal(err)
+		}
+		if w.got != "v" || w.Len() == 0 {
+			t.Errorf("got context value %v and %d bytes", w.got, w.Len())
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer c1.Close()
+		defer c2.Close()
+		// Nothing reads from c2, so writes to c1 block.
+		h := Options{WriteTimeout: 10 * time.Millisecond}.New(c1, NewTextFormatter)
+		err := h.Handle(context.Background(), slog.NewRecord(testTime, slog.LevelInfo, "m", 0))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("got %v, want deadline exceeded", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		h = Options{CheckContext: true}.New(c1, NewTextFormatter)
+		err = h.Handle(ctx, slog.NewRecord(testTime, slog.LevelInfo, "m", 0))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("context deadline: got %v, want deadline exceeded", err)
+		}
+	})
+}
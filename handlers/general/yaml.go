@@ -0,0 +1,185 @@
+package general
+
+import (
+	"encoding"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
+)
+
+// NewYAMLFormatter returns a Formatter that writes each log event as
+// a YAML document, starting with "---". Groups are written as nested
+// mappings, times in RFC 3339 format and durations as strings like "1.5s".
+// Strings that YAML would read as something else, like "true" or "1.0",
+// or that contain special characters, are double-quoted. Values of kind
+// KindAny other than levels, errors and encoding.TextMarshalers are
+// written as JSON, which YAML parsers accept.
+func NewYAMLFormatter() Formatter {
+	return &yamlFormatter{}
+}
+
+type yamlFormatter struct {
+	indent int // depth of open groups
+}
+
+func (f *yamlFormatter) SetOpenGroups(groups []string) {
+	f.indent = len(groups)
+}
+
+func (f *yamlFormatter) AppendBegin(buf []byte) []byte {
+	f.indent = 0
+	return append(buf, "---\n"...)
+}
+
+func (*yamlFormatter) AppendEnd(buf []byte) []byte { return buf }
+
+func (f *yamlFormatter) AppendOpenGroup(buf []byte, name string) []byte {
+	buf = f.appendKey(buf, name)
+	f.indent++
+	return append(buf, '\n')
+}
+
+func (f *yamlFormatter) AppendCloseGroup(buf []byte, name string) []byte {
+	f.indent--
+	return buf
+}
+
+// AppendSeparatorIfNeeded does nothing, because each Attr ends its line.
+func (*yamlFormatter) AppendSeparatorIfNeeded(buf []byte) []byte { return buf }
+
+func (f *yamlFormatter) AppendAttr(buf []byte, a slog.Attr, openGroups []string) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return buf
+		}
+		if a.Key != "" {
+			buf = f.AppendOpenGroup(buf, a.Key)
+		}
+		for _, a2 := range attrs {
+			buf = f.AppendAttr(buf, a2, openGroups)
+		}
+		if a.Key != "" {
+			buf = f.AppendCloseGroup(buf, a.Key)
+		}
+		return buf
+	}
+	buf = f.appendKey(buf, a.Key)
+	buf = append(buf, ' ')
+	buf = appendYAMLValue(buf, a.Value)
+	return append(buf, '\n')
+}
+
+// appendKey appends the indentation and key of a line, and a colon.
+func (f *yamlFormatter) appendKey(buf []byte, key string) []byte {
+	for i := 0; i < f.indent; i++ {
+		buf = append(buf, "  "...)
+	}
+	buf = appendYAMLString(buf, key)
+	return append(buf, ':')
+}
+
+func appendYAMLValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendYAMLString(buf, v.String())
+	case slog.KindInt64:
+		return strconv.AppendInt(buf, v.Int64(), 10)
+	case slog.KindUint64:
+		return strconv.AppendUint(buf, v.Uint64(), 10)
+	case slog.KindFloat64:
+		f := v.Float64()
+		switch {
+		case math.IsNaN(f):
+			return append(buf, ".nan"...)
+		case math.IsInf(f, 1):
+			return append(buf, ".inf"...)
+		case math.IsInf(f, -1):
+			return append(buf, "-.inf"...)
+		}
+		return strconv.AppendFloat(buf, f, 'g', -1, 64)
+	case slog.KindBool:
+		return strconv.AppendBool(buf, v.Bool())
+	case slog.KindDuration:
+		return appendYAMLString(buf, v.Duration().String())
+	case slog.KindTime:
+		return v.Time().AppendFormat(buf, time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case slog.Level:
+			return append(buf, levels.String(x)...)
+		case error:
+			return appendYAMLString(buf, x.Error())
+		case encoding.TextMarshaler:
+			text, err := x.MarshalText()
+			if err != nil {
+				return appendYAMLString(buf, "!ERROR: "+err.Error())
+			}
+			return appendYAMLString(buf, string(text))
+		}
+		if bs, ok := byteSlice(v.Any()); ok {
+			return strconv.AppendQuote(buf, string(bs))
+		}
+		bs, err := json.Marshal(v.Any())
+		if err != nil {
+			return appendYAMLString(buf, "!ERROR: "+err.Error())
+		}
+		return append(buf, bs...)
+	default:
+		return appendYAMLString(buf, v.String())
+	}
+}
+
+// appendYAMLString appends s as a plain scalar if YAML would read it as
+// the same string, and as a double-quoted scalar otherwise.
+func appendYAMLString(buf []byte, s string) []byte {
+	if yamlNeedsQuoting(s) {
+		// Go's escapes are a subset of those of YAML's double-quoted style.
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+// yamlNeedsQuoting reports whether s can't be written as a plain scalar.
+func yamlNeedsQuoting(s string) bool {
+	if s == "" || s[0] == ' ' || s[len(s)-1] == ' ' {
+		return true
+	}
+	// Indicator characters can't begin a plain scalar.
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	// Strings that YAML would read as null, a bool or a number.
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n", ".nan", ".inf", "-.inf", "+.inf":
+		return true
+	}
+	// ParseFloat also accepts words like "nan" and "inf",
+	// which YAML reads as strings.
+	if c := s[0]; c == '+' || c == '.' || ('0' <= c && c <= '9') {
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return true
+		}
+		if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return true
+		}
+	}
+	return false
+}
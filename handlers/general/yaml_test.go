@@ -0,0 +1,74 @@
+package general
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestYAMLFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(&buf, NewYAMLFormatter).
+		WithAttrs([]slog.Attr{slog.Int("w", 1)}).
+		WithGroup("G").
+		WithAttrs([]slog.Attr{slog.String("s", "plain")})
+	r := slog.NewRecord(testTime, slog.LevelInfo, "hello: world", 0)
+	r.AddAttrs(
+		slog.Group("H", "x", 2),
+		slog.String("empty", ""),
+		slog.String("bool", "true"),
+		slog.String("num", "1.5"),
+		slog.String("lines", "a\nb"),
+		slog.String("dash", "-x"),
+		slog.String("key with space", "ok"),
+		slog.Float64("nan", math.NaN()),
+		slog.Float64("f", 2.5),
+		slog.Duration("d", 1500*time.Millisecond),
+		slog.Any("err", errors.New("bad: thing")),
+		slog.Any("m", map[string]int{"a": 1}),
+		slog.Bool("b", false),
+	)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	r = slog.NewRecord(testTime, slog.LevelWarn, "second", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := `---
+time: 2000-01-02T03:04:05Z
+level: INFO
+msg: "hello: world"
+w: 1
+G:
+  s: plain
+  H:
+    x: 2
+  empty: ""
+  bool: "true"
+  num: "1.5"
+  lines: "a\nb"
+  dash: "-x"
+  key with space: ok
+  nan: .nan
+  f: 2.5
+  d: 1.5s
+  err: "bad: thing"
+  m: {"a":1}
+  b: false
+---
+time: 2000-01-02T03:04:05Z
+level: WARN
+msg: second
+w: 1
+G:
+  s: plain
+`
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot\n%s\nwant\n%s", got, want)
+	}
+}
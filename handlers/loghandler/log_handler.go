@@ -2,32 +2,84 @@
 package loghandler
 
 import (
+	"bytes"
 	"context"
+	"encoding"
 	"fmt"
 	"io"
 	"log/slog"
-	"runtime"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jba/slog/internal/frames"
+	"github.com/jba/slog/internal/logvalue"
+	"github.com/jba/slog/levels"
 )
 
 type Handler struct {
 	opts      slog.HandlerOptions
+	replace   bool           // opts.ReplaceAttr was set by the user
+	raw       bool           // format values with %v
+	loc       *time.Location // if non-nil, convert record times to this
+	clock     func() time.Time
 	prefix    string // preformatted group names followed by a dot
-	preformat string // preformatted Attrs, with an initial space
+	groups    []string
+	preformat []byte // preformatted Attrs, with an initial space
 
-	mu sync.Mutex
+	mu *sync.Mutex // shared among clones
 	w  io.Writer
 }
 
+// Options are options for a [Handler].
+type Options struct {
+	slog.HandlerOptions
+
+	// If RawValues is true, keys and values are written with
+	// fmt's %v verb, without quoting. Otherwise they are formatted
+	// like those of [slog.TextHandler].
+	RawValues bool
+
+	// If TimeUTC is true, the record's time is converted to UTC before
+	// it is formatted. Otherwise, if Location is non-nil, it is converted
+	// to Location. Times in other Attrs are unchanged.
+	TimeUTC  bool
+	Location *time.Location
+
+	// Clock, if non-nil, supplies the time of records whose time is zero,
+	// which would otherwise have no time. Tests can use it to make output
+	// deterministic; see [github.com/jba/slog/handlertest.FakeClock].
+	Clock func() time.Time
+}
+
 func New(w io.Writer, opts *slog.HandlerOptions) *Handler {
-	h := &Handler{w: w}
+	var o Options
 	if opts != nil {
-		h.opts = *opts
+		o.HandlerOptions = *opts
+	}
+	return o.New(w)
+}
+
+// New constructs a Handler with the given options.
+func (opts Options) New(w io.Writer) *Handler {
+	h := &Handler{
+		w:     w,
+		mu:    &sync.Mutex{},
+		opts:  opts.HandlerOptions,
+		raw:   opts.RawValues,
+		loc:   opts.Location,
+		clock: opts.Clock,
+	}
+	if opts.TimeUTC {
+		h.loc = time.UTC
 	}
 	if h.opts.ReplaceAttr == nil {
 		h.opts.ReplaceAttr = func(_ []string, a slog.Attr) slog.Attr { return a }
+	} else {
+		h.replace = true
 	}
 	return h
 }
@@ -41,47 +93,77 @@ func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
 }
 
 func (h *Handler) WithGroup(name string) slog.Handler {
-	return &Handler{
-		w:         h.w,
-		opts:      h.opts,
-		preformat: h.preformat,
-		prefix:    h.prefix + name + ".",
-	}
+	c := h.clone()
+	c.prefix = h.prefix + name + "."
+	c.groups = append(c.groups, name)
+	return c
 }
 
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	var buf []byte
-	for _, a := range attrs {
-		buf = h.appendAttr(buf, h.prefix, a)
+	if len(attrs) == 0 {
+		return h
 	}
-	return &Handler{
-		w:         h.w,
-		opts:      h.opts,
-		prefix:    h.prefix,
-		preformat: h.preformat + string(buf),
+	c := h.clone()
+	// The clipped preformat is copied by the first append, so the
+	// Attrs of h and its other clones are not disturbed.
+	for _, a := range attrs {
+		c.preformat = c.appendAttr(c.preformat, c.prefix, c.groups, a, 0)
 	}
+	return c
+}
+
+// clone returns a copy of h whose slices will be copied when appended to.
+func (h *Handler) clone() *Handler {
+	c := *h
+	c.groups = slices.Clip(c.groups)
+	c.preformat = slices.Clip(c.preformat)
+	return &c
+}
+
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
 }
 
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	var buf []byte
+	bufp := bufPool.Get().(*[]byte)
+	buf := (*bufp)[:0]
+	defer func() {
+		// Don't hold on to large buffers.
+		if cap(buf) <= 16<<10 {
+			*bufp = buf
+			bufPool.Put(bufp)
+		}
+	}()
+	if r.Time.IsZero() && h.clock != nil {
+		r.Time = h.clock()
+	}
 	if !r.Time.IsZero() {
-		buf = r.Time.AppendFormat(buf, time.RFC3339)
-		buf = append(buf, ' ')
+		t := r.Time
+		if h.loc != nil {
+			t = t.In(h.loc)
+		}
+		buf = h.appendBuiltin(buf, slog.Time(slog.TimeKey, t))
 	}
-	buf = append(buf, r.Level.String()...)
-	buf = append(buf, ' ')
+	buf = h.appendBuiltin(buf, slog.Any(slog.LevelKey, r.Level))
 	if h.opts.AddSource && r.PC != 0 {
-		fs := runtime.CallersFrames([]uintptr{r.PC})
-		f, _ := fs.Next()
-		buf = append(buf, f.File...)
-		buf = append(buf, ':')
-		buf = strconv.AppendInt(buf, int64(f.Line), 10)
-		buf = append(buf, ' ')
-	}
-	buf = append(buf, r.Message...)
+		if h.replace {
+			buf = h.appendBuiltin(buf, slog.Any(slog.SourceKey, source(r.PC)))
+		} else {
+			// Without a ReplaceAttr to pass it to, a *slog.Source
+			// would only be allocated to be formatted.
+			f := frames.Frame(r.PC)
+			buf = appendFileLine(buf, f.File, f.Line)
+			buf = append(buf, ' ')
+		}
+	}
+	buf = h.appendBuiltin(buf, slog.String(slog.MessageKey, r.Message))
+	buf = bytes.TrimSuffix(buf, []byte{' '})
 	buf = append(buf, h.preformat...)
 	r.Attrs(func(a slog.Attr) bool {
-		buf = h.appendAttr(buf, h.prefix, a)
+		buf = h.appendAttr(buf, h.prefix, h.groups, a, 0)
 		return true
 	})
 	buf = append(buf, '\n')
@@ -91,23 +173,173 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 	return err
 }
 
-func (h *Handler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
-	if a.Equal(slog.Attr{}) {
+// appendBuiltin appends the value of a built-in Attr after calling
+// ReplaceAttr on it, followed by a space. The output has no keys, so
+// only the value of the replacement is used. If it has an empty key,
+// nothing is appended.
+func (h *Handler) appendBuiltin(buf []byte, a slog.Attr) []byte {
+	a = h.opts.ReplaceAttr(nil, a)
+	if a.Key == "" {
 		return buf
 	}
+	v := logvalue.Resolve(a.Value)
+	switch x := v.Any().(type) {
+	case time.Time:
+		buf = x.AppendFormat(buf, time.RFC3339)
+	case slog.Level:
+		buf = append(buf, levels.String(x)...)
+	case *slog.Source:
+		buf = appendFileLine(buf, x.File, x.Line)
+	default:
+		buf = fmt.Appendf(buf, "%v", x)
+	}
+	return append(buf, ' ')
+}
+
+// appendFileLine appends a source location as file:line.
+func appendFileLine(buf []byte, file string, line int) []byte {
+	buf = append(buf, file...)
+	buf = append(buf, ':')
+	return strconv.AppendInt(buf, int64(line), 10)
+}
+
+// maxGroupDepth is the deepest nesting of groups within an Attr
+// that a Handler will write.
+const maxGroupDepth = 100
+
+// appendAttr appends a, which is in the given groups and nested depth
+// groups deep within an Attr. The prefix is the groups joined by dots,
+// followed by a dot.
+// ReplaceAttr is called on a if it is not a group.
+func (h *Handler) appendAttr(buf []byte, prefix string, groups []string, a slog.Attr, depth int) []byte {
+	a.Value = logvalue.Resolve(a.Value)
 	if a.Value.Kind() != slog.KindGroup {
-		buf = append(buf, ' ')
-		buf = append(buf, prefix...)
-		buf = append(buf, a.Key...)
-		buf = append(buf, '=')
-		return fmt.Appendf(buf, "%v", a.Value.Any())
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = logvalue.Resolve(a.Value)
+		// Like slog's built-in handlers, don't call ReplaceAttr on
+		// the members of a group it returns.
+		return h.appendReplaced(buf, prefix, a, depth)
+	}
+	if depth >= maxGroupDepth {
+		return h.appendReplaced(buf, prefix, a, depth)
 	}
-	// Group
 	if a.Key != "" {
 		prefix += a.Key + "."
+		groups = append(slices.Clip(groups), a.Key)
 	}
 	for _, a := range a.Value.Group() {
-		buf = h.appendAttr(buf, prefix, a)
+		buf = h.appendAttr(buf, prefix, groups, a, depth+1)
 	}
 	return buf
 }
+
+// appendReplaced appends a, on which ReplaceAttr has already been called.
+func (h *Handler) appendReplaced(buf []byte, prefix string, a slog.Attr, depth int) []byte {
+	a.Value = logvalue.Resolve(a.Value)
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup && depth < maxGroupDepth {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, a := range a.Value.Group() {
+			buf = h.appendReplaced(buf, prefix, a, depth+1)
+		}
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// Probably a LogValuer that returns itself in a group.
+		a.Value = slog.StringValue("!ERROR: groups nested too deeply")
+	}
+	buf = append(buf, ' ')
+	if h.raw {
+		buf = append(buf, prefix...)
+		buf = append(buf, a.Key...)
+		buf = append(buf, '=')
+		return fmt.Appendf(buf, "%v", a.Value.Any())
+	}
+	buf = appendKey(buf, prefix, a.Key)
+	buf = append(buf, '=')
+	return appendValueSafely(buf, a.Value)
+}
+
+// appendKey appends prefix followed by key, quoted if necessary,
+// without concatenating them unless they must be quoted.
+func appendKey(buf []byte, prefix, key string) []byte {
+	if prefix == "" || needsQuoting(prefix) || (key != "" && needsQuoting(key)) {
+		return appendString(buf, prefix+key)
+	}
+	buf = append(buf, prefix...)
+	return append(buf, key...)
+}
+
+// appendValueSafely calls appendValue. If that panics, in a MarshalText
+// method, appendValueSafely discards what appendValue wrote and appends
+// the panic value instead, prefixed with "!PANIC: ".
+func appendValueSafely(buf []byte, v slog.Value) (res []byte) {
+	n := len(buf)
+	defer func() {
+		if p := recover(); p != nil {
+			res = appendString(buf[:n], fmt.Sprintf("!PANIC: %v", p))
+		}
+	}()
+	return appendValue(buf, v)
+}
+
+// appendValue appends v as slog.TextHandler does.
+func appendValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendString(buf, v.String())
+	case slog.KindTime:
+		return appendString(buf, v.Time().Format("2006-01-02T15:04:05.000Z07:00"))
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case encoding.TextMarshaler:
+			data, err := x.MarshalText()
+			if err != nil {
+				return appendString(buf, "!ERROR:"+err.Error())
+			}
+			return appendString(buf, string(data))
+		case []byte:
+			return strconv.AppendQuote(buf, string(x))
+		}
+		return appendString(buf, fmt.Sprintf("%+v", v.Any()))
+	default:
+		return appendString(buf, v.String())
+	}
+}
+
+// appendString appends s, quoted if necessary.
+func appendString(buf []byte, s string) []byte {
+	if needsQuoting(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+// needsQuoting reports whether s must be quoted so that the output
+// can be parsed: if it is empty, or contains a space, '=', '"'
+// or a non-printing character.
+func needsQuoting(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, r := range s {
+		if r == '=' || r == '"' || r == utf8.RuneError || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the source location of pc.
+func source(pc uintptr) *slog.Source {
+	f := frames.Frame(pc)
+	return &slog.Source{
+		Function: f.Function,
+		File:     f.File,
+		Line:     f.Line,
+	}
+}
@@ -3,10 +3,20 @@ package loghandler
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"net"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/jba/slog/handlertest"
+	"github.com/jba/slog/levels"
 )
 
 var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
@@ -15,6 +25,7 @@ func TestOutput(t *testing.T) {
 	for _, test := range []struct {
 		name    string
 		handler func(io.Writer, *slog.HandlerOptions) *Handler
+		opts    *slog.HandlerOptions
 		with    func(*slog.Logger) *slog.Logger
 		attrs   []slog.Attr
 		want    string
@@ -51,11 +62,57 @@ func TestOutput(t *testing.T) {
 			attrs: []slog.Attr{slog.String("c", "foo"), slog.Bool("b", true)},
 			want:  `2023-04-03T01:02:03Z INFO message wa=1 wb=2 p1.wc=3 p1.p2.c=foo p1.p2.b=true`,
 		},
+		{
+			name:    "quoting",
+			handler: New,
+			attrs: []slog.Attr{
+				slog.String("a b", "c d"), slog.String("e", "x=y"), slog.String("f", ""),
+				slog.Any("g", []byte("hi")), slog.Any("h", net.IPv4(1, 2, 3, 4)),
+				slog.Time("t", testTime),
+			},
+			want: `2023-04-03T01:02:03Z INFO message "a b"="c d" e="x=y" f="" g="hi" h=1.2.3.4 t=2023-04-03T01:02:03.000Z`,
+		},
+		{
+			name:    "raw",
+			handler: func(w io.Writer, opts *slog.HandlerOptions) *Handler { return Options{RawValues: true}.New(w) },
+			attrs:   []slog.Attr{slog.String("a b", "c d"), slog.Any("g", []byte("hi"))},
+			want:    `2023-04-03T01:02:03Z INFO message a b=c d g=[104 105]`,
+		},
+		{
+			name: "Location",
+			handler: func(w io.Writer, opts *slog.HandlerOptions) *Handler {
+				return Options{Location: time.FixedZone("CET", 60*60)}.New(w)
+			},
+			attrs: []slog.Attr{slog.Time("t", testTime)},
+			want:  `2023-04-03T02:02:03+01:00 INFO message t=2023-04-03T01:02:03.000Z`,
+		},
+		{
+			name:    "ReplaceAttr",
+			handler: New,
+			opts: &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				switch {
+				case a.Key == slog.TimeKey:
+					return slog.Attr{}
+				case a.Key == slog.LevelKey:
+					return slog.String(a.Key, "I")
+				case a.Key == "c":
+					return slog.String("C", strings.Join(groups, "/"))
+				case a.Key == "r":
+					return slog.Group("R", "x", 1)
+				}
+				return a
+			}},
+			with: func(l *slog.Logger) *slog.Logger {
+				return l.With("c", 0).WithGroup("p1").With("c", 0)
+			},
+			attrs: []slog.Attr{slog.Group("g", slog.Int("c", 1)), slog.Int("r", 2)},
+			want:  `I message C="" p1.C=p1 p1.g.C=p1/g p1.R.x=1`,
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			var buf bytes.Buffer
-			h := test.handler(&buf, nil)
-			logger := slog.New(setTimeHandler{testTime, h})
+			h := test.handler(&buf, test.opts)
+			logger := slog.New(handlertest.WithClock(h, testClock))
 			if test.with != nil {
 				logger = test.with(logger)
 			}
@@ -70,24 +127,205 @@ func TestOutput(t *testing.T) {
 	}
 }
 
-type setTimeHandler struct {
-	t time.Time
-	h slog.Handler
+func TestSource(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(&buf, &slog.HandlerOptions{AddSource: true})
+	logger := slog.New(handlertest.WithClock(h, testClock))
+	logger.Info("message", "a", 1)
+	_, file, line, _ := runtime.Caller(0)
+	got := strings.TrimSuffix(buf.String(), "\n")
+	want := fmt.Sprintf("2023-04-03T01:02:03Z INFO %s:%d message a=1", file, line-1)
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
 }
 
-func (h setTimeHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.h.Enabled(ctx, level)
+func TestSourceAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	ctx := context.Background()
+	allocs := func(opts *slog.HandlerOptions, pc uintptr) float64 {
+		h := New(io.Discard, opts)
+		r := slog.NewRecord(testTime, slog.LevelInfo, "m", pc)
+		return testing.AllocsPerRun(100, func() { h.Handle(ctx, r) })
+	}
+	without := allocs(&slog.HandlerOptions{AddSource: true}, 0)
+	if with := allocs(&slog.HandlerOptions{AddSource: true}, pcs[0]); with > without {
+		t.Errorf("got %.0f allocs with a source location, %.0f without", with, without)
+	}
+
+	// With a ReplaceAttr, the source is a *slog.Source.
+	var buf bytes.Buffer
+	h := New(&buf, &slog.HandlerOptions{
+		AddSource: true,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if src, ok := a.Value.Any().(*slog.Source); ok {
+				src.File = filepath.Base(src.File)
+			}
+			return a
+		},
+	})
+	h.Handle(ctx, slog.NewRecord(testTime, slog.LevelInfo, "m", pcs[0]))
+	if got := buf.String(); !strings.Contains(got, " log_handler_test.go:") {
+		t.Errorf("got %q, want the base of the file", got)
+	}
 }
 
-func (h setTimeHandler) WithGroup(name string) slog.Handler {
-	return setTimeHandler{h.t, h.h.WithGroup(name)}
+func TestLevelNames(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(New(&buf, nil))
+	logger.Log(context.Background(), levels.LevelPanic, "m")
+	logger.Log(context.Background(), levels.LevelPanic+1, "m")
+	got := buf.String()
+	for _, want := range []string{" PANIC m\n", " ERROR+5 m\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q does not contain %q", got, want)
+		}
+	}
 }
 
-func (h setTimeHandler) WithAttrs(as []slog.Attr) slog.Handler {
-	return setTimeHandler{h.t, h.h.WithAttrs(as)}
+func testClock() time.Time { return testTime }
+
+func TestClock(t *testing.T) {
+	var buf bytes.Buffer
+	clock := handlertest.NewFakeClock(testTime, time.Second)
+	h := Options{Clock: clock.Now}.New(&buf)
+	// The clock supplies the time only for records without one.
+	for _, tm := range []time.Time{{}, {}, testTime.Add(time.Hour)} {
+		if err := h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).Handle(context.Background(), slog.NewRecord(tm, slog.LevelInfo, "m", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "2023-04-03T01:02:03Z INFO m a=1\n" +
+		"2023-04-03T01:02:04Z INFO m a=1\n" +
+		"2023-04-03T02:02:03Z INFO m a=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWithAttrsSiblings(t *testing.T) {
+	// Handlers made from the same parent don't share preformatted Attrs.
+	var buf bytes.Buffer
+	parent := slog.New(New(&buf, nil)).With("a", 1).WithGroup("g")
+	for i := 0; i < 3; i++ {
+		parent = parent.With("x", i)
+	}
+	b := parent.With("b", 2)
+	c := parent.With("c", 3)
+	b.Info("m")
+	c.Info("m")
+	b.Info("m", "d", 4)
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		_, after, _ := strings.Cut(line, " INFO ")
+		got = append(got, after)
+	}
+	want := []string{
+		"m a=1 g.x=0 g.x=1 g.x=2 g.b=2",
+		"m a=1 g.x=0 g.x=1 g.x=2 g.c=3",
+		"m a=1 g.x=0 g.x=1 g.x=2 g.b=2 g.d=4",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// recursiveValuer's LogValue contains itself.
+type recursiveValuer struct{}
+
+func (recursiveValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Any("r", recursiveValuer{}))
+}
+
+type panicValuer struct{}
+
+func (panicValuer) LogValue() slog.Value { panic("boom") }
+
+type panicMarshaler struct{}
+
+func (panicMarshaler) MarshalText() ([]byte, error) { panic("text boom") }
+
+func TestBadLogValuers(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(New(&buf, nil))
+	l.Info("m", "rec", recursiveValuer{}, "p", panicValuer{}, "tm", panicMarshaler{}, "a", 1)
+	got := buf.String()
+	for _, want := range []string{"rec.r.r.r.", `="!ERROR: groups nested too deeply"`, ` p="!PANIC: boom"`, ` tm="!PANIC: text boom" a=1`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
 }
 
-func (h setTimeHandler) Handle(ctx context.Context, r slog.Record) error {
-	r.Time = h.t
-	return h.h.Handle(ctx, r)
+func TestConformance(t *testing.T) {
+	handlertest.Suite{
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return New(w, opts) },
+		Parse:      parseLines,
+	}.Run(t)
+}
+
+func TestCompatibility(t *testing.T) {
+	handlertest.Comparison{
+		Reference: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			return slog.NewTextHandler(w, opts)
+		},
+		ParseReference: handlertest.ParseText,
+		NewHandler:     func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return New(w, opts) },
+		Parse:          parseLines,
+		// The handler writes times to the second.
+		Ignore: []string{slog.TimeKey},
+	}.Run(t)
+}
+
+// parseLines parses Handler output for handlertest.
+// It assumes that no message or value contains a space, even if quoted,
+// and that the message does not contain "=".
+func parseLines(data []byte) ([]map[string]any, error) {
+	var ms []map[string]any
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]any{}
+		fields := strings.Fields(line)
+		if _, err := time.Parse(time.RFC3339, fields[0]); err == nil {
+			m[slog.TimeKey] = fields[0]
+			fields = fields[1:]
+		}
+		var l slog.Level
+		if len(fields) > 0 && l.UnmarshalText([]byte(fields[0])) == nil {
+			m[slog.LevelKey] = fields[0]
+			fields = fields[1:]
+		}
+		if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+			m[slog.MessageKey] = fields[0]
+			fields = fields[1:]
+		}
+		for _, f := range fields {
+			k, v, ok := strings.Cut(f, "=")
+			if !ok {
+				return nil, fmt.Errorf("bad field %q", f)
+			}
+			if uv, err := strconv.Unquote(v); err == nil {
+				v = uv
+			}
+			keys := strings.Split(k, ".")
+			g := m
+			for _, k := range keys[:len(keys)-1] {
+				sub, ok := g[k].(map[string]any)
+				if !ok {
+					sub = map[string]any{}
+					g[k] = sub
+				}
+				g = sub
+			}
+			g[keys[len(keys)-1]] = v
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
 }
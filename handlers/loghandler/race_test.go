@@ -0,0 +1,5 @@
+//go:build race
+
+package loghandler
+
+const raceEnabled = true
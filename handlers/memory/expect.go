@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// An Expectation describes a record that a test expects to have been
+// logged. Build one with [Handler.Expect] and its methods, then check it
+// with Logged or NotLogged:
+//
+//	h.Expect().Level(slog.LevelWarn).Msg("slow request").
+//		Attr("http.request.method", "GET").
+//		Attr("http.response", slog.GroupValue(slog.Int("status", 200))).
+//		Logged(t)
+//
+// Attributes are named by dotted paths through groups, and the values of
+// groups match partially: a record's group matches if it has all of the
+// attributes of the expected group, whatever else it has. LogValuers in
+// the record are resolved, and groups with empty keys are treated as
+// part of the enclosing group, as handlers output them.
+//
+// When no record matches, Logged reports how the closest record differs
+// from the Expectation.
+type Expectation struct {
+	h      *Handler
+	checks []check
+}
+
+// A check returns a description of how a record fails to meet a
+// condition, or "" if it meets it.
+type check func(slog.Record) string
+
+// missing is in the descriptions of failures for missing attributes.
+// A record that lacks an attribute is further from matching than one
+// that has it with a different value.
+const missing = ": missing"
+
+// Expect returns an Expectation that matches any record of h.
+func (h *Handler) Expect() *Expectation {
+	return &Expectation{h: h}
+}
+
+func (e *Expectation) add(c check) *Expectation {
+	e.checks = append(e.checks, c)
+	return e
+}
+
+// Level requires the record's level to be l.
+func (e *Expectation) Level(l slog.Level) *Expectation {
+	return e.add(func(r slog.Record) string {
+		if r.Level != l {
+			return fmt.Sprintf("level: got %s, want %s", r.Level, l)
+		}
+		return ""
+	})
+}
+
+// Msg requires the record's message to be msg.
+func (e *Expectation) Msg(msg string) *Expectation {
+	return e.add(func(r slog.Record) string {
+		if r.Message != msg {
+			return fmt.Sprintf("message: got %q, want %q", r.Message, msg)
+		}
+		return ""
+	})
+}
+
+// MsgContains requires the record's message to contain substr.
+func (e *Expectation) MsgContains(substr string) *Expectation {
+	return e.add(func(r slog.Record) string {
+		if !strings.Contains(r.Message, substr) {
+			return fmt.Sprintf("message: got %q, want it to contain %q", r.Message, substr)
+		}
+		return ""
+	})
+}
+
+// Attr requires the record to have an attribute at path whose value
+// matches value. The value is converted with [slog.AnyValue], so it may
+// be a Go value like "GET" or 200, or a [slog.Value]. If it is a group,
+// the record's value must be a group with at least its attributes.
+func (e *Expectation) Attr(path string, value any) *Expectation {
+	want := slog.AnyValue(value).Resolve()
+	return e.attr(path, want.String(), func(v slog.Value) bool { return partialMatch(v, want) })
+}
+
+// AttrFunc requires the record to have an attribute at path whose value,
+// after resolving, satisfies f. The description is used in failure
+// messages, as in "want <desc>".
+func (e *Expectation) AttrFunc(path, desc string, f func(slog.Value) bool) *Expectation {
+	return e.attr(path, desc, f)
+}
+
+func (e *Expectation) attr(path, want string, f func(slog.Value) bool) *Expectation {
+	keys := strings.Split(path, ".")
+	return e.add(func(r slog.Record) string {
+		// Any attribute at path may match, as with HasAttr.
+		// Failures describe the first one.
+		var (
+			first slog.Value
+			found bool
+		)
+		if findAttr(r, keys, func(v slog.Value) bool {
+			if !found {
+				first, found = v, true
+			}
+			return f(v)
+		}) {
+			return ""
+		}
+		if !found {
+			return fmt.Sprintf("%s%s, want %s", path, missing, want)
+		}
+		return fmt.Sprintf("%s: got %s, want %s", path, first, want)
+	})
+}
+
+// NoAttr requires the record not to have an attribute at path.
+func (e *Expectation) NoAttr(path string) *Expectation {
+	keys := strings.Split(path, ".")
+	return e.add(func(r slog.Record) string {
+		var got slog.Value
+		if findAttr(r, keys, func(v slog.Value) bool { got = v; return true }) {
+			return fmt.Sprintf("%s: got %s, want none", path, got)
+		}
+		return ""
+	})
+}
+
+// Find returns the records that meet the Expectation.
+func (e *Expectation) Find() []slog.Record {
+	return e.h.Find(e.matches)
+}
+
+func (e *Expectation) matches(r slog.Record) bool {
+	for _, c := range e.checks {
+		if c(r) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Logged reports an error to t if no record meets the Expectation.
+// It returns the first record that does.
+func (e *Expectation) Logged(t testing.TB) slog.Record {
+	t.Helper()
+	rs := e.h.Records()
+	if len(rs) == 0 {
+		t.Errorf("no records")
+		return slog.Record{}
+	}
+	var (
+		closest  slog.Record
+		failures []string
+		minDist  = -1
+	)
+	for _, r := range rs {
+		var fs []string
+		dist := 0
+		for _, c := range e.checks {
+			if f := c(r); f != "" {
+				fs = append(fs, f)
+				dist++
+				if strings.Contains(f, missing) {
+					dist++
+				}
+			}
+		}
+		if len(fs) == 0 {
+			return r
+		}
+		if minDist < 0 || dist < minDist {
+			closest, failures, minDist = r, fs, dist
+		}
+	}
+	t.Errorf("no record matches; the closest is\n\t%s\nwhich differs in\n\t%s\nhave:\n%s",
+		describe(closest), strings.Join(failures, "\n\t"), e.h.dump())
+	return slog.Record{}
+}
+
+// NotLogged reports an error to t if any record meets the Expectation.
+func (e *Expectation) NotLogged(t testing.TB) {
+	t.Helper()
+	if rs := e.Find(); len(rs) > 0 {
+		t.Errorf("got %d matching records, want none; the first is\n\t%s", len(rs), describe(rs[0]))
+	}
+}
+
+// describe returns a one-line description of r.
+func describe(r slog.Record) string {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	b.WriteString(" ")
+	b.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteString(" ")
+		b.WriteString(a.String())
+		return true
+	})
+	return b.String()
+}
+
+// partialMatch reports whether got matches want. Groups match if got has
+// all of want's attributes, with matching values.
+func partialMatch(got, want slog.Value) bool {
+	if want.Kind() != slog.KindGroup {
+		return valuesEqual(got, want)
+	}
+	if got.Kind() != slog.KindGroup {
+		return false
+	}
+	for _, wa := range want.Group() {
+		wv := wa.Value.Resolve()
+		if !findInGroup(got.Group(), []string{wa.Key}, func(gv slog.Value) bool { return partialMatch(gv, wv) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type request struct{ method, path string }
+
+func (r request) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("method", r.method), slog.String("path", r.path))
+}
+
+// recordingT records the errors reported to it.
+type recordingT struct {
+	testing.TB
+	errs []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestExpect(t *testing.T) {
+	h := New(nil)
+	l := slog.New(h)
+	l.Info("start")
+	l.WithGroup("http").Warn("slow request",
+		"request", request{"GET", "/a"},
+		slog.Group("response", "status", 200, "bytes", 512),
+		slog.Group("", "ms", 1500))
+
+	r := h.Expect().Level(slog.LevelWarn).Msg("slow request").
+		Attr("http.request.method", "GET").
+		Attr("http.response", slog.GroupValue(slog.Int("status", 200))). // partial
+		Attr("http.ms", 1500).                                           // in an inline group
+		AttrFunc("http.response.bytes", "more than 100", func(v slog.Value) bool {
+			return v.Kind() == slog.KindInt64 && v.Int64() > 100
+		}).
+		NoAttr("http.error").
+		Logged(t)
+	if r.Message != "slow request" {
+		t.Errorf("Logged returned %q", r.Message)
+	}
+	h.Expect().MsgContains("start").Logged(t)
+	h.Expect().Level(slog.LevelError).NotLogged(t)
+	if got := len(h.Expect().Find()); got != 2 {
+		t.Errorf("Find: got %d records, want 2", got)
+	}
+
+	for _, test := range []struct {
+		e    *Expectation
+		want []string // substrings of the error
+	}{
+		{
+			h.Expect().Msg("slow request").Attr("http.request.method", "POST").Attr("http.user", "pat"),
+			[]string{
+				"closest is\n\tWARN slow request",
+				"http.request.method: got GET, want POST",
+				"http.user: missing, want pat",
+			},
+		},
+		{
+			h.Expect().Attr("http.response", slog.GroupValue(slog.Int("status", 500))),
+			[]string{"http.response: got [status=200 bytes=512], want [status=500]"},
+		},
+		{
+			h.Expect().Level(slog.LevelInfo).Msg("stop"),
+			[]string{"closest is\n\tINFO start", `message: got "start", want "stop"`, "have:\n\tINFO start\n\tWARN"},
+		},
+	} {
+		ft := &recordingT{}
+		test.e.Logged(ft)
+		if len(ft.errs) != 1 {
+			t.Fatalf("got %d errors, want 1", len(ft.errs))
+		}
+		for _, w := range test.want {
+			if !strings.Contains(ft.errs[0], w) {
+				t.Errorf("error\n%s\ndoes not contain\n%s", ft.errs[0], w)
+			}
+		}
+	}
+
+	ft := &recordingT{}
+	h.Expect().Attr("http.request", request{"GET", "/a"}).NotLogged(ft)
+	if len(ft.errs) != 1 || !strings.Contains(ft.errs[0], "got 1 matching records") {
+		t.Errorf("NotLogged: got %q", ft.errs)
+	}
+
+	h.Reset()
+	ft = &recordingT{}
+	h.Expect().Logged(ft)
+	if len(ft.errs) != 1 || ft.errs[0] != "no records" {
+		t.Errorf("no records: got %q", ft.errs)
+	}
+}
+
+func TestExpectAgreesWithHasAttr(t *testing.T) {
+	h := New(nil)
+	slog.New(h).Info("m",
+		"a", 1, "a", 2, // duplicate keys
+		"req", request{"GET", "/a"},
+		slog.Group("", "inline", true),
+		slog.Group("g", slog.Group("", "x", "y")))
+	r := h.Records()[0]
+	for _, a := range []slog.Attr{
+		slog.Int("a", 1),
+		slog.Int("a", 2),
+		slog.Int("a", 3),
+		slog.String("req.method", "GET"),
+		slog.String("req.method", "PUT"),
+		slog.Bool("inline", true),
+		slog.String("g.x", "y"),
+		slog.String("x", "y"),
+		slog.String("nope", ""),
+	} {
+		has := HasAttr(a)(r)
+		expected := h.Expect().Attr(a.Key, a.Value).matches(r)
+		if has != expected {
+			t.Errorf("%s: HasAttr says %t, Expect says %t", a, has, expected)
+		}
+	}
+}
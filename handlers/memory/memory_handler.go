@@ -0,0 +1,203 @@
+// Package memory provides a slog.Handler that keeps records in memory,
+// for use in tests. Tests can find records with predicates, or describe
+// them with [Handler.Expect].
+package memory
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jba/slog/handlers/simple"
+)
+
+// Handler records every slog.Record it handles. It is safe for
+// concurrent use.
+//
+// Handlers derived from a Handler with WithAttrs and WithGroup record
+// into the same Handler. The attributes and groups they add are
+// included in the recorded records, as with [simple.Handler].
+type Handler struct {
+	slog.Handler
+
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+// New returns a new Handler. Only the Level field of opts is used.
+func New(opts *slog.HandlerOptions) *Handler {
+	var o slog.HandlerOptions
+	if opts != nil {
+		o.Level = opts.Level
+	}
+	h := &Handler{}
+	h.Handler = simple.Handler(h.add, o)
+	return h
+}
+
+func (h *Handler) add(r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+// Records returns a copy of the records handled so far, in order.
+func (h *Handler) Records() []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rs := make([]slog.Record, len(h.records))
+	for i, r := range h.records {
+		rs[i] = r.Clone()
+	}
+	return rs
+}
+
+// Reset discards all records.
+func (h *Handler) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = nil
+}
+
+// Find returns the records that satisfy all of the predicates.
+func (h *Handler) Find(preds ...Predicate) []slog.Record {
+	var rs []slog.Record
+	for _, r := range h.Records() {
+		if matches(r, preds) {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// AssertLogged reports an error to t if no record has the given level and
+// message and contains all of the given attrs. See [HasAttr] for how attrs
+// are matched.
+func (h *Handler) AssertLogged(t testing.TB, level slog.Level, msg string, attrs ...slog.Attr) {
+	t.Helper()
+	preds := []Predicate{Level(level), Message(msg)}
+	for _, a := range attrs {
+		preds = append(preds, HasAttr(a))
+	}
+	if len(h.Find(preds...)) == 0 {
+		t.Errorf("no record with level %s, message %q and attrs %v; have:\n%s",
+			level, msg, attrs, h.dump())
+	}
+}
+
+// AssertNotLogged reports an error to t if any record has the given level
+// and a message containing substr.
+func (h *Handler) AssertNotLogged(t testing.TB, level slog.Level, substr string) {
+	t.Helper()
+	if rs := h.Find(Level(level), MessageContains(substr)); len(rs) > 0 {
+		t.Errorf("got %d records with level %s and message containing %q", len(rs), level, substr)
+	}
+}
+
+// dump returns a description of the records, one per line.
+func (h *Handler) dump() string {
+	var b strings.Builder
+	for _, r := range h.Records() {
+		b.WriteString("\t")
+		b.WriteString(describe(r))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// A Predicate reports whether a record has some property.
+type Predicate func(slog.Record) bool
+
+func matches(r slog.Record, preds []Predicate) bool {
+	for _, p := range preds {
+		if !p(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Level returns a Predicate that matches records with level l.
+func Level(l slog.Level) Predicate {
+	return func(r slog.Record) bool { return r.Level == l }
+}
+
+// MinLevel returns a Predicate that matches records whose level
+// is at least l.
+func MinLevel(l slog.Level) Predicate {
+	return func(r slog.Record) bool { return r.Level >= l }
+}
+
+// Message returns a Predicate that matches records whose message is msg.
+func Message(msg string) Predicate {
+	return func(r slog.Record) bool { return r.Message == msg }
+}
+
+// MessageContains returns a Predicate that matches records whose message
+// contains substr.
+func MessageContains(substr string) Predicate {
+	return func(r slog.Record) bool { return strings.Contains(r.Message, substr) }
+}
+
+// HasAttr returns a Predicate that matches records with an Attr
+// equal to a. If a's key contains dots, it is treated as a path
+// through groups; for example, "g.a" matches the Attr with key "a"
+// in the group "g". Values are resolved before comparison.
+func HasAttr(a slog.Attr) Predicate {
+	path := strings.Split(a.Key, ".")
+	want := a.Value.Resolve()
+	return func(r slog.Record) bool {
+		return findAttr(r, path, func(v slog.Value) bool { return valuesEqual(v, want) })
+	}
+}
+
+// findAttr calls f with the resolved value of each attribute of r at the
+// path given by keys, until f returns true, and reports whether it did.
+// A group with an empty key is searched as part of its enclosing group.
+// Both HasAttr and Expectation use it, so that they agree.
+func findAttr(r slog.Record, keys []string, f func(slog.Value) bool) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = findInAttr(a, keys, f)
+		return !found
+	})
+	return found
+}
+
+func findInAttr(a slog.Attr, keys []string, f func(slog.Value) bool) bool {
+	v := a.Value.Resolve()
+	if a.Key == "" && v.Kind() == slog.KindGroup {
+		return findInGroup(v.Group(), keys, f)
+	}
+	if a.Key != keys[0] {
+		return false
+	}
+	if len(keys) == 1 {
+		return f(v)
+	}
+	if v.Kind() != slog.KindGroup {
+		return false
+	}
+	return findInGroup(v.Group(), keys[1:], f)
+}
+
+func findInGroup(as []slog.Attr, keys []string, f func(slog.Value) bool) bool {
+	for _, a := range as {
+		if findInAttr(a, keys, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesEqual is like slog.Value.Equal, but does not panic
+// on uncomparable values of kind Any.
+func valuesEqual(v1, v2 slog.Value) bool {
+	if v1.Kind() == slog.KindAny && v2.Kind() == slog.KindAny {
+		return reflect.DeepEqual(v1.Any(), v2.Any())
+	}
+	return v1.Equal(v2)
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := New(nil)
+	logger := slog.New(h)
+	logger.Debug("disabled")
+	logger.Info("hello", "a", 1)
+	logger.With("b", 2).WithGroup("g").Warn("world", "c", []byte("x"), slog.Group("", "d", 4))
+
+	rs := h.Records()
+	if len(rs) != 2 {
+		t.Fatalf("got %d records, want 2", len(rs))
+	}
+	for _, test := range []struct {
+		preds []Predicate
+		want  int
+	}{
+		{nil, 2},
+		{[]Predicate{Level(slog.LevelInfo)}, 1},
+		{[]Predicate{MinLevel(slog.LevelInfo)}, 2},
+		{[]Predicate{MinLevel(slog.LevelWarn)}, 1},
+		{[]Predicate{MessageContains("l")}, 2},
+		{[]Predicate{Message("hello"), HasAttr(slog.Int("a", 1))}, 1},
+		{[]Predicate{HasAttr(slog.Int("a", 2))}, 0},
+		{[]Predicate{HasAttr(slog.Int("b", 2))}, 1},
+		{[]Predicate{HasAttr(slog.Any("g.c", []byte("x")))}, 1},
+		{[]Predicate{HasAttr(slog.Int("g.d", 4))}, 1},
+		{[]Predicate{HasAttr(slog.Int("c", 3))}, 0},
+	} {
+		if got := len(h.Find(test.preds...)); got != test.want {
+			t.Errorf("%d predicates: got %d, want %d", len(test.preds), got, test.want)
+		}
+	}
+
+	h.AssertLogged(t, slog.LevelWarn, "world", slog.Int("b", 2), slog.Int("g.d", 4))
+	h.AssertNotLogged(t, slog.LevelDebug, "disabled")
+
+	ft := &fakeT{}
+	h.AssertLogged(ft, slog.LevelError, "world")
+	if !ft.failed {
+		t.Error("AssertLogged did not fail")
+	}
+
+	h.Reset()
+	if got := len(h.Records()); got != 0 {
+		t.Errorf("after Reset, got %d records", got)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	h := New(nil)
+	logger := slog.New(h)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.With("i", i).Info("m")
+		}(i)
+	}
+	wg.Wait()
+	if got := len(h.Records()); got != 10 {
+		t.Errorf("got %d records, want 10", got)
+	}
+}
+
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(string, ...any) { t.failed = true }
@@ -0,0 +1,314 @@
+// Package msgpack provides a slog.Handler that writes MessagePack events
+// in the Message mode of Fluentd's forward protocol.
+package msgpack
+
+import (
+	"context"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/withsupport"
+)
+
+// Handler writes each record as a Fluentd forward-protocol message:
+// a MessagePack array of the tag, the record time as an EventTime
+// and a map of the record's level, message and attributes.
+//
+// Times within attributes are written as RFC 3339 strings, and durations
+// as integer nanoseconds.
+type Handler struct {
+	opts slog.HandlerOptions
+	tag  string
+	goa  *withsupport.GroupOrAttrs
+
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// New returns a Handler that writes to w, tagging each message with tag.
+func New(w io.Writer, tag string, opts *slog.HandlerOptions) *Handler {
+	h := &Handler{w: w, tag: tag, mu: &sync.Mutex{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool { attrs = append(attrs, a); return true })
+
+	// Build the record map from the innermost group outward.
+	gs := h.goa.Collect()
+	var groups []string
+	for _, g := range gs {
+		if g.Group != "" {
+			groups = append(groups, g.Group)
+		}
+	}
+	attrs = h.resolve(groups, attrs)
+	for i := len(gs) - 1; i >= 0; i-- {
+		g := gs[i]
+		if g.Group != "" {
+			groups = groups[:len(groups)-1]
+			if len(attrs) > 0 {
+				attrs = []slog.Attr{{Key: g.Group, Value: slog.GroupValue(attrs...)}}
+			}
+		} else {
+			attrs = append(h.resolve(groups, g.Attrs), attrs...)
+		}
+	}
+	var builtins []slog.Attr
+	builtins = append(builtins, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		builtins = append(builtins, slog.Any(slog.SourceKey, source(r.PC)))
+	}
+	builtins = append(builtins, slog.String(slog.MessageKey, r.Message))
+	attrs = append(h.resolve(nil, builtins), attrs...)
+
+	buf := make([]byte, 0, 1024)
+	buf = appendArrayHeader(buf, 3)
+	buf = appendString(buf, h.tag)
+	buf = appendEventTime(buf, t)
+	buf = appendMap(buf, attrs)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+// resolve returns the attrs that should be written for as, which
+// appear within groups. It calls ReplaceAttr on each non-group Attr,
+// discards empty Attrs and empty groups, and inlines groups with empty keys.
+func (h *Handler) resolve(groups []string, as []slog.Attr) []slog.Attr {
+	var res []slog.Attr
+	for _, a := range as {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			gs := groups
+			if a.Key != "" {
+				gs = append(gs[:len(gs):len(gs)], a.Key)
+			}
+			ras := h.resolve(gs, a.Value.Group())
+			if len(ras) == 0 {
+				continue
+			}
+			if a.Key == "" {
+				res = append(res, ras...)
+			} else {
+				res = append(res, slog.Attr{Key: a.Key, Value: slog.GroupValue(ras...)})
+			}
+			continue
+		}
+		if h.opts.ReplaceAttr != nil {
+			a = h.opts.ReplaceAttr(groups, a)
+			a.Value = a.Value.Resolve()
+		}
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// source returns the source location of pc.
+func source(pc uintptr) *slog.Source {
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	return &slog.Source{
+		Function: f.Function,
+		File:     f.File,
+		Line:     f.Line,
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+// eventTimeType is the MessagePack extension type of Fluentd's EventTime.
+const eventTimeType = 0
+
+// appendEventTime appends t as a Fluentd EventTime: a fixext 8 holding
+// big-endian 32-bit seconds and nanoseconds.
+func appendEventTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, 0xd7, eventTimeType)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(t.Unix()))
+	return binary.BigEndian.AppendUint32(buf, uint32(t.Nanosecond()))
+}
+
+// appendMap appends as, which must already be resolved, as a map.
+func appendMap(buf []byte, as []slog.Attr) []byte {
+	buf = appendMapHeader(buf, len(as))
+	for _, a := range as {
+		buf = appendString(buf, a.Key)
+		buf = appendValue(buf, a.Value)
+	}
+	return buf
+}
+
+func appendValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendString(buf, v.String())
+	case slog.KindInt64:
+		return appendInt(buf, v.Int64())
+	case slog.KindUint64:
+		return appendUint(buf, v.Uint64())
+	case slog.KindFloat64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v.Float64()))
+	case slog.KindBool:
+		if v.Bool() {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case slog.KindDuration:
+		return appendInt(buf, v.Duration().Nanoseconds())
+	case slog.KindTime:
+		return appendString(buf, v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		return appendMap(buf, v.Group())
+	case slog.KindAny:
+		return appendAny(buf, v.Any())
+	default:
+		return appendString(buf, v.String())
+	}
+}
+
+func appendAny(buf []byte, x any) []byte {
+	switch x := x.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case []byte:
+		return appendBinary(buf, x)
+	case *slog.Source:
+		return appendMap(buf, []slog.Attr{
+			slog.String("function", x.Function),
+			slog.String("file", x.File),
+			slog.Int("line", x.Line),
+		})
+	case error:
+		return appendString(buf, x.Error())
+	case encoding.TextMarshaler:
+		data, err := x.MarshalText()
+		if err != nil {
+			return appendString(buf, err.Error())
+		}
+		return appendString(buf, string(data))
+	default:
+		return appendString(buf, fmt.Sprint(x))
+	}
+}
+
+func appendInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(buf, uint64(i))
+	case i >= -32:
+		return append(buf, byte(i))
+	case i >= math.MinInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(i))
+	}
+}
+
+func appendUint(buf []byte, u uint64) []byte {
+	switch {
+	case u <= math.MaxInt8:
+		return append(buf, byte(u))
+	case u <= math.MaxUint8:
+		return append(buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), u)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendBinary(buf []byte, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc6), uint32(n))
+	}
+	return append(buf, b...)
+}
+
+func appendArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n))
+	}
+}
+
+func appendMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(n))
+	}
+}
@@ -0,0 +1,221 @@
+package msgpack
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlertest"
+)
+
+var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 5, time.UTC)
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	var h slog.Handler = New(&buf, "app", nil)
+	h = h.WithAttrs([]slog.Attr{slog.Int("a", 1)}).WithGroup("g").WithGroup("h")
+	r := slog.NewRecord(testTime, slog.LevelWarn, "m", 0)
+	r.AddAttrs(slog.Bool("b", true), slog.Group("", slog.Int("c", -1)))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "93" + // array of 3
+		"a3617070" + // "app"
+		"d700" + "642a258b" + "00000005" + // EventTime
+		"84" + // map of 4
+		"a56c6576656c" + "a45741524e" + // "level": "WARN"
+		"a36d7367" + "a16d" + // "msg": "m"
+		"a161" + "01" + // "a": 1
+		"a167" + "81" + "a168" + "82" + // "g": {"h": {
+		"a162" + "c3" + // "b": true
+		"a163" + "ff" // "c": -1
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestEmptyGroup(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+	h := New(&buf, "t", opts).WithGroup("g")
+	r := slog.NewRecord(testTime, slog.LevelInfo, "", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "93" + "a174" + "d700642a258b00000005" + "81" + "a36d7367" + "a0"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestInts(t *testing.T) {
+	for _, test := range []struct {
+		in   int64
+		want string
+	}{
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-200, "d1ff38"},
+		{70000, "ce00011170"},
+		{-70000, "d2fffeee90"},
+	} {
+		got := hex.EncodeToString(appendInt(nil, test.in))
+		if got != test.want {
+			t.Errorf("%d: got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	handlertest.Suite{
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			return New(w, "tag", opts)
+		},
+		Parse: parseMessages,
+		// Every message has a time, so the time can be neither zero nor removed.
+		Skip: []string{"zero time", "ReplaceAttr remove built-ins"},
+	}.Run(t)
+}
+
+// parseMessages parses a sequence of forward-protocol messages
+// for handlertest.
+func parseMessages(data []byte) ([]map[string]any, error) {
+	var ms []map[string]any
+	for len(data) > 0 {
+		v, rest, err := decodeValue(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+		msg, ok := v.([]any)
+		if !ok || len(msg) != 3 {
+			return nil, fmt.Errorf("bad message %v", v)
+		}
+		m, ok := msg[2].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("bad record %v", msg[2])
+		}
+		if t := msg[1].(time.Time); !t.IsZero() {
+			m[slog.TimeKey] = t
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// decodeValue decodes the subset of MessagePack written by Handler.
+func decodeValue(data []byte) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	b, data := data[0], data[1:]
+	n := func(size int) int {
+		switch size {
+		case 1:
+			return int(data[0])
+		case 2:
+			return int(binary.BigEndian.Uint16(data))
+		default:
+			return int(binary.BigEndian.Uint32(data))
+		}
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), data, nil
+	case b >= 0xe0:
+		return int64(int8(b)), data, nil
+	case b&0xe0 == 0xa0:
+		l := int(b & 0x1f)
+		return string(data[:l]), data[l:], nil
+	case b == 0xd9 || b == 0xda || b == 0xdb:
+		size := 1 << (b - 0xd9)
+		l := n(size)
+		return string(data[size : size+l]), data[size+l:], nil
+	case b&0xf0 == 0x80:
+		return decodeMap(data, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return decodeArray(data, int(b&0x0f))
+	case b == 0xc0:
+		return nil, data, nil
+	case b == 0xc2:
+		return false, data, nil
+	case b == 0xc3:
+		return true, data, nil
+	case b == 0xcc:
+		return int64(data[0]), data[1:], nil
+	case b == 0xcd:
+		return int64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case b == 0xce:
+		return int64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case b == 0xcf:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	case b == 0xd0:
+		return int64(int8(data[0])), data[1:], nil
+	case b == 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(data))), data[2:], nil
+	case b == 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(data))), data[4:], nil
+	case b == 0xd3:
+		return int64(binary.BigEndian.Uint64(data)), data[8:], nil
+	case b == 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+	case b == 0xd7 && data[0] == eventTimeType:
+		sec := binary.BigEndian.Uint32(data[1:])
+		nsec := binary.BigEndian.Uint32(data[5:])
+		return time.Unix(int64(sec), int64(nsec)), data[9:], nil
+	case b == 0xde:
+		return decodeMap(data[2:], n(2))
+	case b == 0xdc:
+		return decodeArray(data[2:], n(2))
+	default:
+		return nil, nil, fmt.Errorf("unsupported byte %#x", b)
+	}
+}
+
+func decodeMap(data []byte, n int) (any, []byte, error) {
+	m := map[string]any{}
+	for i := 0; i < n; i++ {
+		k, rest, err := decodeValue(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, rest, err := decodeValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[k.(string)] = v
+		data = rest
+	}
+	return m, data, nil
+}
+
+func decodeArray(data []byte, n int) (any, []byte, error) {
+	var a []any
+	for i := 0; i < n; i++ {
+		v, rest, err := decodeValue(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		a = append(a, v)
+		data = rest
+	}
+	return a, data, nil
+}
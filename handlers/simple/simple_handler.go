@@ -4,10 +4,20 @@ import (
 	"context"
 	"log/slog"
 	"slices"
+	"sync"
 
+	"github.com/jba/slog/internal/frames"
 	"github.com/jba/slog/withsupport"
 )
 
+// Handler returns a slog.Handler that calls handle with each record,
+// after adding the Attrs and groups of WithAttrs and WithGroup to it.
+//
+// If opts.AddSource is true, an Attr with the key [slog.SourceKey] and a
+// *slog.Source value is added first. If opts.ReplaceAttr is non-nil, it
+// is called on the source Attr and on each non-group Attr, with the
+// groups that contain it. The built-in time, level and message are fields
+// of the record, so they are left to handle.
 func Handler(handle func(slog.Record) error, opts slog.HandlerOptions) slog.Handler {
 	return &simpleHandler{opts, handle, nil}
 }
@@ -38,22 +48,110 @@ func (h *simpleHandler) WithAttrs(as []slog.Attr) slog.Handler {
 	return &h2
 }
 
+// scratch holds slices that Handle reuses across records.
+type scratch struct {
+	attrs    []slog.Attr // the Attrs of the record being built
+	replaced []slog.Attr // the result of ReplaceAttr on a WithAttrs call's Attrs
+	groups   []string
+}
+
+var scratchPool = sync.Pool{New: func() any { return new(scratch) }}
+
+// maxScratch is the largest slice capacity that is returned to scratchPool.
+const maxScratch = 1024
+
 func (h *simpleHandler) Handle(ctx context.Context, r slog.Record) error {
-	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	s := scratchPool.Get().(*scratch)
+	r2 := h.newRecord(r, s)
+	if cap(s.attrs) <= maxScratch && cap(s.replaced) <= maxScratch {
+		// r2 has copies of the Attrs in s, so s can be reused
+		// before r2 is handled. Clear the slices so they don't
+		// keep the values of r alive.
+		clear(s.attrs[:cap(s.attrs)])
+		clear(s.replaced[:cap(s.replaced)])
+		scratchPool.Put(s)
+	}
+	return h.handle(r2)
+}
 
-	var attrs []slog.Attr
+// newRecord returns a copy of r with the source and the Attrs and groups
+// of WithAttrs and WithGroup added, using the slices of s for temporary
+// storage. The members of each group are copied to a new slice, because
+// the group's Value retains it.
+func (h *simpleHandler) newRecord(r slog.Record, s *scratch) slog.Record {
+	rep := h.opts.ReplaceAttr != nil
+	var groups []string
+	if rep {
+		s.groups = h.appendGroups(s.groups[:0])
+		groups = s.groups
+	}
+	attrs := s.attrs[:0]
 	r.Attrs(func(a slog.Attr) bool { attrs = append(attrs, a); return true })
+	if rep {
+		attrs = h.replaceAttrs(attrs[:0], groups, attrs)
+	}
 	for g := h.goa; g != nil; g = g.Next {
 		if g.Group != "" {
-			anys := make([]any, len(attrs))
-			for i, a := range attrs {
-				anys[i] = a
-			}
-			attrs = []slog.Attr{slog.Group(g.Group, anys...)}
+			groups = groups[:max(len(groups)-1, 0)]
+			v := slog.GroupValue(slices.Clone(attrs)...)
+			attrs = append(attrs[:0], slog.Attr{Key: g.Group, Value: v})
+		} else if rep {
+			s.replaced = h.replaceAttrs(s.replaced[:0], groups, g.Attrs)
+			attrs = slices.Insert(attrs, 0, s.replaced...)
 		} else {
-			attrs = append(slices.Clip(g.Attrs), attrs...)
+			attrs = slices.Insert(attrs, 0, g.Attrs...)
 		}
 	}
+	if h.opts.AddSource && r.PC != 0 {
+		f := frames.Frame(r.PC)
+		src := slog.Any(slog.SourceKey, &slog.Source{Function: f.Function, File: f.File, Line: f.Line})
+		s.replaced = h.replaceAttrs(s.replaced[:0], nil, []slog.Attr{src})
+		attrs = slices.Insert(attrs, 0, s.replaced...)
+	}
+	s.attrs = attrs
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
 	r2.AddAttrs(attrs...)
-	return h.handle(r2)
+	return r2
+}
+
+// appendGroups appends the groups of h.goa to groups, outermost first.
+func (h *simpleHandler) appendGroups(groups []string) []string {
+	for g := h.goa; g != nil; g = g.Next {
+		if g.Group != "" {
+			groups = append(groups, g.Group)
+		}
+	}
+	slices.Reverse(groups)
+	return groups
+}
+
+// replaceAttrs appends to dst the result of calling opts.ReplaceAttr on
+// each non-group Attr in as, which are in groups, and returns the
+// extended slice. Attrs that ReplaceAttr replaces with non-group Attrs
+// with empty keys are removed. If opts.ReplaceAttr is nil, the Attrs are
+// appended unchanged. dst may share its array with as, provided that
+// as begins at or after len(dst).
+func (h *simpleHandler) replaceAttrs(dst []slog.Attr, groups []string, as []slog.Attr) []slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return append(dst, as...)
+	}
+	groups = slices.Clip(groups)
+	for _, a := range as {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			gs := groups
+			if a.Key != "" {
+				gs = append(gs, a.Key)
+			}
+			a.Value = slog.GroupValue(h.replaceAttrs(nil, gs, a.Value.Group())...)
+		} else {
+			a = h.opts.ReplaceAttr(groups, a)
+			a.Value = a.Value.Resolve()
+			if a.Key == "" && a.Value.Kind() != slog.KindGroup {
+				continue
+			}
+		}
+		dst = append(dst, a)
+	}
+	return dst
 }
@@ -2,10 +2,16 @@ package simple
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jba/slog/handlertest"
 )
 
 func newHandle(w io.Writer) func(slog.Record) error {
@@ -59,3 +65,121 @@ func Test(t *testing.T) {
 		t.Errorf("got\n%s\nwant\n%s", got, want)
 	}
 }
+
+func TestReplaceAttrAndSource(t *testing.T) {
+	var buf bytes.Buffer
+	opts := slog.HandlerOptions{
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.SourceKey:
+				s := a.Value.Any().(*slog.Source)
+				return slog.String(a.Key, filepath.Base(s.File))
+			case "secret":
+				return slog.Attr{}
+			}
+			return slog.String(a.Key, strings.Join(groups, "."))
+		},
+	}
+	logger := slog.New(Handler(newHandle(&buf), opts))
+	logger.With("a", 1).
+		WithGroup("G").
+		With("b", 2, "secret", 3).
+		WithGroup("H").
+		Info("msg", "c", 3, slog.Group("I", "d", 4))
+	got := buf.String()
+	want := `level=INFO msg="msg" source=simple_handler_test.go a= (G) b=G (H) c=G.H (I) d=G.H.I`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRetainedRecords(t *testing.T) {
+	// Records kept by the handle function are unaffected by later records,
+	// even though Handle reuses its slices.
+	var rs []slog.Record
+	logger := slog.New(Handler(func(r slog.Record) error {
+		rs = append(rs, r.Clone())
+		return nil
+	}, slog.HandlerOptions{})).With("a", 1).WithGroup("G")
+	for i := 0; i < 3; i++ {
+		logger.With("b", i).Info("msg", "c", i)
+	}
+	for i, r := range rs {
+		var buf bytes.Buffer
+		if err := newHandle(&buf)(r); err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`level=INFO msg="msg" a=1 (G) b=%d c=%[1]d`, i)
+		if got := buf.String(); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	h := Handler(func(slog.Record) error { return nil }, slog.HandlerOptions{}).
+		WithAttrs([]slog.Attr{slog.Int("a", 1)}).
+		WithAttrs([]slog.Attr{slog.Int("b", 2)}).(*simpleHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Int("c", 3))
+	// Once its slices have grown, a scratch can be reused without allocating.
+	var s scratch
+	h.newRecord(r, &s)
+	got := testing.AllocsPerRun(10, func() { h.newRecord(r, &s) })
+	if got != 0 {
+		t.Errorf("got %.1f allocs, want 0", got)
+	}
+}
+
+// BenchmarkWith measures loggers with many calls to With and WithGroup.
+func BenchmarkWith(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		opts slog.HandlerOptions
+	}{
+		{"plain", slog.HandlerOptions{}},
+		{"ReplaceAttr", slog.HandlerOptions{
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr { return a },
+		}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			logger := slog.New(Handler(func(r slog.Record) error {
+				r.Attrs(func(slog.Attr) bool { return true })
+				return nil
+			}, test.opts))
+			for i := 0; i < 5; i++ {
+				logger = logger.With("id", "abc", "depth", i).WithGroup("layer")
+			}
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logger.LogAttrs(ctx, slog.LevelInfo, "msg", slog.Int("status", 200), slog.Bool("ok", true))
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	handlertest.TestHandler(t, func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+		if opts == nil {
+			opts = &slog.HandlerOptions{}
+		}
+		// The simple handler applies ReplaceAttr to all but the built-in
+		// Attrs, which are left to the handle function.
+		jopts := &slog.HandlerOptions{Level: opts.Level}
+		if rep := opts.ReplaceAttr; rep != nil {
+			jopts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return rep(groups, a)
+				}
+				return a
+			}
+		}
+		jh := slog.NewJSONHandler(w, jopts)
+		return Handler(func(r slog.Record) error {
+			return jh.Handle(context.Background(), r)
+		}, *opts)
+	}, handlertest.ParseJSON)
+}
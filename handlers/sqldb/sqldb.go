@@ -0,0 +1,384 @@
+// Package sqldb provides a slog.Handler that writes records to a table
+// of a database like PostgreSQL or ClickHouse, in bulk INSERT statements.
+//
+// The caller opens the database with the driver of their choice:
+//
+//	db, err := sql.Open("pgx", dsn)
+//	...
+//	q, err := spill.Open("/var/spool/logs", nil) // github.com/jba/slog/writers/spill
+//	...
+//	h, err := sqldb.Options{Table: "logs", Spill: q}.New(db)
+//	...
+//	defer h.Close()
+//	logger := slog.New(h)
+//
+// The table must exist. By default it has the columns
+//
+//	time   the record's time, as a time.Time
+//	level  the numeric level, as an int64
+//	msg    the message
+//	source file:line, or NULL if Options.AddSource is false
+//	attrs  the Attrs as a JSON object, for a JSONB or JSON column
+//
+// For example, in PostgreSQL:
+//
+//	CREATE TABLE logs (time timestamptz, level integer, msg text, source text, attrs jsonb)
+//
+// Records are written in batches by a background goroutine, each batch in
+// a single INSERT with a row of values for each record. If a batch can't
+// be written and Options.Spill is set, it is saved there, and written
+// with a later batch. Otherwise it is kept in memory, up to
+// Options.MaxQueued records, to be tried again.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jba/slog/internal/batch"
+	"github.com/jba/slog/slogmap"
+	"github.com/jba/slog/withsupport"
+	"github.com/jba/slog/writers/spill"
+)
+
+// Options are options for a [Handler].
+type Options struct {
+	// Level reports the minimum level to log.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+
+	// If AddSource is true, the source column holds the file and line
+	// of the call that logged the record.
+	AddSource bool
+
+	// Table is the name of the table, which may be qualified, as in
+	// "db.logs". It is written into the INSERT statement as is.
+	// If empty, "logs" is used.
+	Table string
+
+	// Columns are the names of the table's columns.
+	Columns Columns
+
+	// Placeholders selects the parameter syntax of the database.
+	// The default, Question, is that of ClickHouse, MySQL and SQLite.
+	// Use Dollar for PostgreSQL.
+	Placeholders Placeholders
+
+	// BatchSize is the largest number of records in one INSERT.
+	// When that many records are waiting, they are written.
+	// If zero, 1000 is used.
+	// PostgreSQL allows at most 65535 parameters in a statement,
+	// so BatchSize times the number of columns must not exceed that.
+	BatchSize int
+
+	// FlushInterval is the longest that a record waits to be written.
+	// If zero, one second is used.
+	FlushInterval time.Duration
+
+	// Spill, if non-nil, holds batches that couldn't be written, each
+	// as one record, until a later flush writes them. Batches left in
+	// Spill when the program stops are written by a later Handler that
+	// uses the same directory. If Spill is nil, those batches are kept
+	// in memory instead. The Handler does not close Spill.
+	Spill *spill.Queue
+
+	// MaxQueued is the most records that wait in memory to be written.
+	// If more wait, because batches can't be written and Spill is nil,
+	// the oldest are dropped. [Handler.Dropped] counts them.
+	// If zero, ten times BatchSize is used.
+	MaxQueued int
+
+	// OnError, if non-nil, is called with errors from writing batches in
+	// the background. Errors from Flush and Close are returned instead.
+	OnError func(error)
+}
+
+// Columns are the names of the columns of the table.
+// An empty name uses the default. A name of "-" omits the column.
+type Columns struct {
+	Time   string // default "time"
+	Level  string // default "level"
+	Msg    string // default "msg"
+	Source string // default "source"
+	Attrs  string // default "attrs"
+}
+
+// names returns the names of the columns, in the order of a row,
+// with "" for omitted ones.
+func (c Columns) names() [5]string {
+	ns := [5]string{c.Time, c.Level, c.Msg, c.Source, c.Attrs}
+	for i, def := range [5]string{"time", "level", "msg", "source", "attrs"} {
+		switch ns[i] {
+		case "":
+			ns[i] = def
+		case "-":
+			ns[i] = ""
+		}
+	}
+	return ns
+}
+
+// Placeholders is the syntax of statement parameters.
+type Placeholders int
+
+const (
+	Question Placeholders = iota // ?
+	Dollar                       // $1, $2, ...
+)
+
+// maxDollarParams is the most parameters PostgreSQL allows in a statement.
+const maxDollarParams = 65535
+
+// A Handler writes records to a database table.
+type Handler struct {
+	opts Options
+	b    *batch.Writer[row] // shared by clones
+	goa  *withsupport.GroupOrAttrs
+}
+
+// New returns a Handler that writes to db with the default options.
+func New(db *sql.DB) (*Handler, error) {
+	return Options{}.New(db)
+}
+
+// New returns a Handler that writes to db.
+// It returns an error if the options are invalid.
+func (opts Options) New(db *sql.DB) (*Handler, error) {
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	cols := opts.Columns.names()
+	ncols := 0
+	for _, c := range cols {
+		if c != "" {
+			ncols++
+		}
+	}
+	if ncols == 0 {
+		return nil, errors.New("sqldb: all columns are omitted")
+	}
+	switch opts.Placeholders {
+	case Question:
+	case Dollar:
+		if n := opts.BatchSize * ncols; n > maxDollarParams {
+			return nil, fmt.Errorf("sqldb: BatchSize %d with %d columns needs %d parameters, more than the %d allowed",
+				opts.BatchSize, ncols, n, maxDollarParams)
+		}
+	default:
+		return nil, fmt.Errorf("sqldb: unknown Placeholders value %d", opts.Placeholders)
+	}
+	w := &writer{db: db, opts: opts, cols: cols}
+	bopts := batch.Options[row]{
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		MaxQueued:     opts.MaxQueued,
+		Write:         w.insert,
+		OnError:       opts.OnError,
+	}
+	if opts.Spill != nil {
+		bopts.Spill = w.spill
+		bopts.Unspill = w.unspill
+	}
+	return &Handler{opts: opts, b: batch.New(bopts)}, nil
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	return &h2
+}
+
+// Handle queues a row for r. It returns ErrClosed if the Handler is
+// closed, and no other error.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var source *string
+	if h.opts.AddSource && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		s := f.File + ":" + strconv.Itoa(f.Line)
+		source = &s
+	}
+	js, err := json.Marshal(slogmap.StringErrors(slogmap.FromAttrs(h.goa.Nest(withsupport.RecordAttrs(r)))))
+	if err != nil {
+		js, _ = json.Marshal(map[string]string{"!ERROR": err.Error()})
+	}
+	if !h.b.Add(row{
+		Time:   r.Time,
+		Level:  int64(r.Level),
+		Msg:    r.Message,
+		Source: source,
+		Attrs:  string(js),
+	}) {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Flush writes the queued records, and any spilled batches.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.b.Flush(ctx)
+}
+
+// Close writes the queued records and stops the background goroutine.
+// It does not close the database. Records handled after Close are
+// rejected. Records that Close can't write or spill are dropped.
+// Close waits as long as writing takes; use [Handler.CloseContext]
+// to limit that.
+func (h *Handler) Close() error {
+	return h.b.Close()
+}
+
+// CloseContext is like Close, but stops writing when ctx is done, and
+// spills or drops the records that are left.
+func (h *Handler) CloseContext(ctx context.Context) error {
+	return h.b.CloseContext(ctx)
+}
+
+// Dropped returns the number of records that were dropped because they
+// couldn't be written or spilled.
+func (h *Handler) Dropped() int64 {
+	return h.b.Dropped()
+}
+
+// ErrClosed is returned by Handle after Close is called.
+var ErrClosed = errors.New("sqldb: handler is closed")
+
+// A row holds the column values of a record.
+// A spilled batch is a JSON array of rows.
+type row struct {
+	Time   time.Time
+	Level  int64
+	Msg    string
+	Source *string
+	Attrs  string
+}
+
+func (r row) values() [5]any {
+	var source any
+	if r.Source != nil {
+		source = *r.Source
+	}
+	return [5]any{r.Time, r.Level, r.Msg, source, r.Attrs}
+}
+
+// A writer writes batches of rows to the database or to opts.Spill.
+type writer struct {
+	db   *sql.DB
+	opts Options
+	cols [5]string
+}
+
+// insert writes rows in a single INSERT statement.
+func (w *writer) insert(ctx context.Context, rows []row) error {
+	var cols []string
+	for _, c := range w.cols {
+		if c != "" {
+			cols = append(cols, c)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", w.opts.Table, strings.Join(cols, ", "))
+	args := make([]any, 0, len(rows)*len(cols))
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		first := true
+		for j, v := range r.values() {
+			if w.cols[j] == "" {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			args = append(args, v)
+			if w.opts.Placeholders == Dollar {
+				b.WriteByte('$')
+				b.WriteString(strconv.Itoa(len(args)))
+			} else {
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+	}
+	if _, err := w.db.ExecContext(ctx, b.String(), args...); err != nil {
+		return fmt.Errorf("sqldb: writing %d records: %w", len(rows), err)
+	}
+	return nil
+}
+
+// spill saves rows in opts.Spill, as one record.
+func (w *writer) spill(rows []row) error {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return fmt.Errorf("sqldb: spilling: %w", err)
+	}
+	if err := w.opts.Spill.Push(data); err != nil {
+		return fmt.Errorf("sqldb: spilling: %w", err)
+	}
+	return nil
+}
+
+// unspill writes the spilled batches, oldest first, removing each one
+// that is written. It stops at the first failure to write.
+// A batch that is written by more than one INSERT may have some of its
+// rows written twice, if a later INSERT fails.
+//
+// A batch that can't be decoded is removed, so that it doesn't block
+// the ones after it, and reported in the returned error.
+func (w *writer) unspill(ctx context.Context) error {
+	var errs []error
+	for {
+		data, err := w.opts.Spill.Peek()
+		if errors.Is(err, spill.ErrEmpty) {
+			return errors.Join(errs...)
+		}
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("sqldb: %w", err))...)
+		}
+		var rows []row
+		if err := json.Unmarshal(data, &rows); err != nil {
+			errs = append(errs, fmt.Errorf("sqldb: reading spilled batch: %w", err))
+			w.opts.Spill.Remove()
+			continue
+		}
+		for len(rows) > 0 {
+			n := min(len(rows), w.opts.BatchSize)
+			if err := w.insert(ctx, rows[:n]); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			rows = rows[n:]
+		}
+		w.opts.Spill.Remove()
+	}
+}
@@ -0,0 +1,458 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/writers/spill"
+)
+
+func TestInsert(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "default",
+			want: "INSERT INTO logs (time, level, msg, source, attrs) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)",
+		},
+		{
+			name: "columns",
+			opts: Options{
+				Table:        "app.logs",
+				Columns:      Columns{Time: "ts", Source: "-", Attrs: "data"},
+				Placeholders: Dollar,
+			},
+			want: "INSERT INTO app.logs (ts, level, msg, data) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db, fdb := openFake(t)
+			test.opts.FlushInterval = time.Hour
+			h, err := test.opts.New(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := slog.New(h)
+			l.Info("a")
+			l.Info("b")
+			if err := h.Close(); err != nil {
+				t.Fatal(err)
+			}
+			execs := fdb.get()
+			if len(execs) != 1 {
+				t.Fatalf("got %d statements, want 1", len(execs))
+			}
+			if got := execs[0].query; got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOptionsErrors(t *testing.T) {
+	db, _ := openFake(t)
+	for _, test := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Placeholders: 7}, "unknown Placeholders"},
+		{Options{Placeholders: Dollar, BatchSize: 20000}, "more than the 65535 allowed"},
+		{Options{Columns: Columns{Time: "-", Level: "-", Msg: "-", Source: "-", Attrs: "-"}}, "all columns"},
+	} {
+		h, err := test.opts.New(db)
+		if err == nil {
+			h.Close()
+			t.Errorf("%+v: got nil, want error", test.opts)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%+v: got %q, want it to contain %q", test.opts, err, test.want)
+		}
+	}
+	// The default BatchSize fits.
+	h, err := Options{Placeholders: Dollar}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+}
+
+func TestCloseContext(t *testing.T) {
+	db, fdb := openFake(t)
+	h, err := Options{BatchSize: 1, FlushInterval: time.Hour}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdb.setStall(true)
+	l := slog.New(h)
+	l.Info("a") // starts a background INSERT, which stalls
+	l.Info("b")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.CloseContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if got := h.Dropped(); got != 2 {
+		t.Errorf("dropped %d, want 2", got)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	db, fdb := openFake(t)
+	h, err := Options{BatchSize: 2, FlushInterval: time.Hour, AddSource: true}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(h).With("user", "pat").WithGroup("req")
+	l.Info("one", "id", 7, "err", errors.New("e"))
+	l.Warn("two")
+	// The batch is full, so it is written in the background.
+	fdb.wait(t, 1)
+	l.Error("three")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(context.Background(), slog.Record{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: got %v, want ErrClosed", err)
+	}
+
+	execs := fdb.get()
+	if len(execs) != 2 {
+		t.Fatalf("got %d statements, want 2", len(execs))
+	}
+	if got, want := len(execs[0].args), 10; got != want {
+		t.Fatalf("got %d args, want %d", got, want)
+	}
+	args := execs[0].args
+	if tm, ok := args[0].(time.Time); !ok || time.Since(tm) > time.Minute {
+		t.Errorf("time: got %v", args[0])
+	}
+	if got, want := args[1], int64(slog.LevelInfo); got != want {
+		t.Errorf("level: got %v, want %v", got, want)
+	}
+	if args[2] != "one" {
+		t.Errorf("msg: got %v", args[2])
+	}
+	if s, _ := args[3].(string); !strings.Contains(s, "sqldb_test.go:") {
+		t.Errorf("source: got %v", args[3])
+	}
+	if got, want := args[4], `{"req":{"err":"e","id":7},"user":"pat"}`; got != want {
+		t.Errorf("attrs: got %v, want %v", got, want)
+	}
+	if got, want := execs[1].args[2], "three"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpill(t *testing.T) {
+	db, fdb := openFake(t)
+	q := openSpill(t)
+	var mu sync.Mutex
+	var bgErrs []error
+	h, err := Options{
+		FlushInterval: time.Hour,
+		Spill:         q,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			bgErrs = append(bgErrs, err)
+		},
+	}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l := slog.New(h)
+
+	fdb.setFail(true)
+	l.Info("a", "x", 1)
+	l.Info("b")
+	if err := h.Flush(ctx); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if q.Size() == 0 {
+		t.Fatal("nothing was spilled")
+	}
+
+	// The spilled records are written after the next successful batch.
+	fdb.setFail(false)
+	l.Info("c")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Size(); got != 0 {
+		t.Errorf("got %d spilled bytes, want 0", got)
+	}
+	var msgs []string
+	for _, e := range fdb.get() {
+		for i := 2; i < len(e.args); i += 5 {
+			msgs = append(msgs, e.args[i].(string))
+		}
+	}
+	if got, want := strings.Join(msgs, " "), "c a b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The spilled values have the same types.
+	args := fdb.get()[1].args
+	if _, ok := args[0].(time.Time); !ok {
+		t.Errorf("time: got %T", args[0])
+	}
+	if _, ok := args[1].(int64); !ok {
+		t.Errorf("level: got %T", args[1])
+	}
+	if args[3] != nil {
+		t.Errorf("source: got %v, want nil", args[3])
+	}
+	if got, want := args[4], `{"x":1}`; got != want {
+		t.Errorf("attrs: got %v, want %v", got, want)
+	}
+	if len(bgErrs) != 0 {
+		t.Errorf("background errors: %v", bgErrs)
+	}
+}
+
+func TestSpillRecord(t *testing.T) {
+	// A spilled batch is a JSON array of rows.
+	src := "f.go:1"
+	r := row{Time: time.Date(2023, 4, 3, 1, 2, 3, 4, time.UTC), Level: 4, Msg: "m", Source: &src, Attrs: `{"a":1}`}
+	w := &writer{opts: Options{Spill: openSpill(t)}}
+	if err := w.spill([]row{r, r}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := w.opts.Spill.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ms []map[string]any
+	if err := json.Unmarshal(data, &ms); err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0]["Msg"] != "m" || ms[0]["Source"] != src {
+		t.Errorf("got %v", ms)
+	}
+	var rows []row
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !rows[1].Time.Equal(r.Time) || *rows[1].Source != src || rows[1].Attrs != r.Attrs {
+		t.Errorf("got %+v, want two of %+v", rows, r)
+	}
+}
+
+func TestUnspillBadBatch(t *testing.T) {
+	db, fdb := openFake(t)
+	q := openSpill(t)
+	w := &writer{db: db, opts: Options{Spill: q, BatchSize: 10}, cols: Columns{}.names()}
+	// The bad batch comes first, so it would block the good one.
+	if err := q.Push([]byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.spill([]row{{Msg: "good"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := w.unspill(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "reading spilled batch") {
+		t.Errorf("got %v, want error about reading a spilled batch", err)
+	}
+	if execs := fdb.get(); len(execs) != 1 || execs[0].args[2] != "good" {
+		t.Errorf("got %+v, want the good row", execs)
+	}
+	// The bad batch isn't tried again.
+	if err := w.unspill(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if got := q.Size(); got != 0 {
+		t.Errorf("got %d spilled bytes, want 0", got)
+	}
+}
+
+func openSpill(t *testing.T) *spill.Queue {
+	t.Helper()
+	q, err := spill.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestRequeue(t *testing.T) {
+	db, fdb := openFake(t)
+	h, err := Options{FlushInterval: time.Hour, MaxQueued: 2}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l := slog.New(h)
+	fdb.setFail(true)
+	l.Info("a")
+	l.Info("b")
+	if err := h.Flush(ctx); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	l.Info("c")
+	if got, want := h.Dropped(), int64(1); got != want {
+		t.Errorf("dropped: got %d, want %d", got, want)
+	}
+	fdb.setFail(false)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, e := range fdb.get() {
+		for i := 2; i < len(e.args); i += 5 {
+			msgs = append(msgs, e.args[i].(string))
+		}
+	}
+	if got, want := strings.Join(msgs, " "), "b c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// A fake database/sql driver that records the statements it executes.
+
+type fakeDB struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	fail  bool
+	stall bool // statements wait for their context to be done
+	execs []exec
+}
+
+type exec struct {
+	query string
+	args  []any
+}
+
+func (d *fakeDB) exec(e exec) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fail {
+		return errors.New("fake failure")
+	}
+	d.execs = append(d.execs, e)
+	d.cond.Broadcast()
+	return nil
+}
+
+func (d *fakeDB) setStall(b bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stall = b
+}
+
+func (d *fakeDB) setFail(b bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fail = b
+}
+
+func (d *fakeDB) get() []exec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.execs
+}
+
+// wait waits until n statements have been executed.
+func (d *fakeDB) wait(t *testing.T, n int) {
+	t.Helper()
+	timer := time.AfterFunc(5*time.Second, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.cond.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.execs) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d statements", n)
+		}
+		d.cond.Wait()
+	}
+}
+
+var (
+	fakeMu  sync.Mutex
+	fakeDBs = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("sqldbfake", fakeDriver{})
+}
+
+// openFake opens a database that records to a new fakeDB.
+func openFake(t *testing.T) (*sql.DB, *fakeDB) {
+	d := &fakeDB{}
+	d.cond = sync.NewCond(&d.mu)
+	fakeMu.Lock()
+	fakeDBs[t.Name()] = d
+	fakeMu.Unlock()
+	db, err := sql.Open("sqldbfake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return &fakeConn{fakeDBs[name]}, nil
+}
+
+type fakeConn struct{ d *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.d, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	d     *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	as := make([]any, len(args))
+	for i, a := range args {
+		as[i] = a
+	}
+	if err := s.d.exec(exec{s.query, as}); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.d.mu.Lock()
+	stall := s.d.stall
+	s.d.mu.Unlock()
+	if stall {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return s.Exec(vs)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
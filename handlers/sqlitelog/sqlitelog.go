@@ -0,0 +1,342 @@
+// Package sqlitelog provides a slog.Handler that writes records to a
+// table in a SQLite database, for queryable logs without a log server.
+//
+// The caller opens the database with the SQLite driver of their choice:
+//
+//	db, err := sql.Open("sqlite", "logs.db") // modernc.org/sqlite
+//	...
+//	h, err := sqlitelog.Options{IndexedKeys: []string{"req.id"}}.New(db)
+//	...
+//	defer h.Close()
+//	logger := slog.New(h)
+//
+// Each record becomes a row with the columns
+//
+//	time   TEXT     the time in UTC, as 2006-01-02T15:04:05.000000000Z, which
+//	                sorts in time order and which SQLite's date functions accept;
+//	                the time of handling for records with a zero time
+//	level  INTEGER  the numeric level, so that "level >= 8" selects errors
+//	msg    TEXT
+//	source TEXT     file:line, or NULL if Options.AddSource is false
+//	attrs  TEXT     the Attrs as a JSON object, for SQLite's JSON functions
+//
+// followed by a column for each of Options.IndexedKeys.
+//
+// Records are written in batches, each in one transaction, by a
+// background goroutine. Call [Handler.Close] to write the last batch.
+package sqlitelog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jba/slog/internal/batch"
+	"github.com/jba/slog/slogmap"
+	"github.com/jba/slog/withsupport"
+)
+
+// Options are options for a [Handler].
+type Options struct {
+	// Level reports the minimum level to log.
+	// If nil, the Handler uses [slog.LevelInfo].
+	Level slog.Leveler
+
+	// If AddSource is true, the source column holds the file and line
+	// of the call that logged the record.
+	AddSource bool
+
+	// Table is the name of the table. It is created if it doesn't exist.
+	// If empty, "logs" is used.
+	Table string
+
+	// IndexedKeys are keys of Attrs that get their own indexed column,
+	// named by the key. Keys of Attrs in groups are joined with dots,
+	// as in "req.id". The Attrs are also kept in the attrs column.
+	// A key may not be the name of another column, ignoring case.
+	IndexedKeys []string
+
+	// BatchSize is the number of records that causes a batch to be
+	// written. If zero, 100 is used.
+	BatchSize int
+
+	// FlushInterval is the longest that a record waits to be written.
+	// If zero, one second is used.
+	FlushInterval time.Duration
+
+	// MaxQueued is the most records that wait to be written. When
+	// a batch can't be written, its records are kept to be tried again
+	// with the next one; if that makes more than MaxQueued records
+	// wait, the oldest are dropped. [Handler.Dropped] counts them.
+	// If zero, ten times BatchSize is used.
+	MaxQueued int
+
+	// Unless NoWAL is true, New puts the database in write-ahead-log
+	// mode, which lets readers query it while records are written.
+	NoWAL bool
+
+	// OnError, if non-nil, is called with errors from writing batches in
+	// the background. Errors from Flush and Close are returned instead.
+	OnError func(error)
+}
+
+// A Handler writes records to a SQLite table.
+type Handler struct {
+	opts Options
+	b    *batch.Writer[[]any] // shared by clones
+	goa  *withsupport.GroupOrAttrs
+}
+
+// New returns a Handler that writes to db with the default options.
+func New(db *sql.DB) (*Handler, error) {
+	return Options{}.New(db)
+}
+
+// New creates the table and indexes if necessary, and returns a Handler
+// that writes to db.
+func (opts Options) New(db *sql.DB) (*Handler, error) {
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = 10 * opts.BatchSize
+	}
+	if err := opts.checkIndexedKeys(); err != nil {
+		return nil, err
+	}
+	for _, stmt := range opts.schema() {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("sqlitelog: %s: %w", stmt, err)
+		}
+	}
+	w := &writer{db: db, insert: opts.insertStatement()}
+	b := batch.New(batch.Options[[]any]{
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		MaxQueued:     opts.MaxQueued,
+		Write:         w.write,
+		OnError:       opts.OnError,
+	})
+	return &Handler{opts: opts, b: b}, nil
+}
+
+// builtinColumns are the names of the columns that every table has.
+var builtinColumns = []string{"time", "level", "msg", "source", "attrs"}
+
+// checkIndexedKeys reports an error if an indexed key would make a
+// column with the same name as another. SQLite column names are not
+// case-sensitive.
+func (opts Options) checkIndexedKeys() error {
+	seen := map[string]bool{}
+	for _, c := range builtinColumns {
+		seen[c] = true
+	}
+	for _, k := range opts.IndexedKeys {
+		lk := strings.ToLower(k)
+		if seen[lk] {
+			return fmt.Errorf("sqlitelog: indexed key %q duplicates another column", k)
+		}
+		seen[lk] = true
+	}
+	return nil
+}
+
+// schema returns the statements that prepare the database.
+func (opts Options) schema() []string {
+	var stmts []string
+	if !opts.NoWAL {
+		stmts = append(stmts, "PRAGMA journal_mode=WAL")
+	}
+	table := quoteIdent(opts.Table)
+	cols := []string{"time TEXT NOT NULL", "level INTEGER NOT NULL", "msg TEXT NOT NULL", "source TEXT", "attrs TEXT NOT NULL"}
+	for _, k := range opts.IndexedKeys {
+		cols = append(cols, quoteIdent(k))
+	}
+	stmts = append(stmts,
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(cols, ", ")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (time)", quoteIdent(opts.Table+"_time"), table))
+	for _, k := range opts.IndexedKeys {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+			quoteIdent(opts.Table+"_"+k), table, quoteIdent(k)))
+	}
+	return stmts
+}
+
+func (opts Options) insertStatement() string {
+	cols := slices.Clone(builtinColumns)
+	for _, k := range opts.IndexedKeys {
+		cols = append(cols, quoteIdent(k))
+	}
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(opts.Table), strings.Join(cols, ", "), params)
+}
+
+// quoteIdent quotes a SQLite identifier.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	return &h2
+}
+
+// Handle queues a row for r. It returns ErrClosed if the Handler is
+// closed, and no other error.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var source any
+	if h.opts.AddSource && r.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		source = f.File + ":" + strconv.Itoa(f.Line)
+	}
+	m := slogmap.StringErrors(slogmap.FromAttrs(h.goa.Nest(withsupport.RecordAttrs(r))))
+	js, err := json.Marshal(m)
+	if err != nil {
+		js, _ = json.Marshal(map[string]string{"!ERROR": err.Error()})
+	}
+	t := r.Time
+	if t.IsZero() {
+		// The time column can't be empty, and a zero time would sort
+		// before every other row.
+		t = time.Now()
+	}
+	row := []any{formatTime(t), int64(r.Level), r.Message, source, string(js)}
+	for _, k := range h.opts.IndexedKeys {
+		row = append(row, lookup(m, k))
+	}
+	if !h.b.Add(row) {
+		return ErrClosed
+	}
+	return nil
+}
+
+// timeLayout has a fixed width, so that times in UTC compare as strings
+// in the same order as they do as times, and the index on the time
+// column can be used for ranges.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// lookup returns the value at the dotted path key in m, or nil.
+func lookup(m map[string]any, key string) any {
+	if v, ok := m[key]; ok {
+		return sqlValue(v)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] == '.' {
+			if sub, ok := m[key[:i]].(map[string]any); ok {
+				if v := lookup(sub, key[i+1:]); v != nil {
+					return v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sqlValue converts a value of a map made by slogmap.FromAttrs to one that
+// database/sql accepts.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case nil, string, int64, float64, bool:
+		return v
+	case uint64:
+		if v <= 1<<63-1 {
+			return int64(v)
+		}
+		return strconv.FormatUint(v, 10)
+	case time.Time:
+		return formatTime(v)
+	case time.Duration:
+		return int64(v)
+	case map[string]any:
+		js, _ := json.Marshal(v)
+		return string(js)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Flush writes the queued records.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.b.Flush(ctx)
+}
+
+// Close writes the queued records and stops the background goroutine.
+// It does not close the database. Records handled after Close are
+// rejected. Records that Close can't write are dropped.
+func (h *Handler) Close() error {
+	return h.b.Close()
+}
+
+// Dropped returns the number of records that were dropped because they
+// couldn't be written.
+func (h *Handler) Dropped() int64 {
+	return h.b.Dropped()
+}
+
+// ErrClosed is returned by Handle after Close is called.
+var ErrClosed = errors.New("sqlitelog: handler is closed")
+
+// A writer writes batches of rows to the database.
+type writer struct {
+	db     *sql.DB
+	insert string
+}
+
+// write writes rows in a single transaction.
+func (w *writer) write(ctx context.Context, rows [][]any) (err error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlitelog: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("sqlitelog: writing %d records: %w", len(rows), err)
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, w.insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
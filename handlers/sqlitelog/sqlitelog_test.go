@@ -0,0 +1,407 @@
+package sqlitelog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchema(t *testing.T) {
+	db, rec := openFake(t)
+	h, err := Options{IndexedKeys: []string{"req.id"}}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	want := []string{
+		`PRAGMA journal_mode=WAL`,
+		`CREATE TABLE IF NOT EXISTS "logs" (time TEXT NOT NULL, level INTEGER NOT NULL, msg TEXT NOT NULL, source TEXT, attrs TEXT NOT NULL, "req.id")`,
+		`CREATE INDEX IF NOT EXISTS "logs_time" ON "logs" (time)`,
+		`CREATE INDEX IF NOT EXISTS "logs_req.id" ON "logs" ("req.id")`,
+	}
+	if got := rec.statements(); !slicesEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestIndexedKeyConflicts(t *testing.T) {
+	for _, keys := range [][]string{
+		{"time"},
+		{"req.id", "Level"},
+		{"a", "A"},
+	} {
+		db, rec := openFake(t)
+		_, err := Options{IndexedKeys: keys}.New(db)
+		if err == nil || !strings.Contains(err.Error(), "duplicates another column") {
+			t.Errorf("%q: got %v, want a duplicate-column error", keys, err)
+		}
+		if got := rec.statements(); len(got) != 0 {
+			t.Errorf("%q: executed %q", keys, got)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	db, rec := openFake(t)
+	h, err := Options{
+		IndexedKeys:   []string{"req.id", "user"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		AddSource:     true,
+		NoWAL:         true,
+	}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.reset()
+	l := slog.New(h).With("user", "pat").WithGroup("req")
+	l.Info("one", "id", 7, "err", errors.New("e"))
+	l.Warn("two")
+	// The batch is full, so it is written in the background.
+	rec.wait(t, 2)
+	l.Error("three")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(context.Background(), slog.Record{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: got %v, want ErrClosed", err)
+	}
+
+	rows := rec.rows()
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if got := rec.commits(); got != 2 {
+		t.Errorf("got %d commits, want 2", got)
+	}
+	r := rows[0]
+	if got, want := r[1], int64(slog.LevelInfo); got != want {
+		t.Errorf("level: got %v, want %v", got, want)
+	}
+	if r[2] != "one" {
+		t.Errorf("msg: got %v", r[2])
+	}
+	if s, _ := r[3].(string); !strings.Contains(s, "sqlitelog_test.go:") {
+		t.Errorf("source: got %v", r[3])
+	}
+	var attrs map[string]any
+	if err := json.Unmarshal([]byte(r[4].(string)), &attrs); err != nil {
+		t.Fatal(err)
+	}
+	wantAttrs := map[string]any{"user": "pat", "req": map[string]any{"id": 7.0, "err": "e"}}
+	if got, _ := json.Marshal(attrs); string(got) != mustMarshal(wantAttrs) {
+		t.Errorf("attrs: got %s, want %s", got, mustMarshal(wantAttrs))
+	}
+	if r[5] != int64(7) || r[6] != "pat" {
+		t.Errorf("indexed: got %v, %v; want 7, pat", r[5], r[6])
+	}
+	if rows[1][5] != nil {
+		t.Errorf("missing indexed key: got %v, want nil", rows[1][5])
+	}
+	if tm, err := time.Parse(time.RFC3339Nano, r[0].(string)); err != nil || time.Since(tm) > time.Minute {
+		t.Errorf("time: got %v, %v", r[0], err)
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	times := []time.Time{
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2024, 1, 1, 23, 4, 5, 500, est), // 04:04:05.0000005 UTC
+		time.Date(2024, 1, 2, 4, 4, 5, 1e8, time.UTC),
+	}
+	want := []string{
+		"2024-01-02T03:04:05.000000000Z",
+		"2024-01-02T04:04:05.000000500Z",
+		"2024-01-02T04:04:05.100000000Z",
+	}
+	for i, tm := range times {
+		got := formatTime(tm)
+		if got != want[i] {
+			t.Errorf("got %s, want %s", got, want[i])
+		}
+		if i > 0 && got <= formatTime(times[i-1]) {
+			t.Errorf("%s does not sort after %s", got, formatTime(times[i-1]))
+		}
+	}
+}
+
+func TestZeroTime(t *testing.T) {
+	db, rec := openFake(t)
+	h, err := Options{NoWAL: true}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rows := rec.rows()
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	if got := rows[0][0].(string); got < formatTime(start) {
+		t.Errorf("got time %s, want no earlier than %s", got, formatTime(start))
+	}
+}
+
+func TestWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	db, rec := openFake(t)
+	h, err := Options{MaxQueued: 3, FlushInterval: time.Hour, NoWAL: true}.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := slog.New(h)
+	check := func(want string, wantDropped int64) {
+		t.Helper()
+		var msgs []string
+		for _, r := range rec.rows() {
+			msgs = append(msgs, r[2].(string))
+		}
+		if got := strings.Join(msgs, " "); got != want {
+			t.Errorf("got rows %q, want %q", got, want)
+		}
+		if got := h.Dropped(); got != wantDropped {
+			t.Errorf("got %d dropped, want %d", got, wantDropped)
+		}
+	}
+
+	// A failed batch is written with the next one.
+	rec.failInserts(1)
+	l.Info("a")
+	l.Info("b")
+	if err := h.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded, want error")
+	}
+	check("", 0)
+	l.Info("c")
+	if err := h.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	check("a b c", 0)
+
+	// Beyond MaxQueued, the oldest records are dropped.
+	rec.failInserts(1)
+	l.Info("d")
+	l.Info("e")
+	if err := h.Flush(ctx); err == nil {
+		t.Fatal("Flush succeeded, want error")
+	}
+	l.Info("f")
+	l.Info("g")
+	if err := h.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	check("a b c e f g", 1)
+
+	// What Close can't write is dropped.
+	rec.failInserts(1)
+	l.Info("h")
+	if err := h.Close(); err == nil {
+		t.Fatal("Close succeeded, want error")
+	}
+	check("a b c e f g", 2)
+}
+
+func mustMarshal(x any) string {
+	b, err := json.Marshal(x)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func slicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A fake database/sql driver that records what is executed.
+
+type recorder struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	execs []exec
+	fail  int // number of INSERTs to fail
+}
+
+// failInserts makes the next n INSERTs fail.
+func (r *recorder) failInserts(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = n
+}
+
+type exec struct {
+	query string
+	args  []any
+}
+
+func (r *recorder) add(e exec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.execs = append(r.execs, e)
+	r.cond.Broadcast()
+}
+
+func (r *recorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.execs = nil
+}
+
+func (r *recorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ss []string
+	for _, e := range r.execs {
+		ss = append(ss, e.query)
+	}
+	return ss
+}
+
+func (r *recorder) rows() [][]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rows [][]any
+	for _, e := range r.execs {
+		if strings.HasPrefix(e.query, "INSERT") {
+			rows = append(rows, e.args)
+		}
+	}
+	return rows
+}
+
+func (r *recorder) commits() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.execs {
+		if e.query == "COMMIT" {
+			n++
+		}
+	}
+	return n
+}
+
+// wait waits until n rows have been inserted.
+func (r *recorder) wait(t *testing.T, n int) {
+	t.Helper()
+	timer := time.AfterFunc(5*time.Second, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.cond.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		rows := 0
+		for _, e := range r.execs {
+			if strings.HasPrefix(e.query, "INSERT") {
+				rows++
+			}
+		}
+		if rows >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d rows", n)
+		}
+		r.cond.Wait()
+	}
+}
+
+var (
+	fakeMu   sync.Mutex
+	fakeRecs = map[string]*recorder{}
+)
+
+func init() {
+	sql.Register("sqlitelogfake", fakeDriver{})
+}
+
+// openFake opens a database that records to a new recorder.
+func openFake(t *testing.T) (*sql.DB, *recorder) {
+	r := &recorder{}
+	r.cond = sync.NewCond(&r.mu)
+	fakeMu.Lock()
+	fakeRecs[t.Name()] = r
+	fakeMu.Unlock()
+	db, err := sql.Open("sqlitelogfake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, r
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return &fakeConn{fakeRecs[name]}, nil
+}
+
+type fakeConn struct{ r *recorder }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.r, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{c.r}, nil }
+
+type fakeTx struct{ r *recorder }
+
+func (tx fakeTx) Commit() error   { tx.r.add(exec{query: "COMMIT"}); return nil }
+func (tx fakeTx) Rollback() error { tx.r.add(exec{query: "ROLLBACK"}); return nil }
+
+type fakeStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "INSERT") {
+		s.r.mu.Lock()
+		fail := s.r.fail > 0
+		if fail {
+			s.r.fail--
+		}
+		s.r.mu.Unlock()
+		if fail {
+			return nil, errors.New("disk I/O error")
+		}
+	}
+	as := make([]any, len(args))
+	for i, a := range args {
+		as[i] = a
+	}
+	s.r.add(exec{s.query, as})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
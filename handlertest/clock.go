@@ -0,0 +1,60 @@
+package handlertest
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/middleware"
+)
+
+// A FakeClock is a clock for deterministic tests. Its Now method can be
+// used as the Clock option of the handlers in this module, or passed
+// to [WithClock].
+type FakeClock struct {
+	mu   sync.Mutex
+	t    time.Time
+	step time.Duration
+}
+
+// NewFakeClock returns a FakeClock whose Now returns start,
+// then advances by step. Step may be zero.
+func NewFakeClock(start time.Time, step time.Duration) *FakeClock {
+	return &FakeClock{t: start, step: step}
+}
+
+// Now returns the clock's time, then advances it by the step.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.t
+	c.t = c.t.Add(c.step)
+	return t
+}
+
+// Set sets the clock's time.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+// Advance moves the clock's time forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// WithClock returns a handler that sets the time of each record to now()
+// before passing it to h, for handlers without a Clock option.
+// Records with a zero time are left alone.
+func WithClock(h slog.Handler, now func() time.Time) slog.Handler {
+	return middleware.Transform(middleware.RecordTransformerFunc(func(_ context.Context, r slog.Record) (slog.Record, bool) {
+		if !r.Time.IsZero() {
+			r.Time = now()
+		}
+		return r, true
+	}))(h)
+}
@@ -0,0 +1,40 @@
+package handlertest
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+	c := NewFakeClock(start, time.Second)
+	for i, want := range []time.Time{start, start.Add(time.Second)} {
+		if got := c.Now(); !got.Equal(want) {
+			t.Errorf("#%d: got %s, want %s", i, got, want)
+		}
+	}
+	c.Advance(time.Minute)
+	if got, want := c.Now(), start.Add(time.Minute+2*time.Second); !got.Equal(want) {
+		t.Errorf("after Advance: got %s, want %s", got, want)
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("after Set: got %s, want %s", got, start)
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	start := time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+	var buf bytes.Buffer
+	h := WithClock(slog.NewTextHandler(&buf, nil), NewFakeClock(start, time.Second).Now)
+	l := slog.New(h).With("a", 1)
+	l.Info("x")
+	l.Info("y")
+	want := "time=2023-04-03T01:02:03.000Z level=INFO msg=x a=1\n" +
+		"time=2023-04-03T01:02:04.000Z level=INFO msg=y a=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
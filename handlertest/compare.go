@@ -0,0 +1,249 @@
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A Comparison checks that a handler's output means the same as that of a
+// reference handler, like those of log/slog, for the cases of the
+// conformance suite. Both handlers are given the same records, and their
+// output is parsed into maps and compared, so the handlers can differ in
+// format, key order and spacing but not in content:
+//
+//	handlertest.Comparison{
+//		Reference: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+//			return slog.NewJSONHandler(w, opts)
+//		},
+//		ParseReference: handlertest.ParseJSON,
+//		NewHandler:     newMyHandler,
+//		Parse:          handlertest.ParseJSON,
+//	}.Run(t)
+//
+// Values are compared by formatting them with fmt.Sprint, except that
+// numbers are compared numerically and times to the millisecond, the
+// precision of [slog.TextHandler].
+type Comparison struct {
+	// Reference returns the handler whose output is taken as correct.
+	Reference func(w io.Writer, opts *slog.HandlerOptions) slog.Handler
+
+	// ParseReference parses the output of the reference handler,
+	// as Suite.Parse does. If nil, Parse is used.
+	ParseReference func([]byte) ([]map[string]any, error)
+
+	// NewHandler and Parse are as in [Suite].
+	NewHandler func(w io.Writer, opts *slog.HandlerOptions) slog.Handler
+	Parse      func([]byte) ([]map[string]any, error)
+
+	// Ignore holds dotted paths of keys that are not compared, for
+	// differences that are intended.
+	Ignore []string
+
+	// Skip holds the names of cases that should not be run.
+	Skip []string
+}
+
+// Run runs each case as a subtest of t, reporting the differences
+// between the outputs as errors.
+func (c Comparison) Run(t *testing.T) {
+	parseRef := c.ParseReference
+	if parseRef == nil {
+		parseRef = c.Parse
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if slices.Contains(c.Skip, tc.name) {
+				t.Skip("skipped by Comparison.Skip")
+			}
+			var refBuf, buf bytes.Buffer
+			tc.f(slog.New(&tee{c.Reference(&refBuf, tc.opts), c.NewHandler(&buf, tc.opts)}))
+			want, err := parseRef(refBuf.Bytes())
+			if err != nil {
+				t.Fatalf("parsing reference output %q: %v", refBuf.Bytes(), err)
+			}
+			got, err := c.Parse(buf.Bytes())
+			if err != nil {
+				t.Fatalf("parsing %q: %v", buf.Bytes(), err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d records, want %d\nreference output:\n%s\noutput:\n%s",
+					len(got), len(want), refBuf.Bytes(), buf.Bytes())
+			}
+			for i := range got {
+				for _, d := range Diff(got[i], want[i], c.Ignore...) {
+					t.Errorf("record %d: %s", i, d)
+				}
+			}
+		})
+	}
+}
+
+// Diff returns the differences between got and want, parsed records
+// as described in [Suite.Parse], one per line. Keys with the dotted paths
+// in ignore are not compared.
+func Diff(got, want map[string]any, ignore ...string) []string {
+	var diffs []string
+	var diff func(prefix string, got, want map[string]any)
+	diff = func(prefix string, got, want map[string]any) {
+		var keys []string
+		for k := range want {
+			keys = append(keys, k)
+		}
+		for k := range got {
+			if _, ok := want[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := prefix + k
+			if slices.Contains(ignore, path) {
+				continue
+			}
+			g, gok := got[k]
+			w, wok := want[k]
+			switch {
+			case !gok:
+				diffs = append(diffs, fmt.Sprintf("%s: missing, want %v", path, w))
+			case !wok:
+				diffs = append(diffs, fmt.Sprintf("%s: got %v, want none", path, g))
+			default:
+				gm, gIsMap := g.(map[string]any)
+				wm, wIsMap := w.(map[string]any)
+				if gIsMap && wIsMap {
+					diff(path+".", gm, wm)
+				} else if gIsMap || wIsMap || !sameValue(g, w) {
+					diffs = append(diffs, fmt.Sprintf("%s: got %v, want %v", path, g, w))
+				}
+			}
+		}
+	}
+	diff("", got, want)
+	return diffs
+}
+
+// sameValue reports whether two values from parsed output mean the same.
+func sameValue(a, b any) bool {
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	if as == bs {
+		return true
+	}
+	if af, err := strconv.ParseFloat(as, 64); err == nil {
+		if bf, err := strconv.ParseFloat(bs, 64); err == nil {
+			return af == bf || math.IsNaN(af) && math.IsNaN(bf)
+		}
+	}
+	if at, err := time.Parse(time.RFC3339Nano, as); err == nil {
+		if bt, err := time.Parse(time.RFC3339Nano, bs); err == nil {
+			return at.Truncate(time.Millisecond).Equal(bt.Truncate(time.Millisecond))
+		}
+	}
+	return false
+}
+
+// ParseText parses output in the format of [slog.TextHandler], one
+// record per line. Dotted keys are treated as paths through groups.
+func ParseText(data []byte) ([]map[string]any, error) {
+	var ms []map[string]any
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		m, err := parseTextLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", line, err)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func parseTextLine(s string) (map[string]any, error) {
+	m := map[string]any{}
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return m, nil
+		}
+		key, rest, err := textToken(s, "=")
+		if err != nil {
+			return nil, err
+		}
+		if rest == "" || rest[0] != '=' {
+			return nil, fmt.Errorf("missing '=' after %q", key)
+		}
+		val, rest, err := textToken(rest[1:], " ")
+		if err != nil {
+			return nil, err
+		}
+		s = rest
+		keys := strings.Split(key, ".")
+		g := m
+		for _, k := range keys[:len(keys)-1] {
+			sub, ok := g[k].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				g[k] = sub
+			}
+			g = sub
+		}
+		g[keys[len(keys)-1]] = val
+	}
+}
+
+// textToken returns the quoted or unquoted token at the start of s,
+// ending before one of the bytes in stop, and the rest of s.
+func textToken(s, stop string) (tok, rest string, err error) {
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", err
+		}
+		tok, err := strconv.Unquote(q)
+		return tok, s[len(q):], err
+	}
+	i := strings.IndexAny(s, stop)
+	if i < 0 {
+		return s, "", nil
+	}
+	return s[:i], s[i:], nil
+}
+
+// tee is a handler that passes records to two handlers.
+type tee struct {
+	h1, h2 slog.Handler
+}
+
+func (t *tee) Enabled(ctx context.Context, l slog.Level) bool {
+	return t.h1.Enabled(ctx, l) || t.h2.Enabled(ctx, l)
+}
+
+func (t *tee) Handle(ctx context.Context, r slog.Record) error {
+	var err1, err2 error
+	if t.h1.Enabled(ctx, r.Level) {
+		err1 = t.h1.Handle(ctx, r.Clone())
+	}
+	if t.h2.Enabled(ctx, r.Level) {
+		err2 = t.h2.Handle(ctx, r)
+	}
+	return errors.Join(err1, err2)
+}
+
+func (t *tee) WithAttrs(as []slog.Attr) slog.Handler {
+	return &tee{t.h1.WithAttrs(as), t.h2.WithAttrs(as)}
+}
+
+func (t *tee) WithGroup(name string) slog.Handler {
+	return &tee{t.h1.WithGroup(name), t.h2.WithGroup(name)}
+}
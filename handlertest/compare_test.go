@@ -0,0 +1,72 @@
+package handlertest
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// The two handlers of log/slog agree with each other.
+func TestCompareTextJSON(t *testing.T) {
+	Comparison{
+		Reference: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			return slog.NewJSONHandler(w, opts)
+		},
+		ParseReference: ParseJSON,
+		NewHandler: func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+			return slog.NewTextHandler(w, opts)
+		},
+		Parse: ParseText,
+	}.Run(t)
+}
+
+func TestDiff(t *testing.T) {
+	got := map[string]any{
+		"time":  "2024-05-01T12:00:00.123Z",
+		"level": "INFO",
+		"n":     "1000000",
+		"g":     map[string]any{"a": "1", "extra": "x"},
+		"h":     "flat",
+		"skip":  "1",
+	}
+	want := map[string]any{
+		"time":  "2024-05-01T12:00:00.123456Z",
+		"level": "WARN",
+		"n":     1e6,
+		"g":     map[string]any{"a": 1.0, "b": "2"},
+		"h":     map[string]any{"c": "3"},
+		"skip":  "2",
+	}
+	wantDiffs := []string{
+		"g.b: missing, want 2",
+		"g.extra: got x, want none",
+		"h: got flat, want map[c:3]",
+		"level: got INFO, want WARN",
+	}
+	if got := Diff(got, want, "skip"); !slices.Equal(got, wantDiffs) {
+		t.Errorf("got\n%q\nwant\n%q", got, wantDiffs)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	got, err := ParseText([]byte("a=1 g.b=\"x y\" g.h.c=\"\"\n\nmsg=m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"a": "1", "g": map[string]any{"b": "x y", "h": map[string]any{"c": ""}}},
+		{"msg": "m"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if d := Diff(got[i], want[i]); len(d) > 0 {
+			t.Errorf("record %d: %q", i, d)
+		}
+	}
+	if _, err := ParseText([]byte("a")); err == nil {
+		t.Error("got nil error for missing '='")
+	}
+}
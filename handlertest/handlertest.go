@@ -0,0 +1,348 @@
+// Package handlertest provides a conformance suite for implementations
+// of slog.Handler.
+//
+// It is similar to testing/slogtest, but covers more cases, including
+// interleaved calls to WithGroup and WithAttrs, handler options, and
+// concurrent calls to Handle.
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHandler runs the conformance suite on the handler returned
+// by newHandler. See [Suite] for details.
+func TestHandler(t *testing.T,
+	newHandler func(io.Writer, *slog.HandlerOptions) slog.Handler,
+	parse func([]byte) ([]map[string]any, error)) {
+
+	Suite{NewHandler: newHandler, Parse: parse}.Run(t)
+}
+
+// A Suite is a configured conformance suite.
+type Suite struct {
+	// NewHandler returns a handler that writes to w and honors opts.
+	// If opts is nil, the handler should use default options.
+	NewHandler func(w io.Writer, opts *slog.HandlerOptions) slog.Handler
+
+	// Parse parses the handler's output into one map per record.
+	// A group should be represented as a map[string]any.
+	// The built-in attributes should use the keys slog.TimeKey,
+	// slog.LevelKey and slog.MessageKey.
+	// The values are compared by formatting them with fmt.Sprint,
+	// so their types do not matter.
+	Parse func([]byte) ([]map[string]any, error)
+
+	// Skip holds the names of cases that should not be run,
+	// for handlers that deliberately behave differently.
+	Skip []string
+}
+
+// Run runs each case as a subtest of t.
+func (s Suite) Run(t *testing.T) {
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if slices.Contains(s.Skip, c.name) {
+				t.Skip("skipped by Suite.Skip")
+			}
+			var buf bytes.Buffer
+			h := s.NewHandler(&buf, c.opts)
+			c.f(slog.New(h))
+			recs, err := s.Parse(buf.Bytes())
+			if err != nil {
+				t.Fatalf("parsing %q: %v", buf.Bytes(), err)
+			}
+			if c.nrecs == 0 {
+				c.nrecs = 1
+			}
+			if c.nrecs < 0 {
+				c.nrecs = 0
+			}
+			if len(recs) != c.nrecs {
+				t.Fatalf("got %d records, want %d; output:\n%s", len(recs), c.nrecs, buf.Bytes())
+			}
+			for _, rec := range recs {
+				for _, chk := range c.checks {
+					if msg := chk(rec); msg != "" {
+						t.Errorf("%s\nrecord: %v", msg, rec)
+					}
+				}
+			}
+		})
+	}
+}
+
+type testCase struct {
+	name   string
+	opts   *slog.HandlerOptions
+	f      func(*slog.Logger)
+	nrecs  int // number of expected records; 0 means 1, -1 means 0
+	checks []check
+}
+
+// A check returns a non-empty message if the record is wrong.
+type check func(map[string]any) string
+
+const message = "message"
+
+var cases = []testCase{
+	{
+		name:   "built-ins",
+		f:      func(l *slog.Logger) { l.Info(message) },
+		checks: []check{hasKey(slog.TimeKey), hasAttr(slog.LevelKey, "INFO"), hasAttr(slog.MessageKey, message)},
+	},
+	{
+		name:   "attrs",
+		f:      func(l *slog.Logger) { l.Info(message, "a", 1, "b", "two") },
+		checks: []check{hasAttr("a", 1), hasAttr("b", "two")},
+	},
+	{
+		name: "zero time",
+		f: func(l *slog.Logger) {
+			r := slog.NewRecord(time.Time{}, slog.LevelInfo, message, 0)
+			_ = l.Handler().Handle(context.Background(), r)
+		},
+		checks: []check{missingKey(slog.TimeKey), hasAttr(slog.MessageKey, message)},
+	},
+	{
+		name:   "empty attr",
+		f:      func(l *slog.Logger) { l.Info(message, "a", 1, slog.Attr{}, "b", 2) },
+		checks: []check{hasAttr("a", 1), hasAttr("b", 2), missingKey("")},
+	},
+	{
+		name:   "WithAttrs",
+		f:      func(l *slog.Logger) { l.With("a", 1).Info(message, "b", 2) },
+		checks: []check{hasAttr("a", 1), hasAttr("b", 2)},
+	},
+	{
+		name: "WithAttrs twice",
+		f: func(l *slog.Logger) {
+			l2 := l.With("a", 1)
+			l2.With("b", 2).Info(message)
+			l2.With("c", 3).Info(message)
+		},
+		nrecs:  2,
+		checks: []check{hasAttr("a", 1), either(hasAttr("b", 2), hasAttr("c", 3))},
+	},
+	{
+		name:   "WithGroup",
+		f:      func(l *slog.Logger) { l.WithGroup("G").Info(message, "a", 1) },
+		checks: []check{hasAttr("G.a", 1), hasAttr(slog.MessageKey, message), missingKey("G." + slog.MessageKey)},
+	},
+	{
+		name:   "empty WithGroup",
+		f:      func(l *slog.Logger) { l.WithGroup("G").Info(message) },
+		checks: []check{missingKey("G")},
+	},
+	{
+		name:   "WithGroup empty name",
+		f:      func(l *slog.Logger) { l.WithGroup("").Info(message, "a", 1) },
+		checks: []check{hasAttr("a", 1)},
+	},
+	{
+		name: "interleaved WithGroup and WithAttrs",
+		f: func(l *slog.Logger) {
+			l.With("a", 1).WithGroup("G").With("b", 2).WithGroup("H").Info(message, "c", 3)
+		},
+		checks: []check{hasAttr("a", 1), hasAttr("G.b", 2), hasAttr("G.H.c", 3), missingKey("G.a")},
+	},
+	{
+		name: "sibling WithGroups",
+		f: func(l *slog.Logger) {
+			l2 := l.WithGroup("G")
+			l2.WithGroup("H").Info(message, "a", 1)
+			l2.WithGroup("I").Info(message, "a", 1)
+		},
+		nrecs:  2,
+		checks: []check{either(hasAttr("G.H.a", 1), hasAttr("G.I.a", 1)), missingKey("G.H.I")},
+	},
+	{
+		name:   "group",
+		f:      func(l *slog.Logger) { l.Info(message, slog.Group("G", "a", 1, slog.Group("H", "b", 2))) },
+		checks: []check{hasAttr("G.a", 1), hasAttr("G.H.b", 2)},
+	},
+	{
+		name:   "inline group",
+		f:      func(l *slog.Logger) { l.Info(message, slog.Group("", "a", 1, "b", 2)) },
+		checks: []check{hasAttr("a", 1), hasAttr("b", 2)},
+	},
+	{
+		name:   "empty group",
+		f:      func(l *slog.Logger) { l.Info(message, "a", 1, slog.Group("G")) },
+		checks: []check{hasAttr("a", 1), missingKey("G")},
+	},
+	{
+		name:   "LogValuer",
+		f:      func(l *slog.Logger) { l.Info(message, "v", valuer{}, "g", groupValuer{}) },
+		checks: []check{hasAttr("v", "resolved"), hasAttr("g.a", 1)},
+	},
+	{
+		name: "Level option",
+		opts: &slog.HandlerOptions{Level: slog.LevelWarn},
+		f: func(l *slog.Logger) {
+			l.Info(message)
+			l.Warn(message)
+		},
+		checks: []check{hasAttr(slog.LevelKey, "WARN")},
+	},
+	{
+		name: "ReplaceAttr remove built-ins",
+		opts: &slog.HandlerOptions{ReplaceAttr: removeBuiltins},
+		f:    func(l *slog.Logger) { l.Info(message, "a", 1) },
+		checks: []check{
+			missingKey(slog.TimeKey), missingKey(slog.LevelKey), missingKey(slog.MessageKey),
+			hasAttr("a", 1),
+		},
+	},
+	{
+		name: "ReplaceAttr groups",
+		opts: &slog.HandlerOptions{ReplaceAttr: groupsValue},
+		f: func(l *slog.Logger) {
+			l.With("x", 0).WithGroup("G").With("x", 0).Info(message, slog.Group("H", "x", 0), "x", 0)
+		},
+		checks: []check{hasAttr("x", ""), hasAttr("G.x", "G"), hasAttr("G.H.x", "G.H")},
+	},
+	{
+		name: "ReplaceAttr not called on groups",
+		opts: &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Value.Kind() == slog.KindGroup {
+				return slog.Bool("replaced_group", true)
+			}
+			return a
+		}},
+		f:      func(l *slog.Logger) { l.Info(message, slog.Group("G", "a", 1)) },
+		checks: []check{hasAttr("G.a", 1), missingKey("replaced_group")},
+	},
+	{
+		name: "concurrent Handle",
+		f: func(l *slog.Logger) {
+			var wg sync.WaitGroup
+			for i := 0; i < concurrency; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					l.With("a", 1).Info(message, "b", strings.Repeat("x", 100))
+				}()
+			}
+			wg.Wait()
+		},
+		nrecs:  concurrency,
+		checks: []check{hasAttr("a", 1), hasAttr("b", strings.Repeat("x", 100))},
+	},
+}
+
+const concurrency = 10
+
+type valuer struct{}
+
+func (valuer) LogValue() slog.Value { return slog.StringValue("resolved") }
+
+type groupValuer struct{}
+
+func (groupValuer) LogValue() slog.Value { return slog.GroupValue(slog.Int("a", 1)) }
+
+func removeBuiltins(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.TimeKey, slog.LevelKey, slog.MessageKey:
+			return slog.Attr{}
+		}
+	}
+	return a
+}
+
+// groupsValue replaces the value of each Attr with key "x" by its groups.
+func groupsValue(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == "x" {
+		a.Value = slog.StringValue(strings.Join(groups, "."))
+	}
+	return a
+}
+
+// lookup returns the value at the dotted path in m.
+func lookup(m map[string]any, path string) (any, bool) {
+	keys := strings.Split(path, ".")
+	var v any = m
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		v, ok = m[k]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func hasKey(path string) check {
+	return func(m map[string]any) string {
+		if _, ok := lookup(m, path); !ok {
+			return fmt.Sprintf("missing key %q", path)
+		}
+		return ""
+	}
+}
+
+func missingKey(path string) check {
+	return func(m map[string]any) string {
+		if _, ok := lookup(m, path); ok {
+			return fmt.Sprintf("unexpected key %q", path)
+		}
+		return ""
+	}
+}
+
+func hasAttr(path string, want any) check {
+	return func(m map[string]any) string {
+		v, ok := lookup(m, path)
+		if !ok {
+			return fmt.Sprintf("missing key %q", path)
+		}
+		if got, w := fmt.Sprint(v), fmt.Sprint(want); got != w {
+			return fmt.Sprintf("%s: got %q, want %q", path, got, w)
+		}
+		return ""
+	}
+}
+
+// either succeeds if either check succeeds.
+func either(c1, c2 check) check {
+	return func(m map[string]any) string {
+		msg1 := c1(m)
+		if msg1 == "" {
+			return ""
+		}
+		if c2(m) == "" {
+			return ""
+		}
+		return msg1
+	}
+}
+
+// ParseJSON parses a sequence of JSON objects, one per record.
+// The objects need not be separated by newlines.
+func ParseJSON(data []byte) ([]map[string]any, error) {
+	var ms []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var m map[string]any
+		if err := dec.Decode(&m); err == io.EOF {
+			return ms, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+}
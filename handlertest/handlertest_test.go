@@ -0,0 +1,13 @@
+package handlertest
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestJSONHandler(t *testing.T) {
+	TestHandler(t, func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+		return slog.NewJSONHandler(w, opts)
+	}, ParseJSON)
+}
@@ -0,0 +1,189 @@
+// Package httplog provides net/http middleware that logs requests
+// through a slog.Logger.
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jba/slog/internal/response"
+	"github.com/jba/slog/slogctx"
+	"github.com/jba/slog/trace"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+// Keys for the attributes logged by the middleware.
+const (
+	MethodKey    = "method"
+	PathKey      = "path"
+	StatusKey    = "status"
+	DurationKey  = "duration"
+	BytesKey     = "bytes"
+	RemoteIPKey  = "remote_ip"
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+	SpanKey      = "span"
+)
+
+// Options are options for the middleware.
+type Options struct {
+	// Level is the level of request records.
+	// If nil, [slog.LevelInfo] is used.
+	// Requests that fail with a 5xx status are always logged at
+	// [slog.LevelError].
+	Level slog.Leveler
+
+	// RouteLevels overrides Level for requests whose path begins with
+	// one of its keys. The longest matching prefix wins.
+	// For example, mapping "/healthz" to [slog.LevelDebug] hides health
+	// checks unless debug logging is enabled.
+	RouteLevels map[string]slog.Level
+
+	// If LogStart is true, a record is also logged when the request starts.
+	LogStart bool
+
+	// RequestIDHeader is the header that holds the request ID.
+	// If empty, "X-Request-Id" is used. If a request does not
+	// have the header, or its value is longer than 128 bytes or has
+	// characters other than printable ASCII, a random ID is generated.
+	// The ID is set on the response header as well.
+	RequestIDHeader string
+}
+
+// New returns an http.Handler that calls next and logs each request to l
+// with the default options.
+func New(l *slog.Logger, next http.Handler) http.Handler {
+	return Options{}.New(l, next)
+}
+
+// New returns an http.Handler that calls next and logs each request to l.
+//
+// The finish record has the message "request finished" and includes
+// the method, path, status, duration, response body size, remote IP and
+// request ID. If the request context holds an OpenTelemetry span,
+// its trace and span IDs are added, and if it holds a span from
+// package [trace], its name is added.
+//
+// The context of the request passed to next holds the request ID, which
+// [RequestID] returns, and a logger derived from l that adds the request
+// ID to each record, which [slogctx.Logger] returns. Handlers can use that
+// logger to correlate their records with the request.
+func (opts Options) New(l *slog.Logger, next http.Handler) http.Handler {
+	header := opts.RequestIDHeader
+	if header == "" {
+		header = "X-Request-Id"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// The header comes from the client, so an ID that could
+		// flood or forge log lines is replaced.
+		id := r.Header.Get(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(header, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = slogctx.NewContext(ctx, l.With(RequestIDKey, id))
+		r = r.WithContext(ctx)
+
+		level := opts.level(r.URL.Path)
+		common := []slog.Attr{
+			slog.String(MethodKey, r.Method),
+			slog.String(PathKey, r.URL.Path),
+			slog.String(RemoteIPKey, remoteIP(r)),
+			slog.String(RequestIDKey, id),
+		}
+		if opts.LogStart {
+			l.LogAttrs(ctx, level, "request started", withTrace(ctx, common)...)
+		}
+
+		rw := response.NewWriter(w)
+		next.ServeHTTP(rw, r)
+		if rw.Status() >= 500 {
+			level = slog.LevelError
+		}
+		if !l.Enabled(ctx, level) {
+			return
+		}
+		attrs := append(common,
+			slog.Int(StatusKey, rw.Status()),
+			slog.Duration(DurationKey, time.Since(start)),
+			slog.Int64(BytesKey, rw.Bytes()))
+		l.LogAttrs(ctx, level, "request finished", withTrace(ctx, attrs)...)
+	})
+}
+
+func (opts Options) level(path string) slog.Level {
+	level := slog.LevelInfo
+	if opts.Level != nil {
+		level = opts.Level.Level()
+	}
+	best := -1
+	for prefix, l := range opts.RouteLevels {
+		if strings.HasPrefix(path, prefix) && len(prefix) > best {
+			level = l
+			best = len(prefix)
+		}
+	}
+	return level
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the request ID stored in ctx by the middleware,
+// or the empty string if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// maxRequestIDLen is the longest request ID accepted from a client.
+const maxRequestIDLen = 128
+
+// validRequestID reports whether id is non-empty, at most
+// maxRequestIDLen bytes long, and all printable ASCII.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// withTrace returns attrs followed by the trace correlation attributes
+// for ctx, if any.
+func withTrace(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	if sc := otrace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs,
+			slog.String(TraceIDKey, sc.TraceID().String()),
+			slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	if name := trace.SpanName(ctx); name != "" {
+		attrs = append(attrs, slog.String(SpanKey, name))
+	}
+	return attrs
+}
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,155 @@
+package httplog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/handlers/memory"
+	"github.com/jba/slog/slogctx"
+	"github.com/jba/slog/trace"
+	otrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	h := memory.New(&slog.HandlerOptions{Level: slog.LevelDebug})
+	l := slog.New(h)
+	var gotID string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = RequestID(r.Context())
+		if r.URL.Path == "/fail" {
+			http.Error(w, "oops", http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, "hello")
+	})
+	opts := Options{
+		LogStart:    true,
+		RouteLevels: map[string]slog.Level{"/health": slog.LevelDebug, "/healthz/deep": slog.LevelWarn},
+	}
+	mw := opts.New(l, next)
+
+	serve := func(path string, ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+		req.RemoteAddr = "10.1.2.3:999"
+		req.Header.Set("X-Request-Id", "abc")
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		return w
+	}
+
+	ctx, span := (&trace.Tracer{}).Start(context.Background(), "outer")
+	defer span.End()
+	w := serve("/hello", ctx)
+	if got := w.Header().Get("X-Request-Id"); got != "abc" {
+		t.Errorf("response header: got %q, want %q", got, "abc")
+	}
+	if gotID != "abc" {
+		t.Errorf("RequestID: got %q, want %q", gotID, "abc")
+	}
+	h.AssertLogged(t, slog.LevelInfo, "request started",
+		slog.String(MethodKey, "GET"), slog.String(PathKey, "/hello"),
+		slog.String(RemoteIPKey, "10.1.2.3"), slog.String(RequestIDKey, "abc"),
+		slog.String(SpanKey, "outer"))
+	h.AssertLogged(t, slog.LevelInfo, "request finished",
+		slog.Int(StatusKey, 200), slog.Int64(BytesKey, 5))
+
+	h.Reset()
+	serve("/healthz", context.Background())
+	h.AssertLogged(t, slog.LevelDebug, "request finished", slog.String(PathKey, "/healthz"))
+	h.Reset()
+	serve("/healthz/deep", context.Background())
+	h.AssertLogged(t, slog.LevelWarn, "request finished")
+	h.Reset()
+	serve("/fail", context.Background())
+	h.AssertLogged(t, slog.LevelError, "request finished", slog.Int(StatusKey, 500))
+}
+
+func TestGeneratedRequestID(t *testing.T) {
+	h := memory.New(nil)
+	mw := New(slog.New(h), http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	mw.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	id := w.Header().Get("X-Request-Id")
+	if len(id) != 16 {
+		t.Errorf("got request ID %q, want 16 hex digits", id)
+	}
+	h.AssertLogged(t, slog.LevelInfo, "request finished", slog.String(RequestIDKey, id), slog.Int(StatusKey, 404))
+}
+
+func TestUntrustedRequestID(t *testing.T) {
+	for _, id := range []string{
+		"abc\nlevel=ERROR msg=forged",
+		"\x1b[31mred",
+		"é",
+		strings.Repeat("x", maxRequestIDLen+1),
+	} {
+		h := memory.New(nil)
+		mw := New(slog.New(h), http.NotFoundHandler())
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", id)
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, req)
+		got := w.Header().Get("X-Request-Id")
+		if len(got) != 16 {
+			t.Errorf("%q: got request ID %q, want a generated one", id, got)
+		}
+		h.AssertLogged(t, slog.LevelInfo, "request finished", slog.String(RequestIDKey, got))
+	}
+	// The longest allowed ID is kept.
+	id := strings.Repeat("x", maxRequestIDLen)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", id)
+	w := httptest.NewRecorder()
+	New(slog.New(memory.New(nil)), http.NotFoundHandler()).ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-Id"); got != id {
+		t.Errorf("got %q, want the ID from the request", got)
+	}
+}
+
+func TestTraceIDs(t *testing.T) {
+	h := memory.New(nil)
+	mw := New(slog.New(h), http.NotFoundHandler())
+	sc := otrace.NewSpanContext(otrace.SpanContextConfig{
+		TraceID: otrace.TraceID{1, 2, 3},
+		SpanID:  otrace.SpanID{4, 5, 6},
+	})
+	ctx := otrace.ContextWithSpanContext(context.Background(), sc)
+	mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	h.AssertLogged(t, slog.LevelInfo, "request finished",
+		slog.String(TraceIDKey, "01020300000000000000000000000000"),
+		slog.String(SpanIDKey, "0405060000000000"))
+}
+
+func TestContextLogger(t *testing.T) {
+	h := memory.New(nil)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slogctx.Logger(r.Context()).Info("handling", "a", 1)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	New(slog.New(h), next).ServeHTTP(httptest.NewRecorder(), req)
+	h.AssertLogged(t, slog.LevelInfo, "handling", slog.String(RequestIDKey, "abc"), slog.Int("a", 1))
+}
+
+func TestFlush(t *testing.T) {
+	// Streaming handlers can flush through the middleware.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "event")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Error(err)
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("ResponseWriter is not an http.Flusher")
+		}
+	})
+	w := httptest.NewRecorder()
+	New(slog.New(memory.New(nil)), next).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.Flushed {
+		t.Error("not flushed")
+	}
+}
@@ -0,0 +1,208 @@
+// Package batch queues items and writes them in batches from a
+// background goroutine, for handlers that write records to a database.
+package batch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configure a [Writer].
+type Options[T any] struct {
+	// BatchSize is the most items passed to one call of Write.
+	// When that many items are waiting, they are written.
+	BatchSize int
+
+	// FlushInterval is the longest that an item waits to be written.
+	FlushInterval time.Duration
+
+	// MaxQueued is the most items that wait to be written. When a batch
+	// can't be written and there is no Spill function, its items are kept
+	// to be tried again with the next one; if that makes more than
+	// MaxQueued items wait, the oldest are dropped.
+	// If zero, ten times BatchSize is used.
+	MaxQueued int
+
+	// Write writes a batch.
+	Write func(context.Context, []T) error
+
+	// Spill, if non-nil, is called with a batch that Write failed to
+	// write, instead of keeping it. Flushing continues with the next
+	// batch. If Spill fails, the batch is dropped.
+	Spill func([]T) error
+
+	// Unspill, if non-nil, is called at the end of a flush in which a
+	// batch was written, or there was nothing to write, to retry the
+	// batches that were passed to Spill.
+	Unspill func(context.Context) error
+
+	// OnError, if non-nil, is called with errors from flushing in
+	// the background.
+	OnError func(error)
+}
+
+// A Writer queues items and writes them in batches.
+type Writer[T any] struct {
+	opts Options[T]
+
+	mu      sync.Mutex
+	items   []T
+	closed  bool
+	dropped atomic.Int64
+
+	writeMu sync.Mutex // held while writing
+	ctx     context.Context
+	cancel  context.CancelFunc // cancels ctx, for background writes
+	kick    chan struct{}      // a batch is full
+	done    chan struct{}      // closed by Close
+	exited  chan struct{}      // closed when run returns
+}
+
+// New returns a Writer and starts its background goroutine.
+// Call [Writer.Close] to stop it.
+func New[T any](opts Options[T]) *Writer[T] {
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = 10 * opts.BatchSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Writer[T]{
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		kick:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues x to be written. It reports false if w is closed.
+func (w *Writer[T]) Add(x T) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.items = append(w.items, x)
+	w.trim()
+	if len(w.items) >= w.opts.BatchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Dropped returns the number of items that were dropped because they
+// couldn't be written.
+func (w *Writer[T]) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// run flushes when a batch is full or FlushInterval has passed,
+// until w is closed.
+func (w *Writer[T]) run() {
+	defer close(w.exited)
+	t := time.NewTicker(w.opts.FlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+		case <-w.kick:
+		}
+		if err := w.Flush(w.ctx); err != nil && w.opts.OnError != nil {
+			w.opts.OnError(err)
+		}
+	}
+}
+
+// trim drops the oldest items if more than MaxQueued are waiting.
+// w.mu must be held.
+func (w *Writer[T]) trim() {
+	if n := len(w.items) - w.opts.MaxQueued; n > 0 {
+		w.items = slices.Delete(w.items, 0, n)
+		w.dropped.Add(int64(n))
+	}
+}
+
+// Flush writes the queued items in batches of at most BatchSize.
+// If a batch fails and there is no Spill function, it and the items
+// after it are queued again, ahead of those added since Flush began.
+func (w *Writer[T]) Flush(ctx context.Context) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	w.mu.Lock()
+	items := w.items
+	w.items = nil
+	w.mu.Unlock()
+	var errs []error
+	ok := len(items) == 0
+	for len(items) > 0 {
+		n := min(len(items), w.opts.BatchSize)
+		if err := w.opts.Write(ctx, items[:n]); err != nil {
+			errs = append(errs, err)
+			if w.opts.Spill == nil {
+				w.mu.Lock()
+				w.items = append(items, w.items...)
+				w.trim()
+				w.mu.Unlock()
+				return errors.Join(errs...)
+			}
+			if err := w.opts.Spill(items[:n]); err != nil {
+				errs = append(errs, err)
+				w.dropped.Add(int64(n))
+			}
+		} else {
+			ok = true
+		}
+		items = items[n:]
+	}
+	// Retry spilled batches only when writing seems to work.
+	if ok && w.opts.Unspill != nil {
+		if err := w.opts.Unspill(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the background goroutine and flushes the queued items.
+// Items that it can't write are dropped. Add fails after Close.
+func (w *Writer[T]) Close() error {
+	return w.CloseContext(context.Background())
+}
+
+// CloseContext is like Close, but gives up writing when ctx is done,
+// both in the background goroutine and in the final flush.
+func (w *Writer[T]) CloseContext(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	defer w.cancel()
+	close(w.done)
+	select {
+	case <-w.exited:
+	case <-ctx.Done():
+		w.cancel()
+		<-w.exited
+	}
+	err := w.Flush(ctx)
+	// Nothing will write what is left.
+	w.mu.Lock()
+	w.dropped.Add(int64(len(w.items)))
+	w.items = nil
+	w.mu.Unlock()
+	return err
+}
@@ -0,0 +1,161 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A sink records the batches it is given, or fails.
+type sink struct {
+	mu      sync.Mutex
+	batches [][]int
+	fail    bool
+}
+
+func (s *sink) write(_ context.Context, xs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("fail")
+	}
+	s.batches = append(s.batches, slices.Clone(xs))
+	return nil
+}
+
+func (s *sink) setFail(b bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = b
+}
+
+func (s *sink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprint(s.batches)
+}
+
+func TestFlush(t *testing.T) {
+	var s sink
+	w := New(Options[int]{BatchSize: 2, FlushInterval: time.Hour, Write: s.write})
+	defer w.Close()
+	for i := 1; i <= 5; i++ {
+		w.Add(i)
+	}
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Some batches may have been written by the background goroutine,
+	// but none is too large and the items stay in order.
+	var all []int
+	for _, b := range s.batches {
+		if len(b) > 2 {
+			t.Errorf("batch %v is too large", b)
+		}
+		all = append(all, b...)
+	}
+	if got, want := fmt.Sprint(all), "[1 2 3 4 5]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	var s sink
+	w := New(Options[int]{BatchSize: 10, MaxQueued: 3, FlushInterval: time.Hour, Write: s.write})
+	ctx := context.Background()
+	s.setFail(true)
+	w.Add(1)
+	w.Add(2)
+	if err := w.Flush(ctx); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	w.Add(3)
+	w.Add(4)
+	if got, want := w.Dropped(), int64(1); got != want {
+		t.Errorf("dropped: got %d, want %d", got, want)
+	}
+	s.setFail(false)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.String(), "[[2 3 4]]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if w.Add(5) {
+		t.Error("Add after Close succeeded")
+	}
+}
+
+func TestSpill(t *testing.T) {
+	var s sink
+	var spilled [][]int
+	unspills := 0
+	w := New(Options[int]{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		Write:         s.write,
+		Spill: func(xs []int) error {
+			spilled = append(spilled, slices.Clone(xs))
+			if len(spilled) > 1 {
+				return errors.New("spill failed")
+			}
+			return nil
+		},
+		Unspill: func(context.Context) error { unspills++; return nil },
+	})
+	ctx := context.Background()
+	s.setFail(true)
+	w.Add(1)
+	w.Add(2)
+	if err := w.Flush(ctx); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	// The second spill fails, so its batch is dropped.
+	w.Add(3)
+	if err := w.Flush(ctx); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if got, want := fmt.Sprint(spilled), "[[1 2] [3]]"; got != want {
+		t.Errorf("spilled: got %s, want %s", got, want)
+	}
+	if got, want := w.Dropped(), int64(1); got != want {
+		t.Errorf("dropped: got %d, want %d", got, want)
+	}
+	if unspills != 0 {
+		t.Errorf("unspilled after failure")
+	}
+	s.setFail(false)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if unspills != 1 {
+		t.Errorf("got %d unspills, want 1", unspills)
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	// Write blocks until its context is done, like a stalled database.
+	started := make(chan struct{}, 10)
+	stall := func(ctx context.Context, xs []int) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	w := New(Options[int]{BatchSize: 1, FlushInterval: time.Hour, Write: stall})
+	w.Add(1) // kicks a background write
+	<-started
+	w.Add(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := w.CloseContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if got := w.Dropped(); got != 2 {
+		t.Errorf("dropped %d, want 2", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Package frames resolves program counters to stack frames, caching the
+// results so that records logged from the same call site over and over
+// don't pay for [runtime.CallersFrames] each time.
+package frames
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// maxEntries bounds the size of the cache. A program rarely logs from
+// more call sites than this; if it does, the cache is emptied and
+// refilled with the call sites in use.
+const maxEntries = 4096
+
+type cache struct {
+	m sync.Map // uintptr to runtime.Frame
+	n atomic.Int64
+}
+
+var current atomic.Pointer[cache]
+
+func init() {
+	current.Store(&cache{})
+}
+
+// Frame returns the frame for pc, which should be a return address as
+// in a [slog.Record]. The frame's Func field is not set.
+func Frame(pc uintptr) runtime.Frame {
+	c := current.Load()
+	if f, ok := c.m.Load(pc); ok {
+		return f.(runtime.Frame)
+	}
+	fs := runtime.CallersFrames([]uintptr{pc})
+	f, _ := fs.Next()
+	// Don't keep the Func, which is only needed to find the frame.
+	f.Func = nil
+	if _, loaded := c.m.LoadOrStore(pc, f); !loaded && c.n.Add(1) > maxEntries {
+		current.CompareAndSwap(c, &cache{})
+	}
+	return f
+}
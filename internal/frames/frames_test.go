@@ -0,0 +1,56 @@
+package frames
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func callerPC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	return pcs[0]
+}
+
+func TestFrame(t *testing.T) {
+	pc := callerPC()
+	for i := 0; i < 2; i++ {
+		f := Frame(pc)
+		if !strings.HasSuffix(f.Function, "TestFrame") || !strings.HasSuffix(f.File, "frames_test.go") || f.Line == 0 {
+			t.Errorf("got %+v", f)
+		}
+	}
+	if _, ok := current.Load().m.Load(pc); !ok {
+		t.Error("frame not cached")
+	}
+}
+
+func TestBound(t *testing.T) {
+	c := current.Load()
+	pc := callerPC()
+	// Fill the cache with fake PCs until it is replaced.
+	for i := uintptr(0); current.Load() == c; i++ {
+		if i > maxEntries+1 {
+			t.Fatal("cache not replaced")
+		}
+		Frame(pc + i)
+	}
+	if n := current.Load().n.Load(); n != 0 {
+		t.Errorf("new cache has %d entries", n)
+	}
+}
+
+func BenchmarkFrame(b *testing.B) {
+	pc := callerPC()
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Frame(pc)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fs := runtime.CallersFrames([]uintptr{pc})
+			fs.Next()
+		}
+	})
+}
@@ -0,0 +1,26 @@
+// Package keyvals converts Attrs to the alternating keys and values
+// taken by loggers like logr.Logger and go-kit's log.Logger.
+package keyvals
+
+import "log/slog"
+
+// Append appends the key-value pairs for a to kvs. Groups are flattened
+// by prefixing keys with the group name and a dot; prefix holds the
+// names of the enclosing groups, each followed by a dot. Values are
+// resolved, and Attrs with empty keys are omitted.
+func Append(kvs []any, prefix string, a slog.Attr) []any {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			kvs = Append(kvs, prefix, ga)
+		}
+		return kvs
+	}
+	if a.Key == "" {
+		return kvs
+	}
+	return append(kvs, prefix+a.Key, a.Value.Any())
+}
@@ -0,0 +1,21 @@
+package keyvals
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	var kvs []any
+	for _, a := range []slog.Attr{
+		slog.Int("a", 1),
+		slog.Group("g", slog.String("b", "x"), slog.Group("", slog.Bool("c", true))),
+		slog.String("", "dropped"),
+	} {
+		kvs = Append(kvs, "p.", a)
+	}
+	if got, want := fmt.Sprint(kvs), "[p.a 1 p.g.b x p.g.c true]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
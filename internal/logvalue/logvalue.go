@@ -0,0 +1,37 @@
+// Package logvalue resolves [slog.LogValuer]s the way the handlers in
+// this module want: like [slog.Value.Resolve], but a panic becomes a
+// single-line "!PANIC: ..." string instead of an error holding a stack
+// trace.
+package logvalue
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// maxLogValues is the most LogValue calls Resolve makes, as in slog.
+const maxLogValues = 100
+
+// Resolve repeatedly calls LogValue on v while it implements
+// [slog.LogValuer], and returns the result.
+// If LogValue panics, Resolve returns a string value "!PANIC: " followed
+// by the panic value. If v resolves to a LogValuer too many times,
+// Resolve returns an error value, as [slog.Value.Resolve] does.
+func Resolve(v slog.Value) (rv slog.Value) {
+	if v.Kind() != slog.KindLogValuer {
+		return v
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rv = slog.StringValue(fmt.Sprintf("!PANIC: %v", p))
+		}
+	}()
+	orig := v
+	for i := 0; i < maxLogValues; i++ {
+		if v.Kind() != slog.KindLogValuer {
+			return v
+		}
+		v = v.LogValuer().LogValue()
+	}
+	return slog.AnyValue(fmt.Errorf("LogValue called too many times on Value of type %T", orig.Any()))
+}
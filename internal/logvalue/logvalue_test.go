@@ -0,0 +1,30 @@
+package logvalue
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type valuer func() slog.Value
+
+func (v valuer) LogValue() slog.Value { return v() }
+
+func TestResolve(t *testing.T) {
+	var loop valuer
+	loop = func() slog.Value { return slog.AnyValue(loop) }
+	for _, test := range []struct {
+		v    slog.Value
+		want string
+	}{
+		{slog.IntValue(1), "1"},
+		{slog.AnyValue(valuer(func() slog.Value { return slog.StringValue("x") })), "x"},
+		{slog.AnyValue(valuer(func() slog.Value { panic("boom") })), "!PANIC: boom"},
+		{slog.AnyValue(loop), "LogValue called too many times"},
+	} {
+		got := Resolve(test.v).String()
+		if !strings.HasPrefix(got, test.want) {
+			t.Errorf("got %q, want prefix %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package response provides an http.ResponseWriter that records what
+// was written, for middleware that logs requests.
+package response
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Writer records the status and body size of a response.
+// It passes Flush and Hijack through to the ResponseWriter it wraps,
+// so streaming and websocket handlers work behind it.
+type Writer struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// NewWriter returns a Writer that wraps w.
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w}
+}
+
+// Status returns the status of the response. If no status was written,
+// it is http.StatusOK, as net/http would send.
+func (w *Writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes returns the number of body bytes written.
+func (w *Writer) Bytes() int64 {
+	return w.bytes
+}
+
+func (w *Writer) WriteHeader(status int) {
+	// Informational statuses, like 103 Early Hints, precede the real one.
+	// As in net/http, 101 Switching Protocols is final.
+	informational := status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+	if w.status == 0 && !informational {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *Writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection of the wrapped ResponseWriter. It
+// returns [http.ErrNotSupported] if that ResponseWriter can't be hijacked.
+func (w *Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap supports http.ResponseController.
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
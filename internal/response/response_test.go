@@ -0,0 +1,67 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewWriter(rec)
+	if got := w.Status(); got != http.StatusOK {
+		t.Errorf("status before writing: got %d, want 200", got)
+	}
+	w.WriteHeader(http.StatusTeapot)
+	w.WriteHeader(http.StatusOK) // the first status is the one sent
+	io.WriteString(w, "hello")
+	if got, want := w.Status(), http.StatusTeapot; got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+	if got := w.Bytes(); got != 5 {
+		t.Errorf("bytes: got %d, want 5", got)
+	}
+
+	// Flush and Hijack go to the wrapped ResponseWriter, directly
+	// or through a ResponseController.
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Flushed {
+		t.Error("not flushed")
+	}
+	if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack: got %v, want ErrNotSupported", err)
+	}
+}
+
+// codesWriter records the statuses written to it.
+type codesWriter struct {
+	http.ResponseWriter
+	codes []int
+}
+
+func (w *codesWriter) WriteHeader(status int) { w.codes = append(w.codes, status) }
+
+func TestInformationalStatus(t *testing.T) {
+	cw := &codesWriter{ResponseWriter: httptest.NewRecorder()}
+	w := NewWriter(cw)
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusNotFound)
+	if got, want := w.Status(), http.StatusNotFound; got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+	if got, want := fmt.Sprint(cw.codes), "[103 404]"; got != want {
+		t.Errorf("forwarded %s, want %s", got, want)
+	}
+
+	w = NewWriter(httptest.NewRecorder())
+	w.WriteHeader(http.StatusSwitchingProtocols)
+	if got, want := w.Status(), http.StatusSwitchingProtocols; got != want {
+		t.Errorf("status: got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+// Package severity maps slog levels to the severities of log services
+// like Cloud Logging and Datadog, which share the syslog ladder.
+package severity
+
+import "log/slog"
+
+// Severities.
+const (
+	Debug = iota
+	Info
+	Warning
+	Error
+	Critical
+	Alert
+	Emergency
+)
+
+// Of returns the severity of l: Debug below [slog.LevelInfo], Info below
+// [slog.LevelWarn], Warning below [slog.LevelError], and Error, Critical,
+// Alert and Emergency for each step of 4 above that.
+// Use it to index an array of a service's names for the severities.
+func Of(l slog.Level) int {
+	switch {
+	case l < slog.LevelInfo:
+		return Debug
+	case l < slog.LevelWarn:
+		return Info
+	case l < slog.LevelError:
+		return Warning
+	case l < slog.LevelError+4:
+		return Error
+	case l < slog.LevelError+8:
+		return Critical
+	case l < slog.LevelError+12:
+		return Alert
+	default:
+		return Emergency
+	}
+}
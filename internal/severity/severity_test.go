@@ -0,0 +1,30 @@
+package severity
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	for _, test := range []struct {
+		l    slog.Level
+		want int
+	}{
+		{slog.LevelDebug - 4, Debug},
+		{slog.LevelInfo - 1, Debug},
+		{slog.LevelInfo, Info},
+		{slog.LevelWarn - 1, Info},
+		{slog.LevelWarn, Warning},
+		{slog.LevelError, Error},
+		{slog.LevelError + 3, Error},
+		{slog.LevelError + 4, Critical},
+		{slog.LevelError + 8, Alert},
+		{slog.LevelError + 11, Alert},
+		{slog.LevelError + 12, Emergency},
+		{slog.LevelError + 100, Emergency},
+	} {
+		if got := Of(test.l); got != test.want {
+			t.Errorf("%v: got %d, want %d", test.l, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package jsonlog reads the output of JSON handlers, like
+// [slog.JSONHandler] and the JSON Formatter of package
+// github.com/jba/slog/handlers/general, back into records, so that
+// archived logs can be sent through another handler:
+//
+//	r := jsonlog.NewReader(file)
+//	for {
+//		rec, err := r.Read()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		if err := h.Handle(ctx, rec); err != nil {
+//			return err
+//		}
+//	}
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jba/slog/levels"
+)
+
+// Options are options for a [Reader].
+type Options struct {
+	// TimeKey, LevelKey and MessageKey are the keys of the built-in
+	// fields. If empty, [slog.TimeKey], [slog.LevelKey] and
+	// [slog.MessageKey] are used.
+	TimeKey, LevelKey, MessageKey string
+}
+
+// A Reader reads records from a sequence of JSON objects, one per record,
+// with or without newlines between them.
+//
+// The time, level and message of a record are taken from the top-level
+// built-in fields, if they have the expected types: a time in RFC 3339
+// format, a level name accepted by [levels.Parse] or an integer level,
+// and a string. A built-in field of another type is kept as an Attr.
+// Other fields become Attrs, in the order they appear, with their values
+// typed as well as JSON allows: a number becomes an int64 if it is an
+// integer that fits, then a uint64, and otherwise a float64; an object
+// becomes a group; and an array becomes a []any of such values, with
+// objects as []slog.Attr. The
+// source location can't be restored, so it is kept as a group Attr.
+type Reader struct {
+	dec  *json.Decoder
+	opts Options
+}
+
+// NewReader returns a Reader that reads from r with the default options.
+func NewReader(r io.Reader) *Reader {
+	return Options{}.NewReader(r)
+}
+
+// NewReader returns a Reader that reads from r.
+func (opts Options) NewReader(r io.Reader) *Reader {
+	if opts.TimeKey == "" {
+		opts.TimeKey = slog.TimeKey
+	}
+	if opts.LevelKey == "" {
+		opts.LevelKey = slog.LevelKey
+	}
+	if opts.MessageKey == "" {
+		opts.MessageKey = slog.MessageKey
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &Reader{dec: dec, opts: opts}
+}
+
+// Read returns the next record.
+// It returns io.EOF when there are no more records.
+// Since the input can't be resynchronized after a syntax error, every
+// error other than a value that isn't an object ends reading.
+func (r *Reader) Read() (slog.Record, error) {
+	tok, err := r.dec.Token()
+	if err != nil {
+		return slog.Record{}, err
+	}
+	if tok != json.Delim('{') {
+		// Skip the value, so the next Read can continue.
+		if d, ok := tok.(json.Delim); ok && d == '[' {
+			if _, err := readArray(r.dec); err != nil {
+				return slog.Record{}, err
+			}
+		}
+		return slog.Record{}, fmt.Errorf("jsonlog: record is %v, not an object", tok)
+	}
+	attrs, err := readObject(r.dec)
+	if err != nil {
+		return slog.Record{}, unexpectedEOF(err)
+	}
+	var (
+		t                time.Time
+		level            slog.Level
+		msg              string
+		hasT, hasL, hasM bool
+	)
+	rest := attrs[:0]
+	for _, a := range attrs {
+		switch {
+		case a.Key == r.opts.TimeKey && !hasT:
+			if a.Value.Kind() == slog.KindString {
+				if pt, err := time.Parse(time.RFC3339Nano, a.Value.String()); err == nil {
+					t, hasT = pt, true
+					continue
+				}
+			}
+		case a.Key == r.opts.LevelKey && !hasL:
+			if a.Value.Kind() == slog.KindString {
+				if l, err := levels.Parse(a.Value.String()); err == nil {
+					level, hasL = l, true
+					continue
+				}
+			} else if a.Value.Kind() == slog.KindInt64 {
+				level, hasL = slog.Level(a.Value.Int64()), true
+				continue
+			}
+		case a.Key == r.opts.MessageKey && !hasM:
+			if a.Value.Kind() == slog.KindString {
+				msg, hasM = a.Value.String(), true
+				continue
+			}
+		}
+		rest = append(rest, a)
+	}
+	rec := slog.NewRecord(t, level, msg, 0)
+	rec.AddAttrs(rest...)
+	return rec, nil
+}
+
+// readObject reads the members of an object whose opening brace has
+// been read, through its closing brace.
+func readObject(dec *json.Decoder) ([]slog.Attr, error) {
+	var attrs []slog.Attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("jsonlog: key is %v, not a string", tok)
+		}
+		v, err := readValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: v})
+	}
+	// The closing brace.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// readArray reads the elements of an array whose opening bracket has
+// been read, through its closing bracket.
+func readArray(dec *json.Decoder) ([]any, error) {
+	xs := []any{}
+	for dec.More() {
+		v, err := readValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, v.Any())
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return xs, nil
+}
+
+func readValue(dec *json.Decoder) (slog.Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return slog.Value{}, err
+	}
+	switch x := tok.(type) {
+	case json.Delim:
+		if x == '{' {
+			attrs, err := readObject(dec)
+			if err != nil {
+				return slog.Value{}, err
+			}
+			return slog.GroupValue(attrs...), nil
+		}
+		xs, err := readArray(dec)
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return slog.AnyValue(xs), nil
+	case json.Number:
+		return numberValue(x), nil
+	case string:
+		return slog.StringValue(x), nil
+	case bool:
+		return slog.BoolValue(x), nil
+	default: // nil
+		return slog.AnyValue(nil), nil
+	}
+}
+
+// numberValue returns n as the narrowest of int64, uint64 and float64
+// that holds it.
+func numberValue(n json.Number) slog.Value {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return slog.Int64Value(i)
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return slog.Uint64Value(u)
+	}
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return slog.Float64Value(f)
+}
+
+// unexpectedEOF converts io.EOF in the middle of a record to
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package jsonlog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/levels"
+)
+
+var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 4000, time.UTC)
+
+// readAll returns each record read from r as a string.
+func readAll(t *testing.T, r *Reader) []string {
+	t.Helper()
+	var got []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, recordString(rec))
+	}
+}
+
+func recordString(r slog.Record) string {
+	var b strings.Builder
+	b.WriteString(r.Time.Format(time.RFC3339Nano) + " " + levels.String(r.Level) + " " + r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteString(" " + a.String() + ":" + a.Value.Kind().String())
+		return true
+	})
+	return b.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		new  func(io.Writer) slog.Handler
+	}{
+		{"slog", func(w io.Writer) slog.Handler { return slog.NewJSONHandler(w, nil) }},
+		{"general", func(w io.Writer) slog.Handler { return general.New(w, general.NewJSONFormatter) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := test.new(&buf).WithAttrs([]slog.Attr{slog.String("s", "x")}).WithGroup("g")
+			for _, l := range []slog.Level{slog.LevelWarn, levels.LevelFatal} {
+				r := slog.NewRecord(testTime, l, "hello", 0)
+				r.AddAttrs(
+					slog.Int("i", -3),
+					slog.Uint64("u", 1<<63),
+					slog.Float64("f", 1.5),
+					slog.Bool("b", true),
+					slog.Any("n", nil),
+					slog.Any("a", []any{1, "two"}),
+					slog.Group("h", slog.String("msg", "inner")),
+				)
+				if err := h.Handle(context.Background(), r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := " hello s=x:String g=[i=-3 u=9223372036854775808 f=1.5 b=true n=<nil> a=[1 two] h=[msg=inner]]:Group"
+			got := readAll(t, NewReader(&buf))
+			if len(got) != 2 {
+				t.Fatalf("got %d records, want 2", len(got))
+			}
+			for i, l := range []string{"WARN", "FATAL"} {
+				w := "2023-04-03T01:02:03.000004Z " + l + want
+				if test.name == "general" {
+					// The general handler writes milliseconds.
+					w = strings.Replace(w, ".000004Z", "Z", 1)
+				}
+				if got[i] != w {
+					t.Errorf("\ngot  %s\nwant %s", got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestTypes(t *testing.T) {
+	in := `{"time":"x","level":4,"msg":3,"msg":"m","i":1,"u":18446744073709551615,"f":1e3,"o":{"p":[{"q":1}]}}`
+	got := readAll(t, NewReader(strings.NewReader(in)))
+	want := []string{"0001-01-01T00:00:00Z WARN m time=x:String msg=3:Int64 i=1:Int64 u=18446744073709551615:Uint64 f=1000:Float64 o=[p=[[q=1]]]:Group"}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	in := `{"ts":"2023-04-03T01:02:03Z","severity":"error","message":"m","msg":"x"}` + "\n"
+	r := Options{TimeKey: "ts", LevelKey: "severity", MessageKey: "message"}.NewReader(strings.NewReader(in))
+	got := readAll(t, r)
+	want := "2023-04-03T01:02:03Z ERROR m msg=x:String"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	// A value that isn't an object is skipped.
+	r := NewReader(strings.NewReader(`[1, {"a": 2}] {"msg":"m"} {"msg":`))
+	if _, err := r.Read(); err == nil {
+		t.Error("array: got nil, want error")
+	}
+	if rec, err := r.Read(); err != nil || rec.Message != "m" {
+		t.Errorf("got %v, %v, want message m", rec.Message, err)
+	}
+	if _, err := r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated: got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
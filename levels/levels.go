@@ -0,0 +1,108 @@
+// Package levels adds the Panic and Fatal levels of logrus and zap to slog,
+// with functions that log at those levels and then panic or exit.
+//
+// The handlers in this module write these levels as PANIC and FATAL.
+// For other handlers, use [ReplaceAttr].
+package levels
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Levels above [slog.LevelError].
+const (
+	LevelPanic = slog.LevelError + 4
+	LevelFatal = slog.LevelError + 8
+)
+
+// String returns the name of l: "PANIC" and "FATAL" for exactly
+// LevelPanic and LevelFatal, and [slog.Level.String] otherwise.
+func String(l slog.Level) string {
+	switch l {
+	case LevelPanic:
+		return "PANIC"
+	case LevelFatal:
+		return "FATAL"
+	default:
+		return l.String()
+	}
+}
+
+// Parse returns the level named by s, which may be a name returned by
+// String, like "FATAL", or one accepted by [slog.Level.UnmarshalText],
+// like "WARN+2". Case is ignored.
+func Parse(s string) (slog.Level, error) {
+	switch strings.ToUpper(s) {
+	case "PANIC":
+		return LevelPanic, nil
+	case "FATAL":
+		return LevelFatal, nil
+	}
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// ReplaceAttr is a function for [slog.HandlerOptions.ReplaceAttr] that
+// writes the built-in level with String.
+func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(String(l))
+		}
+	}
+	return a
+}
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+// Fatal logs a record at LevelFatal to l, then calls os.Exit(1).
+// If l is nil, [slog.Default] is used.
+func Fatal(ctx context.Context, l *slog.Logger, msg string, args ...any) {
+	log(ctx, l, LevelFatal, msg, args)
+	exit(1)
+}
+
+// Panic logs a record at LevelPanic to l, then panics with msg.
+// If l is nil, [slog.Default] is used.
+func Panic(ctx context.Context, l *slog.Logger, msg string, args ...any) {
+	log(ctx, l, LevelPanic, msg, args)
+	panic(msg)
+}
+
+// Fatalf formats its arguments as with fmt.Sprintf and calls Fatal
+// with the result as the message.
+func Fatalf(ctx context.Context, l *slog.Logger, format string, args ...any) {
+	log(ctx, l, LevelFatal, fmt.Sprintf(format, args...), nil)
+	exit(1)
+}
+
+// Panicf formats its arguments as with fmt.Sprintf and calls Panic
+// with the result as the message.
+func Panicf(ctx context.Context, l *slog.Logger, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log(ctx, l, LevelPanic, msg, nil)
+	panic(msg)
+}
+
+// log logs with the caller of its caller as the source.
+func log(ctx context.Context, l *slog.Logger, level slog.Level, msg string, args []any) {
+	if l == nil {
+		l = slog.Default()
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, log and its caller
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Handler().Handle(ctx, r)
+}
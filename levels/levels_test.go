@@ -0,0 +1,93 @@
+package levels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelError, "ERROR"},
+		{LevelPanic, "PANIC"},
+		{LevelFatal, "FATAL"},
+		{LevelFatal + 1, "ERROR+9"},
+	} {
+		if got := String(test.level); got != test.want {
+			t.Errorf("%d: got %q, want %q", test.level, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want slog.Level
+	}{
+		{"INFO", slog.LevelInfo},
+		{"warn+2", slog.LevelWarn + 2},
+		{"PANIC", LevelPanic},
+		{"fatal", LevelFatal},
+		{"ERROR+9", LevelFatal + 1},
+	} {
+		got, err := Parse(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %v, %v, want %v", test.in, got, err, test.want)
+		}
+		// Parse reverses String.
+		if got, err := Parse(String(test.want)); err != nil || got != test.want {
+			t.Errorf("Parse(String(%d)): got %v, %v", test.want, got, err)
+		}
+	}
+	if _, err := Parse("LOUD"); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func TestFatal(t *testing.T) {
+	var code int
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{AddSource: true, ReplaceAttr: ReplaceAttr}))
+	Fatal(context.Background(), l, "bye", "a", 1)
+	_, file, line, _ := runtime.Caller(0)
+	if code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	got := buf.String()
+	for _, want := range []string{"level=FATAL", fmt.Sprintf("source=%s:%d", file, line-1), "msg=bye a=1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q does not contain %q", got, want)
+		}
+	}
+
+	buf.Reset()
+	Fatalf(context.Background(), l, "n=%d", 2)
+	if got := buf.String(); !strings.Contains(got, `level=FATAL`) || !strings.Contains(got, `msg="n=2"`) {
+		t.Errorf("Fatalf: got %q", got)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr}))
+	defer func() {
+		if p := recover(); p != "oops" {
+			t.Errorf("got panic %v, want oops", p)
+		}
+		if got := buf.String(); !strings.Contains(got, "level=PANIC msg=oops a=1") {
+			t.Errorf("got %q", got)
+		}
+	}()
+	Panic(context.Background(), l, "oops", "a", 1)
+}
@@ -0,0 +1,91 @@
+// Package levelvar supports changing the level of a [slog.LevelVar]
+// at run time, with an http.Handler or with signals.
+package levelvar
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Options are options for the handler.
+type Options struct {
+	// Lookup returns the LevelVar for the name in the "name" query
+	// parameter, and reports whether it exists. If Lookup is nil, a request
+	// with a name is answered with 404 Not Found.
+	Lookup func(name string) (*slog.LevelVar, bool)
+
+	// Create, if non-nil, is used instead of Lookup by PUT requests.
+	// It returns the LevelVar for name, creating it if necessary, so
+	// that a level can be set for a name that doesn't have one yet.
+	Create func(name string) *slog.LevelVar
+
+	// Authorize, if non-nil, is called before each request is served.
+	// If it returns an error, the request fails with 403 Forbidden.
+	Authorize func(*http.Request) error
+}
+
+// Level is the JSON body of requests and responses.
+type Level struct {
+	Name  string     `json:"name,omitempty"`
+	Level slog.Level `json:"level"`
+}
+
+// New returns a handler for lv with the default options.
+func New(lv *slog.LevelVar) http.Handler {
+	return Options{}.New(lv)
+}
+
+// New returns a handler for lv.
+//
+// A GET request responds with the current level as JSON, for example
+//
+//	{"level":"INFO"}
+//
+// A PUT request sets the level from a body of the same form, and responds
+// with the new level. Levels can be given as names like "DEBUG" or
+// "WARN+2", as accepted by [slog.Level.UnmarshalJSON].
+//
+// If the request has a "name" query parameter, the LevelVar is found
+// with [Options.Lookup], or [Options.Create] for a PUT, instead of
+// using lv.
+func (opts Options) New(lv *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.Authorize != nil {
+			if err := opts.Authorize(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		name := r.URL.Query().Get("name")
+		v := lv
+		if name != "" {
+			var ok bool
+			if r.Method == http.MethodPut && opts.Create != nil {
+				v, ok = opts.Create(name), true
+			} else if opts.Lookup != nil {
+				v, ok = opts.Lookup(name)
+			}
+			if !ok {
+				http.Error(w, "unknown name "+name, http.StatusNotFound)
+				return
+			}
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			var body Level
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			v.Set(body.Level)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Level{Name: name, Level: v.Level()})
+	})
+}
@@ -0,0 +1,83 @@
+package levelvar
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var def, db slog.LevelVar
+	opts := Options{
+		Lookup: func(name string) (*slog.LevelVar, bool) {
+			if name == "db" {
+				return &db, true
+			}
+			return nil, false
+		},
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "ok" {
+				return errors.New("no")
+			}
+			return nil
+		},
+	}
+	h := opts.New(&def)
+
+	for _, test := range []struct {
+		method, target, body string
+		noAuth               bool
+		wantCode             int
+		wantBody             string
+	}{
+		{"GET", "/", "", false, 200, `{"level":"INFO"}`},
+		{"PUT", "/", `{"level":"DEBUG"}`, false, 200, `{"level":"DEBUG"}`},
+		{"GET", "/", "", false, 200, `{"level":"DEBUG"}`},
+		{"PUT", "/?name=db", `{"level":"WARN+2"}`, false, 200, `{"name":"db","level":"WARN+2"}`},
+		{"GET", "/?name=db", "", false, 200, `{"name":"db","level":"WARN+2"}`},
+		{"GET", "/?name=nope", "", false, 404, "unknown name nope"},
+		{"PUT", "/", `{"level":"LOUD"}`, false, 400, ""},
+		{"POST", "/", "", false, 405, "method not allowed"},
+		{"GET", "/", "", true, 403, "no"},
+	} {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		if !test.noAuth {
+			req.Header.Set("Authorization", "ok")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != test.wantCode {
+			t.Errorf("%s %s: got code %d, want %d", test.method, test.target, w.Code, test.wantCode)
+		}
+		if got := strings.TrimSpace(w.Body.String()); test.wantBody != "" && got != test.wantBody {
+			t.Errorf("%s %s: got body %q, want %q", test.method, test.target, got, test.wantBody)
+		}
+	}
+	if got, want := def.Level(), slog.LevelDebug; got != want {
+		t.Errorf("default: got %s, want %s", got, want)
+	}
+}
+
+func TestHandlerCreate(t *testing.T) {
+	created := map[string]*slog.LevelVar{}
+	opts := Options{
+		Create: func(name string) *slog.LevelVar {
+			lv := &slog.LevelVar{}
+			created[name] = lv
+			return lv
+		},
+	}
+	h := opts.New(&slog.LevelVar{})
+	req := httptest.NewRequest("PUT", "/?name=new", strings.NewReader(`{"level":"WARN"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got code %d: %s", w.Code, w.Body)
+	}
+	if lv := created["new"]; lv == nil || lv.Level() != slog.LevelWarn {
+		t.Errorf("got %v, want a LevelVar set to WARN", lv)
+	}
+}
@@ -0,0 +1,93 @@
+package levelvar
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+)
+
+// Signals describes how signals change the level of a LevelVar.
+// A typical configuration for a daemon is
+//
+//	levelvar.Signals{
+//		Set:     map[os.Signal]slog.Level{syscall.SIGUSR1: slog.LevelDebug},
+//		Restore: []os.Signal{syscall.SIGUSR2},
+//	}
+type Signals struct {
+	// Set maps a signal to the level to set when it arrives.
+	Set map[os.Signal]slog.Level
+
+	// Restore lists signals that restore the level the LevelVar had
+	// when Install was called.
+	Restore []os.Signal
+
+	// Cycle lists signals that advance the level to the next one in
+	// CycleLevels, wrapping around at the end. If the current level is
+	// not in CycleLevels, the first one is used.
+	Cycle []os.Signal
+
+	// CycleLevels are the levels for Cycle. If empty, DEBUG, INFO, WARN
+	// and ERROR are used.
+	CycleLevels []slog.Level
+}
+
+// notify is signal.Notify, replaced in tests.
+var notify = signal.Notify
+
+var defaultCycleLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// Install starts handling the signals in s, changing the level of lv.
+// It returns a function that stops handling them; after it returns,
+// lv is no longer changed. The stop function may be called more than once.
+// If s has no signals, Install does nothing.
+func (s Signals) Install(lv *slog.LevelVar) (stop func()) {
+	initial := lv.Level()
+	cycleLevels := s.CycleLevels
+	if len(cycleLevels) == 0 {
+		cycleLevels = defaultCycleLevels
+	}
+	var sigs []os.Signal
+	for sig := range s.Set {
+		sigs = append(sigs, sig)
+	}
+	sigs = append(sigs, s.Restore...)
+	sigs = append(sigs, s.Cycle...)
+	if len(sigs) == 0 {
+		// signal.Notify with no signals would catch them all.
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	notify(ch, sigs...)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-ch:
+				if l, ok := s.Set[sig]; ok {
+					lv.Set(l)
+				} else if slices.Contains(s.Restore, sig) {
+					lv.Set(initial)
+				} else if slices.Contains(s.Cycle, sig) {
+					i := slices.Index(cycleLevels, lv.Level())
+					lv.Set(cycleLevels[(i+1)%len(cycleLevels)])
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package levelvar
+
+import (
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignals(t *testing.T) {
+	var lv slog.LevelVar
+	stop := Signals{
+		Set:     map[os.Signal]slog.Level{syscall.SIGUSR1: slog.LevelDebug},
+		Restore: []os.Signal{syscall.SIGUSR2},
+		Cycle:   []os.Signal{syscall.SIGHUP},
+	}.Install(&lv)
+	defer stop()
+
+	for _, test := range []struct {
+		sig  syscall.Signal
+		want slog.Level
+	}{
+		{syscall.SIGUSR1, slog.LevelDebug},
+		{syscall.SIGHUP, slog.LevelInfo},
+		{syscall.SIGHUP, slog.LevelWarn},
+		{syscall.SIGUSR2, slog.LevelInfo},
+		{syscall.SIGHUP, slog.LevelWarn},
+		{syscall.SIGHUP, slog.LevelError},
+		{syscall.SIGHUP, slog.LevelDebug},
+	} {
+		if err := syscall.Kill(os.Getpid(), test.sig); err != nil {
+			t.Fatal(err)
+		}
+		waitForLevel(t, &lv, test.want)
+	}
+	stop()
+	stop()
+}
+
+func waitForLevel(t *testing.T, lv *slog.LevelVar, want slog.Level) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for lv.Level() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got level %s, want %s", lv.Level(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNoSignals(t *testing.T) {
+	defer func(f func(chan<- os.Signal, ...os.Signal)) { notify = f }(notify)
+	notify = func(chan<- os.Signal, ...os.Signal) { t.Error("Notify called") }
+	var lv slog.LevelVar
+	stop := Signals{Set: map[os.Signal]slog.Level{}}.Install(&lv)
+	stop()
+	stop()
+}
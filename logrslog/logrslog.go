@@ -0,0 +1,167 @@
+// Package logrslog connects github.com/go-logr/logr and log/slog.
+//
+// [NewLogSink] lets code that logs with a logr.Logger write to a
+// slog.Handler, and [NewHandler] lets code that logs with a slog.Logger
+// write to a logr.Logger.
+//
+// Verbosities and levels are converted with the verbosity package.
+package logrslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jba/slog/internal/keyvals"
+	"github.com/jba/slog/verbosity"
+)
+
+// ErrorKey is the key of the Attr holding the error passed to
+// logr.Logger.Error.
+const ErrorKey = "err"
+
+// NewLogSink returns a logr.LogSink that calls h.
+//
+// Each call to WithName corresponds to a call to WithGroup, so the
+// attributes of l.WithName("a").WithName("b") appear in group "b"
+// within group "a".
+// Errors are logged at [slog.LevelError] with the error in an Attr
+// whose key is [ErrorKey].
+func NewLogSink(h slog.Handler) logr.LogSink {
+	return &logSink{h: h}
+}
+
+type logSink struct {
+	h         slog.Handler
+	callDepth int
+}
+
+var (
+	_ logr.LogSink          = (*logSink)(nil)
+	_ logr.CallDepthLogSink = (*logSink)(nil)
+)
+
+func (s *logSink) Init(info logr.RuntimeInfo) {
+	s.callDepth += info.CallDepth
+}
+
+func (s *logSink) Enabled(level int) bool {
+	return s.h.Enabled(context.Background(), verbosity.ToLevel(level))
+}
+
+func (s *logSink) Info(level int, msg string, keysAndValues ...any) {
+	s.log(verbosity.ToLevel(level), msg, keysAndValues)
+}
+
+func (s *logSink) Error(err error, msg string, keysAndValues ...any) {
+	if !s.h.Enabled(context.Background(), slog.LevelError) {
+		return
+	}
+	kvs := append([]any{slog.Any(ErrorKey, err)}, keysAndValues...)
+	s.log(slog.LevelError, msg, kvs)
+}
+
+func (s *logSink) log(level slog.Level, msg string, kvs []any) {
+	var pcs [1]uintptr
+	// Skip runtime.Callers, log, Info or Error, and the frames added by logr.
+	runtime.Callers(3+s.callDepth, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.AddAttrs(attrs(kvs)...)
+	_ = s.h.Handle(context.Background(), r) // LogSinks cannot return errors
+}
+
+func (s *logSink) WithValues(keysAndValues ...any) logr.LogSink {
+	s2 := *s
+	s2.h = s.h.WithAttrs(attrs(keysAndValues))
+	return &s2
+}
+
+func (s *logSink) WithName(name string) logr.LogSink {
+	s2 := *s
+	s2.h = s.h.WithGroup(name)
+	return &s2
+}
+
+func (s *logSink) WithCallDepth(depth int) logr.LogSink {
+	s2 := *s
+	s2.callDepth += depth
+	return &s2
+}
+
+// attrs converts logr key-value pairs to Attrs, as slog.Logger.Log would.
+// Values that implement logr.Marshaler are replaced by the result of
+// their MarshalLog method.
+// The caller's slice is not modified.
+func attrs(kvs []any) []slog.Attr {
+	copied := false
+	for i, v := range kvs {
+		if m, ok := v.(logr.Marshaler); ok {
+			if !copied {
+				kvs = slices.Clone(kvs)
+				copied = true
+			}
+			kvs[i] = m.MarshalLog()
+		}
+	}
+	return slog.Group("", kvs...).Value.Group()
+}
+
+////////////////////////////////////////////////////////////////
+
+// NewHandler returns a slog.Handler that writes to l.
+//
+// Records at [slog.LevelError] or above are logged with l.Error and a nil
+// error. Other records are logged with l.V(v).Info, where v is the
+// verbosity corresponding to the record's level; levels above
+// [slog.LevelInfo] have verbosity zero.
+//
+// Groups are represented by prefixing keys with the group name
+// and a dot. Attr values are passed to l after being resolved.
+// The record's time is not passed to l.
+func NewHandler(l logr.Logger) slog.Handler {
+	return &handler{l: l}
+}
+
+type handler struct {
+	l      logr.Logger
+	prefix string // group names, each followed by a dot
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= slog.LevelError {
+		return h.l.GetSink() != nil
+	}
+	return h.l.V(verbosity.FromLevel(level)).Enabled()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	kvs := make([]any, 0, 2*r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = keyvals.Append(kvs, h.prefix, a)
+		return true
+	})
+	if r.Level >= slog.LevelError {
+		h.l.Error(nil, r.Message, kvs...)
+	} else {
+		h.l.V(verbosity.FromLevel(r.Level)).Info(r.Message, kvs...)
+	}
+	return nil
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	var kvs []any
+	for _, a := range as {
+		kvs = keyvals.Append(kvs, h.prefix, a)
+	}
+	return &handler{l: h.l.WithValues(kvs...), prefix: h.prefix}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{l: h.l, prefix: h.prefix + name + "."}
+}
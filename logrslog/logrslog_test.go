@@ -0,0 +1,162 @@
+package logrslog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/jba/slog/handlers/memory"
+)
+
+func TestLogSink(t *testing.T) {
+	h := memory.New(&slog.HandlerOptions{Level: slog.LevelDebug})
+	l := logr.New(NewLogSink(h))
+
+	l.Info("info", "a", 1)
+	l.V(4).Info("debug")
+	l.V(5).Info("disabled")
+	l.WithValues("b", 2).WithName("n").Error(errors.New("bad"), "error", "c", secret("pw"))
+
+	h.AssertLogged(t, slog.LevelInfo, "info", slog.Int("a", 1))
+	h.AssertLogged(t, slog.LevelDebug, "debug")
+	h.AssertNotLogged(t, slog.LevelDebug-1, "disabled")
+	h.AssertLogged(t, slog.LevelError, "error",
+		slog.Int("b", 2),
+		slog.Any("n."+ErrorKey, errors.New("bad")),
+		slog.String("n.c", "***"))
+}
+
+func TestLogSinkSource(t *testing.T) {
+	h := memory.New(nil)
+	l := logr.New(NewLogSink(h))
+	helper := func(l logr.Logger) { l.WithCallDepth(1).Info("m") }
+	var wantLines []int
+	l.Info("m")
+	wantLines = append(wantLines, callerLine()-1)
+	helper(l)
+	wantLines = append(wantLines, callerLine()-1)
+
+	rs := h.Records()
+	if len(rs) != len(wantLines) {
+		t.Fatalf("got %d records, want %d", len(rs), len(wantLines))
+	}
+	for i, r := range rs {
+		fs := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := fs.Next()
+		if !strings.HasSuffix(f.File, "logrslog_test.go") || f.Line != wantLines[i] {
+			t.Errorf("got %s:%d, want line %d", f.File, f.Line, wantLines[i])
+		}
+	}
+}
+
+func callerLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+type secret string
+
+func (secret) MarshalLog() any { return "***" }
+
+func TestHandler(t *testing.T) {
+	var lines []string
+	l := funcr.New(func(prefix, args string) {
+		lines = append(lines, strings.TrimSpace(prefix+" "+args))
+	}, funcr.Options{Verbosity: 4})
+	logger := slog.New(NewHandler(l))
+
+	logger.Info("info", "a", 1)
+	logger.Debug("debug")
+	logger.Log(context.Background(), slog.LevelDebug-1, "disabled")
+	logger.Warn("warn")
+	logger.With("b", 2).WithGroup("g").Error("error", slog.Group("h", "c", 3))
+
+	want := []string{
+		`"level"=0 "msg"="info" "a"=1`,
+		`"level"=4 "msg"="debug"`,
+		`"level"=0 "msg"="warn"`,
+		`"msg"="error" "error"=null "b"=2 "g.h.c"=3`,
+	}
+	if got := strings.Join(lines, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestAttrsDoesNotModify(t *testing.T) {
+	kvs := []any{"a", 1, "s", secret("pw")}
+	as := attrs(kvs)
+	if got := as[1].Value.String(); got != "***" {
+		t.Errorf("got %q, want ***", got)
+	}
+	if kvs[3] != secret("pw") {
+		t.Errorf("caller's slice modified: %v", kvs)
+	}
+}
+
+func TestHandlerSlogtest(t *testing.T) {
+	var ms []map[string]any
+	h := NewHandler(logr.New(&mapSink{add: func(m map[string]any) { ms = append(ms, m) }}))
+	if h.WithGroup("") != h {
+		t.Error("WithGroup with an empty name did not return the receiver")
+	}
+	err := slogtest.TestHandler(h, func() []map[string]any { return ms })
+	// The record's time is not passed to the logr.Logger.
+	var errs []error
+	if e, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range e.Unwrap() {
+			if !strings.Contains(err.Error(), `missing key "time"`) {
+				errs = append(errs, err)
+			}
+		}
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		t.Error(err)
+	}
+}
+
+// mapSink is a logr.LogSink that converts each log event to a map
+// as slogtest expects, turning dotted keys into nested maps.
+type mapSink struct {
+	add    func(map[string]any)
+	values []any
+}
+
+func (s *mapSink) Init(logr.RuntimeInfo) {}
+func (s *mapSink) Enabled(int) bool      { return true }
+
+func (s *mapSink) Info(level int, msg string, kvs ...any) {
+	m := map[string]any{slog.LevelKey: level, slog.MessageKey: msg}
+	for _, kv := range [][]any{s.values, kvs} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			keys := strings.Split(kv[i].(string), ".")
+			g := m
+			for _, k := range keys[:len(keys)-1] {
+				sub, ok := g[k].(map[string]any)
+				if !ok {
+					sub = map[string]any{}
+					g[k] = sub
+				}
+				g = sub
+			}
+			g[keys[len(keys)-1]] = kv[i+1]
+		}
+	}
+	s.add(m)
+}
+
+func (s *mapSink) Error(err error, msg string, kvs ...any) { s.Info(0, msg, kvs...) }
+
+func (s *mapSink) WithValues(kvs ...any) logr.LogSink {
+	return &mapSink{add: s.add, values: append(slices.Clip(s.values), kvs...)}
+}
+
+func (s *mapSink) WithName(string) logr.LogSink { return s }
@@ -0,0 +1,116 @@
+// Package metrics counts log records.
+//
+// Handlers report events to a [Hooks]. [Counters] is a Hooks that keeps
+// counts in memory; the Collector in package
+// github.com/jba/slog/metrics/promcollector is one that exports them
+// to Prometheus. [NewHandler] adds hooks to any handler; some handlers,
+// like general.Handler, also accept Hooks directly.
+//
+// A handler from [RecorderOptions.NewHandler] derives metrics from the
+// contents of records instead, and reports them to a [Recorder].
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Hooks receives events from handlers.
+// Its methods may be called concurrently.
+type Hooks interface {
+	// OnRecord is called when a handler handles a record.
+	OnRecord(level slog.Level)
+	// OnDrop is called when a record is not output, with the reason.
+	OnDrop(reason string)
+	// OnError is called when a handler fails to output a record.
+	OnError(err error)
+}
+
+// Reasons for dropping a record.
+const (
+	DropLevel = "level" // the record reached Handle but its level was not enabled
+	DropError = "error" // the record could not be written
+)
+
+// NewHandler returns a handler that reports to hooks and passes records
+// to h. A record passed to Handle whose level h does not enable is
+// reported as a drop with reason DropLevel and not passed on; calls to
+// Enabled are not counted, since callers may ask more than once or
+// without logging. An error from h.Handle is reported to OnError and
+// as a drop with reason DropError.
+func NewHandler(h slog.Handler, hooks Hooks) slog.Handler {
+	return &handler{h: h, hooks: hooks}
+}
+
+type handler struct {
+	h     slog.Handler
+	hooks Hooks
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.h.Enabled(ctx, r.Level) {
+		h.hooks.OnDrop(DropLevel)
+		return nil
+	}
+	h.hooks.OnRecord(r.Level)
+	err := h.h.Handle(ctx, r)
+	if err != nil {
+		h.hooks.OnError(err)
+		h.hooks.OnDrop(DropError)
+	}
+	return err
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	return &handler{h: h.h.WithAttrs(as), hooks: h.hooks}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{h: h.h.WithGroup(name), hooks: h.hooks}
+}
+
+////////////////////////////////////////////////////////////////
+
+// Counters is a Hooks that counts records by level, drops by reason,
+// and errors. The zero value is ready to use.
+type Counters struct {
+	records sync.Map // slog.Level -> *atomic.Int64
+	drops   sync.Map // string -> *atomic.Int64
+	errors  atomic.Int64
+}
+
+var _ Hooks = (*Counters)(nil)
+
+func (c *Counters) OnRecord(level slog.Level) { counter(&c.records, level).Add(1) }
+func (c *Counters) OnDrop(reason string)      { counter(&c.drops, reason).Add(1) }
+func (c *Counters) OnError(error)             { c.errors.Add(1) }
+
+func counter[K comparable](m *sync.Map, k K) *atomic.Int64 {
+	if v, ok := m.Load(k); ok {
+		return v.(*atomic.Int64)
+	}
+	v, _ := m.LoadOrStore(k, &atomic.Int64{})
+	return v.(*atomic.Int64)
+}
+
+func load[K comparable](m *sync.Map, k K) int64 {
+	if v, ok := m.Load(k); ok {
+		return v.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// Records returns the number of records handled at level.
+func (c *Counters) Records(level slog.Level) int64 { return load(&c.records, level) }
+
+// Drops returns the number of records dropped for reason.
+func (c *Counters) Drops(reason string) int64 { return load(&c.drops, reason) }
+
+// Errors returns the number of errors.
+func (c *Counters) Errors() int64 { return c.errors.Load() }
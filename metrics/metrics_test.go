@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers/discard"
+)
+
+type failHandler struct{ slog.Handler }
+
+func (failHandler) Handle(context.Context, slog.Record) error { return errors.New("fail") }
+
+func TestHandler(t *testing.T) {
+	var c Counters
+	h := NewHandler(discard.New(slog.LevelInfo), &c)
+	l := slog.New(h).With("a", 1).WithGroup("g")
+	l.Debug("d")
+	h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelDebug, "d", 0))
+	l.Info("i")
+	l.Info("i")
+	l.Error("e")
+	slog.New(NewHandler(failHandler{discard.New(slog.LevelInfo)}, &c)).Warn("w")
+
+	for _, test := range []struct {
+		name      string
+		got, want int64
+	}{
+		{"DEBUG records", c.Records(slog.LevelDebug), 0},
+		{"INFO records", c.Records(slog.LevelInfo), 2},
+		{"WARN records", c.Records(slog.LevelWarn), 1},
+		{"ERROR records", c.Records(slog.LevelError), 1},
+		{"level drops", c.Drops(DropLevel), 1},
+		{"error drops", c.Drops(DropError), 1},
+		{"errors", c.Errors(), 1},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, test.got, test.want)
+		}
+	}
+
+}
@@ -0,0 +1,25 @@
+module github.com/jba/slog/metrics/promcollector
+
+go 1.21
+
+require (
+	github.com/jba/slog v0.0.0
+	github.com/prometheus/client_golang v1.19.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
+)
+
+// This replace is for development inside the repository only; it has no
+// effect on modules that import this one. Until github.com/jba/slog is
+// tagged, importers must require a pseudo-version of it that has the
+// metrics package.
+replace github.com/jba/slog => ../..
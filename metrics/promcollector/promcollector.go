@@ -0,0 +1,67 @@
+// Package promcollector exports the events of logging handlers to
+// Prometheus.
+//
+// It is a separate module so that the rest of github.com/jba/slog does
+// not depend on the Prometheus client. Its go.mod replaces
+// github.com/jba/slog with the enclosing directory, which only affects
+// builds inside the repository.
+package promcollector
+
+import (
+	"log/slog"
+
+	"github.com/jba/slog/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A Collector is a [metrics.Hooks] that is also a [prometheus.Collector].
+// It exports the counters log_records_total, with a "level" label,
+// log_dropped_total, with a "reason" label, and log_errors_total:
+//
+//	c := promcollector.New()
+//	prometheus.MustRegister(c)
+//	h := general.Options{Hooks: c}.New(os.Stderr, general.NewJSONFormatter)
+type Collector struct {
+	records *prometheus.CounterVec
+	drops   *prometheus.CounterVec
+	errors  prometheus.Counter
+}
+
+var (
+	_ metrics.Hooks        = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// New returns a new Collector.
+func New() *Collector {
+	return &Collector{
+		records: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "log_records_total",
+			Help: "Log records handled, by level.",
+		}, []string{"level"}),
+		drops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "log_dropped_total",
+			Help: "Log records dropped, by reason.",
+		}, []string{"reason"}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "log_errors_total",
+			Help: "Errors from log handlers.",
+		}),
+	}
+}
+
+func (c *Collector) OnRecord(level slog.Level) { c.records.WithLabelValues(level.String()).Inc() }
+func (c *Collector) OnDrop(reason string)      { c.drops.WithLabelValues(reason).Inc() }
+func (c *Collector) OnError(error)             { c.errors.Inc() }
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.records.Describe(ch)
+	c.drops.Describe(ch)
+	c.errors.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.records.Collect(ch)
+	c.drops.Collect(ch)
+	c.errors.Collect(ch)
+}
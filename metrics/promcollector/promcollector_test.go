@@ -0,0 +1,42 @@
+package promcollector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/handlers/discard"
+	"github.com/jba/slog/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type failHandler struct{ slog.Handler }
+
+func (failHandler) Handle(context.Context, slog.Record) error { return errors.New("fail") }
+
+func TestCollector(t *testing.T) {
+	c := New()
+	l := slog.New(metrics.NewHandler(discard.New(slog.LevelInfo), c))
+	l.Info("i")
+	l.Info("i")
+	l.Error("e")
+	slog.New(metrics.NewHandler(failHandler{discard.New(slog.LevelInfo)}, c)).Warn("w")
+
+	want := `# HELP log_dropped_total Log records dropped, by reason.
+# TYPE log_dropped_total counter
+log_dropped_total{reason="error"} 1
+# HELP log_errors_total Errors from log handlers.
+# TYPE log_errors_total counter
+log_errors_total 1
+# HELP log_records_total Log records handled, by level.
+# TYPE log_records_total counter
+log_records_total{level="ERROR"} 1
+log_records_total{level="INFO"} 2
+log_records_total{level="WARN"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Recorder receives metrics derived from log records by the handler
+// returned by [RecorderOptions.NewHandler]. Implement it with a metrics
+// library to derive request, error and duration metrics from existing
+// log calls. Its methods may be called concurrently.
+//
+// The msg argument is the record's message, which in slog is usually a
+// constant that identifies the call site, with variable data in Attrs.
+// The exemplar holds the Attrs named by RecorderOptions.ExemplarKeys,
+// like a trace ID, for libraries that attach exemplars to samples.
+type Recorder interface {
+	// Count adds one to the counter for the level and message.
+	Count(level slog.Level, msg string, exemplar []slog.Attr)
+	// Observe adds d to the histogram for the level and message.
+	Observe(level slog.Level, msg string, d time.Duration, exemplar []slog.Attr)
+}
+
+// OtherMessage replaces messages beyond the limit set by
+// RecorderOptions.MaxMessages.
+const OtherMessage = "other"
+
+// RecorderOptions are options for a handler that reports to a [Recorder].
+type RecorderOptions struct {
+	// Level reports the minimum level of records to report.
+	// If nil, [slog.LevelInfo] is used.
+	Level slog.Leveler
+
+	// DurationKey, if non-empty, is the key of a top-level Attr of kind
+	// [slog.KindDuration] whose value is observed, such as the latency
+	// of a request. Attrs from WithAttrs count if no group was opened
+	// before them.
+	DurationKey string
+
+	// ExemplarKeys are the keys of top-level Attrs passed as exemplars.
+	ExemplarKeys []string
+
+	// MaxMessages bounds the number of distinct messages reported, to
+	// keep the number of time series in check when messages are built
+	// from variable data. Messages seen after the limit is reached are
+	// reported as OtherMessage. If zero, 1000 is used.
+	MaxMessages int
+}
+
+// NewHandler returns a handler that writes nothing, but reports each
+// record it handles to rec: a count, and an observation if the record
+// has an Attr with the DurationKey. To keep writing logs, send records
+// to both it and the handler that writes them.
+func (opts RecorderOptions) NewHandler(rec Recorder) slog.Handler {
+	if opts.Level == nil {
+		opts.Level = slog.LevelInfo
+	}
+	if opts.MaxMessages <= 0 {
+		opts.MaxMessages = 1000
+	}
+	return &recorderHandler{opts: &opts, rec: rec, msgs: &messageSet{}}
+}
+
+type recorderHandler struct {
+	opts    *RecorderOptions
+	rec     Recorder
+	msgs    *messageSet // shared among clones
+	attrs   []slog.Attr // top-level Attrs from WithAttrs that matter
+	grouped bool        // WithGroup was called
+}
+
+// messageSet holds the distinct messages that have been reported.
+type messageSet struct {
+	m sync.Map // string to struct{}
+	n atomic.Int64
+}
+
+// message returns msg, or OtherMessage if the limit is reached and msg
+// is new.
+func (s *messageSet) message(msg string, max int) string {
+	if _, ok := s.m.Load(msg); ok {
+		return msg
+	}
+	if s.n.Add(1) > int64(max) {
+		s.n.Add(-1)
+		return OtherMessage
+	}
+	if _, loaded := s.m.LoadOrStore(msg, struct{}{}); loaded {
+		s.n.Add(-1)
+	}
+	return msg
+}
+
+func (h *recorderHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.opts.Level.Level()
+}
+
+func (h *recorderHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	if h.grouped {
+		return h
+	}
+	var keep []slog.Attr
+	for _, a := range as {
+		if h.matters(a.Key) {
+			keep = append(keep, a)
+		}
+	}
+	if len(keep) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(slices.Clip(h.attrs), keep...)
+	return &h2
+}
+
+func (h *recorderHandler) WithGroup(name string) slog.Handler {
+	if name == "" || h.grouped {
+		return h
+	}
+	h2 := *h
+	h2.grouped = true
+	return &h2
+}
+
+// matters reports whether an Attr with the key is used.
+func (h *recorderHandler) matters(key string) bool {
+	return (key == h.opts.DurationKey && key != "") || slices.Contains(h.opts.ExemplarKeys, key)
+}
+
+func (h *recorderHandler) Handle(_ context.Context, r slog.Record) error {
+	var (
+		d        time.Duration
+		hasD     bool
+		exemplar []slog.Attr
+	)
+	visit := func(a slog.Attr) bool {
+		if !h.matters(a.Key) {
+			return true
+		}
+		a.Value = a.Value.Resolve()
+		if a.Key == h.opts.DurationKey {
+			if a.Value.Kind() == slog.KindDuration {
+				d, hasD = a.Value.Duration(), true
+			}
+		} else {
+			// A later Attr replaces an earlier one.
+			if i := slices.IndexFunc(exemplar, func(e slog.Attr) bool { return e.Key == a.Key }); i >= 0 {
+				exemplar[i] = a
+			} else {
+				exemplar = append(exemplar, a)
+			}
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		visit(a)
+	}
+	if !h.grouped {
+		r.Attrs(visit)
+	}
+	msg := h.msgs.message(r.Message, h.opts.MaxMessages)
+	h.rec.Count(r.Level, msg, exemplar)
+	if hasD {
+		h.rec.Observe(r.Level, msg, d, exemplar)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeRecorder records calls as strings.
+type fakeRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *fakeRecorder) Count(l slog.Level, msg string, ex []slog.Attr) {
+	r.add(fmt.Sprintf("count %s %q %v", l, msg, ex))
+}
+
+func (r *fakeRecorder) Observe(l slog.Level, msg string, d time.Duration, ex []slog.Attr) {
+	r.add(fmt.Sprintf("observe %s %q %s %v", l, msg, d, ex))
+}
+
+func (r *fakeRecorder) add(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, s)
+}
+
+func TestRecorderHandler(t *testing.T) {
+	rec := &fakeRecorder{}
+	h := RecorderOptions{DurationKey: "latency", ExemplarKeys: []string{"trace_id", "user"}}.NewHandler(rec)
+	l := slog.New(h).With("trace_id", "t1", "other", 1)
+	l.Debug("disabled", "latency", time.Second)
+	l.Info("request", "latency", 2*time.Second, "trace_id", "t2")
+	l.Warn("slow", "latency", "not a duration")
+	l.WithGroup("g").Error("failed", "latency", time.Second, "user", "ann")
+	want := []string{
+		`count INFO "request" [trace_id=t2]`,
+		`observe INFO "request" 2s [trace_id=t2]`,
+		`count WARN "slow" [trace_id=t1]`,
+		`count ERROR "failed" [trace_id=t1]`,
+	}
+	if diff := cmp.Diff(want, rec.calls); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRecorderMaxMessages(t *testing.T) {
+	rec := &fakeRecorder{}
+	l := slog.New(RecorderOptions{MaxMessages: 2}.NewHandler(rec))
+	for _, msg := range []string{"a", "b", "c", "a", "d"} {
+		l.Info(msg)
+	}
+	want := []string{
+		`count INFO "a" []`,
+		`count INFO "b" []`,
+		`count INFO "other" []`,
+		`count INFO "a" []`,
+		`count INFO "other" []`,
+	}
+	if diff := cmp.Diff(want, rec.calls); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status describes the health of a part of a logging pipeline,
+// like a handler or a writer. Fields that don't apply are zero.
+type Status struct {
+	// Records is the number of records handled or written.
+	Records int64 `json:"records"`
+	// Bytes is the number of bytes written.
+	Bytes int64 `json:"bytes"`
+	// Errors is the number of errors.
+	Errors int64 `json:"errors"`
+	// QueueDepth is the number of records waiting to be written.
+	QueueDepth int64 `json:"queue_depth"`
+	// LastError is the text of the most recent error, and LastErrorTime
+	// is when it happened. They are zero if there have been no errors.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+}
+
+// A StatusReporter reports its Status. Handlers and writers in this
+// module that keep counts implement it, by convention with a method
+// that is safe to call concurrently with their other methods.
+type StatusReporter interface {
+	Status() Status
+}
+
+// A Tracker keeps the counts for a Status. Components embed or hold one,
+// and call its methods as records are written. The zero value is ready
+// to use. Its methods may be called concurrently.
+type Tracker struct {
+	records, bytes, errors atomic.Int64
+	lastErr                atomic.Pointer[errorAt]
+}
+
+type errorAt struct {
+	msg string
+	t   time.Time
+}
+
+// Wrote records that a record of n bytes was written.
+func (t *Tracker) Wrote(n int) {
+	t.records.Add(1)
+	t.bytes.Add(int64(n))
+}
+
+// Failed records err.
+func (t *Tracker) Failed(err error) {
+	t.errors.Add(1)
+	t.lastErr.Store(&errorAt{err.Error(), time.Now()})
+}
+
+// Status returns the counts. Its QueueDepth is zero.
+func (t *Tracker) Status() Status {
+	s := Status{
+		Records: t.records.Load(),
+		Bytes:   t.bytes.Load(),
+		Errors:  t.errors.Load(),
+	}
+	if e := t.lastErr.Load(); e != nil {
+		s.LastError = e.msg
+		s.LastErrorTime = e.t
+	}
+	return s
+}
+
+// Status returns the number of records and errors counted by c.
+func (c *Counters) Status() Status {
+	var n int64
+	c.records.Range(func(_, v any) bool {
+		n += v.(*atomic.Int64).Load()
+		return true
+	})
+	return Status{Records: n, Errors: c.Errors()}
+}
+
+// A Reporter collects the Statuses of the named parts of a pipeline.
+// Its ServeHTTP method writes them as a JSON object whose keys are the
+// names, so that it can be installed as a health endpoint:
+//
+//	var rep metrics.Reporter
+//	rep.Add("stderr", h)
+//	rep.Add("collector", w)
+//	http.Handle("/debug/logstatus", &rep)
+//
+// The zero value is ready to use.
+type Reporter struct {
+	mu        sync.Mutex
+	reporters map[string]StatusReporter
+}
+
+// Add adds r to the Reporter with the given name,
+// replacing any with the same name.
+func (rep *Reporter) Add(name string, r StatusReporter) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.reporters == nil {
+		rep.reporters = map[string]StatusReporter{}
+	}
+	rep.reporters[name] = r
+}
+
+// Statuses returns the current Status of each part, by name.
+func (rep *Reporter) Statuses() map[string]Status {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	m := make(map[string]Status, len(rep.reporters))
+	for name, r := range rep.reporters {
+		m[name] = r.Status()
+	}
+	return m
+}
+
+// ServeHTTP writes the Statuses as JSON.
+func (rep *Reporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.MarshalIndent(rep.Statuses(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
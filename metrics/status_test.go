@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	var tr Tracker
+	if s := tr.Status(); s != (Status{}) {
+		t.Errorf("zero Tracker: got %+v", s)
+	}
+	tr.Wrote(10)
+	tr.Wrote(5)
+	before := time.Now()
+	tr.Failed(errors.New("boom"))
+	s := tr.Status()
+	if s.Records != 2 || s.Bytes != 15 || s.Errors != 1 || s.LastError != "boom" {
+		t.Errorf("got %+v", s)
+	}
+	if s.LastErrorTime.Before(before) {
+		t.Errorf("LastErrorTime %s is before the error", s.LastErrorTime)
+	}
+}
+
+type fixedStatus Status
+
+func (s fixedStatus) Status() Status { return Status(s) }
+
+func TestReporter(t *testing.T) {
+	var c Counters
+	c.OnRecord(0)
+	c.OnRecord(4)
+	c.OnError(nil)
+	var rep Reporter
+	rep.Add("counters", &c)
+	rep.Add("writer", fixedStatus{Records: 7, Bytes: 70, QueueDepth: 3})
+
+	w := httptest.NewRecorder()
+	rep.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type: got %q, want %q", got, want)
+	}
+	var got map[string]Status
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{
+		"counters": {Records: 2, Errors: 1},
+		"writer":   {Records: 7, Bytes: 70, QueueDepth: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for name, ws := range want {
+		if gs := got[name]; gs != ws {
+			t.Errorf("%s: got %+v, want %+v", name, gs, ws)
+		}
+	}
+}
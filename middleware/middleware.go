@@ -0,0 +1,87 @@
+// Package middleware composes handlers that wrap other handlers.
+//
+// A [Middleware] wraps a handler in another. [Chain] combines several
+// into one, and [Transform] makes a Middleware from a [RecordTransformer],
+// which changes or drops records without having to implement the rest
+// of [slog.Handler]:
+//
+//	h := middleware.Chain(
+//		middleware.Transform(enrich),
+//		middleware.Transform(sample),
+//	)(slog.NewJSONHandler(w, nil))
+package middleware
+
+import (
+	"context"
+	"log/slog"
+)
+
+// A Middleware returns a handler that wraps h.
+type Middleware func(h slog.Handler) slog.Handler
+
+// Chain returns a Middleware that applies ms in order, so that the
+// first sees records first. Chain() returns the handler it is given.
+func Chain(ms ...Middleware) Middleware {
+	return func(h slog.Handler) slog.Handler {
+		for i := len(ms) - 1; i >= 0; i-- {
+			h = ms[i](h)
+		}
+		return h
+	}
+}
+
+// A RecordTransformer changes records before they are handled.
+type RecordTransformer interface {
+	// Transform returns the record to handle in place of r, and whether
+	// to handle it at all. It must not modify r's attributes; to change
+	// them, build a new record or use [slog.Record.Clone].
+	Transform(ctx context.Context, r slog.Record) (slog.Record, bool)
+}
+
+// RecordTransformerFunc is a function that implements [RecordTransformer].
+type RecordTransformerFunc func(ctx context.Context, r slog.Record) (slog.Record, bool)
+
+func (f RecordTransformerFunc) Transform(ctx context.Context, r slog.Record) (slog.Record, bool) {
+	return f(ctx, r)
+}
+
+// Transform returns a Middleware whose handlers pass records through t
+// before handling them.
+//
+// The handlers delegate Enabled, WithAttrs and WithGroup to the handler
+// they wrap, so t sees only the attributes of each record, not those
+// added by WithAttrs, and attributes that t adds are in the groups
+// added by WithGroup.
+func Transform(t RecordTransformer) Middleware {
+	return func(h slog.Handler) slog.Handler {
+		return &transformer{t, h}
+	}
+}
+
+type transformer struct {
+	t RecordTransformer
+	h slog.Handler
+}
+
+func (t *transformer) Enabled(ctx context.Context, level slog.Level) bool {
+	return t.h.Enabled(ctx, level)
+}
+
+// Handle calls the next handler with the transformed record, if
+// the RecordTransformer keeps it. The level of the transformed record
+// is not checked with Enabled.
+func (t *transformer) Handle(ctx context.Context, r slog.Record) error {
+	r, ok := t.t.Transform(ctx, r)
+	if !ok {
+		return nil
+	}
+	return t.h.Handle(ctx, r)
+}
+
+func (t *transformer) WithAttrs(as []slog.Attr) slog.Handler {
+	return &transformer{t.t, t.h.WithAttrs(as)}
+}
+
+func (t *transformer) WithGroup(name string) slog.Handler {
+	return &transformer{t.t, t.h.WithGroup(name)}
+}
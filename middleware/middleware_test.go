@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return Transform(RecordTransformerFunc(func(_ context.Context, r slog.Record) (slog.Record, bool) {
+			order = append(order, name)
+			return r, true
+		}))
+	}
+	var buf bytes.Buffer
+	h := Chain(mark("a"), mark("b"), mark("c"))(slog.NewTextHandler(&buf, nil))
+	slog.New(h).Info("m")
+	if got, want := strings.Join(order, ""), "abc"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	inner := slog.NewTextHandler(&buf, nil)
+	if got := Chain()(inner); got != inner {
+		t.Errorf("Chain(): got %v, want the handler", got)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	// Add an attribute and drop records with a "drop" attribute.
+	tr := RecordTransformerFunc(func(_ context.Context, r slog.Record) (slog.Record, bool) {
+		drop := false
+		r.Attrs(func(a slog.Attr) bool {
+			drop = drop || a.Key == "drop"
+			return !drop
+		})
+		if drop {
+			return r, false
+		}
+		r = r.Clone()
+		r.AddAttrs(slog.Bool("seen", true))
+		return r, true
+	})
+	var buf bytes.Buffer
+	inner := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := slog.New(Transform(tr)(inner)).With("a", 1).WithGroup("g")
+	l.Info("disabled")
+	l.Warn("kept", "b", 2)
+	l.Warn("dropped", "drop", true)
+	l.Error("kept2")
+
+	want := "level=WARN msg=kept a=1 g.b=2 g.seen=true\n" +
+		"level=ERROR msg=kept2 a=1 g.seen=true\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if l.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Info is enabled, want disabled")
+	}
+}
@@ -0,0 +1,211 @@
+// Package oplog provides a slog.Handler that collects the records of an
+// operation, such as an HTTP request, and logs them as a single entry
+// when the operation finishes, as App Engine groups the logs of a request.
+//
+// Call [Handler.Begin] at the start of the operation to get a context
+// that marks it. Records logged with that context (or one derived from it)
+// are held until the context is done or [Handler.Flush] is called. Then
+// the Handler's next handler receives a single parent record that holds
+// them all. Records logged with other contexts pass through immediately.
+package oplog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/slogmap"
+	"github.com/jba/slog/withsupport"
+)
+
+// A Mode determines how the records of an operation are combined.
+type Mode int
+
+const (
+	// ModeGroup writes each record as a group whose key is the record's
+	// index: records.0, records.1 and so on.
+	ModeGroup Mode = iota
+	// ModeArray writes the records as a []map[string]any, which JSON
+	// handlers write as an array of objects.
+	ModeArray
+)
+
+// Options are options for a [Handler].
+type Options struct {
+	// Mode determines how the records are combined. The default is ModeGroup.
+	Mode Mode
+
+	// Message is the message of the parent record.
+	// If empty, "operation" is used.
+	Message string
+
+	// Key is the key of the Attr that holds the records.
+	// If empty, "records" is used.
+	Key string
+
+	// OnError, if non-nil, is called with the error from writing a parent
+	// record when the operation's context is done. Errors from Flush are
+	// returned instead.
+	OnError func(error)
+}
+
+// Handler collects the records of operations. See the package
+// documentation for details.
+//
+// The parent record's time is the time Begin was called, its level is the
+// highest level of the records, and its Attrs are those passed to Begin,
+// followed by the records. Each record has the time, level and message
+// under the usual keys, followed by its Attrs, including those from
+// WithAttrs and WithGroup.
+type Handler struct {
+	opts  Options
+	next  slog.Handler
+	inner slog.Handler // next, with the groups and attrs of goa
+	goa   *withsupport.GroupOrAttrs
+	id    *int // identifies the Handler and its clones in contexts
+}
+
+// New returns a Handler that writes to next with the default options.
+func New(next slog.Handler) *Handler {
+	return Options{}.New(next)
+}
+
+// New returns a Handler that writes to next.
+func (opts Options) New(next slog.Handler) *Handler {
+	if opts.Message == "" {
+		opts.Message = "operation"
+	}
+	if opts.Key == "" {
+		opts.Key = "records"
+	}
+	return &Handler{opts: opts, next: next, inner: next, id: new(int)}
+}
+
+// An operation holds the records of one operation.
+type operation struct {
+	start time.Time
+	attrs []slog.Attr
+	stop  func() bool // stops the context.AfterFunc
+
+	mu      sync.Mutex
+	done    bool
+	entries []entry
+}
+
+// An entry is a record together with the state of the Handler that
+// received it.
+type entry struct {
+	r   slog.Record
+	goa *withsupport.GroupOrAttrs
+}
+
+type opKey struct{ id *int }
+
+// Begin returns a context that marks the start of an operation.
+// The attrs are added to the parent record. When the context is done,
+// the records of the operation are written.
+func (h *Handler) Begin(ctx context.Context, attrs ...slog.Attr) context.Context {
+	op := &operation{start: time.Now(), attrs: slices.Clone(attrs)}
+	ctx = context.WithValue(ctx, opKey{h.id}, op)
+	op.stop = context.AfterFunc(ctx, func() {
+		if err := h.flush(context.WithoutCancel(ctx), op); err != nil && h.opts.OnError != nil {
+			h.opts.OnError(err)
+		}
+	})
+	return ctx
+}
+
+// Flush writes the records of the operation marked by ctx, if any, and
+// ends it. Later records logged with ctx pass through immediately.
+func (h *Handler) Flush(ctx context.Context) error {
+	op, ok := ctx.Value(opKey{h.id}).(*operation)
+	if !ok {
+		return nil
+	}
+	op.stop()
+	return h.flush(ctx, op)
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithGroup(name)
+	h2.inner = h.inner.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.goa = h2.goa.WithAttrs(as)
+	h2.inner = h.inner.WithAttrs(as)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if op, ok := ctx.Value(opKey{h.id}).(*operation); ok {
+		op.mu.Lock()
+		if !op.done {
+			op.entries = append(op.entries, entry{r.Clone(), h.goa})
+			op.mu.Unlock()
+			return nil
+		}
+		op.mu.Unlock()
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// flush ends op and writes its parent record, if it has any records.
+func (h *Handler) flush(ctx context.Context, op *operation) error {
+	op.mu.Lock()
+	if op.done {
+		op.mu.Unlock()
+		return nil
+	}
+	op.done = true
+	entries := op.entries
+	op.entries = nil
+	op.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	level := entries[0].r.Level
+	for _, e := range entries[1:] {
+		level = max(level, e.r.Level)
+	}
+	p := slog.NewRecord(op.start, level, h.opts.Message, 0)
+	p.AddAttrs(op.attrs...)
+	switch h.opts.Mode {
+	case ModeArray:
+		ms := make([]map[string]any, len(entries))
+		for i, e := range entries {
+			ms[i] = slogmap.FromAttrs(e.attrs())
+		}
+		p.AddAttrs(slog.Any(h.opts.Key, ms))
+	default:
+		gs := make([]slog.Attr, len(entries))
+		for i, e := range entries {
+			gs[i] = slog.Attr{Key: strconv.Itoa(i), Value: slog.GroupValue(e.attrs()...)}
+		}
+		p.AddAttrs(slog.Attr{Key: h.opts.Key, Value: slog.GroupValue(gs...)})
+	}
+	return h.next.Handle(ctx, p)
+}
+
+// attrs returns the built-in Attrs of e followed by its other Attrs,
+// within the groups of WithGroup.
+func (e entry) attrs() []slog.Attr {
+	attrs := e.goa.Nest(withsupport.RecordAttrs(e.r))
+	builtins := []slog.Attr{
+		slog.Time(slog.TimeKey, e.r.Time),
+		slog.Any(slog.LevelKey, e.r.Level),
+		slog.String(slog.MessageKey, e.r.Message),
+	}
+	return append(builtins, attrs...)
+}
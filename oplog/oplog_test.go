@@ -0,0 +1,111 @@
+package oplog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jba/slog/handlertest"
+)
+
+func removeTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func TestGroup(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	l := slog.New(h)
+	ctx := h.Begin(context.Background(), slog.String("path", "/x"))
+	l.InfoContext(ctx, "one", "a", 1)
+	l.With("b", 2).WithGroup("g").WarnContext(ctx, "two", "c", 3)
+	l.Info("other")
+	if err := h.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l.InfoContext(ctx, "after")
+	got := buf.String()
+	want := `level=INFO msg=other
+level=WARN msg=operation path=/x records.0.level=INFO records.0.msg=one records.0.a=1 records.1.level=WARN records.1.msg=two records.1.b=2 records.1.g.c=3
+level=INFO msg=after
+`
+	if got != want {
+		t.Errorf("\ngot\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestArrayOnDone(t *testing.T) {
+	w := make(chanWriter, 1)
+	h := Options{Mode: ModeArray, Message: "request", Key: "logs"}.
+		New(slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	l := slog.New(h)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = h.Begin(ctx)
+	l.InfoContext(ctx, "one", slog.Group("g", "a", 1))
+	l.ErrorContext(ctx, "two")
+	cancel()
+	// The records are written in another goroutine.
+	var data []byte
+	select {
+	case data = <-w:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("%v: %q", err, data)
+	}
+	logs := got["logs"].([]any)
+	for _, l := range logs {
+		delete(l.(map[string]any), slog.TimeKey)
+	}
+	want := map[string]any{
+		"level": "ERROR",
+		"msg":   "request",
+		"logs": []any{
+			map[string]any{"level": "INFO", "msg": "one", "g": map[string]any{"a": 1.0}},
+			map[string]any{"level": "ERROR", "msg": "two"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+// chanWriter sends each write to the channel.
+type chanWriter chan []byte
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- bytes.Clone(p)
+	return len(p), nil
+}
+
+func TestEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(slog.NewTextHandler(&buf, nil))
+	ctx := h.Begin(context.Background())
+	if err := h.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q, want no output", buf.String())
+	}
+}
+
+func TestConformance(t *testing.T) {
+	// Records outside an operation pass through to the next handler.
+	handlertest.TestHandler(t, func(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+		return New(slog.NewJSONHandler(w, opts))
+	}, handlertest.ParseJSON)
+}
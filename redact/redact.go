@@ -0,0 +1,150 @@
+// Package redact removes sensitive data from attributes.
+//
+// A [Redactor] replaces the values of attributes whose keys look
+// sensitive, like "password", and the parts of string values that match
+// patterns, like email addresses. Its ReplaceAttr method can be used
+// in [slog.HandlerOptions] or [general.Options]:
+//
+//	r := redact.Options{Values: []*regexp.Regexp{redact.Email}}.New()
+//	h := slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: r.ReplaceAttr})
+//
+// A [Reloadable] is used the same way, and its rules can be replaced
+// while the program runs, for example from a file with [Reloadable.WatchFile].
+package redact
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// DefaultKeys are the key substrings used when Options.Keys is nil.
+var DefaultKeys = []string{"password", "passwd", "secret", "token", "authorization", "api_key", "apikey"}
+
+// Patterns for common sensitive values.
+var (
+	Email      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	CreditCard = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// Options are options for a [Redactor].
+type Options struct {
+	// Keys are substrings of keys whose values should be redacted.
+	// Matching ignores case. A key matches if it, or the name of any
+	// group containing it, contains one of the substrings.
+	// If nil, DefaultKeys is used.
+	Keys []string
+
+	// Values are patterns to redact from string and error values.
+	// Only the matching parts of a value are replaced.
+	Values []*regexp.Regexp
+
+	// Replacement replaces redacted values.
+	// If empty, "[REDACTED]" is used.
+	Replacement string
+}
+
+// A Redactor redacts attributes.
+type Redactor struct {
+	keys        []string // lower case
+	values      []*regexp.Regexp
+	replacement string
+}
+
+// New returns a Redactor with the given options.
+func (opts Options) New() *Redactor {
+	r := &Redactor{
+		values:      opts.Values,
+		replacement: opts.Replacement,
+	}
+	keys := opts.Keys
+	if keys == nil {
+		keys = DefaultKeys
+	}
+	for _, k := range keys {
+		r.keys = append(r.keys, strings.ToLower(k))
+	}
+	if r.replacement == "" {
+		r.replacement = "[REDACTED]"
+	}
+	return r
+}
+
+// ReplaceAttr redacts a, which is in the given groups.
+// It has the signature of the ReplaceAttr field of [slog.HandlerOptions].
+func (r *Redactor) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	for _, g := range groups {
+		if r.sensitiveKey(g) {
+			return slog.String(a.Key, r.replacement)
+		}
+	}
+	return r.Redact(a)
+}
+
+// Redact returns a with sensitive data replaced.
+// LogValuers are resolved, and the members of groups are redacted as well,
+// so Redact can be used on attributes that will not be passed to a
+// ReplaceAttr function.
+func (r *Redactor) Redact(a slog.Attr) slog.Attr {
+	a, _ = r.redact(a)
+	return a
+}
+
+// redact redacts a and reports whether it changed.
+func (r *Redactor) redact(a slog.Attr) (slog.Attr, bool) {
+	if r.sensitiveKey(a.Key) {
+		return slog.String(a.Key, r.replacement), true
+	}
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		as := a.Value.Group()
+		var as2 []slog.Attr // nil until something changes
+		for i, ga := range as {
+			ga2, changed := r.redact(ga)
+			if changed && as2 == nil {
+				as2 = append(make([]slog.Attr, 0, len(as)), as[:i]...)
+			}
+			if as2 != nil {
+				as2 = append(as2, ga2)
+			}
+		}
+		if as2 != nil {
+			return slog.Attr{Key: a.Key, Value: slog.GroupValue(as2...)}, true
+		}
+	case slog.KindString:
+		if s, ok := r.redactString(a.Value.String()); ok {
+			return slog.String(a.Key, s), true
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			if s, ok := r.redactString(err.Error()); ok {
+				return slog.String(a.Key, s), true
+			}
+		}
+	}
+	return a, false
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces the parts of s that match r's value patterns.
+// It reports whether anything was replaced.
+func (r *Redactor) redactString(s string) (string, bool) {
+	changed := false
+	for _, re := range r.values {
+		if re.MatchString(s) {
+			s = re.ReplaceAllLiteralString(s, r.replacement)
+			changed = true
+		}
+	}
+	return s, changed
+}
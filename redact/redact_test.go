@@ -0,0 +1,55 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type user struct{ name, password string }
+
+func (u user) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", u.name), slog.String("password", u.password))
+}
+
+func TestHandler(t *testing.T) {
+	r := Options{Values: []*regexp.Regexp{Email, CreditCard}}.New()
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: r.ReplaceAttr}))
+	l.With("Access_Token", "abc").WithGroup("req").Info("m",
+		"note", "mail bob@example.com or pay 4111 1111 1111 1111",
+		slog.Group("Authorization", "scheme", "Bearer", "value", "xyz"),
+		"user", user{"pat", "hunter2"},
+		"err", errors.New("no user al@example.org"),
+		"n", 3,
+	)
+	got := buf.String()
+	got = got[strings.Index(got, "level="):]
+	want := `level=INFO msg=m Access_Token=[REDACTED] req.note="mail [REDACTED] or pay [REDACTED]" ` +
+		`req.Authorization.scheme=[REDACTED] req.Authorization.value=[REDACTED] ` +
+		`req.user.name=pat req.user.password=[REDACTED] req.err="no user [REDACTED]" req.n=3` + "\n"
+	if got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	r := Options{Keys: []string{"ssn"}, Replacement: "***"}.New()
+	for _, test := range []struct {
+		in   slog.Attr
+		want string
+	}{
+		{slog.Int("SSN", 1), "SSN=***"},
+		{slog.String("password", "p"), "password=p"},
+		{slog.Group("g", slog.Int("a", 1), slog.String("my_ssn", "x")), "g=[a=1 my_ssn=***]"},
+		{slog.Any("u", user{"n", "p"}), "u=[name=n password=p]"},
+		{slog.Any("b", []byte("x")), "b=[120]"},
+	} {
+		if got := r.Redact(test.in).String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rules are redaction options in a form that can be read from a file or
+// received over a network. Their JSON form is, for example,
+//
+//	{
+//	  "keys": ["password", "ssn"],
+//	  "values": ["\\b\\d{3}-\\d{2}-\\d{4}\\b"],
+//	  "replacement": "***"
+//	}
+type Rules struct {
+	// Keys is as in [Options]. If it is absent, DefaultKeys is used.
+	Keys []string `json:"keys,omitempty"`
+
+	// Values are regular expressions in the syntax of package regexp,
+	// compiled to the Values of Options.
+	Values []string `json:"values,omitempty"`
+
+	// Replacement is as in Options.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// ReadRules reads Rules as JSON from r. Unknown fields are an error.
+func ReadRules(r io.Reader) (Rules, error) {
+	var rules Rules
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return Rules{}, fmt.Errorf("redact: reading rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Options compiles the patterns of rules. If any do not compile, it
+// returns an error that describes all of them.
+func (rules Rules) Options() (Options, error) {
+	opts := Options{Keys: rules.Keys, Replacement: rules.Replacement}
+	var errs []error
+	for i, p := range rules.Values {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("redact: value %d: %w", i, err))
+			continue
+		}
+		opts.Values = append(opts.Values, re)
+	}
+	if len(errs) > 0 {
+		return Options{}, errors.Join(errs...)
+	}
+	return opts, nil
+}
+
+// A Reloadable is a [Redactor] whose rules can be replaced while it is
+// in use, so that new patterns can be deployed without restarting the
+// program. Each call to ReplaceAttr or Redact uses the rules current at
+// the time of the call. Its methods may be called concurrently.
+type Reloadable struct {
+	r atomic.Pointer[Redactor]
+}
+
+// NewReloadable returns a Reloadable that starts with the given options.
+func NewReloadable(opts Options) *Reloadable {
+	rl := &Reloadable{}
+	rl.r.Store(opts.New())
+	return rl
+}
+
+// Redactor returns the current Redactor.
+func (rl *Reloadable) Redactor() *Redactor {
+	return rl.r.Load()
+}
+
+// ReplaceAttr is like [Redactor.ReplaceAttr], with the current rules.
+func (rl *Reloadable) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	return rl.r.Load().ReplaceAttr(groups, a)
+}
+
+// Redact is like [Redactor.Redact], with the current rules.
+func (rl *Reloadable) Redact(a slog.Attr) slog.Attr {
+	return rl.r.Load().Redact(a)
+}
+
+// Set replaces the rules with opts.
+func (rl *Reloadable) Set(opts Options) {
+	rl.r.Store(opts.New())
+}
+
+// Load replaces the rules with rules. If they are invalid, it returns
+// an error and the rules are unchanged.
+func (rl *Reloadable) Load(rules Rules) error {
+	opts, err := rules.Options()
+	if err != nil {
+		return err
+	}
+	rl.Set(opts)
+	return nil
+}
+
+// Read replaces the rules with ones read as JSON from r, which may be a
+// file or the body of a response from a configuration service. If they
+// cannot be read or are invalid, the rules are unchanged.
+func (rl *Reloadable) Read(r io.Reader) error {
+	rules, err := ReadRules(r)
+	if err != nil {
+		return err
+	}
+	return rl.Load(rules)
+}
+
+// ReadFile is like Read, reading the file with the given name.
+func (rl *Reloadable) ReadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rl.Read(f)
+}
+
+// WatchFile reads the rules from the named file with ReadFile whenever
+// its modification time or size changes, checking every interval. The
+// file is read once before WatchFile returns, and its error, if any, is
+// returned along with the stop function. Later errors, which leave the
+// rules unchanged, are passed to onError if it is non-nil.
+//
+// WatchFile returns a function that stops watching; after it returns,
+// the rules are no longer changed. The stop function may be called more
+// than once.
+func (rl *Reloadable) WatchFile(name string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	var last os.FileInfo
+	check := func() error {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			return nil
+		}
+		// Remember the file even if it is invalid, so the same
+		// error isn't reported at every interval.
+		last = info
+		return rl.ReadFile(name)
+	}
+	err = check()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := check(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}, err
+}
@@ -0,0 +1,150 @@
+package redact
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRulesOptions(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(`{"keys": ["ssn"], "values": ["\\d{3}-\\d{4}"], "replacement": "#"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := rules.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := opts.New()
+	for _, test := range []struct {
+		in   slog.Attr
+		want string
+	}{
+		{slog.Int("my_ssn", 1), "my_ssn=#"},
+		{slog.String("phone", "call 555-1234"), "phone=call #"},
+		{slog.String("password", "p"), "password=p"},
+	} {
+		if got := r.Redact(test.in).String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.in, got, test.want)
+		}
+	}
+
+	if _, err := ReadRules(strings.NewReader(`{"key": ["ssn"]}`)); err == nil {
+		t.Error("unknown field: got nil error")
+	}
+	_, err = Rules{Values: []string{"ok", "(", "[z-a]"}}.Options()
+	if err == nil {
+		t.Fatal("bad patterns: got nil error")
+	}
+	for _, want := range []string{"value 1:", "value 2:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	rl := NewReloadable(Options{})
+	a := slog.String("note", "ssn 123-45-6789")
+	if got, want := rl.Redact(a).String(), "note=ssn 123-45-6789"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if err := rl.Read(strings.NewReader(`{"values": ["\\d{3}-\\d{2}-\\d{4}"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rl.Redact(a).String(), "note=ssn [REDACTED]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// Invalid rules leave the current ones in place.
+	if err := rl.Load(Rules{Values: []string{"("}}); err == nil {
+		t.Error("got nil error")
+	}
+	if got, want := rl.ReplaceAttr([]string{"g"}, a).String(), "note=ssn [REDACTED]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rules.json")
+	write := func(s string, mtime time.Time) {
+		t.Helper()
+		// Replace the file by renaming, so it is never seen partly written.
+		// Set the time explicitly, in case the file system's resolution
+		// is too coarse to see the change.
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(tmp, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	write(`{"keys": ["a"]}`, start)
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	rl := NewReloadable(Options{})
+	stop, err := rl.WatchFile(name, time.Millisecond, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	redacted := func(key string) bool {
+		return rl.Redact(slog.Int(key, 1)).Value.Kind() == slog.KindString
+	}
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		for i := 0; i < 1000; i++ {
+			if cond() {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("timed out")
+	}
+	if !redacted("a") || redacted("b") {
+		t.Fatal("initial rules not loaded")
+	}
+
+	write(`{"keys": ["b"]}`, start.Add(time.Second))
+	waitFor(func() bool { return redacted("b") && !redacted("a") })
+
+	write(`{"keys": ["c"], "values": ["("]}`, start.Add(2*time.Second))
+	waitFor(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	if !redacted("b") {
+		t.Error("invalid file replaced the rules")
+	}
+
+	stop()
+	stop()
+	write(`{"keys": ["d"]}`, start.Add(3*time.Second))
+	time.Sleep(10 * time.Millisecond)
+	if redacted("d") {
+		t.Error("rules changed after stop")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 {
+		t.Errorf("got errors %v, want one", errors.Join(errs...))
+	}
+}
@@ -0,0 +1,191 @@
+// Package registry provides loggers named by dotted paths, like
+// "server.http.client", whose levels can be set at run time for a name
+// and all the names below it.
+package registry
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A Registry creates named loggers and holds their levels.
+// It is safe for concurrent use.
+type Registry struct {
+	h       slog.Handler
+	def     slog.Leveler
+	nameKey string
+
+	mu     sync.Mutex
+	levels map[string]*slog.LevelVar
+	gen    atomic.Int64 // incremented when levels changes
+}
+
+// Options are options for a [Registry].
+type Options struct {
+	// Level is the level of loggers whose name has no level set,
+	// nor any of its prefixes.
+	// If nil, [slog.LevelInfo] is used.
+	Level slog.Leveler
+
+	// NameKey, if non-empty, is the key of an attribute holding the
+	// logger's name, which is added to each of its records.
+	NameKey string
+}
+
+// New returns a Registry whose loggers write to h with the default options.
+func New(h slog.Handler) *Registry {
+	return Options{}.New(h)
+}
+
+// New returns a Registry whose loggers write to h.
+// The registry decides which records are enabled, so h should
+// be enabled at all levels that might be set.
+func (opts Options) New(h slog.Handler) *Registry {
+	r := &Registry{
+		h:       h,
+		def:     opts.Level,
+		nameKey: opts.NameKey,
+		levels:  map[string]*slog.LevelVar{},
+	}
+	if r.def == nil {
+		r.def = slog.LevelInfo
+	}
+	return r
+}
+
+// Logger returns a logger with the given name.
+func (r *Registry) Logger(name string) *slog.Logger {
+	return slog.New(r.Handler(name))
+}
+
+// Handler returns a handler for the given name.
+func (r *Registry) Handler(name string) slog.Handler {
+	h := r.h
+	if r.nameKey != "" {
+		h = h.WithAttrs([]slog.Attr{slog.String(r.nameKey, name)})
+	}
+	return &handler{r: r, name: name, h: h, cache: &atomic.Pointer[cacheEntry]{}}
+}
+
+// SetLevel sets the level of the logger with the given name and of all
+// loggers whose names begin with name followed by a dot, unless a longer
+// prefix of their names has its own level.
+// The empty name sets the level for all loggers.
+func (r *Registry) SetLevel(name string, level slog.Level) {
+	r.LevelVar(name).Set(level)
+}
+
+// LevelVar returns the LevelVar holding the level for name,
+// creating it with the name's current level if necessary.
+// Changing the LevelVar is the same as calling [Registry.SetLevel].
+//
+// To change levels over HTTP, use it with [Registry.Lookup] in a
+// levelvar.Options:
+//
+//	levelvar.Options{Lookup: reg.Lookup, Create: reg.LevelVar}
+func (r *Registry) LevelVar(name string) *slog.LevelVar {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if lv, ok := r.levels[name]; ok {
+		return lv
+	}
+	lv := &slog.LevelVar{}
+	lv.Set(r.lookup(name).Level())
+	r.levels[name] = lv
+	r.gen.Add(1)
+	return lv
+}
+
+// Lookup returns the LevelVar for name, if one has been set.
+// It does not create one; see [Registry.LevelVar].
+func (r *Registry) Lookup(name string) (*slog.LevelVar, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lv, ok := r.levels[name]
+	return lv, ok
+}
+
+// ClearLevel removes the level set for name, so that it again
+// uses the level of its longest prefix that has one.
+func (r *Registry) ClearLevel(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.levels, name)
+	r.gen.Add(1)
+}
+
+// Level returns the level of the logger with the given name.
+func (r *Registry) Level(name string) slog.Level {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(name).Level()
+}
+
+// lookup returns the Leveler for the longest prefix of name with a level.
+// r.mu must be held.
+func (r *Registry) lookup(name string) slog.Leveler {
+	for {
+		if lv, ok := r.levels[name]; ok {
+			return lv
+		}
+		if name == "" {
+			return r.def
+		}
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		} else {
+			name = ""
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////
+
+type handler struct {
+	r     *Registry
+	name  string
+	h     slog.Handler
+	cache *atomic.Pointer[cacheEntry] // shared among clones
+}
+
+// A cacheEntry remembers the result of Registry.lookup
+// for a generation of the registry.
+type cacheEntry struct {
+	gen     int64
+	leveler slog.Leveler
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.leveler().Level()
+}
+
+func (h *handler) leveler() slog.Leveler {
+	gen := h.r.gen.Load()
+	if e := h.cache.Load(); e != nil && e.gen == gen {
+		return e.leveler
+	}
+	h.r.mu.Lock()
+	e := &cacheEntry{gen: h.r.gen.Load(), leveler: h.r.lookup(h.name)}
+	h.r.mu.Unlock()
+	h.cache.Store(e)
+	return e.leveler
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.h.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.h = h.h.WithAttrs(as)
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.h = h.h.WithGroup(name)
+	return &h2
+}
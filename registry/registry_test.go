@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jba/slog/handlers/memory"
+	"github.com/jba/slog/levelvar"
+)
+
+func TestLevels(t *testing.T) {
+	mem := memory.New(&slog.HandlerOptions{Level: slog.LevelDebug - 10})
+	r := Options{NameKey: "logger"}.New(mem)
+	client := r.Logger("server.http.client")
+	server := r.Logger("server")
+	db := r.Logger("db")
+
+	check := func(wantClient, wantServer, wantDB slog.Level) {
+		t.Helper()
+		for _, c := range []struct {
+			name string
+			l    *slog.Logger
+			want slog.Level
+		}{
+			{"server.http.client", client, wantClient},
+			{"server", server, wantServer},
+			{"db", db, wantDB},
+		} {
+			if got := r.Level(c.name); got != c.want {
+				t.Errorf("%s: got level %s, want %s", c.name, got, c.want)
+			}
+			if !c.l.Enabled(context.Background(), c.want) || c.l.Enabled(context.Background(), c.want-1) {
+				t.Errorf("%s: logger not enabled at exactly %s", c.name, c.want)
+			}
+		}
+	}
+
+	check(slog.LevelInfo, slog.LevelInfo, slog.LevelInfo)
+	r.SetLevel("server", slog.LevelDebug)
+	check(slog.LevelDebug, slog.LevelDebug, slog.LevelInfo)
+	r.SetLevel("server.http", slog.LevelWarn)
+	check(slog.LevelWarn, slog.LevelDebug, slog.LevelInfo)
+	r.SetLevel("", slog.LevelError)
+	check(slog.LevelWarn, slog.LevelDebug, slog.LevelError)
+	r.ClearLevel("server.http")
+	check(slog.LevelDebug, slog.LevelDebug, slog.LevelError)
+	r.LevelVar("server").Set(slog.LevelInfo)
+	check(slog.LevelInfo, slog.LevelInfo, slog.LevelError)
+	// "serverx" is not below "server".
+	r.SetLevel("serverx", slog.LevelDebug)
+	check(slog.LevelInfo, slog.LevelInfo, slog.LevelError)
+
+	client.With("a", 1).WithGroup("g").Info("hello", "b", 2)
+	mem.AssertLogged(t, slog.LevelInfo, "hello",
+		slog.String("logger", "server.http.client"), slog.Int("a", 1), slog.Int("g.b", 2))
+}
+
+func TestLookup(t *testing.T) {
+	r := New(memory.New(nil))
+	if _, ok := r.Lookup("a"); ok {
+		t.Fatal("found level for a before setting it")
+	}
+	r.SetLevel("a", slog.LevelWarn)
+	lv, ok := r.Lookup("a")
+	if !ok || lv.Level() != slog.LevelWarn {
+		t.Fatalf("got %v, %t; want WARN, true", lv, ok)
+	}
+}
+
+func TestLevelVarHandler(t *testing.T) {
+	r := New(memory.New(nil))
+	h := levelvar.Options{Lookup: r.Lookup, Create: r.LevelVar}.New(&slog.LevelVar{})
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/?name=server.http", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	// A name that was never set can't be read, but it can be set.
+	if w := serve("GET", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET before PUT: got code %d, want 404", w.Code)
+	}
+	if w := serve("PUT", `{"level":"DEBUG"}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT: got code %d: %s", w.Code, w.Body)
+	}
+	if got := r.Level("server.http.client"); got != slog.LevelDebug {
+		t.Errorf("got level %s, want DEBUG", got)
+	}
+	if w := serve("GET", ""); w.Code != http.StatusOK {
+		t.Errorf("GET after PUT: got code %d, want 200", w.Code)
+	}
+}
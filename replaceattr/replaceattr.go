@@ -0,0 +1,122 @@
+// Package replaceattr provides ready-made functions for the ReplaceAttr
+// field of [slog.HandlerOptions] and [general.Options], and a way to
+// combine them:
+//
+//	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+//		ReplaceAttr: replaceattr.Chain(
+//			replaceattr.RenameKey(slog.MessageKey, "message"),
+//			replaceattr.FormatDuration("ms"),
+//			replaceattr.TimeAsUnixMillis,
+//		),
+//	})
+//
+// Handlers don't call ReplaceAttr on Attrs whose values are groups, so
+// none of these functions change the names of groups.
+//
+// [general.Options]: https://pkg.go.dev/github.com/jba/slog/handlers/general#Options
+package replaceattr
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// A Func is a function for the ReplaceAttr field of [slog.HandlerOptions].
+type Func = func(groups []string, a slog.Attr) slog.Attr
+
+// Chain returns a Func that calls each of fs in turn, passing each the
+// Attr returned by the one before. It stops if an Attr is removed, that is,
+// if one of fs returns an Attr with an empty key. Nil elements of fs are
+// ignored.
+func Chain(fs ...Func) Func {
+	var nonNil []Func
+	for _, f := range fs {
+		if f != nil {
+			nonNil = append(nonNil, f)
+		}
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		for _, f := range nonNil {
+			a = f(groups, a)
+			if a.Key == "" {
+				break
+			}
+		}
+		return a
+	}
+}
+
+// RenameKey returns a Func that changes the key of Attrs with key from
+// to to, in any group. Use it to rename the built-in Attrs:
+//
+//	replaceattr.RenameKey(slog.MessageKey, "message")
+func RenameKey(from, to string) Func {
+	return func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == from {
+			a.Key = to
+		}
+		return a
+	}
+}
+
+// LowercaseKeys is a Func that makes every key lower case.
+func LowercaseKeys(_ []string, a slog.Attr) slog.Attr {
+	a.Key = strings.ToLower(a.Key)
+	return a
+}
+
+// TrimPrefix returns a Func that removes prefix from the start of keys.
+// Keys that consist only of prefix are left alone, so no Attr loses its key.
+func TrimPrefix(prefix string) Func {
+	return func(_ []string, a slog.Attr) slog.Attr {
+		if k := strings.TrimPrefix(a.Key, prefix); k != "" {
+			a.Key = k
+		}
+		return a
+	}
+}
+
+// durationUnits are the units accepted by FormatDuration.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// FormatDuration returns a Func that replaces duration values with the
+// number of the given units they contain: an int64 for "ns", and a
+// float64 for the other units, "us" (or "µs"), "ms", "s", "m" and "h".
+// It panics if unit is not one of those.
+func FormatDuration(unit string) Func {
+	u, ok := durationUnits[unit]
+	if !ok {
+		panic(fmt.Sprintf("replaceattr: unknown duration unit %q", unit))
+	}
+	return func(_ []string, a slog.Attr) slog.Attr {
+		if a.Value.Kind() != slog.KindDuration {
+			return a
+		}
+		d := a.Value.Duration()
+		if u == time.Nanosecond {
+			a.Value = slog.Int64Value(int64(d))
+		} else {
+			a.Value = slog.Float64Value(float64(d) / float64(u))
+		}
+		return a
+	}
+}
+
+// TimeAsUnixMillis is a Func that replaces time values, including the
+// built-in time, with the number of milliseconds since the Unix epoch.
+func TimeAsUnixMillis(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindTime {
+		a.Value = slog.Int64Value(a.Value.Time().UnixMilli())
+	}
+	return a
+}
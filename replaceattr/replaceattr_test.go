@@ -0,0 +1,87 @@
+package replaceattr
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+
+func TestFuncs(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		f    Func
+		in   slog.Attr
+		want slog.Attr
+	}{
+		{"RenameKey", RenameKey("msg", "message"), slog.String("msg", "m"), slog.String("message", "m")},
+		{"RenameKey other", RenameKey("msg", "message"), slog.String("x", "m"), slog.String("x", "m")},
+		{"LowercaseKeys", LowercaseKeys, slog.Int("UserID", 1), slog.Int("userid", 1)},
+		{"TrimPrefix", TrimPrefix("app_"), slog.Int("app_id", 1), slog.Int("id", 1)},
+		{"TrimPrefix whole key", TrimPrefix("app_"), slog.Int("app_", 1), slog.Int("app_", 1)},
+		{"FormatDuration ms", FormatDuration("ms"), slog.Duration("d", 1500*time.Microsecond), slog.Float64("d", 1.5)},
+		{"FormatDuration ns", FormatDuration("ns"), slog.Duration("d", time.Second), slog.Int64("d", 1e9)},
+		{"FormatDuration other kind", FormatDuration("s"), slog.Int("d", 3), slog.Int("d", 3)},
+		{"TimeAsUnixMillis", TimeAsUnixMillis, slog.Time("t", testTime), slog.Int64("t", testTime.UnixMilli())},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.f(nil, test.in)
+			if !got.Equal(test.want) {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatDurationBadUnit(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("got no panic")
+		}
+	}()
+	FormatDuration("days")
+}
+
+func TestChain(t *testing.T) {
+	var calls int
+	count := func(_ []string, a slog.Attr) slog.Attr { calls++; return a }
+	remove := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == "secret" {
+			return slog.Attr{}
+		}
+		return a
+	}
+	f := Chain(TrimPrefix("x_"), nil, remove, count, LowercaseKeys)
+	if got, want := f(nil, slog.Int("x_SECRET", 1)), slog.Int("secret", 1); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := f(nil, slog.Int("x_secret", 1)); got.Key != "" {
+		t.Errorf("got %s, want empty Attr", got)
+	}
+	if calls != 1 {
+		t.Errorf("later Funcs called %d times, want 1", calls)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: Chain(
+			RenameKey(slog.MessageKey, "message"),
+			FormatDuration("ms"),
+			TimeAsUnixMillis,
+		),
+	}))
+	r := slog.NewRecord(testTime, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Group("req", slog.Duration("elapsed", 2*time.Millisecond)))
+	if err := l.Handler().Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	want := "time=1680483723000 level=INFO message=m req.elapsed=2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot  %s\nwant %s", got, want)
+	}
+}
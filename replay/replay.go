@@ -0,0 +1,134 @@
+// Package replay sends recorded logs through a handler, at their
+// original pace or a multiple of it, for load-testing log pipelines and
+// benchmarking handlers with realistic records.
+//
+//	f, _ := os.Open("prod.jsonl")
+//	stats, err := replay.Options{Speed: 10}.Replay(ctx, jsonlog.NewReader(f), h)
+//
+// The slogreplay command does the same from the command line.
+package replay
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/jba/slog/binary"
+)
+
+// A Source is a sequence of records. Read returns io.EOF after the last
+// one. A [*jsonlog.Reader] is a Source; use [BinarySource] for the
+// output of a BinaryHandler.
+//
+// [*jsonlog.Reader]: https://pkg.go.dev/github.com/jba/slog/jsonlog#Reader
+type Source interface {
+	Read() (slog.Record, error)
+}
+
+// BinarySource returns a Source that reads the frames written by
+// a BinaryHandler of package github.com/jba/slog/handlers from r,
+// skipping frames that are damaged.
+func BinarySource(r io.Reader) Source {
+	return binarySource{binary.NewStreamDecoder(r)}
+}
+
+type binarySource struct {
+	d *binary.StreamDecoder
+}
+
+func (s binarySource) Read() (slog.Record, error) {
+	return s.d.NextRecord()
+}
+
+// Options are options for [Options.Replay].
+type Options struct {
+	// Speed scales the pace of the replay: records are handled at
+	// intervals of the differences of their times divided by Speed, so
+	// 1 is the original pace and 2 twice as fast. Records without
+	// times, or with times before that of the first record, are handled
+	// at once. If Speed is zero or negative, records are handled as
+	// fast as possible.
+	Speed float64
+
+	// If Retime is true, each record's time is set to the time it is
+	// handled, so that the output looks like a live log.
+	Retime bool
+
+	// Limit, if positive, is the most records to handle.
+	Limit int
+
+	// OnError, if non-nil, is called with each error returned by the
+	// handler. Replay continues after such errors.
+	OnError func(error)
+}
+
+// Stats describe a replay.
+type Stats struct {
+	Records  int           // records read from the source
+	Handled  int           // records that were enabled and handled
+	Errors   int           // errors returned by the handler
+	Elapsed  time.Duration // time from the start to the end of the replay
+	Recorded time.Duration // time between the first and last record, as recorded
+}
+
+// Replay reads records from src and passes those that are enabled to h,
+// until src is exhausted, the Limit is reached or ctx is done. It
+// returns an error from src other than io.EOF, or ctx's error.
+func (opts Options) Replay(ctx context.Context, src Source, h slog.Handler) (stats Stats, err error) {
+	var (
+		start       = time.Now()
+		first, last time.Time // of the records
+	)
+	defer func() { stats.Elapsed = time.Since(start) }()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for opts.Limit <= 0 || stats.Records < opts.Limit {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		r, err := src.Read()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Records++
+		if !r.Time.IsZero() {
+			if first.IsZero() {
+				first = r.Time
+			}
+			if r.Time.After(last) {
+				last = r.Time
+				stats.Recorded = last.Sub(first)
+			}
+			if opts.Speed > 0 && r.Time.After(first) {
+				due := start.Add(time.Duration(float64(r.Time.Sub(first)) / opts.Speed))
+				if d := time.Until(due); d > 0 {
+					timer.Reset(d)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						return stats, ctx.Err()
+					}
+				}
+			}
+		}
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if opts.Retime {
+			r.Time = time.Now()
+		}
+		stats.Handled++
+		if err := h.Handle(ctx, r); err != nil {
+			stats.Errors++
+			if opts.OnError != nil {
+				opts.OnError(err)
+			}
+		}
+	}
+	return stats, nil
+}
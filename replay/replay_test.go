@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlers"
+	"github.com/jba/slog/handlers/memory"
+)
+
+var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+
+// sliceSource is a Source of the records in a slice.
+type sliceSource []slog.Record
+
+func (s *sliceSource) Read() (slog.Record, error) {
+	if len(*s) == 0 {
+		return slog.Record{}, io.EOF
+	}
+	r := (*s)[0]
+	*s = (*s)[1:]
+	return r, nil
+}
+
+// records returns records at the given offsets from testTime,
+// with INFO level and messages "0", "1", ....
+func records(offsets ...time.Duration) *sliceSource {
+	var s sliceSource
+	for i, d := range offsets {
+		s = append(s, slog.NewRecord(testTime.Add(d), slog.LevelInfo, string(rune('0'+i)), 0))
+	}
+	return &s
+}
+
+func messages(h *memory.Handler) string {
+	var s string
+	for _, r := range h.Records() {
+		s += r.Message
+	}
+	return s
+}
+
+func TestReplay(t *testing.T) {
+	h := memory.New(nil)
+	src := records(0, time.Second, 3*time.Second)
+	(*src)[1].Level = slog.LevelDebug
+	stats, err := Options{}.Replay(context.Background(), src, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := messages(h), "02"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if stats.Records != 3 || stats.Handled != 2 || stats.Errors != 0 || stats.Recorded != 3*time.Second {
+		t.Errorf("got %+v", stats)
+	}
+	if got := h.Records()[1].Time; !got.Equal(testTime.Add(3 * time.Second)) {
+		t.Errorf("time changed to %v", got)
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	h := memory.New(nil)
+	// At 20 times the pace, the last record comes after 50ms.
+	stats, err := Options{Speed: 20}.Replay(context.Background(), records(0, -time.Second, time.Second), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Elapsed < 50*time.Millisecond {
+		t.Errorf("took %s, want at least 50ms", stats.Elapsed)
+	}
+	if got, want := messages(h), "012"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLimitAndRetime(t *testing.T) {
+	h := memory.New(nil)
+	before := time.Now()
+	stats, err := Options{Limit: 2, Retime: true}.Replay(context.Background(), records(0, 0, 0), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 2 || messages(h) != "01" {
+		t.Errorf("got %+v, %q", stats, messages(h))
+	}
+	for _, r := range h.Records() {
+		if r.Time.Before(before) {
+			t.Errorf("time %v not reset", r.Time)
+		}
+	}
+}
+
+type failHandler struct{ slog.Handler }
+
+func (failHandler) Handle(context.Context, slog.Record) error { return errors.New("fail") }
+
+func TestErrors(t *testing.T) {
+	var errs int
+	opts := Options{OnError: func(error) { errs++ }}
+	stats, err := opts.Replay(context.Background(), records(0, 0), failHandler{memory.New(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Errors != 2 || errs != 2 {
+		t.Errorf("got %d errors, %d calls to OnError, want 2", stats.Errors, errs)
+	}
+
+	// A done context stops a slow replay.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = Options{Speed: 1}.Replay(ctx, records(0, time.Hour), memory.New(nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestBinarySource(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(handlers.NewBinaryHandler(&buf, nil))
+	l.Info("a", "x", 1)
+	l.Warn("b")
+	h := memory.New(nil)
+	stats, err := Options{}.Replay(context.Background(), BinarySource(&buf), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Handled != 2 || messages(h) != "ab" {
+		t.Errorf("got %+v, %q", stats, messages(h))
+	}
+}
@@ -0,0 +1,47 @@
+// Package slogctx stores loggers in contexts, so that code deep in a
+// call stack can log with attributes added by its callers without
+// having a logger passed to it.
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		ctx := slogctx.With(r.Context(), "user", user)
+//		process(ctx)
+//	}
+//
+//	func process(ctx context.Context) {
+//		slogctx.Logger(ctx).InfoContext(ctx, "processing") // includes user=...
+//	}
+package slogctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx that holds l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// Logger returns the logger stored in ctx, or [slog.Default] if there is none.
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx that holds the logger of ctx, as returned
+// by [Logger], with args added as by [slog.Logger.With].
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, Logger(ctx).With(args...))
+}
+
+// WithGroup returns a copy of ctx that holds the logger of ctx, as
+// returned by [Logger], with the group added as by [slog.Logger.WithGroup].
+// Attributes added later by With, and those of the records logged with
+// the logger, are in the group.
+func WithGroup(ctx context.Context, name string) context.Context {
+	return NewContext(ctx, Logger(ctx).WithGroup(name))
+}
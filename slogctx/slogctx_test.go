@@ -0,0 +1,42 @@
+package slogctx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	ctx := context.Background()
+	if got := Logger(ctx); got != slog.Default() {
+		t.Error("empty context: did not get slog.Default()")
+	}
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	ctx = NewContext(ctx, l)
+	if got := Logger(ctx); got != l {
+		t.Error("did not get the stored logger")
+	}
+
+	ctx = With(ctx, "a", 1)
+	inner := WithGroup(ctx, "g")
+	inner = With(inner, "b", 2)
+	Logger(inner).Info("m", "c", 3)
+	// The outer context is unchanged.
+	Logger(ctx).Info("n")
+
+	want := "level=INFO msg=m a=1 g.b=2 g.c=3\n" +
+		"level=INFO msg=n a=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,227 @@
+// Package slogf logs records whose messages are templates, like those
+// of Serilog, keeping the template and its arguments separate so that
+// analytics can group records by template:
+//
+//	slogf.Infof(ctx, logger, "user {user} logged in from {ip}", name, ip)
+//
+// produces a record whose message is the template itself and which has
+// a group Attr with key [ArgsKey] holding the arguments:
+//
+//	msg="user {user} logged in from {ip}" args.user=pat args.ip=10.0.0.1
+//
+// Printf-style templates are also recognized; their arguments are keyed
+// by position:
+//
+//	slogf.Infof(ctx, logger, "retrying in %s", d) // args.0=2s
+//
+// A handler from [NewHandler] renders such records for people, replacing
+// the message with the interpolated one and adding the template as an
+// Attr with key [TemplateKey]:
+//
+//	msg="user pat logged in from 10.0.0.1" msg_template="user {user} logged in from {ip}" args.user=pat args.ip=10.0.0.1
+package slogf
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jba/slog/middleware"
+)
+
+// Keys used by this package.
+const (
+	// ArgsKey is the key of the group holding a template's arguments.
+	ArgsKey = "args"
+	// TemplateKey is the key of the template in rendered records.
+	TemplateKey = "msg_template"
+)
+
+// Debugf logs a record at LevelDebug with the template as its message and
+// args in a group with key ArgsKey. If l is nil, [slog.Default] is used.
+func Debugf(ctx context.Context, l *slog.Logger, template string, args ...any) {
+	log(ctx, l, slog.LevelDebug, template, args)
+}
+
+// Infof is like [Debugf], but logs at LevelInfo.
+func Infof(ctx context.Context, l *slog.Logger, template string, args ...any) {
+	log(ctx, l, slog.LevelInfo, template, args)
+}
+
+// Warnf is like [Debugf], but logs at LevelWarn.
+func Warnf(ctx context.Context, l *slog.Logger, template string, args ...any) {
+	log(ctx, l, slog.LevelWarn, template, args)
+}
+
+// Errorf is like [Debugf], but logs at LevelError.
+func Errorf(ctx context.Context, l *slog.Logger, template string, args ...any) {
+	log(ctx, l, slog.LevelError, template, args)
+}
+
+// log logs with the caller of its caller as the source.
+func log(ctx context.Context, l *slog.Logger, level slog.Level, template string, args []any) {
+	if l == nil {
+		l = slog.Default()
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, log and its caller
+	r := slog.NewRecord(time.Now(), level, template, pcs[0])
+	if len(args) > 0 {
+		r.AddAttrs(ArgsAttr(template, args...))
+	}
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// ArgsAttr returns the group Attr that holds args for template.
+// An argument is keyed by the name of the placeholder in the same
+// position, or by its position if there is none.
+func ArgsAttr(template string, args ...any) slog.Attr {
+	names := placeholders(template)
+	as := make([]slog.Attr, len(args))
+	for i, a := range args {
+		key := strconv.Itoa(i)
+		if i < len(names) {
+			key = names[i]
+		}
+		as[i] = slog.Any(key, a)
+	}
+	return slog.Attr{Key: ArgsKey, Value: slog.GroupValue(as...)}
+}
+
+// NewHandler returns a handler that renders the templates of records
+// logged by the functions of this package, as described by [Render],
+// and passes the records to h. Other records are passed unchanged.
+//
+// The handler is made with [middleware.Transform], so Attrs that it
+// adds are in the groups of WithGroup.
+func NewHandler(h slog.Handler) slog.Handler {
+	return middleware.Transform(middleware.RecordTransformerFunc(
+		func(_ context.Context, r slog.Record) (slog.Record, bool) {
+			return Render(r), true
+		}))(h)
+}
+
+// Render returns r with its template rendered, if it has one: a group
+// Attr with key ArgsKey. The message of the result is the template
+// interpolated with the arguments, and its first Attr holds the template,
+// with key TemplateKey. Its other Attrs, including the arguments, are
+// those of r.
+//
+// Templates with placeholders in braces, like "{user}", are interpolated
+// by replacing each placeholder with the argument of the same name.
+// "{{" and "}}" stand for literal braces. Other templates are
+// interpolated with [fmt.Sprintf].
+func Render(r slog.Record) slog.Record {
+	var args []slog.Attr
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == ArgsKey && a.Value.Kind() == slog.KindGroup {
+			args = a.Value.Group()
+			found = true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return r
+	}
+	r2 := slog.NewRecord(r.Time, r.Level, interpolate(r.Message, args), r.PC)
+	r2.AddAttrs(slog.String(TemplateKey, r.Message))
+	r.Attrs(func(a slog.Attr) bool {
+		r2.AddAttrs(a)
+		return true
+	})
+	return r2
+}
+
+// interpolate returns template with args substituted.
+func interpolate(template string, args []slog.Attr) string {
+	if placeholders(template) == nil {
+		vals := make([]any, len(args))
+		for i, a := range args {
+			vals[i] = a.Value.Resolve().Any()
+		}
+		return fmt.Sprintf(template, vals...)
+	}
+	var b strings.Builder
+	scan(template, func(lit, name string) {
+		b.WriteString(lit)
+		if name == "" {
+			return
+		}
+		for _, a := range args {
+			if a.Key == name {
+				b.WriteString(a.Value.Resolve().String())
+				return
+			}
+		}
+		// No argument: leave the placeholder.
+		b.WriteString("{" + name + "}")
+	})
+	return b.String()
+}
+
+// placeholders returns the names of the placeholders in template, in
+// order, with duplicates removed. It returns nil if there are none.
+func placeholders(template string) []string {
+	var names []string
+	scan(template, func(_, name string) {
+		if name == "" {
+			return
+		}
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		names = append(names, name)
+	})
+	return names
+}
+
+// scan splits template into literal text and placeholders, calling f
+// with each placeholder's name and the literal text preceding it. The
+// last call has the text that follows the last placeholder and an empty
+// name.
+func scan(template string, f func(lit, name string)) {
+	var lit strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(template) && template[i+1] == c:
+			lit.WriteByte(c)
+			i++
+		case c == '{':
+			n := nameLen(template[i+1:])
+			if n > 0 && i+1+n < len(template) && template[i+1+n] == '}' {
+				f(lit.String(), template[i+1:i+1+n])
+				lit.Reset()
+				i += n + 1
+			} else {
+				lit.WriteByte(c)
+			}
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	f(lit.String(), "")
+}
+
+// nameLen returns the length of the placeholder name at the start of s.
+// Names consist of ASCII letters, digits and underscores.
+func nameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,98 @@
+package slogf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaceholders(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"no placeholders", nil},
+		{"%s and %d", nil},
+		{"user {user} from {ip}", []string{"user", "ip"}},
+		{"{a}{b}{a}", []string{"a", "b"}},
+		{"{{escaped}} {x}", []string{"x"}},
+		{"{not a name} {ok_1}", []string{"ok_1"}},
+		{"{unclosed", nil},
+		{"{}", nil},
+	} {
+		if got := placeholders(test.in); !slices.Equal(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		args     []any
+		want     string
+	}{
+		{"user {user} from {ip}", []any{"pat", "10.0.0.1"}, "user pat from 10.0.0.1"},
+		{"{a} then {b} then {a}", []any{1, 2}, "1 then 2 then 1"},
+		{"{{literal}} {x}", []any{true}, "{literal} true"},
+		{"missing {x} {y}", []any{1}, "missing 1 {y}"},
+		{"retrying in %s after %d tries", []any{2 * time.Second, 3}, "retrying in 2s after 3 tries"},
+		{"%.1f%%", []any{99.44}, "99.4%"},
+	} {
+		r := slog.NewRecord(time.Time{}, slog.LevelInfo, test.template, 0)
+		r.AddAttrs(ArgsAttr(test.template, test.args...))
+		got := Render(r)
+		if got.Message != test.want {
+			t.Errorf("%q: got %q, want %q", test.template, got.Message, test.want)
+		}
+	}
+
+	// Records without arguments are unchanged.
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "{x}", 0)
+	r.AddAttrs(slog.Int("x", 1))
+	if got := Render(r); got.Message != "{x}" || got.NumAttrs() != 1 {
+		t.Errorf("got %q with %d attrs, want unchanged record", got.Message, got.NumAttrs())
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.TimeKey:
+				return slog.Attr{}
+			case slog.SourceKey:
+				s := a.Value.Any().(*slog.Source)
+				return slog.String(a.Key, fmt.Sprintf("%s:%d", filepath.Base(s.File), s.Line))
+			}
+			return a
+		},
+	}
+	ctx := context.Background()
+	raw := slog.New(slog.NewTextHandler(&buf, opts))
+	rendered := slog.New(NewHandler(slog.NewTextHandler(&buf, opts)))
+
+	Infof(ctx, raw, "user {user} logged in", "pat")
+	_, _, line, _ := runtime.Caller(0)
+	Warnf(ctx, rendered.With("a", 1), "user {user} logged in", "pat")
+	Debugf(ctx, rendered, "not enabled")
+	Errorf(ctx, rendered, "no args")
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{
+		fmt.Sprintf(`level=INFO source=slogf_test.go:%d msg="user {user} logged in" args.user=pat`, line-1),
+		fmt.Sprintf(`level=WARN source=slogf_test.go:%d msg="user pat logged in" a=1 msg_template="user {user} logged in" args.user=pat`, line+1),
+		fmt.Sprintf(`level=ERROR source=slogf_test.go:%d msg="no args"`, line+3),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
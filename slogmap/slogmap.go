@@ -0,0 +1,188 @@
+// Package slogmap converts records to nested maps and back, for sinks
+// like document stores, templates and test assertions that want maps.
+//
+// A record
+//
+//	logger.With("user", "ann").WithGroup("req").Info("done", "method", "GET")
+//
+// becomes
+//
+//	map[string]any{
+//		"time":  time.Time{...},
+//		"level": slog.LevelInfo,
+//		"msg":   "done",
+//		"user":  "ann",
+//		"req":   map[string]any{"method": "GET"},
+//	}
+package slogmap
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jba/slog/internal/frames"
+	"github.com/jba/slog/withsupport"
+)
+
+// FromRecord returns the contents of r as a map.
+// It is FromRecordWith with no groups or Attrs from a handler.
+func FromRecord(r slog.Record) map[string]any {
+	return FromRecordWith(nil, r)
+}
+
+// FromRecordWith returns the contents of r as a map, with the groups
+// and Attrs in g, which a handler has collected from calls to
+// WithGroup and WithAttrs.
+//
+// The built-in fields are stored under the keys [slog.TimeKey] as a
+// [time.Time], omitted if r.Time is zero, [slog.LevelKey] as a
+// [slog.Level], [slog.MessageKey] as a string, and [slog.SourceKey]
+// as a [*slog.Source], omitted if r.PC is zero.
+//
+// Attrs are stored as the handlers of package log/slog would write
+// them: LogValuers are resolved, a group becomes a map[string]any,
+// Attrs of a group with an empty key are stored in the enclosing map,
+// and empty groups and Attrs with empty keys are omitted. Other
+// values are stored as returned by [slog.Value.Any], so an int is an
+// int64. When a key repeats in the same map, the later value replaces
+// the earlier one, except that two groups are merged.
+func FromRecordWith(g *withsupport.GroupOrAttrs, r slog.Record) map[string]any {
+	m := map[string]any{}
+	if !r.Time.IsZero() {
+		m[slog.TimeKey] = r.Time
+	}
+	m[slog.LevelKey] = r.Level
+	m[slog.MessageKey] = r.Message
+	if r.PC != 0 {
+		f := frames.Frame(r.PC)
+		m[slog.SourceKey] = &slog.Source{Function: f.Function, File: f.File, Line: f.Line}
+	}
+	g.Iterate(func(groups []string, a slog.Attr) bool {
+		addAttr(m, groups, a)
+		return true
+	})
+	groups := g.Groups()
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(m, groups, a)
+		return true
+	})
+	return m
+}
+
+// FromAttrs returns as as a map, storing them as [FromRecordWith]
+// stores a record's Attrs.
+func FromAttrs(as []slog.Attr) map[string]any {
+	m := map[string]any{}
+	for _, a := range as {
+		addAttr(m, nil, a)
+	}
+	return m
+}
+
+// StringErrors replaces each error in m and the maps within it by its
+// message, unless the error implements [json.Marshaler]. Then
+// json.Marshal writes the errors of m as [slog.JSONHandler] writes them.
+// It returns m.
+func StringErrors(m map[string]any) map[string]any {
+	for k, v := range m {
+		switch v := v.(type) {
+		case map[string]any:
+			StringErrors(v)
+		case json.Marshaler:
+		case error:
+			m[k] = v.Error()
+		}
+	}
+	return m
+}
+
+// addAttr adds a to the map at the end of the path of groups from m,
+// creating the maps along the path if a is not empty.
+func addAttr(m map[string]any, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, a2 := range a.Value.Group() {
+			addAttr(m, groups, a2)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	for _, g := range groups {
+		sub, ok := m[g].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[g] = sub
+		}
+		m = sub
+	}
+	m[a.Key] = a.Value.Any()
+}
+
+// ToRecord returns a record made from m, reversing [FromRecord].
+//
+// The time, level and message are taken from the keys [slog.TimeKey],
+// [slog.LevelKey] and [slog.MessageKey], if they hold a time.Time, a
+// [slog.Level] and a string. The value of [slog.SourceKey] is dropped,
+// because a record's source location can't be set. Other entries
+// become Attrs, in order of their keys, with each map[string]any
+// becoming a group.
+func ToRecord(m map[string]any) slog.Record {
+	var (
+		t     time.Time
+		level slog.Level
+		msg   string
+	)
+	rest := map[string]any{}
+	for k, v := range m {
+		switch x := v.(type) {
+		case time.Time:
+			if k == slog.TimeKey {
+				t = x
+				continue
+			}
+		case slog.Level:
+			if k == slog.LevelKey {
+				level = x
+				continue
+			}
+		case string:
+			if k == slog.MessageKey {
+				msg = x
+				continue
+			}
+		case *slog.Source:
+			if k == slog.SourceKey {
+				continue
+			}
+		}
+		rest[k] = v
+	}
+	r := slog.NewRecord(t, level, msg, 0)
+	r.AddAttrs(attrs(rest)...)
+	return r
+}
+
+// attrs returns the entries of m as Attrs, sorted by key.
+func attrs(m map[string]any) []slog.Attr {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	as := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		if sub, ok := m[k].(map[string]any); ok {
+			as = append(as, slog.Attr{Key: k, Value: slog.GroupValue(attrs(sub)...)})
+		} else {
+			as = append(as, slog.Any(k, m[k]))
+		}
+	}
+	return as
+}
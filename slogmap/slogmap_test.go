@@ -0,0 +1,129 @@
+package slogmap
+
+import (
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jba/slog/withsupport"
+)
+
+var testTime = time.Date(2023, time.April, 3, 1, 2, 3, 0, time.UTC)
+
+type userValuer string
+
+func (u userValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", string(u)), slog.Int("id", 7))
+}
+
+func TestFromRecordWith(t *testing.T) {
+	var g *withsupport.GroupOrAttrs
+	g = g.WithAttrs([]slog.Attr{slog.String("service", "api")})
+	g = g.WithGroup("req")
+	g = g.WithAttrs([]slog.Attr{slog.String("method", "GET"), slog.Group("h", slog.Int("a", 1))})
+	g = g.WithGroup("empty")
+	r := slog.NewRecord(testTime, slog.LevelWarn, "done", 0)
+	r.AddAttrs(
+		slog.Any("user", userValuer("ann")),
+		slog.Group("", slog.Bool("inline", true)),
+		slog.Group("none"),
+		slog.Int("", 5),
+		slog.Duration("d", time.Second),
+		slog.Int("x", 1),
+		slog.Int("x", 2),
+	)
+	got := FromRecordWith(g, r)
+	want := map[string]any{
+		"time":    testTime,
+		"level":   slog.LevelWarn,
+		"msg":     "done",
+		"service": "api",
+		"req": map[string]any{
+			"method": "GET",
+			"h":      map[string]any{"a": int64(1)},
+			"empty": map[string]any{
+				"user":   map[string]any{"name": "ann", "id": int64(7)},
+				"inline": true,
+				"d":      time.Second,
+				"x":      int64(2),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestFromRecordEmptyGroups(t *testing.T) {
+	// Groups without Attrs don't appear.
+	g := (*withsupport.GroupOrAttrs)(nil).WithGroup("g")
+	got := FromRecordWith(g, slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0))
+	want := map[string]any{"level": slog.LevelInfo, "msg": "m"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestFromRecordSource(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	m := FromRecord(slog.NewRecord(testTime, slog.LevelInfo, "m", pcs[0]))
+	src, ok := m[slog.SourceKey].(*slog.Source)
+	if !ok {
+		t.Fatalf("got %v, want a *slog.Source", m[slog.SourceKey])
+	}
+	if !strings.HasSuffix(src.File, "slogmap_test.go") || !strings.HasSuffix(src.Function, "TestFromRecordSource") {
+		t.Errorf("got %+v", src)
+	}
+}
+
+func TestFromAttrs(t *testing.T) {
+	got := StringErrors(FromAttrs([]slog.Attr{
+		slog.Any("err", errors.New("bad")),
+		slog.Group("g", slog.Any("err", errors.New("worse")), slog.Any("l", slog.LevelWarn)),
+		slog.Group("empty"),
+	}))
+	want := map[string]any{
+		"err": "bad",
+		"g":   map[string]any{"err": "worse", "l": slog.LevelWarn},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestToRecord(t *testing.T) {
+	m := map[string]any{
+		"time":   testTime,
+		"level":  slog.LevelError,
+		"msg":    "m",
+		"source": &slog.Source{File: "f.go"},
+		"b":      2,
+		"a":      "x",
+		"g":      map[string]any{"z": true, "y": map[string]any{"w": 1.5}},
+	}
+	r := ToRecord(m)
+	if !r.Time.Equal(testTime) || r.Level != slog.LevelError || r.Message != "m" {
+		t.Errorf("got time %v, level %v, msg %q", r.Time, r.Level, r.Message)
+	}
+	var got []string
+	r.Attrs(func(a slog.Attr) bool {
+		got = append(got, a.String())
+		return true
+	})
+	want := []string{"a=x", "b=2", "g=[y=[w=1.5] z=true]"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// A round trip preserves the map, apart from the types of values.
+	delete(m, "source")
+	m["b"] = int64(2)
+	if diff := cmp.Diff(m, FromRecord(r)); diff != "" {
+		t.Errorf("round trip mismatch (-want, +got):\n%s", diff)
+	}
+}
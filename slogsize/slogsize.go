@@ -0,0 +1,72 @@
+// Package slogsize estimates the size of formatted records, so that
+// handlers can allocate a buffer that is large enough at the start
+// instead of growing one repeatedly.
+package slogsize
+
+import (
+	"log/slog"
+)
+
+// Estimates of the formatted sizes of values whose size isn't cheap to
+// compute. They are typical sizes, not maximums.
+const (
+	numberSize   = 10
+	floatSize    = 16
+	durationSize = 12
+	timeSize     = 30
+	anySize      = 32
+
+	// Space for the built-in time and level and their keys.
+	builtinSize = 64
+	// Space for the separators and quotes around each Attr.
+	attrOverhead = 4
+)
+
+// EstimateSize returns an estimate of the number of bytes needed to
+// format r as text or JSON. It walks r's Attrs, including those in
+// groups, but doesn't resolve LogValuers or call methods like Error,
+// so it is much faster than formatting. It does not account for output
+// that depends on the handler, like the source location or Attrs from
+// WithAttrs.
+func EstimateSize(r slog.Record) int {
+	n := builtinSize + attrOverhead + len(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		n += attrSize(a)
+		return true
+	})
+	return n
+}
+
+// attrSize estimates the formatted size of a.
+func attrSize(a slog.Attr) int {
+	return attrOverhead + len(a.Key) + valueSize(a.Value)
+}
+
+// valueSize estimates the formatted size of v.
+func valueSize(v slog.Value) int {
+	switch v.Kind() {
+	case slog.KindString:
+		return len(v.String())
+	case slog.KindInt64, slog.KindUint64:
+		return numberSize
+	case slog.KindFloat64:
+		return floatSize
+	case slog.KindBool:
+		return len("false")
+	case slog.KindDuration:
+		return durationSize
+	case slog.KindTime:
+		return timeSize
+	case slog.KindGroup:
+		n := 2
+		for _, a := range v.Group() {
+			n += attrSize(a)
+		}
+		return n
+	default:
+		if b, ok := v.Any().([]byte); ok {
+			return len(b)
+		}
+		return anySize
+	}
+}
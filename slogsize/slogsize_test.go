@@ -0,0 +1,49 @@
+package slogsize
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tm := time.Date(2023, time.April, 3, 1, 2, 3, 456789, time.UTC)
+	long := strings.Repeat("x", 5000)
+	for _, test := range []struct {
+		name  string
+		attrs []slog.Attr
+	}{
+		{"empty", nil},
+		{"scalars", []slog.Attr{
+			slog.String("s", "hello"), slog.Int("i", 12345), slog.Float64("f", 3.14159),
+			slog.Bool("b", true), slog.Duration("d", 1500*time.Millisecond), slog.Time("t", tm),
+		}},
+		{"long string", []slog.Attr{slog.String("s", long)}},
+		{"group", []slog.Attr{slog.Group("g", slog.String("s", long), slog.Group("h", slog.Any("b", []byte(long))))}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := slog.NewRecord(tm, slog.LevelInfo, "message", 0)
+			r.AddAttrs(test.attrs...)
+			var buf bytes.Buffer
+			if err := slog.NewJSONHandler(&buf, nil).Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			// The estimate should be within a factor of two of the actual size.
+			got, actual := EstimateSize(r), buf.Len()
+			if got < actual/2 || got > 2*actual {
+				t.Errorf("got %d, actual size %d", got, actual)
+			}
+		})
+	}
+}
+
+func TestEstimateSizeAllocs(t *testing.T) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Int("a", 1), slog.Group("g", slog.String("b", "x")), slog.Any("c", []byte("y")))
+	if got := testing.AllocsPerRun(10, func() { EstimateSize(r) }); got != 0 {
+		t.Errorf("got %.1f allocs, want 0", got)
+	}
+}
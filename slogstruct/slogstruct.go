@@ -0,0 +1,173 @@
+// Package slogstruct converts structs to slog groups.
+//
+// [Value] makes a group with one Attr for each exported field of a struct.
+// The key of a field's Attr is its name, unless the field has a tag like
+//
+//	Field int `slog:"name,omitempty"`
+//
+// A name of "-" omits the field, and omitempty omits it when it has its
+// zero value. The fields of an embedded struct without a name in its tag
+// are included as if they were fields of the outer struct, as with
+// encoding/json.
+//
+// Fields whose values are structs, or non-nil pointers to structs, become
+// nested groups. Structs that know how to present themselves, by
+// implementing [slog.LogValuer], error, [fmt.Stringer],
+// [encoding.TextMarshaler] or [json.Marshaler], are left alone.
+package slogstruct
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Value returns the value of x as a group, if x is a struct or a non-nil
+// pointer to one. Otherwise, it returns [slog.AnyValue](x).
+func Value(x any) slog.Value {
+	v := reflect.ValueOf(x)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !expandable(v.Type()) {
+		return slog.AnyValue(x)
+	}
+	return structValue(v, 0)
+}
+
+// maxDepth is the deepest nesting of structs that Value converts,
+// to stop at cycles of pointers.
+const maxDepth = 32
+
+func structValue(v reflect.Value, depth int) slog.Value {
+	fields := fieldsOf(v.Type())
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+		attrs = append(attrs, slog.Attr{Key: f.name, Value: fieldValue(fv, depth)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// fieldValue returns the value for a field.
+func fieldValue(fv reflect.Value, depth int) slog.Value {
+	switch {
+	case expandable(fv.Type()):
+		return structValue(fv, depth+1)
+	case fv.Kind() == reflect.Pointer && !fv.IsNil() && expandable(fv.Type().Elem()):
+		if depth >= maxDepth {
+			return slog.StringValue("!ERROR: structs nested too deeply")
+		}
+		// Follow the pointer only when the value is resolved,
+		// so it costs nothing if the value isn't written.
+		return slog.AnyValue(valuer{fv.Elem(), depth + 1})
+	default:
+		return slog.AnyValue(fv.Interface())
+	}
+}
+
+type valuer struct {
+	v     reflect.Value
+	depth int
+}
+
+func (v valuer) LogValue() slog.Value { return structValue(v.v, v.depth) }
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns false
+// instead of panicking at a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+var (
+	logValuerType     = reflect.TypeOf((*slog.LogValuer)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// expandable reports whether values of t should become groups.
+func expandable(t reflect.Type) bool {
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{logValuerType, errorType, stringerType, textMarshalerType, jsonMarshalerType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return false
+		}
+	}
+	return true
+}
+
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var cache sync.Map // reflect.Type to []field
+
+// fieldsOf returns the fields of the struct type t, computing them
+// only once for each type.
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := cache.Load(t); ok {
+		return fs.([]field)
+	}
+	fs, _ := cache.LoadOrStore(t, appendFields(nil, t, nil, map[reflect.Type]bool{t: true}))
+	return fs.([]field)
+}
+
+// appendFields appends the fields of t to fs. Embedded structs whose
+// types are in visited are skipped, so that a type that embeds a pointer
+// to itself doesn't recurse forever; as in encoding/json, each type's
+// fields are included only once.
+func appendFields(fs []field, t reflect.Type, index []int, visited map[reflect.Type]bool) []field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("slog")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		idx := append(index[:len(index):len(index)], i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !visited[ft] {
+					visited[ft] = true
+					fs = appendFields(fs, ft, idx, visited)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fs = append(fs, field{name: name, index: idx, omitEmpty: opts == "omitempty"})
+	}
+	return fs
+}
@@ -0,0 +1,129 @@
+package slogstruct
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Inner struct {
+	A int
+}
+
+type embedded struct {
+	E string
+}
+
+type node struct {
+	Val  int
+	Next *node
+}
+
+type config struct {
+	Name     string `slog:"name"`
+	Port     int    `slog:"port,omitempty"`
+	Password string `slog:"-"`
+	private  int
+	Inner    Inner
+	Ptr      *Inner
+	NilPtr   *Inner `slog:",omitempty"`
+	Time     time.Time
+	Addr     netip.Addr
+	Err      error
+	embedded
+}
+
+func TestValue(t *testing.T) {
+	tm := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, test := range []struct {
+		name string
+		x    any
+		want string
+	}{
+		{"int", 3, "v=3"},
+		{"nil", nil, "v=<nil>"},
+		{"nil pointer", (*config)(nil), "v=<nil>"},
+		{
+			"struct",
+			config{
+				Name:     "n",
+				Password: "secret",
+				private:  1,
+				Inner:    Inner{A: 1},
+				Ptr:      &Inner{A: 2},
+				Time:     tm,
+				Addr:     netip.MustParseAddr("1.2.3.4"),
+				Err:      errors.New("e"),
+				embedded: embedded{E: "x"},
+			},
+			"v.name=n v.Inner.A=1 v.Ptr.A=2 v.Time=2023-01-02T03:04:05.000Z v.Addr=1.2.3.4 v.Err=e v.E=x",
+		},
+		{"pointer", &Inner{A: 5}, "v.A=5"},
+		{"cycle", func() *node { n := &node{Val: 1}; n.Next = n; return n }(), ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+			l.Info("", slog.Any("v", Value(test.x)))
+			got := strings.TrimSpace(buf.String())
+			if test.name == "cycle" {
+				if !strings.HasPrefix(got, "v.Val=1 v.Next.Val=1 ") || !strings.HasSuffix(got, `="!ERROR: structs nested too deeply"`) {
+					t.Errorf("got %q", got)
+				}
+				return
+			}
+			if got != test.want {
+				t.Errorf("\ngot  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	f1 := fieldsOf(reflect.TypeOf(config{}))
+	f2 := fieldsOf(reflect.TypeOf(config{}))
+	if &f1[0] != &f2[0] {
+		t.Error("fields not cached")
+	}
+}
+
+type selfEmbed struct {
+	*selfEmbed
+	X int
+}
+
+type mutualA struct {
+	*mutualB
+	A int
+}
+
+type mutualB struct {
+	*mutualA
+	B int
+}
+
+func TestEmbeddedCycle(t *testing.T) {
+	for _, test := range []struct {
+		x    any
+		want string
+	}{
+		{selfEmbed{selfEmbed: &selfEmbed{X: 2}, X: 1}, "[X=1]"},
+		{mutualA{mutualB: &mutualB{B: 2}, A: 1}, "[B=2 A=1]"},
+	} {
+		if got := Value(test.x).String(); got != test.want {
+			t.Errorf("%T: got %s, want %s", test.x, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package slogx
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Lazy returns a LogValuer whose value is the result of f.
+// f is called at most once, the first time a handler resolves the value,
+// and the result is remembered for later resolutions. So f costs nothing
+// if the record's level is disabled, and a handler that resolves the
+// value more than once, or several handlers that share the record, call
+// it only once.
+//
+// Because the result is remembered, a Lazy value passed to
+// [slog.Logger.With] is computed once for all records. To compute a value
+// for each record, pass a new Lazy value to each call.
+func Lazy(f func() slog.Value) slog.LogValuer {
+	return &lazy{f: f}
+}
+
+type lazy struct {
+	once sync.Once
+	f    func() slog.Value
+	v    slog.Value
+}
+
+func (l *lazy) LogValue() slog.Value {
+	l.once.Do(func() {
+		l.v = l.f()
+		l.f = nil
+	})
+	return l.v
+}
@@ -0,0 +1,78 @@
+package slogx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/cloudlogging"
+	"github.com/jba/slog/handlers"
+	"github.com/jba/slog/handlers/general"
+	"github.com/jba/slog/handlers/loghandler"
+	"github.com/jba/slog/handlers/msgpack"
+)
+
+func TestLazyHandlers(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		h    slog.Handler
+	}{
+		{"slog.Text", slog.NewTextHandler(io.Discard, nil)},
+		{"slog.JSON", slog.NewJSONHandler(io.Discard, nil)},
+		{"general.Text", general.New(io.Discard, general.NewTextFormatter)},
+		{"general.JSON", general.New(io.Discard, general.NewJSONFormatter)},
+		{"general.CBOR", general.New(io.Discard, general.NewCBORFormatter)},
+		{"loghandler", loghandler.New(io.Discard, nil)},
+		{"binary", handlers.NewBinaryHandler(io.Discard, nil)},
+		{"msgpack", msgpack.New(io.Discard, "t", nil)},
+		{"cloudlogging", cloudlogging.New(io.Discard)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			const n = 20
+			var shared, fresh atomic.Int64
+			sharedLazy := Lazy(func() slog.Value {
+				shared.Add(1)
+				return slog.IntValue(1)
+			})
+			h := test.h.WithAttrs([]slog.Attr{slog.Any("w", sharedLazy)})
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r := slog.NewRecord(time.Now(), slog.LevelInfo, "m", 0)
+					r.AddAttrs(
+						slog.Any("a", sharedLazy),
+						slog.Any("b", Lazy(func() slog.Value {
+							fresh.Add(1)
+							return slog.GroupValue(slog.String("c", "d"))
+						})),
+					)
+					if err := h.Handle(context.Background(), r); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			if got := shared.Load(); got != 1 {
+				t.Errorf("shared value computed %d times, want 1", got)
+			}
+			if got := fresh.Load(); got != n {
+				t.Errorf("per-record values computed %d times, want %d", got, n)
+			}
+		})
+	}
+}
+
+func TestLazyDisabled(t *testing.T) {
+	called := false
+	l := slog.New(general.New(io.Discard, general.NewJSONFormatter))
+	l.Debug("m", "a", Lazy(func() slog.Value { called = true; return slog.Value{} }))
+	if called {
+		t.Error("function called for disabled level")
+	}
+}
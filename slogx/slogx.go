@@ -0,0 +1,94 @@
+// Package slogx provides constructors for common kinds of Attrs
+// that package slog lacks.
+package slogx
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys used by the constructors that don't take a key.
+const (
+	ErrorKey = "err"
+	StackKey = "stack"
+)
+
+// Err returns an Attr for err with the key "err".
+// If err is nil, it returns the empty Attr, which handlers ignore.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.Any(ErrorKey, err)
+}
+
+// Stack returns an Attr with the key "stack" whose value is the stack of
+// the goroutine that calls Stack, starting with its caller. Each frame is
+// written as the function, followed by a newline, a tab and file:line.
+func Stack() slog.Attr {
+	return slog.String(StackKey, stack(1))
+}
+
+// stack formats the stack of its caller, omitting the first skip frames.
+func stack(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	fs := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		f, more := fs.Next()
+		b.WriteString(f.Function)
+		b.WriteString("\n\t")
+		b.WriteString(f.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(f.Line))
+		if !more {
+			break
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Type returns an Attr whose value is the name of the type of v,
+// as printed by fmt's %T verb.
+func Type(key string, v any) slog.Attr {
+	return slog.String(key, fmt.Sprintf("%T", v))
+}
+
+// Stringer returns an Attr whose value is the result of s.String.
+// String is called only if the Attr is written. If s is nil, or a nil
+// pointer, the value is "<nil>".
+func Stringer(key string, s fmt.Stringer) slog.Attr {
+	return slog.Any(key, stringer{s})
+}
+
+type stringer struct{ s fmt.Stringer }
+
+func (s stringer) LogValue() slog.Value {
+	if s.s == nil {
+		return slog.StringValue("<nil>")
+	}
+	if v := reflect.ValueOf(s.s); v.Kind() == reflect.Pointer && v.IsNil() {
+		return slog.StringValue("<nil>")
+	}
+	return slog.StringValue(s.s.String())
+}
+
+// LazyString returns an Attr whose value is the result of f.
+// As with [Lazy], f is called at most once, and only if the Attr is written.
+func LazyString(key string, f func() string) slog.Attr {
+	return slog.Any(key, Lazy(func() slog.Value { return slog.StringValue(f()) }))
+}
+
+// Duration returns an Attr whose value is d formatted as by
+// [time.Duration.String], like "1.5s", instead of the integer
+// nanoseconds that many handlers write.
+func Duration(key string, d time.Duration) slog.Attr {
+	return slog.String(key, d.String())
+}
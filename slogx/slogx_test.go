@@ -0,0 +1,59 @@
+package slogx
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttrs(t *testing.T) {
+	var ip *net.IP
+	for _, test := range []struct {
+		attr slog.Attr
+		want string
+	}{
+		{Err(errors.New("bad")), "err=bad"},
+		{Err(nil), ""},
+		{Type("t", 1.5), "t=float64"},
+		{Stringer("s", net.IPv4(1, 2, 3, 4)), "s=1.2.3.4"},
+		{Stringer("s", nil), "s=<nil>"},
+		{Stringer("s", ip), "s=<nil>"},
+		{LazyString("l", func() string { return "x" }), "l=x"},
+		{Duration("d", 1500*time.Millisecond), "d=1.5s"},
+	} {
+		var buf bytes.Buffer
+		l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+		l.LogAttrs(nil, slog.LevelInfo, "", test.attr)
+		if got := strings.TrimSpace(buf.String()); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestLazyNotCalled(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	called := false
+	l.Debug("m", LazyString("l", func() string { called = true; return "" }))
+	if called {
+		t.Error("function called for disabled level")
+	}
+}
+
+func TestStack(t *testing.T) {
+	a := Stack()
+	s := a.Value.String()
+	if !strings.HasPrefix(s, "github.com/jba/slog/slogx.TestStack\n\t") || !strings.Contains(s, "slogx_test.go:") {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,94 @@
+// Package stdlog sends the output of a standard library log.Logger
+// to a slog.Handler.
+//
+// It is useful for capturing output from components that only accept
+// a *log.Logger, like http.Server.ErrorLog.
+package stdlog
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// A LevelPattern assigns a level to lines that match a regular expression.
+type LevelPattern struct {
+	Regexp *regexp.Regexp
+	Level  slog.Level
+}
+
+// DefaultLevelPatterns recognize lines that begin with a level name,
+// in any case, optionally followed by a colon, like "ERROR:" or "warn".
+var DefaultLevelPatterns = []LevelPattern{
+	{regexp.MustCompile(`(?i)^\s*(error|err|fatal|panic)\b:?\s*`), slog.LevelError},
+	{regexp.MustCompile(`(?i)^\s*(warning|warn)\b:?\s*`), slog.LevelWarn},
+	{regexp.MustCompile(`(?i)^\s*info\b:?\s*`), slog.LevelInfo},
+	{regexp.MustCompile(`(?i)^\s*debug\b:?\s*`), slog.LevelDebug},
+}
+
+// Options are options for [NewWriter] and [NewLogger].
+type Options struct {
+	// Level is the level of records whose level is not determined
+	// by LevelPatterns.
+	Level slog.Level
+
+	// LevelPatterns are tried in order on each line. The level of the
+	// first matching pattern is used for the record.
+	// Use DefaultLevelPatterns for common conventions.
+	LevelPatterns []LevelPattern
+
+	// If KeepMatch is true, the text matched by a LevelPattern remains
+	// in the message. Otherwise it is removed.
+	KeepMatch bool
+}
+
+// NewWriter returns an io.Writer that turns what is written to it into
+// records for h.
+//
+// Each call to Write produces one record, whose message is the written
+// text without its trailing newline. That matches the behavior of
+// log.Logger, which writes each log entry with a single call.
+// Write never returns an error from h.
+func NewWriter(h slog.Handler, opts *Options) io.Writer {
+	w := &writer{h: h}
+	if opts != nil {
+		w.opts = *opts
+	}
+	return w
+}
+
+// NewLogger returns a log.Logger whose output goes to h.
+// The logger has no prefix or flags; in particular, it does not
+// write a timestamp, because the record has one.
+func NewLogger(h slog.Handler, opts *Options) *log.Logger {
+	return log.New(NewWriter(h, opts), "", 0)
+}
+
+type writer struct {
+	h    slog.Handler
+	opts Options
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	level := w.opts.Level
+	for _, lp := range w.opts.LevelPatterns {
+		if loc := lp.Regexp.FindStringIndex(msg); loc != nil {
+			level = lp.Level
+			if !w.opts.KeepMatch {
+				msg = msg[:loc[0]] + msg[loc[1]:]
+			}
+			break
+		}
+	}
+	ctx := context.Background()
+	if w.h.Enabled(ctx, level) {
+		r := slog.NewRecord(time.Now(), level, msg, 0)
+		_ = w.h.Handle(ctx, r)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,41 @@
+package stdlog
+
+import (
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/jba/slog/handlers/memory"
+)
+
+func TestLogger(t *testing.T) {
+	h := memory.New(nil)
+	l := NewLogger(h, &Options{Level: slog.LevelWarn, LevelPatterns: DefaultLevelPatterns})
+	l.Print("plain")
+	l.Printf("ERROR: failed %d times", 3)
+	l.Println("warning something")
+	l.Print("Info: fyi")
+	l.Print("debug: dropped")
+	l.Print("errors are not levels")
+
+	h.AssertLogged(t, slog.LevelWarn, "plain")
+	h.AssertLogged(t, slog.LevelError, "failed 3 times")
+	h.AssertLogged(t, slog.LevelWarn, "something")
+	h.AssertLogged(t, slog.LevelInfo, "fyi")
+	h.AssertLogged(t, slog.LevelWarn, "errors are not levels")
+	if got, want := len(h.Records()), 5; got != want {
+		t.Errorf("got %d records, want %d", got, want)
+	}
+}
+
+func TestKeepMatch(t *testing.T) {
+	h := memory.New(nil)
+	w := NewWriter(h, &Options{
+		LevelPatterns: []LevelPattern{{regexp.MustCompile(`\[E\]`), slog.LevelError}},
+		KeepMatch:     true,
+	})
+	if _, err := w.Write([]byte("x [E] y\n")); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertLogged(t, slog.LevelError, "x [E] y")
+}
@@ -39,7 +39,7 @@ type Tracer struct {
 var _ otrace.Tracer = (*Tracer)(nil)
 
 func (t *Tracer) Start(ctx context.Context, name string, opts ...otrace.SpanStartOption) (context.Context, otrace.Span) {
-	s := &span{name: name}
+	s := &span{Span: noopSpan, name: name}
 	// Append the new span to the context's spanList, adding a spanList if there is none.
 	sl, ok := ctx.Value(spanListKey{}).(*spanList)
 	if !ok {
@@ -50,12 +50,17 @@ func (t *Tracer) Start(ctx context.Context, name string, opts ...otrace.SpanStar
 	return otrace.ContextWithSpan(ctx, s), s
 }
 
+// A span only has a name. It doesn't belong to a trace, so its
+// SpanContext is invalid, and its methods other than End do nothing.
 type span struct {
-	otrace.Span
-	name string
-	list *spanList
+	otrace.Span // a no-op span
+	name        string
+	list        *spanList
 }
 
+// noopSpan is the span of a context without one.
+var noopSpan = otrace.SpanFromContext(context.Background())
+
 func (s *span) End(options ...otrace.SpanEndOption) {
 	// Remove the span from the context's spanList.
 	s.list.remove(s)
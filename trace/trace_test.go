@@ -4,6 +4,8 @@ import (
 	"context"
 	"log/slog"
 	"testing"
+
+	otrace "go.opentelemetry.io/otel/trace"
 )
 
 func Test(t *testing.T) {
@@ -42,3 +44,24 @@ func (h *handler) Handle(ctx context.Context, r slog.Record) error {
 	}
 	return h.Handler.Handle(ctx, r)
 }
+
+func TestSpanContext(t *testing.T) {
+	parent := otrace.NewSpanContext(otrace.SpanContextConfig{
+		TraceID: otrace.TraceID{1},
+		SpanID:  otrace.SpanID{2},
+	})
+	ctx := otrace.ContextWithSpanContext(context.Background(), parent)
+	ctx, s := (&Tracer{}).Start(ctx, "child")
+	defer s.End()
+	// The child is not the parent, and has no SpanContext of its own.
+	if sc := otrace.SpanContextFromContext(ctx); sc.IsValid() {
+		t.Errorf("got SpanContext %v, want an invalid one", sc)
+	}
+	if s.IsRecording() {
+		t.Error("span is recording")
+	}
+	s.SetName("other")
+	if got := SpanName(ctx); got != "child" {
+		t.Errorf("got name %q, want %q", got, "child")
+	}
+}
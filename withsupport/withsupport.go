@@ -2,7 +2,10 @@
 // Handler.WithGroup.
 package withsupport
 
-import "log/slog"
+import (
+	"log/slog"
+	"slices"
+)
 
 // GroupOrAttrs holds either a group name or a list of slog.Attrs.
 type GroupOrAttrs struct {
@@ -72,3 +75,90 @@ func (g *GroupOrAttrs) Collect() []*GroupOrAttrs {
 	}
 	return res
 }
+
+// Iterate calls f on each Attr in g, in the order they were added. The
+// first argument to f is the list of groups that precede the Attr; f must
+// not modify or retain it. If f returns false, Iterate stops.
+// Unlike Apply, Iterate does not allocate a closure.
+func (g *GroupOrAttrs) Iterate(f func(groups []string, a slog.Attr) bool) {
+	g.iterate(f)
+}
+
+// iterate is Iterate, returning the complete list of groups
+// and whether f always returned true.
+func (g *GroupOrAttrs) iterate(f func([]string, slog.Attr) bool) ([]string, bool) {
+	if g == nil {
+		return nil, true
+	}
+	groups, ok := g.Next.iterate(f)
+	if !ok {
+		return nil, false
+	}
+	if g.Group != "" {
+		return append(groups, g.Group), true
+	}
+	for _, a := range g.Attrs {
+		if !f(groups, a) {
+			return nil, false
+		}
+	}
+	return groups, true
+}
+
+// NumAttrs returns the number of Attrs in g, not counting the members
+// of groups within them.
+func (g *GroupOrAttrs) NumAttrs() int {
+	n := 0
+	for ; g != nil; g = g.Next {
+		n += len(g.Attrs)
+	}
+	return n
+}
+
+// Groups returns the complete list of groups in g, outermost first.
+// It returns nil if there are none.
+func (g *GroupOrAttrs) Groups() []string {
+	var groups []string
+	for ga := g; ga != nil; ga = ga.Next {
+		if ga.Group != "" {
+			groups = append(groups, ga.Group)
+		}
+	}
+	slices.Reverse(groups)
+	return groups
+}
+
+// Nest returns as nested in the groups of g, and preceded at each level
+// by the Attrs of g that were added there. This is how the Attrs of a
+// record appear, with those of a handler, in the handler's output.
+// A group that would be empty is omitted, as slog's handlers omit it.
+// Nest does not modify as or the Attrs of g.
+func (g *GroupOrAttrs) Nest(as []slog.Attr) []slog.Attr {
+	as = slices.Clip(as)
+	// Build from the innermost group outward.
+	for ; g != nil; g = g.Next {
+		if g.Group != "" {
+			if len(as) > 0 {
+				as = []slog.Attr{{Key: g.Group, Value: slog.GroupValue(as...)}}
+			}
+		} else {
+			as = append(slices.Clip(g.Attrs), as...)
+		}
+	}
+	return as
+}
+
+// Record returns a copy of r whose Attrs are those of r nested by
+// [GroupOrAttrs.Nest].
+func (g *GroupOrAttrs) Record(r slog.Record) slog.Record {
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r2.AddAttrs(g.Nest(RecordAttrs(r))...)
+	return r2
+}
+
+// RecordAttrs returns the Attrs of r.
+func RecordAttrs(r slog.Record) []slog.Attr {
+	as := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool { as = append(as, a); return true })
+	return as
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -86,3 +88,67 @@ func TestCollect(t *testing.T) {
 		t.Errorf("-want, +got:\n%s", diff)
 	}
 }
+
+func TestIterate(t *testing.T) {
+	var g *GroupOrAttrs
+	g = g.WithAttrs([]slog.Attr{slog.Int("a", 1)}).
+		WithGroup("G").
+		WithAttrs([]slog.Attr{slog.Int("b", 2), slog.Int("c", 3)}).
+		WithGroup("H").
+		WithAttrs([]slog.Attr{slog.Int("d", 4)})
+
+	var got []string
+	g.Iterate(func(groups []string, a slog.Attr) bool {
+		got = append(got, strings.Join(append(slices.Clip(groups), a.Key), "."))
+		return true
+	})
+	want := []string{"a", "G.b", "G.c", "G.H.d"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("-want, +got:\n%s", diff)
+	}
+
+	got = nil
+	g.Iterate(func(groups []string, a slog.Attr) bool {
+		got = append(got, a.Key)
+		return a.Key != "b"
+	})
+	want = []string{"a", "b"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("early stop: -want, +got:\n%s", diff)
+	}
+
+	if got, want := g.NumAttrs(), 4; got != want {
+		t.Errorf("NumAttrs: got %d, want %d", got, want)
+	}
+	if diff := cmp.Diff([]string{"G", "H"}, g.Groups()); diff != "" {
+		t.Errorf("Groups: -want, +got:\n%s", diff)
+	}
+	var nilg *GroupOrAttrs
+	if nilg.NumAttrs() != 0 || nilg.Groups() != nil {
+		t.Error("nil GroupOrAttrs: want no attrs or groups")
+	}
+}
+
+func TestNest(t *testing.T) {
+	var g *GroupOrAttrs
+	g = g.WithAttrs([]slog.Attr{slog.Int("a", 1)}).
+		WithGroup("G").
+		WithAttrs([]slog.Attr{slog.Int("b", 2)}).
+		WithGroup("H").
+		WithGroup("I")
+
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)
+	r.AddAttrs(slog.Int("c", 3))
+	got := fmt.Sprint(RecordAttrs(g.Record(r)))
+	want := "[a=1 G=[b=2 H=[I=[c=3]]]]"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Empty groups are omitted.
+	got = fmt.Sprint(g.Nest(nil))
+	want = "[a=1 G=[b=2]]"
+	if got != want {
+		t.Errorf("no attrs: got %s, want %s", got, want)
+	}
+}
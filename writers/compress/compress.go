@@ -0,0 +1,122 @@
+// Package compress provides an io.Writer that compresses log output
+// while keeping it readable as it is written.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// A Compressor is a compressing writer. Both *gzip.Writer and the
+// zstd encoder from github.com/klauspost/compress/zstd satisfy it.
+type Compressor interface {
+	io.WriteCloser
+	// Flush writes any buffered data so that it can be decompressed.
+	Flush() error
+	// Reset discards the Compressor's state and makes it write to w.
+	Reset(w io.Writer)
+}
+
+// Options are options for a [Writer].
+type Options struct {
+	// NewCompressor returns a Compressor that writes to w.
+	// If nil, the Writer uses gzip with the default compression level.
+	NewCompressor func(w io.Writer) (Compressor, error)
+
+	// If PerWrite is true, each call to Write is compressed as an
+	// independent stream. For gzip, the output is a sequence of gzip
+	// members, which gzip.Reader reads as a single stream by default.
+	// This compresses less than a single stream, but any prefix of the
+	// output that ends at a write boundary can be decompressed.
+	PerWrite bool
+
+	// FlushEvery is the number of writes between flushes when PerWrite
+	// is false. If it is zero, the Writer flushes only when Flush or
+	// Close is called. Flushing makes all previous writes available to
+	// a reader, at some cost in compression.
+	FlushEvery int
+}
+
+// A Writer compresses the data written to it. It is safe for
+// concurrent use.
+type Writer struct {
+	opts Options
+
+	mu      sync.Mutex
+	w       io.Writer
+	c       Compressor
+	nWrites int // since last flush
+}
+
+// NewWriter returns a Writer that writes compressed data to w.
+// If opts is nil, the Writer uses gzip and flushes only when asked.
+func NewWriter(w io.Writer, opts *Options) (*Writer, error) {
+	cw := &Writer{w: w}
+	if opts != nil {
+		cw.opts = *opts
+	}
+	newc := cw.opts.NewCompressor
+	if newc == nil {
+		newc = Gzip(gzip.DefaultCompression)
+	}
+	c, err := newc(w)
+	if err != nil {
+		return nil, err
+	}
+	cw.c = c
+	return cw, nil
+}
+
+// Gzip returns a function suitable for [Options.NewCompressor] that
+// compresses with gzip at the given level.
+func Gzip(level int) func(io.Writer) (Compressor, error) {
+	return func(w io.Writer) (Compressor, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// Write compresses p and writes it to the underlying writer,
+// flushing or finishing a stream as required by the Writer's options.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.c.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if w.opts.PerWrite {
+		err = w.c.Close()
+		w.c.Reset(w.w)
+		return n, err
+	}
+	w.nWrites++
+	if w.opts.FlushEvery > 0 && w.nWrites >= w.opts.FlushEvery {
+		err = w.flush()
+	}
+	return n, err
+}
+
+// Flush writes all pending compressed data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	w.nWrites = 0
+	return w.c.Flush()
+}
+
+// Close finishes the compressed stream. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.opts.PerWrite {
+		// Each write already finished its stream.
+		return nil
+	}
+	return w.c.Close()
+}
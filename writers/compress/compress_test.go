@@ -0,0 +1,90 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestPerWrite(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &Options{PerWrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want string
+	for _, s := range []string{"one\n", "two\n", "three\n"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+		want += s
+		// Everything written so far can be read.
+		if got := decompress(t, buf.Bytes()); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFlushEvery(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, &Options{NewCompressor: Gzip(gzip.BestSpeed), FlushEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(s string) {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a")
+	write("b")
+	if got, want := readAvailable(t, buf.Bytes()), "ab"; got != want {
+		t.Errorf("after flush: got %q, want %q", got, want)
+	}
+	write("c")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decompress(t, buf.Bytes()), "abc"; got != want {
+		t.Errorf("after close: got %q, want %q", got, want)
+	}
+}
+
+func TestBadLevel(t *testing.T) {
+	if _, err := NewWriter(io.Discard, &Options{NewCompressor: Gzip(99)}); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+// decompress decompresses a complete gzip stream.
+func decompress(t *testing.T, data []byte) string {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+// readAvailable decompresses as much of an unfinished gzip stream
+// as possible.
+func readAvailable(t *testing.T, data []byte) string {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want ErrUnexpectedEOF", err)
+	}
+	return string(got)
+}
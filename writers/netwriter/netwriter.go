@@ -0,0 +1,442 @@
+// Package netwriter provides an io.Writer that sends log records to a
+// network endpoint, such as a TCP input of Logstash, Vector or Fluent Bit.
+//
+// Each call to Write is treated as one record, as the handlers in this
+// module produce them. Records are buffered in memory and sent by a
+// background goroutine, which reconnects after failures. For
+// newline-delimited JSON, set [Options.Newline] and use a JSON handler;
+// for the binary format, use the BinaryHandler of package
+// github.com/jba/slog/handlers, whose records are already framed:
+//
+//	w := netwriter.NewWriter("tcp", "logs:5170", &netwriter.Options{Newline: true})
+//	defer w.Close()
+//	logger := slog.New(slog.NewJSONHandler(w, nil))
+//
+// To send syslog messages, use a Formatter from
+// [github.com/jba/slog/handlers/general.SyslogOptions], with
+// [Options.Newline] or, for servers like Heroku's Logplex that expect it,
+// [Options.OctetCounting].
+//
+// To keep records through an outage that outlasts the buffer, or a
+// restart of the program, set [Options.Spill] to a queue on disk.
+package netwriter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jba/slog/metrics"
+	"github.com/jba/slog/writers/spill"
+)
+
+// Options are options for a [Writer].
+type Options struct {
+	// TLSConfig, if non-nil, is used to make TLS connections.
+	// It is only meaningful for stream networks like "tcp".
+	TLSConfig *tls.Config
+
+	// If Newline is true, a newline is added to each record that does
+	// not end in one.
+	Newline bool
+
+	// If OctetCounting is true, each record is framed by its length in
+	// bytes and a space, as described in RFC 6587 and expected by some
+	// syslog servers and by Heroku's Logplex. A final newline is removed
+	// from the record first. Newline is ignored.
+	OctetCounting bool
+
+	// BufferSize is the most bytes of records to hold while they wait to
+	// be sent, as during an outage. If it is zero, 1 MiB is used.
+	BufferSize int
+
+	// If Block is true, Write waits for room in the buffer when it is full.
+	// Otherwise, the oldest records are dropped to make room.
+	// Block is ignored if Spill is set.
+	Block bool
+
+	// Spill, if non-nil, holds records on disk when the buffer is full,
+	// instead of dropping them. When a record doesn't fit, it and those
+	// in the buffer are moved to Spill, and later records follow them
+	// there until Spill is empty, so that records are sent in order.
+	// Records that remain when Close is called are moved to Spill as
+	// well, and Spill's records are sent first by a later Writer that
+	// uses the same directory. The Writer does not close Spill.
+	Spill *spill.Queue
+
+	// DialTimeout limits each attempt to connect.
+	// If it is zero, 5 seconds is used.
+	DialTimeout time.Duration
+
+	// WriteTimeout limits each write to the connection, so that a peer
+	// that stops reading can't stall the Writer, or its Close, forever.
+	// A write that times out is retried on a new connection, unless the
+	// Writer is closed. If it is zero, 10 seconds is used; if it is
+	// negative, writes are not limited.
+	WriteTimeout time.Duration
+
+	// MinBackoff and MaxBackoff bound the wait between attempts to
+	// connect, which doubles after each failure. If they are zero,
+	// 100 milliseconds and 30 seconds are used.
+	MinBackoff, MaxBackoff time.Duration
+
+	// OnError, if non-nil, is called with each error from connecting
+	// or writing. It is called from the Writer's goroutine.
+	OnError func(error)
+}
+
+// ErrClosed is returned by Write after Close is called.
+var ErrClosed = errors.New("netwriter: writer is closed")
+
+// A Writer sends the records written to it to a network address.
+// It is safe for concurrent use.
+type Writer struct {
+	network, addr string
+	opts          Options
+
+	mu       sync.Mutex
+	cond     *sync.Cond // signaled when the queue or closed changes
+	queue    [][]byte
+	size     int  // total bytes in queue
+	inFlight bool // a record is being sent
+	closed   bool
+	dropped  int64
+
+	spilled   bool  // opts.Spill may hold records
+	fromSpill bool  // the record being sent is at the front of opts.Spill
+	spillErr  error // error reading opts.Spill, to be reported
+
+	stats metrics.Tracker
+
+	done    chan struct{} // closed by Close
+	stopped chan struct{} // closed when the sending goroutine exits
+	conn    net.Conn      // used only by the sending goroutine
+}
+
+// NewWriter returns a Writer that sends records to the address on the
+// named network, as for [net.Dial]. It does not wait for a connection.
+// If opts is nil, the default options are used.
+func NewWriter(network, addr string, opts *Options) *Writer {
+	w := &Writer{
+		network: network,
+		addr:    addr,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.BufferSize <= 0 {
+		w.opts.BufferSize = 1 << 20
+	}
+	if w.opts.DialTimeout <= 0 {
+		w.opts.DialTimeout = 5 * time.Second
+	}
+	if w.opts.WriteTimeout == 0 {
+		w.opts.WriteTimeout = 10 * time.Second
+	}
+	if w.opts.MinBackoff <= 0 {
+		w.opts.MinBackoff = 100 * time.Millisecond
+	}
+	if w.opts.MaxBackoff <= 0 {
+		w.opts.MaxBackoff = 30 * time.Second
+	}
+	w.spilled = w.opts.Spill != nil && w.opts.Spill.Size() > 0
+	w.cond = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+// Write queues a copy of p to be sent as one record. It does not wait
+// for the record to be sent, so it does not report network errors;
+// use [Options.OnError] to observe them.
+func (w *Writer) Write(p []byte) (int, error) {
+	var rec []byte
+	if w.opts.OctetCounting {
+		msg := bytes.TrimSuffix(p, []byte{'\n'})
+		rec = strconv.AppendInt(make([]byte, 0, len(msg)+8), int64(len(msg)), 10)
+		rec = append(rec, ' ')
+		rec = append(rec, msg...)
+	} else {
+		rec = make([]byte, len(p), len(p)+1)
+		copy(rec, p)
+		if w.opts.Newline && (len(rec) == 0 || rec[len(rec)-1] != '\n') {
+			rec = append(rec, '\n')
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.opts.Spill != nil && !w.closed && (w.spilled || w.size+len(rec) > w.opts.BufferSize) {
+		w.spill(rec)
+		return len(p), nil
+	}
+	for w.opts.Block && !w.closed && len(w.queue) > 0 && w.size+len(rec) > w.opts.BufferSize {
+		w.cond.Wait()
+	}
+	if w.closed {
+		return 0, ErrClosed
+	}
+	for len(w.queue) > 0 && w.size+len(rec) > w.opts.BufferSize {
+		w.size -= len(w.queue[0])
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.dropped++
+	}
+	w.queue = append(w.queue, rec)
+	w.size += len(rec)
+	w.cond.Broadcast()
+	return len(p), nil
+}
+
+// spill moves the buffered records to opts.Spill, followed by rec.
+// Records that can't be spilled are dropped.
+// w.mu must be held.
+func (w *Writer) spill(rec []byte) {
+	for _, r := range w.queue {
+		if w.opts.Spill.Push(r) != nil {
+			w.dropped++
+		}
+	}
+	clear(w.queue)
+	w.queue = w.queue[:0]
+	w.size = 0
+	if rec != nil && w.opts.Spill.Push(rec) != nil {
+		w.dropped++
+	}
+	w.spilled = true
+	w.cond.Broadcast()
+}
+
+// Dropped returns the number of records that were dropped, either because
+// the buffer was full or because they could not be sent before Close.
+// With a Spill, records are dropped only if the Spill can't hold them.
+func (w *Writer) Dropped() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// Status reports the records and bytes sent, the errors from connecting
+// and writing, and the number of records buffered in memory. Records in
+// the Spill are not counted in the QueueDepth.
+func (w *Writer) Status() metrics.Status {
+	s := w.stats.Status()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s.QueueDepth = int64(len(w.queue))
+	return s
+}
+
+// Flush waits until all records written so far have been sent,
+// or until ctx is done.
+func (w *Writer) Flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.cond.Broadcast()
+	})
+	defer stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for (len(w.queue) > 0 || w.inFlight || w.spilled) && !w.closed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		w.cond.Wait()
+	}
+	return nil
+}
+
+// Close stops accepting records and waits for the buffered ones to be
+// sent. If connecting or writing fails, the remaining records are dropped,
+// or moved to the Spill if there is one. Records already in the Spill
+// are left there. Close then closes the connection.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	<-w.stopped
+	return nil
+}
+
+// run sends records until the Writer is closed and its queue is empty.
+func (w *Writer) run() {
+	defer close(w.stopped)
+	backoff := w.opts.MinBackoff
+	for {
+		rec, ok, err := w.next()
+		if err != nil {
+			w.report(err)
+		}
+		if !ok {
+			break
+		}
+		if w.conn == nil {
+			c, err := w.dial()
+			if err != nil {
+				w.report(err)
+				if !w.requeue(rec) {
+					break
+				}
+				w.sleep(backoff)
+				backoff = min(2*backoff, w.opts.MaxBackoff)
+				continue
+			}
+			w.conn = c
+			backoff = w.opts.MinBackoff
+		}
+		if w.opts.WriteTimeout > 0 {
+			_ = w.conn.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+		}
+		if _, err := w.conn.Write(rec); err != nil {
+			w.report(err)
+			w.conn.Close()
+			w.conn = nil
+			// A datagram is either sent whole or not at all, and resending
+			// it won't help. A stream record is sent again on a new connection.
+			if isPacket(w.network) {
+				w.sent(true)
+			} else if !w.requeue(rec) {
+				break
+			}
+			continue
+		}
+		w.stats.Wrote(len(rec))
+		w.sent(false)
+	}
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// next removes the first record from the queue and returns it, waiting
+// for one if necessary. When the queue is empty, it takes the first record
+// of opts.Spill instead, leaving it there until it is sent. It returns false
+// if the Writer is closed and the queue is empty. It also returns any error
+// from reading opts.Spill.
+func (w *Writer) next() (_ []byte, _ bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for len(w.queue) == 0 && !w.spilled && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) > 0 || w.closed {
+			break
+		}
+		rec, perr := w.opts.Spill.Peek()
+		if perr == nil {
+			w.inFlight = true
+			w.fromSpill = true
+			return rec, true, err
+		}
+		// Whether the Spill is empty or can't be read, go back to
+		// buffering records in memory.
+		if !errors.Is(perr, spill.ErrEmpty) {
+			err = perr
+		}
+		w.spilled = false
+		w.cond.Broadcast()
+	}
+	if len(w.queue) == 0 {
+		return nil, false, err
+	}
+	rec := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	w.size -= len(rec)
+	w.inFlight = true
+	w.cond.Broadcast()
+	return rec, true, err
+}
+
+// sent records that the record returned by next is done with.
+func (w *Writer) sent(dropped bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight = false
+	if w.fromSpill {
+		w.opts.Spill.Remove()
+		w.fromSpill = false
+	}
+	if dropped {
+		w.dropped++
+	}
+	w.cond.Broadcast()
+}
+
+// requeue puts rec, which could not be sent, back at the front of the
+// queue, or leaves it at the front of opts.Spill if it came from there.
+// If the Writer is closed, it drops rec and the rest of the queue
+// instead, or moves them to opts.Spill, and returns false.
+func (w *Writer) requeue(rec []byte) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight = false
+	w.cond.Broadcast()
+	if w.fromSpill {
+		w.fromSpill = false
+		if w.closed && len(w.queue) > 0 {
+			w.spill(nil)
+		}
+		return !w.closed
+	}
+	if w.closed {
+		if w.opts.Spill != nil {
+			w.queue = append([][]byte{rec}, w.queue...)
+			w.spill(nil)
+			return false
+		}
+		w.dropped += int64(1 + len(w.queue))
+		w.queue = nil
+		w.size = 0
+		return false
+	}
+	w.queue = append([][]byte{rec}, w.queue...)
+	w.size += len(rec)
+	return true
+}
+
+func (w *Writer) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: w.opts.DialTimeout}
+	if w.opts.TLSConfig != nil {
+		return tls.DialWithDialer(d, w.network, w.addr, w.opts.TLSConfig)
+	}
+	return d.Dial(w.network, w.addr)
+}
+
+// sleep waits for d, or until the Writer is closed.
+func (w *Writer) sleep(d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-w.done:
+	}
+}
+
+func (w *Writer) report(err error) {
+	w.stats.Failed(err)
+	if w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+}
+
+func isPacket(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
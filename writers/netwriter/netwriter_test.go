@@ -0,0 +1,371 @@
+package netwriter
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/writers/spill"
+)
+
+func TestTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	testStream(t, l, NewWriter("tcp", l.Addr().String(), &Options{Newline: true}))
+}
+
+func TestTLS(t *testing.T) {
+	s := httptest.NewUnstartedServer(nil)
+	s.StartTLS()
+	cert := s.Certificate()
+	scfg := s.TLS.Clone()
+	s.Close()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", scfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	w := NewWriter("tcp", l.Addr().String(), &Options{
+		Newline:   true,
+		TLSConfig: &tls.Config{RootCAs: pool, ServerName: "example.com"},
+	})
+	testStream(t, l, w)
+}
+
+// testStream writes two records to w and checks that they arrive at l.
+func testStream(t *testing.T, l net.Listener, w *Writer) {
+	t.Helper()
+	for _, s := range []string{"one", "two\n"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"one\n", "two\n"} {
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestOctetCounting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	w := NewWriter("tcp", l.Addr().String(), &Options{OctetCounting: true, Newline: true})
+	defer w.Close()
+	for _, s := range []string{"<14>1 - - - - - - hi\n", "two\nlines", ""} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	want := "20 <14>1 - - - - - - hi9 two\nlines0 "
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	w := NewWriter("tcp", l.Addr().String(), &Options{Newline: true, MinBackoff: time.Millisecond})
+	defer w.Close()
+
+	fmt.Fprint(w, "first")
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := bufio.NewReader(conn).ReadString('\n'); err != nil || got != "first\n" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	conn.Close()
+
+	// Writes to the closed connection eventually fail, and the Writer
+	// reconnects. Keep writing until a record arrives on a new connection.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				fmt.Fprintf(w, "r%d", i)
+			}
+		}
+	}()
+	conn, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 'r' {
+		t.Errorf("got %q, want a record", got)
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	// Get an address with no listener.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var errs int
+	w := NewWriter("tcp", addr, &Options{
+		BufferSize: 10,
+		MinBackoff: time.Hour,
+		OnError:    func(error) { errs++ },
+	})
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(w, "rec%d", i)
+	}
+	if got := w.Dropped(); got < 2 {
+		t.Errorf("before Close: got %d dropped, want at least 2", got)
+	}
+	if got := w.Status().QueueDepth; got < 1 {
+		t.Errorf("before Close: got queue depth %d, want at least 1", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Dropped(); got != 5 {
+		t.Errorf("after Close: got %d dropped, want 5", got)
+	}
+	if s := w.Status(); s.Records != 0 || s.Errors == 0 || s.LastError == "" {
+		t.Errorf("after Close: got %+v, want errors and no records", s)
+	}
+	if errs == 0 {
+		t.Error("OnError not called")
+	}
+}
+
+func TestUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w := NewWriter("udp", pc.LocalAddr().String(), nil)
+	defer w.Close()
+	for _, s := range []string{"one", "two"} {
+		fmt.Fprint(w, s)
+	}
+	buf := make([]byte, 100)
+	for _, want := range []string{"one", "two"} {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	w := NewWriter("tcp", l.Addr().String(), &Options{BufferSize: 4, Block: true})
+	defer w.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			fmt.Fprint(w, "abc")
+		}
+	}()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf := make([]byte, 30)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if got := w.Dropped(); got != 0 {
+		t.Errorf("got %d dropped, want 0", got)
+	}
+}
+
+func TestSpill(t *testing.T) {
+	dir := t.TempDir()
+	// Get an address with no listener.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	q, err := spill.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter("tcp", addr, &Options{
+		Newline:    true,
+		BufferSize: 10,
+		MinBackoff: time.Hour,
+		Spill:      q,
+	})
+	var want []string
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(w, "rec%d", i)
+		want = append(want, fmt.Sprintf("rec%d\n", i))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Dropped(); got != 0 {
+		t.Errorf("got %d dropped, want 0", got)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart, a Writer with the same spill directory sends the
+	// spilled records before new ones.
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	q, err = spill.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	w = NewWriter("tcp", l.Addr().String(), &Options{Newline: true, Spill: q})
+	defer w.Close()
+	fmt.Fprint(w, "after")
+	want = append(want, "after\n")
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, wl := range want {
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != wl {
+			t.Errorf("got %q, want %q", got, wl)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := q.Size(); s != 0 {
+		t.Errorf("spill holds %d bytes after Flush", s)
+	}
+}
+
+func TestCloseStalledPeer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// Accept the connection but never read from it.
+	conns := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			conns <- c
+		}
+	}()
+	w := NewWriter("tcp", l.Addr().String(), &Options{WriteTimeout: 100 * time.Millisecond, BufferSize: 64 << 20})
+	rec := make([]byte, 1<<20)
+	for i := 0; i < 32; i++ {
+		w.Write(rec)
+	}
+	c := <-conns
+	defer c.Close()
+	done := make(chan struct{})
+	go func() {
+		w.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	if w.Dropped() == 0 {
+		t.Error("no records dropped")
+	}
+}
+
+func TestDefaultWriteTimeout(t *testing.T) {
+	w := NewWriter("tcp", "127.0.0.1:1", nil)
+	defer w.Close()
+	if w.opts.WriteTimeout <= 0 {
+		t.Errorf("got WriteTimeout %s, want positive", w.opts.WriteTimeout)
+	}
+}
@@ -0,0 +1,240 @@
+// Package partition provides a handler that writes the records of each
+// tenant of a multi-tenant service to a separate io.Writer, so that each
+// customer's logs are kept apart without a logger for each:
+//
+//	h := partition.NewHandler(
+//		func(tenant string) (io.WriteCloser, error) {
+//			// The tenant comes from the record, so keep it inside dir.
+//			if strings.ContainsAny(tenant, `/\`) || strings.Contains(tenant, "..") {
+//				return nil, fmt.Errorf("bad tenant %q", tenant)
+//			}
+//			return os.OpenFile(filepath.Join(dir, tenant+".log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+//		},
+//		&partition.Options{Key: "tenant_id", IdleTimeout: 10 * time.Minute},
+//		func(w io.Writer) slog.Handler { return slog.NewJSONHandler(w, nil) })
+//	defer h.Close()
+//	logger := slog.New(h)
+//	logger.Info("signed in", "tenant_id", "acme")  // written to acme.log
+//
+// As with package github.com/jba/slog/writers/split, a single handler
+// formats all records, and records are written one at a time under a
+// single mutex.
+package partition
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Options are options for [NewHandler].
+type Options struct {
+	// Key is the key of the attribute whose value names the tenant of a
+	// record. The attribute can be added to the record or to the logger
+	// with With, but not in a group. If Key is empty, attributes are not
+	// consulted.
+	Key string
+
+	// Context, if non-nil, returns the tenant for a record from the
+	// context passed to Handle, or "" if there is none. It is used for
+	// records without a Key attribute.
+	Context func(context.Context) string
+
+	// IdleTimeout is how long a tenant's writer may go unused before it is
+	// closed. Writers are checked as records are handled, and a closed
+	// writer is opened again when a record for its tenant arrives. If it
+	// is zero, writers are closed only by [Handler.Close].
+	IdleTimeout time.Duration
+
+	// Clock, if non-nil, is used instead of [time.Now] to tell when
+	// writers were last used.
+	Clock func() time.Time
+}
+
+// A Handler writes each record to the writer of its tenant.
+// Records with no tenant are written to the writer for the tenant "".
+type Handler struct {
+	h      slog.Handler
+	w      *tenantWriter
+	tenant string // from WithAttrs
+	// inGroup is true if WithGroup has been called, so that the Key
+	// attribute is no longer at the top level.
+	inGroup bool
+}
+
+// tenantWriter writes to the writer of the tenant whose record is being
+// handled.
+type tenantWriter struct {
+	opts Options
+	open func(string) (io.WriteCloser, error)
+
+	mu        sync.Mutex // held while a record is handled
+	cur       io.Writer
+	writers   map[string]*writer
+	lastSweep time.Time
+	closed    bool
+}
+
+type writer struct {
+	wc       io.WriteCloser
+	lastUsed time.Time
+}
+
+func (w *tenantWriter) Write(p []byte) (int, error) {
+	return w.cur.Write(p)
+}
+
+// ErrClosed is returned by Handle after the Handler is closed.
+var ErrClosed = errors.New("partition: handler is closed")
+
+// NewHandler returns a Handler that formats records with the handler
+// returned by newHandler and writes them to the writer that open
+// returns for their tenant. Open is called the first time a tenant has a
+// record, and again after its writer is closed for being idle. If opts
+// is nil, the default options are used.
+//
+// The tenant passed to open comes from record attributes or the context,
+// so it is untrusted input: open must check or escape it before using
+// it as part of a file name or the like.
+//
+// The writer passed to newHandler must only be written to while a
+// record is being handled, as the handlers of package log/slog and of
+// this module do.
+func NewHandler(open func(tenant string) (io.WriteCloser, error), opts *Options, newHandler func(io.Writer) slog.Handler) *Handler {
+	w := &tenantWriter{open: open, writers: map[string]*writer{}}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Clock == nil {
+		w.opts.Clock = time.Now
+	}
+	return &Handler{h: newHandler(w), w: w}
+}
+
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.h.Enabled(ctx, l)
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.h = h.h.WithAttrs(as)
+	if t, ok := h.tenantOf(as); ok {
+		h2.tenant = t
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.h = h.h.WithGroup(name)
+	h2.inGroup = true
+	return &h2
+}
+
+// tenantOf returns the value of the last Key attribute in as.
+func (h *Handler) tenantOf(as []slog.Attr) (tenant string, ok bool) {
+	if h.w.opts.Key == "" || h.inGroup {
+		return "", false
+	}
+	for _, a := range as {
+		if a.Key == h.w.opts.Key {
+			tenant, ok = a.Value.Resolve().String(), true
+		}
+	}
+	return tenant, ok
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	tenant := h.tenant
+	found := false
+	if h.w.opts.Key != "" && !h.inGroup {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == h.w.opts.Key {
+				tenant, found = a.Value.Resolve().String(), true
+			}
+			return true
+		})
+	}
+	if !found && tenant == "" && h.w.opts.Context != nil {
+		tenant = h.w.opts.Context(ctx)
+	}
+
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	if h.w.closed {
+		return ErrClosed
+	}
+	now := h.w.opts.Clock()
+	h.w.sweep(now)
+	tw, err := h.w.writer(tenant)
+	if err != nil {
+		return err
+	}
+	tw.lastUsed = now
+	h.w.cur = tw.wc
+	return h.h.Handle(ctx, r)
+}
+
+// writer returns the writer for tenant, opening it if necessary.
+func (w *tenantWriter) writer(tenant string) (*writer, error) {
+	if tw := w.writers[tenant]; tw != nil {
+		return tw, nil
+	}
+	wc, err := w.open(tenant)
+	if err != nil {
+		return nil, err
+	}
+	tw := &writer{wc: wc}
+	w.writers[tenant] = tw
+	return tw, nil
+}
+
+// sweep closes writers that have been idle for longer than the
+// IdleTimeout. To keep the cost low when there are many tenants, it
+// looks at them at most once per IdleTimeout.
+func (w *tenantWriter) sweep(now time.Time) {
+	d := w.opts.IdleTimeout
+	if d <= 0 || now.Sub(w.lastSweep) < d {
+		return
+	}
+	w.lastSweep = now
+	for tenant, tw := range w.writers {
+		if now.Sub(tw.lastUsed) >= d {
+			// There is no one to report an error to; the tenant's
+			// records have already been written.
+			tw.wc.Close()
+			delete(w.writers, tenant)
+		}
+	}
+}
+
+// Tenants returns the number of tenants whose writers are open.
+func (h *Handler) Tenants() int {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	return len(h.w.writers)
+}
+
+// Close closes the writers of all tenants. After it is called, Handle
+// returns ErrClosed. Close applies to all Handlers derived from h with
+// WithAttrs and WithGroup.
+func (h *Handler) Close() error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	if h.w.closed {
+		return nil
+	}
+	h.w.closed = true
+	var errs []error
+	for _, tw := range h.w.writers {
+		errs = append(errs, tw.wc.Close())
+	}
+	h.w.writers = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,143 @@
+package partition
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlertest"
+)
+
+func newTextHandler(w io.Writer) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
+
+// files is a set of in-memory files, one for each tenant.
+type files struct {
+	bufs   map[string]*bytes.Buffer
+	opened []string
+	closed []string
+}
+
+type file struct {
+	*bytes.Buffer
+	fs     *files
+	tenant string
+}
+
+func (f file) Close() error {
+	f.fs.closed = append(f.fs.closed, f.tenant)
+	return nil
+}
+
+func (fs *files) open(tenant string) (io.WriteCloser, error) {
+	if tenant == "bad" {
+		return nil, errors.New("no access")
+	}
+	fs.opened = append(fs.opened, tenant)
+	if fs.bufs[tenant] == nil {
+		fs.bufs[tenant] = &bytes.Buffer{}
+	}
+	return file{fs.bufs[tenant], fs, tenant}, nil
+}
+
+type tenantKey struct{}
+
+func TestHandler(t *testing.T) {
+	fs := &files{bufs: map[string]*bytes.Buffer{}}
+	h := NewHandler(fs.open, &Options{
+		Key: "tenant",
+		Context: func(ctx context.Context) string {
+			s, _ := ctx.Value(tenantKey{}).(string)
+			return s
+		},
+	}, newTextHandler)
+	l := slog.New(h)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "c")
+
+	l.Info("1", "tenant", "a")
+	l.With("tenant", "b").Info("2")
+	l.With("tenant", "b").Info("3", "tenant", "a") // the record's attribute wins
+	l.InfoContext(ctx, "4")
+	l.With("tenant", "b").InfoContext(ctx, "5")
+	l.WithGroup("g").Info("6", "tenant", "a") // not at the top level
+	l.Info("7")
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "8", 0)); err != nil {
+		t.Fatal(err)
+	}
+	r := slog.NewRecord(time.Time{}, slog.LevelInfo, "9", 0)
+	r.AddAttrs(slog.String("tenant", "bad"))
+	if err := h.Handle(context.Background(), r); err == nil {
+		t.Error("got nil error from failed open")
+	}
+
+	want := map[string]string{
+		"a": "level=INFO msg=1 tenant=a\nlevel=INFO msg=3 tenant=b tenant=a\n",
+		"b": "level=INFO msg=2 tenant=b\nlevel=INFO msg=5 tenant=b\n",
+		"c": "level=INFO msg=4\n",
+		"":  "level=INFO msg=6 g.tenant=a\nlevel=INFO msg=7\nlevel=INFO msg=8\n",
+	}
+	if len(fs.bufs) != len(want) {
+		t.Errorf("got %d tenants, want %d", len(fs.bufs), len(want))
+	}
+	for tenant, w := range want {
+		if got := fs.bufs[tenant].String(); got != w {
+			t.Errorf("%q:\ngot\n%s\nwant\n%s", tenant, got, w)
+		}
+	}
+	if got, want := h.Tenants(), 4; got != want {
+		t.Errorf("got %d open tenants, want %d", got, want)
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(fs.closed)
+	if want := []string{"", "a", "b", "c"}; !slices.Equal(fs.closed, want) {
+		t.Errorf("closed %q, want %q", fs.closed, want)
+	}
+	if err := l.With("tenant", "a").Handler().Handle(context.Background(), r); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestIdle(t *testing.T) {
+	fs := &files{bufs: map[string]*bytes.Buffer{}}
+	clock := handlertest.NewFakeClock(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 0)
+	h := NewHandler(fs.open, &Options{Key: "t", IdleTimeout: time.Minute, Clock: clock.Now}, newTextHandler)
+	l := slog.New(h)
+	logAt := func(d time.Duration, tenant string) {
+		clock.Advance(d)
+		l.Info("m", "t", tenant)
+	}
+	logAt(0, "a")
+	logAt(30*time.Second, "b")
+	logAt(40*time.Second, "b") // a has been idle for 70s
+	if got, want := fs.closed, []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("closed %q, want %q", got, want)
+	}
+	logAt(30*time.Second, "a") // too soon to look again
+	logAt(40*time.Second, "a") // b has been idle for 70s
+	if got, want := fs.closed, []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("closed %q, want %q", got, want)
+	}
+	if got, want := fs.opened, []string{"a", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("opened %q, want %q", got, want)
+	}
+	if got, want := fs.bufs["a"].String(), strings.Repeat("level=INFO msg=m t=a\n", 3); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,392 @@
+// Package spill provides a durable queue of records on disk, for writers
+// that send records over a network to hold them while the other end is
+// unavailable.
+//
+// A [Queue] stores records in a directory, as a sequence of segment files
+// in the frame format of package github.com/jba/slog/binary. Records are
+// read back in the order they were pushed, including after a restart:
+// a Queue opened on a directory that holds records returns them first.
+// The frames' checksums let a Queue skip parts of a file that were
+// corrupted or torn by a crash, losing only the records in those parts.
+//
+// A Queue delivers each record at least once: a record is removed only
+// after it has been handled, so one that was being sent when the program
+// stopped is sent again. Close saves the position of the first record
+// that hasn't been removed; if the program stops without calling Close,
+// the records of the first segment that were removed are read again.
+//
+// See [github.com/jba/slog/writers/netwriter.Options.Spill] and
+// [github.com/jba/slog/handlers/sqldb.Options.Spill].
+package spill
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jba/slog/binary"
+)
+
+// Options are options for a [Queue].
+type Options struct {
+	// MaxSize is the most bytes of records, including their framing, to
+	// hold on disk. When a record doesn't fit, the oldest segment files
+	// are deleted to make room. If it is zero, 256 MiB is used.
+	MaxSize int64
+
+	// SegmentSize is the size at which the Queue starts a new segment
+	// file. Space is reclaimed a segment at a time, as its records are
+	// removed or discarded. If it is zero, 4 MiB is used.
+	SegmentSize int64
+
+	// If Sync is true, Push syncs each record to stable storage, so that
+	// it survives a crash of the operating system as well as the program.
+	Sync bool
+}
+
+var (
+	// ErrEmpty is returned by Peek when the Queue has no records.
+	ErrEmpty = errors.New("spill: queue is empty")
+	// ErrFull is returned by Push when a record doesn't fit even
+	// after discarding all segments but the one being written.
+	ErrFull = errors.New("spill: queue is full")
+	// ErrClosed is returned after Close is called.
+	ErrClosed = errors.New("spill: queue is closed")
+)
+
+// frameOverhead is the number of bytes that framing adds to a record.
+var frameOverhead = int64(len(binary.AppendFrame(nil, nil)))
+
+const (
+	segmentSuffix = ".spill"
+	// positionFile holds the sequence number of the first segment and
+	// the offset of its first record that hasn't been removed.
+	positionFile = "position"
+)
+
+// A Queue is a queue of records stored in files in a directory.
+// It is safe for concurrent use. Only one Queue should use a directory
+// at a time.
+type Queue struct {
+	dir  string
+	opts Options
+
+	mu      sync.Mutex
+	segs    []segment // oldest first
+	nextSeq uint64
+	w       *os.File // the last segment, or nil if Push should start one
+	closed  bool
+
+	// Reading the first segment.
+	r        *os.File // nil if not yet opened
+	dec      *binary.StreamDecoder
+	readOff  int64  // bytes of the first segment consumed
+	front    []byte // the record returned by Peek, nil if none
+	frontLen int64  // bytes of the segment that front takes up
+
+	size      int64 // bytes not yet consumed, in all segments
+	discarded int64
+	corrupt   int64
+}
+
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// Open returns a Queue that stores records in dir, creating dir if
+// necessary. Records left in dir by an earlier Queue are read first.
+// If opts is nil, the default options are used.
+func Open(dir string, opts *Options) (*Queue, error) {
+	q := &Queue{dir: dir}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.MaxSize <= 0 {
+		q.opts.MaxSize = 256 << 20
+	}
+	if q.opts.SegmentSize <= 0 {
+		q.opts.SegmentSize = 4 << 20
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, de := range des {
+		seq, ok := parseSegmentName(de.Name())
+		if !ok || !de.Type().IsRegular() {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		q.segs = append(q.segs, segment{seq, info.Size()})
+		q.size += info.Size()
+	}
+	slices.SortFunc(q.segs, func(a, b segment) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+	if len(q.segs) > 0 {
+		q.nextSeq = q.segs[len(q.segs)-1].seq + 1
+		q.readPosition()
+	}
+	return q, nil
+}
+
+// readPosition sets the read offset from the position file, if it is
+// for the first segment.
+func (q *Queue) readPosition() {
+	data, err := os.ReadFile(filepath.Join(q.dir, positionFile))
+	if err != nil {
+		return
+	}
+	var seq uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &off); err != nil {
+		return
+	}
+	if seq == q.segs[0].seq && off > 0 && off <= q.segs[0].size {
+		q.readOff = off
+		q.size -= off
+	}
+}
+
+// writePosition saves the read offset in the position file.
+func (q *Queue) writePosition() error {
+	path := filepath.Join(q.dir, positionFile)
+	if len(q.segs) == 0 || q.readOff == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	// Write a new file and rename it, so the position is never torn.
+	tmp := path + ".tmp"
+	data := fmt.Sprintf("%d %d\n", q.segs[0].seq, q.readOff)
+	if err := os.WriteFile(tmp, []byte(data), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%016d%s", seq, segmentSuffix)
+}
+
+func parseSegmentName(name string) (uint64, bool) {
+	s, ok := strings.CutSuffix(name, segmentSuffix)
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(s, 10, 64)
+	return seq, err == nil
+}
+
+func (q *Queue) path(s segment) string {
+	return filepath.Join(q.dir, segmentName(s.seq))
+}
+
+// Push appends rec to the Queue.
+func (q *Queue) Push(rec []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	frame := binary.AppendFrame(nil, rec)
+	n := int64(len(frame))
+	// Make room by discarding the oldest segments, but not the one
+	// being written.
+	for q.size+n > q.opts.MaxSize && (len(q.segs) > 1 || len(q.segs) == 1 && q.w == nil) {
+		if err := q.discardFirst(&q.discarded); err != nil {
+			return err
+		}
+	}
+	if q.size+n > q.opts.MaxSize {
+		return ErrFull
+	}
+	if q.w == nil || q.segs[len(q.segs)-1].size >= q.opts.SegmentSize {
+		if err := q.startSegment(); err != nil {
+			return err
+		}
+	}
+	last := &q.segs[len(q.segs)-1]
+	// Write the frame with a single call, so that it is not interleaved
+	// with anything else and a reader sees it whole.
+	nw, err := q.w.Write(frame)
+	last.size += int64(nw)
+	q.size += int64(nw)
+	if err != nil {
+		return err
+	}
+	if q.opts.Sync {
+		return q.w.Sync()
+	}
+	return nil
+}
+
+// startSegment closes the segment being written, if any,
+// and starts a new one.
+func (q *Queue) startSegment() error {
+	if q.w != nil {
+		if err := q.w.Close(); err != nil {
+			return err
+		}
+		q.w = nil
+	}
+	s := segment{seq: q.nextSeq}
+	f, err := os.OpenFile(q.path(s), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	q.nextSeq++
+	q.w = f
+	q.segs = append(q.segs, s)
+	return nil
+}
+
+// discardFirst deletes the first segment, adding the number of its bytes
+// that were not consumed to *count.
+func (q *Queue) discardFirst(count *int64) error {
+	s := q.segs[0]
+	rest := s.size - q.readOff
+	if q.r != nil {
+		q.r.Close()
+		q.r, q.dec = nil, nil
+	}
+	if len(q.segs) == 1 && q.w != nil {
+		q.w.Close()
+		q.w = nil
+	}
+	q.readOff, q.front, q.frontLen = 0, nil, 0
+	q.segs = q.segs[1:]
+	q.size -= rest
+	*count += rest
+	return os.Remove(q.path(s))
+}
+
+// Peek returns the first record in the Queue without removing it.
+// Call Remove to remove it after it has been handled. Peek returns the
+// same record until then, unless the record is discarded to make room.
+// If the Queue has no records, Peek returns ErrEmpty.
+func (q *Queue) Peek() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if q.front != nil {
+		return q.front, nil
+	}
+	for len(q.segs) > 0 {
+		if q.r == nil {
+			f, err := os.Open(q.path(q.segs[0]))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := f.Seek(q.readOff, io.SeekStart); err != nil {
+				f.Close()
+				return nil, err
+			}
+			q.r = f
+			q.dec = binary.NewStreamDecoder(f)
+		}
+		skipped := q.dec.Skipped()
+		data, err := q.dec.NextFrame()
+		if err == nil {
+			q.front = slices.Clone(data)
+			if q.front == nil {
+				q.front = []byte{}
+			}
+			// Count the bytes that were skipped to reach this frame
+			// as part of it, so that they are consumed with it.
+			skipped = q.dec.Skipped() - skipped
+			q.corrupt += skipped
+			q.frontLen = frameOverhead + int64(len(data)) + skipped
+			return q.front, nil
+		}
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		// The segment is used up. All frames are written whole, so
+		// any bytes left over are corrupt. If the segment is the one
+		// being written, it is deleted and the next Push starts another.
+		if err := q.discardFirst(&q.corrupt); err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrEmpty
+}
+
+// Remove removes the record returned by the last call to Peek.
+// It does nothing if there is no such record.
+func (q *Queue) Remove() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.front == nil {
+		return
+	}
+	q.readOff += q.frontLen
+	q.size -= q.frontLen
+	q.front, q.frontLen = nil, 0
+}
+
+// Size returns the number of bytes of records in the Queue, including
+// their framing and any corrupt bytes not yet skipped over.
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Discarded returns the number of bytes of records that were deleted
+// to stay within [Options.MaxSize].
+func (q *Queue) Discarded() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.discarded
+}
+
+// Corrupt returns the number of bytes that were skipped because they
+// were not part of a valid record.
+func (q *Queue) Corrupt() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.corrupt
+}
+
+// Close closes the Queue's files and saves its read position.
+// The records it holds remain in its directory, for a later Queue to read.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	err := q.writePosition()
+	if q.r != nil {
+		err = errors.Join(err, q.r.Close())
+	}
+	if q.w != nil {
+		err = errors.Join(err, q.w.Close())
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package spill
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func open(t *testing.T, dir string, opts *Options) *Queue {
+	t.Helper()
+	q, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func push(t *testing.T, q *Queue, recs ...string) {
+	t.Helper()
+	for _, r := range recs {
+		if err := q.Push([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drain removes and returns all the records in q.
+func drain(t *testing.T, q *Queue) []string {
+	t.Helper()
+	var got []string
+	for {
+		rec, err := q.Peek()
+		if errors.Is(err, ErrEmpty) {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(rec))
+		q.Remove()
+	}
+}
+
+func records(n int) []string {
+	var recs []string
+	for i := 0; i < n; i++ {
+		recs = append(recs, fmt.Sprintf("record %02d", i))
+	}
+	return recs
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestOrder(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir, &Options{SegmentSize: 50})
+	want := records(10)
+	push(t, q, want[:5]...)
+	if n := len(segmentFiles(t, dir)); n < 2 {
+		t.Errorf("got %d segment files, want several", n)
+	}
+	// Peek returns the same record until it is removed.
+	r1, _ := q.Peek()
+	r2, _ := q.Peek()
+	if string(r1) != want[0] || string(r2) != want[0] {
+		t.Errorf("got %q and %q, want %q twice", r1, r2, want[0])
+	}
+	q.Remove()
+	// Records pushed while reading follow the others.
+	push(t, q, want[5:]...)
+	got := append([]string{string(r1)}, drain(t, q)...)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if s := q.Size(); s != 0 {
+		t.Errorf("size is %d after draining", s)
+	}
+	if files := segmentFiles(t, dir); len(files) != 0 {
+		t.Errorf("files remain after draining: %v", files)
+	}
+	// The queue can be used again after it is empty.
+	push(t, q, "again")
+	if got := drain(t, q); !slices.Equal(got, []string{"again"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir, &Options{SegmentSize: 50})
+	want := records(6)
+	push(t, q, want[:4]...)
+	q.Peek()
+	q.Remove()
+	// The second record is being handled when the program stops,
+	// so it is read again.
+	q.Peek()
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Push after Close: got %v, want ErrClosed", err)
+	}
+
+	q = open(t, dir, &Options{SegmentSize: 50})
+	push(t, q, want[4:]...)
+	if got := drain(t, q); !slices.Equal(got, want[1:]) {
+		t.Errorf("got %q, want %q", got, want[1:])
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	recs := records(20)
+	frameSize := frameOverhead + int64(len(recs[0]))
+	q := open(t, dir, &Options{SegmentSize: 2 * frameSize, MaxSize: 6 * frameSize})
+	push(t, q, recs...)
+	got := drain(t, q)
+	// The oldest segments, of two records each, were discarded.
+	if want := recs[14:]; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := q.Discarded(), 14*frameSize; got != want {
+		t.Errorf("discarded %d bytes, want %d", got, want)
+	}
+	if err := q.Push(make([]byte, 7*frameSize)); !errors.Is(err, ErrFull) {
+		t.Errorf("got %v, want ErrFull", err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	q := open(t, dir, nil)
+	recs := records(4)
+	push(t, q, recs...)
+	q.Close()
+
+	files := segmentFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	frameSize := int(frameOverhead) + len(recs[0])
+	data[frameSize+int(frameOverhead)] ^= 0xff // corrupt the second record
+	data = data[:len(data)-3]                  // tear the last one
+	if err := os.WriteFile(files[0], data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	q = open(t, dir, nil)
+	want := []string{recs[0], recs[2]}
+	if got := drain(t, q); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := q.Corrupt(), int64(2*frameSize-3); got != want {
+		t.Errorf("corrupt bytes: got %d, want %d", got, want)
+	}
+	if s := q.Size(); s != 0 {
+		t.Errorf("size is %d after draining", s)
+	}
+}
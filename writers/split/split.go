@@ -0,0 +1,87 @@
+// Package split provides a handler that writes low-level records to one
+// io.Writer and high-level records to another, as a command-line tool
+// might write INFO to standard output and WARN and ERROR to standard
+// error:
+//
+//	h := split.NewHandler(os.Stdout, os.Stderr, nil, func(w io.Writer) slog.Handler {
+//		return slog.NewTextHandler(w, nil)
+//	})
+//
+// Unlike two handlers filtered by level, a single handler formats all
+// records, and records are written one at a time under a single mutex,
+// so when both writers go to the same place, as when stdout and stderr
+// are a terminal, the records appear in the order they were handled.
+package split
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Options are options for [NewHandler].
+type Options struct {
+	// Level is the lowest level of the records written to the high
+	// writer. If nil, [slog.LevelWarn] is used.
+	Level slog.Leveler
+}
+
+// A Handler sends each record to a handler that writes it to the low
+// or high writer, depending on its level.
+type Handler struct {
+	h slog.Handler
+	w *levelWriter
+}
+
+// levelWriter writes to the writer chosen for the record being handled.
+type levelWriter struct {
+	level     slog.Leveler
+	low, high io.Writer
+
+	mu  sync.Mutex // held while a record is handled
+	cur io.Writer
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	return w.cur.Write(p)
+}
+
+// NewHandler returns a Handler that formats records with the handler
+// returned by newHandler. Records below the level in opts are written
+// to low, and the others to high. If opts is nil, the default options
+// are used.
+//
+// The writer passed to newHandler must only be written to while a
+// record is being handled, as the handlers of package log/slog and of
+// this module do.
+func NewHandler(low, high io.Writer, opts *Options, newHandler func(io.Writer) slog.Handler) *Handler {
+	w := &levelWriter{level: slog.LevelWarn, low: low, high: high, cur: low}
+	if opts != nil && opts.Level != nil {
+		w.level = opts.Level
+	}
+	return &Handler{h: newHandler(w), w: w}
+}
+
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.h.Enabled(ctx, l)
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	return &Handler{h: h.h.WithAttrs(as), w: h.w}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{h: h.h.WithGroup(name), w: h.w}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	if r.Level >= h.w.level.Level() {
+		h.w.cur = h.w.high
+	} else {
+		h.w.cur = h.w.low
+	}
+	return h.h.Handle(ctx, r)
+}
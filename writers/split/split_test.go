@@ -0,0 +1,98 @@
+package split
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTextHandler(w io.Writer) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
+
+func TestHandler(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		opts            *Options
+		wantLow, wantHi string
+	}{
+		{
+			"default",
+			nil,
+			"level=DEBUG msg=d a=1\nlevel=INFO msg=i a=1 g.x=2\n",
+			"level=WARN msg=w a=1\nlevel=ERROR msg=e a=1\n",
+		},
+		{
+			"error",
+			&Options{Level: slog.LevelError},
+			"level=DEBUG msg=d a=1\nlevel=INFO msg=i a=1 g.x=2\nlevel=WARN msg=w a=1\n",
+			"level=ERROR msg=e a=1\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var low, high bytes.Buffer
+			l := slog.New(NewHandler(&low, &high, test.opts, newTextHandler)).With("a", 1)
+			l.Debug("d")
+			l.WithGroup("g").Info("i", "x", 2)
+			l.Warn("w")
+			l.Error("e")
+			if got := low.String(); got != test.wantLow {
+				t.Errorf("low:\ngot  %q\nwant %q", got, test.wantLow)
+			}
+			if got := high.String(); got != test.wantHi {
+				t.Errorf("high:\ngot  %q\nwant %q", got, test.wantHi)
+			}
+		})
+	}
+}
+
+// lineWriter records which writer each line went to, in order.
+type lineWriter struct {
+	name  string
+	lines *[]string
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	*w.lines = append(*w.lines, w.name+": "+strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+func TestOrder(t *testing.T) {
+	// The writers share unsynchronized state, so the race detector
+	// would find concurrent writes.
+	var lines []string
+	h := NewHandler(lineWriter{"out", &lines}, lineWriter{"err", &lines}, nil, newTextHandler)
+	l := slog.New(h)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				l.Info("m", "i", i)
+			} else {
+				l.Warn("m", "i", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(lines) != 10 {
+		t.Fatalf("got %d lines, want 10", len(lines))
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "out") != strings.Contains(line, "INFO") {
+			t.Errorf("line %q went to the wrong writer", line)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package timefile provides an io.Writer that writes to a new file at
+// the start of each period of time, like each hour or day, so that
+// retention tools can work with whole periods:
+//
+//	w, err := timefile.New("app-2006-01-02T15.log", &timefile.Options{
+//		Dir:     "/var/log",
+//		Symlink: "/var/log/app.log",
+//	})
+//	h := slog.NewJSONHandler(w, nil)
+//
+// Files are switched on time boundaries only; their size does not matter.
+package timefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Options are options for a [Writer].
+type Options struct {
+	// Period is the length of time covered by each file. Periods that
+	// divide a day, like an hour or 15 minutes, start at midnight in
+	// Location, as does a period of a day. Others start at multiples of
+	// Period since the zero time. If it is zero, an hour is used.
+	Period time.Duration
+
+	// Dir is the directory of the files. File names are joined to it
+	// after they are formatted, so it may contain anything.
+	Dir string
+
+	// Location is the time zone of the boundaries and file names.
+	// If nil, [time.Local] is used.
+	Location *time.Location
+
+	// Symlink, if non-empty, is the name of a symbolic link that the
+	// Writer points at the file being written, so that tools that
+	// follow a single file can find it.
+	Symlink string
+
+	// Clock, if non-nil, is used instead of [time.Now] to tell which
+	// file to write.
+	Clock func() time.Time
+}
+
+// A Writer writes to a file named for the current period.
+// It is safe for concurrent use.
+type Writer struct {
+	layout string
+	opts   Options
+
+	mu     sync.Mutex
+	f      *os.File // nil if no file is open
+	name   string
+	end    time.Time // end of the current file's period
+	closed bool
+}
+
+// ErrClosed is returned by Write after the Writer is closed.
+var ErrClosed = errors.New("timefile: writer is closed")
+
+// New returns a Writer whose file names are formed by formatting the
+// start of each period with layout, as by [time.Time.Format]. For
+// example, the layout "app-2006-01-02T15.log" makes files named like
+// "app-2024-05-01T13.log". The parts of layout that are not time
+// elements must not look like them: "app1-2006.log" would replace the
+// "1" with the month; put fixed directories in [Options.Dir] instead.
+// Directories in the names are created as needed.
+// If opts is nil, the default options are used.
+//
+// The first file is opened by the first Write.
+func New(layout string, opts *Options) (*Writer, error) {
+	w := &Writer{layout: layout}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Period < 0 {
+		return nil, errors.New("timefile: negative period")
+	}
+	if w.opts.Period == 0 {
+		w.opts.Period = time.Hour
+	}
+	if w.opts.Location == nil {
+		w.opts.Location = time.Local
+	}
+	if w.opts.Clock == nil {
+		w.opts.Clock = time.Now
+	}
+	return w, nil
+}
+
+// periodStart returns the start of the period containing t.
+func (w *Writer) periodStart(t time.Time) time.Time {
+	t = t.In(w.opts.Location)
+	p := w.opts.Period
+	const day = 24 * time.Hour
+	if day%p != 0 {
+		return t.Truncate(p)
+	}
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, w.opts.Location)
+	return midnight.Add(t.Sub(midnight) / p * p)
+}
+
+// periodEnd returns the end of the period that starts at start.
+func (w *Writer) periodEnd(start time.Time) time.Time {
+	if w.opts.Period == 24*time.Hour {
+		// Not start.Add, because days with a daylight saving
+		// transition are not 24 hours long.
+		return start.AddDate(0, 0, 1)
+	}
+	return w.periodStart(start.Add(w.opts.Period))
+}
+
+// Write writes p to the file for the current period, first switching to
+// it if necessary.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	var linkErr error
+	if now := w.opts.Clock(); w.f == nil || !now.Before(w.end) {
+		if err := w.switchFile(now); err != nil {
+			if w.f == nil {
+				return 0, err
+			}
+			// The file is open but the symlink couldn't be made.
+			// Write p anyway, so it isn't lost.
+			linkErr = err
+		}
+	}
+	n, err := w.f.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, linkErr
+}
+
+// switchFile closes the current file and opens the one for the period
+// containing now.
+func (w *Writer) switchFile(now time.Time) error {
+	if w.f != nil {
+		err := w.f.Close()
+		w.f = nil
+		if err != nil {
+			return err
+		}
+	}
+	start := w.periodStart(now)
+	name := filepath.Join(w.opts.Dir, start.Format(w.layout))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.f, w.name, w.end = f, name, w.periodEnd(start)
+	if w.opts.Symlink != "" {
+		return w.link()
+	}
+	return nil
+}
+
+// link points the symlink at the current file.
+func (w *Writer) link() error {
+	target := w.name
+	if rel, err := filepath.Rel(filepath.Dir(w.opts.Symlink), w.name); err == nil {
+		target = rel
+	}
+	// Make a new link and rename it over the old one, so that there is
+	// always a link.
+	tmp := w.opts.Symlink + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.opts.Symlink)
+}
+
+// Name returns the name of the file being written,
+// or "" if none has been opened.
+func (w *Writer) Name() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.name
+}
+
+// Sync commits the current file to stable storage.
+func (w *Writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Sync()
+}
+
+// Close closes the current file. After it is called, Write
+// returns ErrClosed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,125 @@
+package timefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jba/slog/handlertest"
+)
+
+func TestPeriodStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, test := range []struct {
+		period    time.Duration
+		t         time.Time
+		wantStart string
+		wantEnd   string
+	}{
+		{time.Hour, time.Date(2024, 5, 1, 13, 59, 59, 0, ny), "2024-05-01T13:00", "2024-05-01T14:00"},
+		{15 * time.Minute, time.Date(2024, 5, 1, 13, 31, 0, 0, ny), "2024-05-01T13:30", "2024-05-01T13:45"},
+		{24 * time.Hour, time.Date(2024, 5, 1, 13, 31, 0, 0, ny), "2024-05-01T00:00", "2024-05-02T00:00"},
+		// The day that daylight saving time starts is 23 hours long.
+		{24 * time.Hour, time.Date(2024, 3, 10, 12, 0, 0, 0, ny), "2024-03-10T00:00", "2024-03-11T00:00"},
+		{time.Hour, time.Date(2024, 3, 10, 23, 30, 0, 0, ny), "2024-03-10T23:00", "2024-03-11T00:00"},
+		{6 * time.Hour, time.Date(2024, 3, 10, 7, 0, 0, 0, ny), "2024-03-10T07:00", "2024-03-10T13:00"},
+	} {
+		w, err := New("", &Options{Period: test.period, Location: ny})
+		if err != nil {
+			t.Fatal(err)
+		}
+		const layout = "2006-01-02T15:04"
+		start := w.periodStart(test.t)
+		gotStart := start.Format(layout)
+		gotEnd := w.periodEnd(start).Format(layout)
+		if gotStart != test.wantStart || gotEnd != test.wantEnd {
+			t.Errorf("%s, %s: got %s to %s, want %s to %s",
+				test.period, test.t, gotStart, gotEnd, test.wantStart, test.wantEnd)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	dir := t.TempDir()
+	clock := handlertest.NewFakeClock(time.Date(2024, 5, 1, 12, 59, 0, 0, time.UTC), 0)
+	link := filepath.Join(dir, "app.log")
+	w, err := New(filepath.Join("2006-01-02", "app-2006-01-02T15.log"), &Options{
+		Dir:      dir,
+		Location: time.UTC,
+		Symlink:  link,
+		Clock:    clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(s string) {
+		t.Helper()
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data[len(data)-len(s):]); got != s {
+			t.Errorf("symlink: got %q at end, want %q", got, s)
+		}
+	}
+	if got := w.Name(); got != "" {
+		t.Errorf("before Write: got name %q", got)
+	}
+	write("a\n")
+	clock.Advance(59 * time.Second)
+	write("b\n")
+	clock.Advance(time.Second)
+	write("c\n")
+	clock.Advance(11 * time.Hour)
+	write("d\n")
+	if got, want := w.Name(), filepath.Join(dir, "2024-05-02", "app-2024-05-02T00.log"); got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Close: got %v, want ErrClosed", err)
+	}
+
+	want := map[string]string{
+		"2024-05-01/app-2024-05-01T12.log": "a\nb\n",
+		"2024-05-01/app-2024-05-01T13.log": "c\n",
+		"2024-05-02/app-2024-05-02T00.log": "d\n",
+	}
+	var names []string
+	for name, contents := range want {
+		names = append(names, name)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != contents {
+			t.Errorf("%s: got %q, want %q", name, got, contents)
+		}
+	}
+	got, err := filepath.Glob(filepath.Join(dir, "*", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range got {
+		got[i], _ = filepath.Rel(dir, g)
+	}
+	slices.Sort(names)
+	if !slices.Equal(got, names) {
+		t.Errorf("got files %q, want %q", got, names)
+	}
+	// The link is relative, so the directory can be moved.
+	if target, err := os.Readlink(link); err != nil || target != "2024-05-02/app-2024-05-02T00.log" {
+		t.Errorf("got link target %q, %v", target, err)
+	}
+}